@@ -0,0 +1,138 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// buildInfo holds the map[string]string given to SetBuildInfo(), if it
+	// was never called (or given nil) the auto-detected info is used
+	buildInfo atomic.Value
+
+	// autoBuildInfo is what could be found via debug.ReadBuildInfo(), it is
+	// only looked up once
+	autoBuildInfo     map[string]string
+	autoBuildInfoOnce sync.Once
+)
+
+// BuildInfo returns a copy of the build info added to output when the
+// Lbuildinfo flag is used, see SetBuildInfo()
+func BuildInfo() map[string]string {
+	info := currentBuildInfo()
+	infoCopy := make(map[string]string, len(info))
+	for k, v := range info {
+		infoCopy[k] = v
+	}
+	return infoCopy
+}
+
+// SetBuildInfo sets the build info (eg: commit hash, build time) that is
+// added to output for levels with the Lbuildinfo flag set, typically set
+// once at startup from values stamped in via -ldflags, eg:
+//   out.SetBuildInfo(map[string]string{"commit": commit, "build_time": built})
+//   out.SetFlags(out.LevelAll, out.LlogfileFlags|out.Lbuildinfo, out.ForLogfile)
+// The info is added to the structured fields (FlagMetadata.Fields) given to
+// formatters and metadata aware writers (eg: JSON or journald output), the
+// plain text output shows a compact version, ie: the first 7 chars of the
+// "commit" value with "-dirty" added if "modified" is "true" (or, with no
+// commit, the "version" value).  If this isn't called (or is given nil) the
+// info is filled in from debug.ReadBuildInfo() when available, ie: "version"
+// (the main module version), "commit", "build_time" and "modified" (from the
+// VCS info Go stamps into binaries built in a repo).
+func SetBuildInfo(info map[string]string) {
+	infoCopy := map[string]string(nil)
+	if info != nil {
+		infoCopy = make(map[string]string, len(info))
+		for k, v := range info {
+			infoCopy[k] = v
+		}
+	}
+	buildInfo.Store(infoCopy)
+}
+
+// currentBuildInfo returns the build info in use (not a copy, don't modify)
+func currentBuildInfo() map[string]string {
+	if info, ok := buildInfo.Load().(map[string]string); ok && info != nil {
+		return info
+	}
+	autoBuildInfoOnce.Do(func() {
+		autoBuildInfo = make(map[string]string)
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			autoBuildInfo["version"] = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				autoBuildInfo["commit"] = setting.Value
+			case "vcs.time":
+				autoBuildInfo["build_time"] = setting.Value
+			case "vcs.modified":
+				autoBuildInfo["modified"] = setting.Value
+			}
+		}
+	})
+	return autoBuildInfo
+}
+
+// buildInfoSummary returns the compact build info shown in text output for
+// the Lbuildinfo flag, eg: "1a2b3c4" or "1a2b3c4-dirty", "" if none known
+func buildInfoSummary() string {
+	info := currentBuildInfo()
+	summary := info["commit"]
+	if len(summary) > 7 {
+		summary = summary[:7]
+	}
+	if summary == "" {
+		return info["version"]
+	}
+	if info["modified"] == "true" {
+		summary += "-dirty"
+	}
+	return summary
+}
+
+// mergeFields adds the given fields to the existing structured fields (the
+// existing fields win if the same key is in both), a new map is returned if
+// anything is added so maps given to us aren't modified
+func mergeFields(fields map[string]interface{}, add map[string]interface{}) map[string]interface{} {
+	if len(add) == 0 {
+		return fields
+	}
+	merged := make(map[string]interface{}, len(fields)+len(add))
+	for k, v := range add {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+// buildInfoFields returns the build info as structured fields
+func buildInfoFields() map[string]interface{} {
+	info := currentBuildInfo()
+	fields := make(map[string]interface{}, len(info))
+	for k, v := range info {
+		fields[k] = v
+	}
+	return fields
+}
@@ -0,0 +1,104 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"sync"
+)
+
+// CappedBuffer is an io.Writer that only keeps the most recent maxBytes of
+// what is written to it, older bytes are discarded as new ones come in (it
+// is a ring of bytes).  This is handy for keeping recent output around in a
+// fixed memory budget, eg: to add the last bit of the log to a crash report:
+//
+//	recent := out.NewCappedBuffer(64 * 1024)
+//	out.SetWriter(out.LevelAll, recent, out.ForLogfile)
+//	out.SetThreshold(out.LevelDebug, out.ForLogfile)
+//	...
+//	report.Log = recent.String()
+type CappedBuffer struct {
+	mu    sync.Mutex
+	ring  []byte // fixed size storage, len(ring) is the cap
+	start int    // index of the oldest byte in the ring
+	size  int    // number of valid bytes in the ring
+}
+
+// NewCappedBuffer returns a CappedBuffer that retains at most maxBytes, a
+// size less than 1 is treated as 1
+func NewCappedBuffer(maxBytes int) *CappedBuffer {
+	if maxBytes < 1 {
+		maxBytes = 1
+	}
+	return &CappedBuffer{ring: make([]byte, maxBytes)}
+}
+
+// Write satisfies the io.Writer interface, it always "succeeds" in writing
+// all of p even though the oldest bytes may be dropped to make room
+func (b *CappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	max := len(b.ring)
+	if n >= max {
+		// only the tail of p survives, it fills the whole ring
+		copy(b.ring, p[n-max:])
+		b.start = 0
+		b.size = max
+		return n, nil
+	}
+	end := (b.start + b.size) % max
+	copied := copy(b.ring[end:], p)
+	copy(b.ring, p[copied:])
+	b.size += n
+	if b.size > max {
+		// we overwrote the oldest bytes, move the start past them
+		b.start = (b.start + b.size - max) % max
+		b.size = max
+	}
+	return n, nil
+}
+
+// Bytes returns a copy of the retained bytes, oldest first
+func (b *CappedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ret := make([]byte, b.size)
+	end := b.start + b.size
+	if end > len(b.ring) {
+		end = len(b.ring)
+	}
+	copied := copy(ret, b.ring[b.start:end])
+	copy(ret[copied:], b.ring)
+	return ret
+}
+
+// String returns the retained bytes as a string, oldest first
+func (b *CappedBuffer) String() string {
+	return string(b.Bytes())
+}
+
+// Len returns the number of bytes currently retained
+func (b *CappedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Reset discards everything retained in the buffer
+func (b *CappedBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.start = 0
+	b.size = 0
+}
@@ -0,0 +1,72 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/cappedbuf.go
+//   Makes sure the capped (ring) buffer keeps only the most recent bytes
+//   as writes wrap around the end of the ring.
+
+package out
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestCappedBufferWrap(t *testing.T) {
+	b := NewCappedBuffer(8)
+	n, err := b.Write([]byte("abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "abc", b.String())
+
+	// fills exactly to the cap
+	b.Write([]byte("defgh"))
+	assert.Equal(t, "abcdefgh", b.String())
+	assert.Equal(t, 8, b.Len())
+
+	// wraps, dropping the oldest 3 bytes
+	b.Write([]byte("ijk"))
+	assert.Equal(t, "defghijk", b.String())
+
+	// wraps again across the end of the ring
+	b.Write([]byte("lmnop"))
+	assert.Equal(t, "ijklmnop", b.String())
+
+	// a write larger than the cap keeps only its own tail
+	n, _ = b.Write([]byte("0123456789"))
+	assert.Equal(t, 10, n)
+	assert.Equal(t, "23456789", b.String())
+
+	b.Reset()
+	assert.Equal(t, "", b.String())
+	b.Write([]byte("xy"))
+	assert.Equal(t, []byte("xy"), b.Bytes())
+}
+
+func TestCappedBufferOutput(t *testing.T) {
+	b := NewCappedBuffer(32)
+	SetWriter(LevelAll, b, ForScreen)
+	for i := 0; i < 10; i++ {
+		Printf("line %d\n", i)
+	}
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 32, b.Len())
+	assert.Contains(t, b.String(), fmt.Sprintf("line %d\n", 9))
+	assert.NotContains(t, b.String(), "line 0\n")
+}
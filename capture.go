@@ -0,0 +1,215 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelCapture holds the screen output captured for a single level, see
+// CaptureLevel() and CaptureLevelRaw()
+type LevelCapture struct {
+	mu       sync.Mutex
+	level    Level
+	messages []string // completed messages (trailing newline removed)
+	partial  string   // output not yet ended with a newline
+
+	// settings replaced while capturing, put back by Restore()
+	origHndl       io.Writer
+	origFlags      int
+	origPrefix     string
+	origPrefixFunc PrefixFunc
+	restored       bool
+}
+
+// CaptureLevel redirects the screen output of the given level (only) into a
+// capture for testing, eg: to check that a func issues a warning:
+//   capture := out.CaptureLevel(out.LevelIssue)
+//   defer capture.Restore()
+//   doSomething()
+//   assert.Equal(t, []string{"disk is nearly full"}, capture.Messages())
+// Other levels are left alone.  While capturing the level's prefix and screen
+// flags are turned off so the messages are just the text given (use the
+// CaptureLevelRaw() routine to keep them).  The screen threshold still
+// applies, so lower it if capturing Verbose, Debug or Trace output.
+func CaptureLevel(level Level) *LevelCapture {
+	return captureLevel(level, false)
+}
+
+// CaptureLevelRaw is the same as CaptureLevel() but the level's prefix and
+// screen flags metadata are left in place, ie: the messages are exactly what
+// would have been written to the screen (minus the trailing newline)
+func CaptureLevelRaw(level Level) *LevelCapture {
+	return captureLevel(level, true)
+}
+
+// captureLevel sets up the capture for CaptureLevel() and CaptureLevelRaw()
+func captureLevel(level Level, raw bool) *LevelCapture {
+	c := &LevelCapture{level: level}
+	o := LevelWriter(level)
+	o.mu.Lock()
+	c.origHndl = o.screenHndl
+	c.origFlags = o.screenFlags
+	c.origPrefix = o.prefix
+	c.origPrefixFunc = o.prefixFunc
+	o.screenHndl = c
+	if !raw {
+		o.screenFlags = 0
+		o.prefix = ""
+		o.prefixFunc = nil
+	}
+	o.mu.Unlock()
+	return c
+}
+
+// Write satisfies the io.Writer interface, each bit of output ending with a
+// newline completes a message (so multi-line output from one call, like a
+// Issueln("line1\nline2"), is a single message)
+func (c *LevelCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partial += string(p)
+	if strings.HasSuffix(c.partial, "\n") {
+		c.messages = append(c.messages, strings.TrimSuffix(c.partial, "\n"))
+		c.partial = ""
+	}
+	return len(p), nil
+}
+
+// Messages returns the messages captured so far, any output that hasn't been
+// ended with a newline yet is included as the last message
+func (c *LevelCapture) Messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := make([]string, len(c.messages), len(c.messages)+1)
+	copy(messages, c.messages)
+	if c.partial != "" {
+		messages = append(messages, c.partial)
+	}
+	return messages
+}
+
+// Restore puts the level's screen writer (and prefix and flags) back the way
+// they were before the capture started, the captured messages remain
+// available.  Calling it more than once is harmless.  Note: if the captured
+// output ended without a newline the screen is marked as being on a fresh
+// line (that partial line never made it to the screen after all).
+func (c *LevelCapture) Restore() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.restored {
+		return
+	}
+	c.restored = true
+	o := LevelWriter(c.level)
+	o.mu.Lock()
+	o.screenHndl = c.origHndl
+	o.screenFlags = c.origFlags
+	o.prefix = c.origPrefix
+	o.prefixFunc = c.origPrefixFunc
+	o.mu.Unlock()
+	if c.partial != "" {
+		// the unfinished line went to the capture, not the screen, so don't
+		// let it stop the next screen output from being prefixed
+		ResetNewline(true, ForScreen)
+	}
+}
+
+// noExitCaptures counts the CaptureOutput() calls running, while non-zero a
+// Fatal() (or other dying output) doesn't exit, as with PKG_OUT_NO_EXIT=1
+var noExitCaptures int32
+
+// exitAllowed returns true if dying output should really exit the tool, ie:
+// the PKG_OUT_NO_EXIT env isn't "1" and no CaptureOutput() is running
+func exitAllowed() bool {
+	return atomic.LoadInt32(&noExitCaptures) == 0 && os.Getenv("PKG_OUT_NO_EXIT") != "1"
+}
+
+// captureBuffer is a goroutine safe bytes.Buffer for CaptureOutput()
+type captureBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write satisfies the io.Writer interface
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns what has been written so far
+func (b *captureBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// CaptureOutput runs fn with the screen and logfile writers of every level
+// pointed at buffers and returns what was written to each, then puts the
+// writers back, eg:
+//
+//	screen, logfile := out.CaptureOutput(func() {
+//		doSomething()
+//	})
+//	assert.Equal(t, "Note: all done\n", screen)
+//
+// While fn runs a Fatal() (or any dying output) doesn't exit, as if the
+// PKG_OUT_NO_EXIT env were "1", so the fatal message can be checked (note
+// that fn carries on after it).  The thresholds, prefixes and flags are left
+// as they are, so the logfile output only has what passes the logfile
+// threshold and has the logfile flags metadata (eg: the pid and time) on
+// it.  Each target starts on a fresh line and the newline tracking is put
+// back afterwards.  The writers are put back even if fn panics (the panic
+// carries on).  Captures affect the whole pkg, don't run them in parallel.
+func CaptureOutput(fn func()) (screen string, logfile string) {
+	type handles struct {
+		screen, logfile io.Writer
+	}
+	screenBuf := &captureBuffer{}
+	logfileBuf := &captureBuffer{}
+	orig := make(map[*LvlOutput]handles, len(std.outputters))
+	for _, o := range std.outputters {
+		o.mu.Lock()
+		orig[o] = handles{screen: o.screenHndl, logfile: o.logfileHndl}
+		o.screenHndl = screenBuf
+		o.logfileHndl = logfileBuf
+		o.mu.Unlock()
+	}
+	mutex.Lock()
+	screenNewline, logfileNewline := std.screenNewline, std.logfileNewline
+	std.screenNewline, std.logfileNewline = true, true
+	mutex.Unlock()
+	atomic.AddInt32(&noExitCaptures, 1)
+
+	defer func() {
+		atomic.AddInt32(&noExitCaptures, -1)
+		for o, h := range orig {
+			o.mu.Lock()
+			o.screenHndl = h.screen
+			o.logfileHndl = h.logfile
+			o.mu.Unlock()
+		}
+		mutex.Lock()
+		std.screenNewline, std.logfileNewline = screenNewline, logfileNewline
+		mutex.Unlock()
+	}()
+	fn()
+	return screenBuf.String(), logfileBuf.String()
+}
@@ -0,0 +1,93 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/capture.go
+//   Checks that a single level's screen output, or all output via
+//   CaptureOutput(), can be captured and restored.
+
+package out
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestCaptureLevel(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Llevel, ForScreen)
+
+	capture := CaptureLevel(LevelIssue)
+	Println("not captured")
+	Issueln("disk is nearly full")
+	Issueln("two\nlines")
+	Issue("partial, ")
+	Issuef("%s\n", "finished")
+	Issue("dangling")
+	capture.Restore()
+	capture.Restore()
+	Issueln("after restore")
+
+	raw := CaptureLevelRaw(LevelNote)
+	Noteln("with prefix")
+	raw.Restore()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, []string{"disk is nearly full", "two\nlines", "partial, finished", "dangling"}, capture.Messages())
+	assert.Equal(t, []string{"NOTE    Note: with prefix"}, raw.Messages())
+	assert.Equal(t, "INFO    not captured\nISSUE   Issue: after restore\n", screenBuf.String())
+}
+
+func TestCaptureOutput(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetStackTraceConfig(0)
+
+	screen, logfile := CaptureOutput(func() {
+		Noteln("all done")
+		Fatalln("giving up")
+		Infoln("still running")
+	})
+	Infoln("after the capture")
+
+	panicked := false
+	func() {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		CaptureOutput(func() {
+			Noteln("about to panic")
+			panic("boom")
+		})
+	}()
+	Infoln("after the panic")
+	after := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: all done\nFatal: giving up\nstill running\n", screen)
+	assert.Equal(t, screen, logfile)
+	assert.True(t, panicked)
+	assert.Equal(t, "after the capture\nafter the panic\n", after)
+	assert.True(t, exitAllowed() || os.Getenv("PKG_OUT_NO_EXIT") == "1")
+}
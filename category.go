@@ -0,0 +1,220 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// categoryFilter holds the SetCategoryFilter() settings, see categoryFilters
+type categoryFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// categoryFilters holds the current *categoryFilter, nil if none is set
+var categoryFilters atomic.Value
+
+// SetCategoryFilter restricts output tagged with a category (see PrintCat())
+// by category name, a second filtering dimension apart from the output level.
+// If the include list isn't empty only categories in it are written, and any
+// category in the exclude list is never written, eg: to drop the chatty
+// "net" and "db" output from the screen and logfile:
+//
+//	out.SetCategoryFilter(nil, []string{"net", "db"})
+//
+// Untagged output is never filtered, and a filtered Fatal still exits.  The
+// filter applies when the output is written (unlike PKG_OUT_DEBUG_SCOPE it is
+// by explicit category, not a substring of the callers func name).  Use nil
+// for both lists to turn filtering off.
+func SetCategoryFilter(include, exclude []string) {
+	if len(include) == 0 && len(exclude) == 0 {
+		categoryFilters.Store((*categoryFilter)(nil))
+		return
+	}
+	filter := &categoryFilter{include: make(map[string]bool), exclude: make(map[string]bool)}
+	for _, category := range include {
+		filter.include[category] = true
+	}
+	for _, category := range exclude {
+		filter.exclude[category] = true
+	}
+	categoryFilters.Store(filter)
+}
+
+// CategoryFilter returns the include and exclude category lists currently
+// in use, see SetCategoryFilter() (both are nil if no filter is set)
+func CategoryFilter() ([]string, []string) {
+	filter, _ := categoryFilters.Load().(*categoryFilter)
+	if filter == nil {
+		return nil, nil
+	}
+	var include, exclude []string
+	for category := range filter.include {
+		include = append(include, category)
+	}
+	for category := range filter.exclude {
+		exclude = append(exclude, category)
+	}
+	sort.Strings(include)
+	sort.Strings(exclude)
+	return include, exclude
+}
+
+// categoryFiltered returns true if output tagged with the given category
+// should not be written based on SetCategoryFilter() settings
+func categoryFiltered(category string) bool {
+	if category == "" {
+		return false
+	}
+	filter, _ := categoryFilters.Load().(*categoryFilter)
+	if filter == nil {
+		return false
+	}
+	if len(filter.include) != 0 && !filter.include[category] {
+		return true
+	}
+	return filter.exclude[category]
+}
+
+// catOutput sends a message tagged with the given category to the screen
+// and/or logfile, the args are checked for a detailed error
+func (o *LvlOutput) catOutput(terminal bool, exitVal int, category string, msg string, v []interface{}) {
+	detErrs := getAnyDetailedErrors(v...)
+	var detErr DetailedError
+	if detErrs != nil {
+		detErr = detErrs[0]
+	}
+	_, err := o.stringOutput(msg, terminal, exitVal, ForBoth, category, nil, 0, detErr)
+	if err != nil {
+		outputFailed(err)
+	}
+}
+
+// catExit returns if output at the given level should exit and the exit
+// value to use, only LevelFatal output exits
+func catExit(level Level) (bool, int) {
+	if level != LevelFatal {
+		return false, 0
+	}
+	return true, int(atomic.LoadInt32(&errorExitVal))
+}
+
+// PrintCat outputs at the given level like Print() but tags the output with
+// a subsystem category (eg: "net", "db" or "auth"), that category is put in
+// the output metadata (see FlagMetadata.Category) for formatters and such,
+// can be shown as "[net] " in the output via the Lcategory flag and can be
+// used to filter output via SetCategoryFilter().  At LevelFatal this exits.
+func PrintCat(category string, level Level, v ...interface{}) {
+	terminate, exitVal := catExit(level)
+	LevelWriter(level).catOutput(terminate, exitVal, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// PrintCatln is like PrintCat() but with a newline added, see PrintCat()
+func PrintCatln(category string, level Level, v ...interface{}) {
+	terminate, exitVal := catExit(level)
+	LevelWriter(level).catOutput(terminate, exitVal, category, fmt.Sprintln(renderArgs(v)...), v)
+}
+
+// PrintCatf is like PrintCat() but with a format string, see PrintCat()
+func PrintCatf(category string, level Level, format string, v ...interface{}) {
+	terminate, exitVal := catExit(level)
+	LevelWriter(level).catOutput(terminate, exitVal, category, fmt.Sprintf(format, v...), v)
+}
+
+// VerboseCat is like Verbose() with the output tagged with the given
+// category, see PrintCat()
+func VerboseCat(category string, v ...interface{}) {
+	VERBOSE.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// VerboseCatf is like Verbosef() with the output tagged with the given
+// category, see PrintCat()
+func VerboseCatf(category string, format string, v ...interface{}) {
+	VERBOSE.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// InfoCat is like Info() with the output tagged with the given category,
+// see PrintCat()
+func InfoCat(category string, v ...interface{}) {
+	INFO.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// InfoCatf is like Infof() with the output tagged with the given category,
+// see PrintCat()
+func InfoCatf(category string, format string, v ...interface{}) {
+	INFO.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// NoteCat is like Note() with the output tagged with the given category,
+// see PrintCat()
+func NoteCat(category string, v ...interface{}) {
+	NOTE.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// NoteCatf is like Notef() with the output tagged with the given category,
+// see PrintCat()
+func NoteCatf(category string, format string, v ...interface{}) {
+	NOTE.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// WarnCat is like Warn() with the output tagged with the given category,
+// see PrintCat()
+func WarnCat(category string, v ...interface{}) {
+	WARN.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// WarnCatf is like Warnf() with the output tagged with the given category,
+// see PrintCat()
+func WarnCatf(category string, format string, v ...interface{}) {
+	WARN.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// IssueCat is like Issue() with the output tagged with the given category,
+// see PrintCat()
+func IssueCat(category string, v ...interface{}) {
+	ISSUE.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// IssueCatf is like Issuef() with the output tagged with the given category,
+// see PrintCat()
+func IssueCatf(category string, format string, v ...interface{}) {
+	ISSUE.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// ErrorCat is like Error() with the output tagged with the given category,
+// see PrintCat()
+func ErrorCat(category string, v ...interface{}) {
+	ERROR.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// ErrorCatf is like Errorf() with the output tagged with the given category,
+// see PrintCat()
+func ErrorCatf(category string, format string, v ...interface{}) {
+	ERROR.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// FatalCat is like Fatal() with the output tagged with the given category,
+// see PrintCat(), it will exit non-zero from the tool
+func FatalCat(category string, v ...interface{}) {
+	FATAL.catOutput(true, int(atomic.LoadInt32(&errorExitVal)), category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// FatalCatf is like Fatalf() with the output tagged with the given category,
+// see PrintCat(), it will exit non-zero from the tool
+func FatalCatf(category string, format string, v ...interface{}) {
+	FATAL.catOutput(true, int(atomic.LoadInt32(&errorExitVal)), category, fmt.Sprintf(format, v...), v)
+}
@@ -0,0 +1,82 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clfTimeFormat is the time layout of the Common Log Format [date] field
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// CLFFormatter is a Formatter (see SetFormatter()) that writes each message
+// as an Apache Common Log Format access log line, eg: for a tool with an
+// embedded web server that wants standard access logs:
+//
+//	127.0.0.1 - bob [02/Jan/2006:15:04:05 -0700] "GET /index.html HTTP/1.1" 200 2326
+//
+// The values come from the structured fields of the output (eg: from an
+// Entry), ie: "remote", "ident", "authuser", "method", "path", "proto",
+// "status" and "bytes" (a "request" field is used as is in place of the
+// method, path and proto), any that are missing show up as "-".  The date is
+// the time of the output and the message text isn't used.  The native
+// prefixes and flags aren't added to the line, by default it applies to the
+// logfile only, eg: for an access log at the info level:
+//
+//	out.SetFormatter(out.LevelInfo, out.CLFFormatter{})
+//	out.WithFields(out.Fields{"remote": ip, "method": r.Method, "path": r.URL.Path,
+//		"proto": r.Proto, "status": 200, "bytes": n}).Info("served")
+type CLFFormatter struct {
+	// Target is where the lines go: ForLogfile (the default if 0),
+	// ForScreen or ForBoth
+	Target int
+}
+
+// FormatMessage implements the Formatter interface, see CLFFormatter
+func (f CLFFormatter) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	target := f.Target
+	if target&ForBoth == 0 {
+		target = ForLogfile
+	}
+	field := func(key string) string {
+		val, ok := mdata.Fields[key]
+		if !ok || val == nil {
+			return "-"
+		}
+		s := strings.TrimSpace(fmt.Sprint(val))
+		if s == "" {
+			return "-"
+		}
+		return strings.Join(strings.Fields(s), "_")
+	}
+	request := "-"
+	if val, ok := mdata.Fields["request"]; ok && val != nil {
+		request = fmt.Sprint(val)
+	} else if _, ok := mdata.Fields["method"]; ok {
+		request = field("method") + " " + field("path")
+		if _, ok := mdata.Fields["proto"]; ok {
+			request += " " + field("proto")
+		}
+	}
+	request = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(request)
+	when := time.Now()
+	if mdata.Time != nil {
+		when = *mdata.Time
+	}
+	line := fmt.Sprintf("%s %s %s [%s] \"%s\" %s %s\n", field("remote"), field("ident"), field("authuser"),
+		when.Format(clfTimeFormat), request, field("status"), field("bytes"))
+	return line, target | FormatterOwnsNewlines, 0, true
+}
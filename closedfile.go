@@ -0,0 +1,106 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// What to do when a logfile write fails because the file has been closed,
+// see SetClosedFilePolicy():
+const (
+	ClosedFileError  = iota // Treat it like any other write error (default)
+	ClosedFileDrop          // Silently drop the logfile output
+	ClosedFileReopen        // Reopen the file and retry the write
+)
+
+// closedFilePolicy is one of the ClosedFile* settings above
+var closedFilePolicy int32 = ClosedFileError
+
+// ClosedFilePolicy returns what is done when a logfile write hits a closed
+// file, one of ClosedFileError, ClosedFileDrop or ClosedFileReopen
+func ClosedFilePolicy() int {
+	return int(atomic.LoadInt32(&closedFilePolicy))
+}
+
+// SetClosedFilePolicy controls what happens when writing to the logfile fails
+// because the file was closed (eg: closed during shutdown while goroutines
+// are still logging, or closed out from under the pkg), by default this is
+// treated like any other write error (ie: reported and the tool exits).  The
+// policy can be one of:
+//   ClosedFileError  // report the error and exit, as for any write error
+//   ClosedFileDrop   // silently drop the logfile output, the screen output
+//                    // keeps working
+//   ClosedFileReopen // reopen the file (the one from SetLogFile() or one
+//                    // from SetLevelFiles()) and retry the write, if that
+//                    // isn't possible the error is reported as usual
+// eg: out.SetClosedFilePolicy(out.ClosedFileDrop).  Only logfile output is
+// affected, screen write errors are always reported.
+func SetClosedFilePolicy(policy int) {
+	if policy < ClosedFileError || policy > ClosedFileReopen {
+		policy = ClosedFileError
+	}
+	atomic.StoreInt32(&closedFilePolicy, int32(policy))
+}
+
+// isClosedFileErr returns true if the error came from writing to a closed
+// file (or a bad file descriptor)
+func isClosedFileErr(err error) bool {
+	return errors.Is(err, os.ErrClosed) || isBadFileErr(err)
+}
+
+// reopenClosedLogfile reopens the closed logfile handle given, if it is the
+// file from SetLogFile() (or UseTempLogFile()) any levels using it are moved
+// to the new handle, returns the handle to retry the write with or nil if
+// the handle can't be reopened
+func reopenClosedLogfile(hndl io.Writer) io.Writer {
+	switch f := hndl.(type) {
+	case *levelFile:
+		if err := f.open(false); err != nil {
+			return nil
+		}
+		return f
+	case *sizeRotateWriter:
+		if err := f.reopen(); err != nil {
+			return nil
+		}
+		return f
+	case *dailyRotateWriter:
+		if err := f.reopen(); err != nil {
+			return nil
+		}
+		return f
+	case *os.File:
+		name := LogFileName()
+		if name == "" || f.Name() != name {
+			return nil
+		}
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			return nil
+		}
+		for _, o := range outputters {
+			o.mu.Lock()
+			if o.logfileHndl == hndl {
+				o.logfileHndl = file
+			}
+			o.mu.Unlock()
+		}
+		return file
+	}
+	return nil
+}
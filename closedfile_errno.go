@@ -0,0 +1,27 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !plan9
+
+package out
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isBadFileErr returns true if the error is a bad file descriptor error (eg:
+// from writing to an *os.File closed underneath it), see isClosedFileErr()
+func isBadFileErr(err error) bool {
+	return errors.Is(err, syscall.EBADF)
+}
@@ -0,0 +1,204 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Color modes for SetColorMode(), ie: when level prefixes on the screen
+// are colored
+const (
+	ColorAuto   = iota // color if the screen writer is a terminal (default)
+	ColorAlways        // always color screen output, eg: for a pager
+	ColorNever         // never color, see also DisableColor()
+)
+
+// colorReset is the ANSI escape that ends a colored prefix
+const colorReset = "\x1b[0m"
+
+// levelColors holds the map[Level]string of ANSI SGR color codes for each
+// level's prefix, see SetLevelColor()
+var levelColors atomic.Value
+
+// colorMode is when colors are used (ColorAuto, ..), see SetColorMode()
+var colorMode int32 = ColorAuto
+
+// maxTerminalFiles bounds terminalFiles, when it's full it is emptied so
+// files opened and closed over time (each a new key) don't pile up
+const maxTerminalFiles = 16
+
+// terminalFiles caches if an *os.File is a terminal that gets colors so the
+// file isn't checked for every message, see terminalColor(), it's guarded by
+// terminalFilesMu and bounded by maxTerminalFiles
+var terminalFiles = make(map[*os.File]bool)
+var terminalFilesMu sync.Mutex
+
+func init() {
+	levelColors.Store(defaultLevelColors())
+}
+
+// defaultLevelColors is the starting color map: trace and debug are dimmed,
+// notes are yellow, warnings and issues bold yellow, errors red and fatals
+// bold red
+func defaultLevelColors() map[Level]string {
+	return map[Level]string{
+		LevelTrace: "2",
+		LevelDebug: "2",
+		LevelNote:  "33",
+		LevelWarn:  "1;33",
+		LevelIssue: "1;33",
+		LevelError: "31",
+		LevelFatal: "1;31",
+	}
+}
+
+// LevelColor returns the ANSI SGR color code used for the given level's
+// prefix on a terminal (eg: "31" for red), empty if the level has no color
+func LevelColor(level Level) string {
+	colors := levelColors.Load().(map[Level]string)
+	if color, ok := colors[level]; ok {
+		return color
+	}
+	// a custom level uses its severity's color unless it has its own
+	return colors[level.Severity()]
+}
+
+// SetLevelColor sets the ANSI SGR color code used for the given level's
+// prefix (or every level's if LevelAll is used, including custom levels from
+// RegisterLevel() which otherwise use their severity's color), the code is
+// what goes between the "ESC[" and the "m" of the escape sequence, eg: "31"
+// is red, "1;31" bold red and "2" dimmed, use "" for no color.  The prefix
+// (eg: "Error: ") is only colored when written to a screen writer that is a
+// terminal, logfile output and screen output to a pipe, file or buffer are
+// never colored.  The defaults are trace and debug dimmed, note yellow,
+// issue bold yellow, error red and fatal bold red, see SetColorMode() to
+// control when colors are used.
+func SetLevelColor(level Level, colorCode string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	colors := make(map[Level]string)
+	for l, c := range levelColors.Load().(map[Level]string) {
+		colors[l] = c
+	}
+	for l := LevelTrace; l <= LevelFatal; l++ {
+		if level == LevelAll || l == level {
+			colors[l] = colorCode
+		}
+	}
+	for i := range registeredLevels().levels {
+		if l := firstCustomLevel + Level(i); level == LevelAll || l == level {
+			colors[l] = colorCode
+		}
+	}
+	levelColors.Store(colors)
+}
+
+// ColorMode returns when level prefixes on the screen are colored, one of
+// ColorAuto, ColorAlways or ColorNever, see SetColorMode()
+func ColorMode() int {
+	return int(atomic.LoadInt32(&colorMode))
+}
+
+// SetColorMode sets when level prefixes on the screen are colored (see
+// SetLevelColor() for the colors), with ColorAuto (the default) they are
+// only colored if the screen writer is a terminal (not a pipe, file or
+// buffer, eg: output piped to grep has no escape codes) and the NO_COLOR
+// env var isn't set.  ColorAlways colors the screen output regardless (eg:
+// for output going to a pager that shows colors) and ColorNever turns the
+// colors off.  Logfile output is never colored.
+func SetColorMode(mode int) {
+	if mode < ColorAuto || mode > ColorNever {
+		mode = ColorAuto
+	}
+	atomic.StoreInt32(&colorMode, int32(mode))
+	if mode != ColorNever {
+		// colors on a Windows console need virtual terminal processing, if
+		// that fails the console just isn't seen as color capable
+		EnableVirtualTerminal()
+	}
+}
+
+// DisableColor turns off the coloring of level prefixes on the screen, the
+// same as SetColorMode(ColorNever)
+func DisableColor() {
+	SetColorMode(ColorNever)
+}
+
+// EnableColor turns the coloring of level prefixes on the screen back on
+// for terminals, the same as SetColorMode(ColorAuto)
+func EnableColor() {
+	SetColorMode(ColorAuto)
+}
+
+// terminalColor returns true if the given screen writer is a terminal that
+// gets colors in ColorAuto mode, it's (or wraps, see unwrapWriter()) an
+// *os.File open on a terminal (on Windows a console that virtual terminal
+// processing could be turned on for, see EnableVirtualTerminal(), the
+// result is cached per file) and the NO_COLOR env var isn't set
+func terminalColor(w io.Writer) bool {
+	f, ok := unwrapWriter(w).(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	terminalFilesMu.Lock()
+	defer terminalFilesMu.Unlock()
+	if term, found := terminalFiles[f]; found {
+		return term
+	}
+	if len(terminalFiles) >= maxTerminalFiles {
+		terminalFiles = make(map[*os.File]bool)
+	}
+	term := isTerminal(f) && enableVirtualTerminal(f) == nil
+	terminalFiles[f] = term
+	return term
+}
+
+// EnableVirtualTerminal turns on virtual terminal processing for the
+// stdout and stderr consoles on Windows 10+ so the ANSI escapes used for
+// colors (see SetLevelColor()) render instead of showing up as garbage, it
+// is done automatically for a console screen writer when colors are on (and
+// when SetColorMode() turns them on) so this is only needed if writing
+// escapes to the console some other way.  An error is returned if stdout or
+// stderr is a console that doesn't support it, elsewhere this does nothing.
+func EnableVirtualTerminal() error {
+	var firstErr error
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if !isTerminal(f) {
+			continue
+		}
+		if err := enableVirtualTerminal(f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// colorPrefix wraps the given prefix in the level's color if colors are on
+// for the screen writer (see SetColorMode()), only the prefix is colored so the
+// message (and its last byte, used for newline tracking) is left as-is
+func colorPrefix(level Level, prefix string, screenHndl io.Writer) string {
+	mode := atomic.LoadInt32(&colorMode)
+	if prefix == "" || mode == ColorNever {
+		return prefix
+	}
+	color := LevelColor(level)
+	if color == "" || (mode == ColorAuto && !terminalColor(screenHndl)) {
+		return prefix
+	}
+	return "\x1b[" + color + "m" + prefix + colorReset
+}
@@ -0,0 +1,45 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag that makes a
+// Windows 10+ console render ANSI escape sequences (ie: colors)
+const enableVirtualTerminalProcessing = 0x0004
+
+// procSetConsoleMode is SetConsoleMode() in kernel32.dll, the syscall pkg
+// only has GetConsoleMode()
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableVirtualTerminal turns on virtual terminal processing for the given
+// file if it's a console so the ANSI color escapes render, an error is
+// returned if it isn't a console or the console is too old to support it
+func enableVirtualTerminal(f *os.File) error {
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return err
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return nil
+	}
+	if ok, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing)); ok == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import "context"
+
+// outputterKey is the context key an Outputter is stored under
+type outputterKey struct{}
+
+// NewContext returns a copy of the given context with the Outputter stored
+// in it, so a request scoped Outputter (eg: one with a request id from the
+// WithFields() method) can be threaded through the calls handling it, eg:
+//
+//	reqOut := out.Child("").WithFields(map[string]interface{}{"request_id": id})
+//	ctx = out.NewContext(ctx, reqOut)
+//	...
+//	out.FromContext(ctx).Noteln("cache miss")
+func NewContext(ctx context.Context, o *Outputter) context.Context {
+	return context.WithValue(ctx, outputterKey{}, o)
+}
+
+// FromContext returns the Outputter stored in the context via NewContext(),
+// if there is none the default Outputter (the one the pkg level routines
+// use) is returned
+func FromContext(ctx context.Context) *Outputter {
+	if ctx != nil {
+		if o, ok := ctx.Value(outputterKey{}).(*Outputter); ok && o != nil {
+			return o
+		}
+	}
+	return std
+}
@@ -0,0 +1,80 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// emittedCounts counts the messages actually written at each built-in
+	// level (to the screen and/or logfile), indexed by level
+	emittedCounts [LevelDiscard]uint64
+
+	// customEmittedCounts holds a *uint64 count of the messages written at
+	// each custom level (see RegisterLevel()), keyed by Level
+	customEmittedCounts sync.Map
+)
+
+// countEmitted notes a message written at the given level, see Counts()
+func countEmitted(level Level) {
+	if level >= LevelTrace && level < LevelDiscard {
+		atomic.AddUint64(&emittedCounts[level], 1)
+		return
+	}
+	count, ok := customEmittedCounts.Load(level)
+	if !ok {
+		count, _ = customEmittedCounts.LoadOrStore(level, new(uint64))
+	}
+	atomic.AddUint64(count.(*uint64), 1)
+}
+
+// Counts returns how many messages have been written at each level (to the
+// screen and/or logfile, a message written to both counts once), eg: so a
+// tool can finish up with "3 errors, 12 warnings" or feed a metrics system:
+//
+//	counts := out.Counts()
+//	out.Printf("%d errors, %d warnings\n", counts[out.LevelError], counts[out.LevelWarn])
+//
+// Unlike the exit summary counts (see SetExitSummary()) output below the
+// thresholds, suppressed output (eg: see SetDebugScope(), SetSampling() or
+// SetDedup()) and failed writes aren't counted.  Levels without any output
+// aren't in the map.  Use ResetCounts() to start counting afresh.
+func Counts() map[Level]uint64 {
+	counts := make(map[Level]uint64)
+	for level := LevelTrace; level < LevelDiscard; level++ {
+		if count := atomic.LoadUint64(&emittedCounts[level]); count != 0 {
+			counts[level] = count
+		}
+	}
+	customEmittedCounts.Range(func(key, value interface{}) bool {
+		if count := atomic.LoadUint64(value.(*uint64)); count != 0 {
+			counts[key.(Level)] = count
+		}
+		return true
+	})
+	return counts
+}
+
+// ResetCounts zeroes the counts of written messages, see Counts()
+func ResetCounts() {
+	for level := LevelTrace; level < LevelDiscard; level++ {
+		atomic.StoreUint64(&emittedCounts[level], 0)
+	}
+	customEmittedCounts.Range(func(key, value interface{}) bool {
+		atomic.StoreUint64(value.(*uint64), 0)
+		return true
+	})
+}
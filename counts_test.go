@@ -0,0 +1,52 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/counts.go
+//   Checks Counts() tracks the messages written at each level (and not those
+//   below the thresholds) and that ResetCounts() clears them.
+
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestCounts(t *testing.T) {
+	ResetCounts()
+	SetWriter(LevelAll, new(bytes.Buffer), ForBoth)
+	SetThreshold(LevelInfo, ForScreen)
+	SetThreshold(LevelWarn, ForLogfile)
+	Debugln("not written")
+	Println("one")
+	Println("two")
+	Warnln("to both targets, counted once")
+	Errorln("oops")
+	audit := RegisterLevel("COUNTAUDIT", int(LevelNote), "Audit: ", nil, nil)
+	fmt.Fprintln(LevelWriter(audit), "audited")
+	SetThreshold(LevelError, ForBoth)
+	Warnln("below the thresholds now")
+	counts := Counts()
+	ResetCounts()
+	resetCounts := Counts()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, map[Level]uint64{LevelInfo: 2, LevelWarn: 1, LevelError: 1, audit: 1}, counts)
+	assert.Equal(t, map[Level]uint64{}, resetCounts)
+}
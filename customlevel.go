@@ -0,0 +1,202 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// firstCustomLevel is the Level of the first level added via RegisterLevel(),
+// well clear of the built-in levels (and LevelDiscard/LevelAll)
+const firstCustomLevel Level = 100
+
+// customLevel is a level added via RegisterLevel()
+type customLevel struct {
+	name     string
+	severity Level
+	prefix   string // the prefix the level was registered with
+	count    *int64 // messages output at the level, for the exit summary
+}
+
+// levelRegistry holds the levels added via RegisterLevel(), indexed by the
+// level minus firstCustomLevel, it's replaced (not changed) when a level is
+// added so it can be read without locking
+type levelRegistry struct {
+	levels []customLevel
+	byName map[string]Level
+}
+
+// customLevels holds the current *levelRegistry, see registeredLevels()
+var customLevels atomic.Value
+
+// registeredLevels returns the current level registry (empty if no levels
+// have been registered)
+func registeredLevels() *levelRegistry {
+	if reg, ok := customLevels.Load().(*levelRegistry); ok {
+		return reg
+	}
+	return &levelRegistry{}
+}
+
+// builtinLevelNames maps the built-in levels to their names, see String()
+var builtinLevelNames = map[Level]string{
+	LevelTrace:   "TRACE",
+	LevelDebug:   "DEBUG",
+	LevelVerbose: "VERBOSE",
+	LevelInfo:    "INFO",
+	LevelNote:    "NOTE",
+	LevelWarn:    "WARN",
+	LevelIssue:   "ISSUE",
+	LevelError:   "ERROR",
+	LevelFatal:   "FATAL",
+	LevelDiscard: "DISCARD",
+}
+
+// builtinLevels maps the built-in level names to their levels, see
+// LevelString2Level()
+var builtinLevels = map[string]Level{
+	"TRACE":   LevelTrace,
+	"DEBUG":   LevelDebug,
+	"VERBOSE": LevelVerbose,
+	"INFO":    LevelInfo,
+	"NOTE":    LevelNote,
+	"WARN":    LevelWarn,
+	"ISSUE":   LevelIssue,
+	"ERROR":   LevelError,
+	"FATAL":   LevelFatal,
+	"DISCARD": LevelDiscard,
+}
+
+// RegisterLevel adds a named output level (eg: "AUDIT" or "SECURITY") to the
+// default Outputter and returns its Level, the severity is the built-in level
+// (LevelTrace through LevelFatal) it is treated as for thresholds, stack
+// traces, exit codes and such, eg: an audit level shown whenever notes are:
+//
+//	audit := out.RegisterLevel("AUDIT", int(out.LevelNote), "Audit: ", nil, nil)
+//	fmt.Fprintf(out.LevelWriter(audit), "user %s logged in\n", user)
+//
+// A nil screen or logfile writer means the writer of the built-in level with
+// the same severity is used (the flags are copied from it too), SetLogFile()
+// and such done after registering cover the new level as well.  The level
+// name is what String() and the Llevel flag show, LevelString2Level() maps
+// it back.  Registering a name that's already a level returns that Level and
+// changes nothing.  Use LevelWriter() (or the Level with routines that take
+// one, eg: PrintCat(), SetPrefix() or SetFlags()) to output at the level.
+// Threshold funcs (see SetThresholdFunc()) are given the custom Level, use
+// its Severity() to compare it to the built-in levels.  Register levels at
+// startup, before output from other goroutines begins.
+func RegisterLevel(name string, severity int, prefix string, screen, logfile io.Writer) Level {
+	sev := levelCheck(Level(severity))
+	if sev == LevelDiscard {
+		sev = LevelFatal
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if level, ok := builtinLevels[name]; ok {
+		return level
+	}
+	old := registeredLevels()
+	if level, ok := old.byName[name]; ok {
+		return level
+	}
+	reg := &levelRegistry{levels: make([]customLevel, len(old.levels), len(old.levels)+1), byName: make(map[string]Level, len(old.byName)+1)}
+	copy(reg.levels, old.levels)
+	for n, l := range old.byName {
+		reg.byName[n] = l
+	}
+	level := firstCustomLevel + Level(len(old.levels))
+	reg.levels = append(reg.levels, customLevel{name: name, severity: sev, prefix: prefix, count: new(int64)})
+	reg.byName[name] = level
+	customLevels.Store(reg)
+
+	o := newCustomLvlOutput(std, level, sev, prefix)
+	if screen != nil {
+		o.screenHndl = screen
+	}
+	if logfile != nil {
+		o.logfileHndl = logfile
+	}
+	std.outputters = append(std.outputters, o)
+	outputters = std.outputters
+	return level
+}
+
+// newCustomLvlOutput returns the output level for a custom level on the given
+// Outputter, with the writers and flags of the built-in level of the same
+// severity
+func newCustomLvlOutput(op *Outputter, level Level, severity Level, prefix string) *LvlOutput {
+	so := op.outputters[severity]
+	so.mu.RLock()
+	defer so.mu.RUnlock()
+	return &LvlOutput{
+		level:       level,
+		prefix:      prefix,
+		screenHndl:  so.screenHndl,
+		screenFlags: so.screenFlags,
+		logfileHndl: so.logfileHndl,
+		logFlags:    so.logFlags,
+		outputter:   op,
+	}
+}
+
+// customLevelInfo returns the custom level info for the given level and true,
+// or false if it isn't a level added via RegisterLevel()
+func customLevelInfo(l Level) (customLevel, bool) {
+	if l < firstCustomLevel {
+		return customLevel{}, false
+	}
+	reg := registeredLevels()
+	if i := int(l - firstCustomLevel); i < len(reg.levels) {
+		return reg.levels[i], true
+	}
+	return customLevel{}, false
+}
+
+// Severity returns the built-in level the level is treated as for thresholds
+// and such, ie: the level itself for the built-in levels or the severity
+// given to RegisterLevel() for a custom level
+func (l Level) Severity() Level {
+	if custom, ok := customLevelInfo(l); ok {
+		return custom.severity
+	}
+	return l
+}
+
+// countCustomOutput notes a message at the given custom level for the exit
+// summary, returns false if it isn't a custom level
+func countCustomOutput(l Level) bool {
+	custom, ok := customLevelInfo(l)
+	if ok {
+		atomic.AddInt64(custom.count, 1)
+	}
+	return ok
+}
+
+// customLevelCounts returns the custom levels with output, in order of
+// severity (and then registration)
+func customLevelCounts() []customLevel {
+	reg := registeredLevels()
+	var counted []customLevel
+	for _, custom := range reg.levels {
+		if atomic.LoadInt64(custom.count) != 0 {
+			counted = append(counted, custom)
+		}
+	}
+	sort.SliceStable(counted, func(i, j int) bool {
+		return counted[i].severity < counted[j].severity
+	})
+	return counted
+}
@@ -0,0 +1,97 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/customlevel.go
+//   Checks levels added via RegisterLevel() are named, ordered by severity
+//   for thresholds and can be output at like the built-in levels.
+
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+// resetCustomLevels drops the levels added via RegisterLevel() so a test can
+// register them afresh (eg: when run again via go test -count=2)
+func resetCustomLevels() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	customLevels.Store(&levelRegistry{byName: make(map[string]Level)})
+	std.outputters = std.outputters[:LevelFatal+1]
+	outputters = std.outputters
+}
+
+func TestRegisterLevel(t *testing.T) {
+	resetCustomLevels()
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	audit := RegisterLevel("AUDIT", int(LevelNote), "Audit: ", screenBuf, nil)
+	again := RegisterLevel("AUDIT", int(LevelFatal), "", nil, nil)
+	builtin := RegisterLevel("ISSUE", int(LevelTrace), "", nil, nil)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(audit, Llevel, ForLogfile)
+	SetThreshold(LevelWarn, ForScreen)
+	SetThreshold(LevelInfo, ForLogfile)
+	auditCount := CurrentExitSummary(0).Counts["AUDIT"]
+
+	fmt.Fprintln(LevelWriter(audit), "below the screen threshold")
+	SetThreshold(LevelNote, ForScreen)
+	fmt.Fprintln(LevelWriter(audit), "user logged in")
+	PrintCatln("auth", audit, "with a category")
+	prefix := Prefix(audit)
+	summary := CurrentExitSummary(0)
+	newOp := NewOutputter()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, audit, again)
+	assert.Equal(t, LevelIssue, builtin)
+	assert.Equal(t, "AUDIT", audit.String())
+	assert.Equal(t, audit, LevelString2Level("AUDIT"))
+	assert.Equal(t, LevelNote, audit.Severity())
+	assert.Equal(t, LevelNote, LevelNote.Severity())
+	assert.Equal(t, "Audit: ", prefix)
+	assert.Equal(t, "Audit: user logged in\nAudit: with a category\n", screenBuf.String())
+	assert.Equal(t, "AUDIT   Audit: below the screen threshold\nAUDIT   Audit: user logged in\nAUDIT   Audit: with a category\n", logBuf.String())
+	assert.Equal(t, auditCount+3, summary.Counts["AUDIT"])
+	assert.Contains(t, summary.String(), fmt.Sprintf(" AUDIT=%d", auditCount+3))
+	assert.Equal(t, audit, newOp.LevelWriter(audit).level)
+	assert.Equal(t, LevelColor(LevelNote), LevelColor(audit))
+}
+
+func TestCustomLevelColor(t *testing.T) {
+	audit := RegisterLevel("COLORAUDIT", int(LevelNote), "Audit: ", nil, nil)
+	SetLevelColor(audit, "35")
+	own := LevelColor(audit)
+	noteColor := LevelColor(LevelNote)
+	SetLevelColor(LevelAll, "36")
+	all := LevelColor(audit)
+	SetLevelColor(LevelAll, "")
+	none := LevelColor(audit)
+	levelColors.Store(defaultLevelColors())
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "35", own)
+	assert.Equal(t, "33", noteColor, "the severity's color is unchanged")
+	assert.Equal(t, "36", all)
+	assert.Equal(t, "", none)
+}
@@ -0,0 +1,142 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// scopePattern is one PKG_OUT_DEBUG_SCOPE (or SetDebugScope()) pattern, a
+// plain substring unless it had a "re:" prefix, negated with a "!" prefix
+type scopePattern struct {
+	substr string
+	re     *regexp.Regexp
+	negate bool
+}
+
+// matches returns true if the pattern matches the func name
+func (p scopePattern) matches(funcName string) bool {
+	if p.re != nil {
+		return p.re.MatchString(funcName)
+	}
+	return strings.Contains(funcName, p.substr)
+}
+
+// debugScope is a parsed set of debug scope patterns along with the string
+// they came from (so env changes can be noticed)
+type debugScope struct {
+	src         string
+	patterns    []scopePattern
+	hasPositive bool
+}
+
+var (
+	// envDebugScope holds the *debugScope parsed from PKG_OUT_DEBUG_SCOPE
+	envDebugScope atomic.Value
+
+	// apiDebugScope holds the *debugScope from SetDebugScope(), nil if unset
+	apiDebugScope atomic.Value
+)
+
+// SetDebugScope restricts debug and trace output to the given packages or
+// funcs (matched against the callers func name, eg:
+// "github.com/jdough/mypkg.FuncA"), the same as setting PKG_OUT_DEBUG_SCOPE
+// to the comma separated patterns (which wins over this if set).  Patterns are
+// a plain substring match unless they start with "re:" for a regexp, and a
+// "!" prefix negates one (a negated match suppresses the output even if other
+// patterns match), eg: everything from the get and put pkgs but not vendor:
+//
+//	out.SetDebugScope([]string{"re:^github.com/dvln/(get|put)", "!vendor/"})
+//
+// Invalid regexps are ignored, use nil to remove the scope restriction.
+func SetDebugScope(patterns []string) {
+	if len(patterns) == 0 {
+		apiDebugScope.Store((*debugScope)(nil))
+		return
+	}
+	apiDebugScope.Store(parseDebugScope(strings.Join(patterns, ","), patterns))
+}
+
+// DebugScope returns the patterns given to SetDebugScope(), nil if none
+// (any PKG_OUT_DEBUG_SCOPE env setting isn't included)
+func DebugScope() []string {
+	scope, _ := apiDebugScope.Load().(*debugScope)
+	if scope == nil {
+		return nil
+	}
+	return strings.Split(scope.src, ",")
+}
+
+// parseDebugScope parses the given debug scope patterns, src is the comma
+// separated form they came from
+func parseDebugScope(src string, patterns []string) *debugScope {
+	scope := &debugScope{src: src}
+	for _, pattern := range patterns {
+		var p scopePattern
+		if strings.HasPrefix(pattern, "!") {
+			p.negate = true
+			pattern = pattern[1:]
+		}
+		if strings.HasPrefix(pattern, "re:") {
+			re, err := regexp.Compile(pattern[3:])
+			if err != nil {
+				continue
+			}
+			p.re = re
+		} else {
+			p.substr = pattern
+		}
+		scope.patterns = append(scope.patterns, p)
+		if !p.negate {
+			scope.hasPositive = true
+		}
+	}
+	return scope
+}
+
+// currentDebugScope returns the debug scope in use, the PKG_OUT_DEBUG_SCOPE
+// env setting if set (parsed again only if it changed) else the one from
+// SetDebugScope(), nil if there is no debug scope restriction
+func currentDebugScope() *debugScope {
+	if env := os.Getenv("PKG_OUT_DEBUG_SCOPE"); env != "" {
+		scope, _ := envDebugScope.Load().(*debugScope)
+		if scope == nil || scope.src != env {
+			scope = parseDebugScope(env, strings.Split(env, ","))
+			envDebugScope.Store(scope)
+		}
+		return scope
+	}
+	scope, _ := apiDebugScope.Load().(*debugScope)
+	return scope
+}
+
+// suppresses returns true if output from the given func is outside of the
+// debug scope, ie: a negated pattern matches it or there are patterns to
+// include output and none of them match it
+func (s *debugScope) suppresses(funcName string) bool {
+	included := !s.hasPositive
+	for _, p := range s.patterns {
+		if !p.matches(funcName) {
+			continue
+		}
+		if p.negate {
+			return true
+		}
+		included = true
+	}
+	return !included
+}
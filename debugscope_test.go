@@ -0,0 +1,62 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/debugscope.go
+//   Checks substring, regexp and negated debug scope patterns set via
+//   SetDebugScope() and the PKG_OUT_DEBUG_SCOPE env.
+
+package out
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetDebugScope(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelTrace, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	envScope := os.Getenv("PKG_OUT_DEBUG_SCOPE")
+	os.Setenv("PKG_OUT_DEBUG_SCOPE", "")
+
+	SetDebugScope([]string{"re:^github.com/dvln/(get|put)"})
+	Debugln("regexp does not match")
+	Noteln("not debug or trace")
+	SetDebugScope([]string{"re:^github.com/dvln/(get|out)\\.TestSet"})
+	Debugln("regexp matches")
+	SetDebugScope([]string{"out.", "!re:DebugScope$"})
+	Traceln("negated match wins")
+	SetDebugScope([]string{"!boguspkg."})
+	Traceln("only negated patterns")
+	scope := DebugScope()
+	os.Setenv("PKG_OUT_DEBUG_SCOPE", "boguspkg.")
+	Debugln("env scope wins")
+	os.Setenv("PKG_OUT_DEBUG_SCOPE", "re:[invalid")
+	Debugln("invalid regexp ignored")
+	os.Setenv("PKG_OUT_DEBUG_SCOPE", envScope)
+	SetDebugScope(nil)
+	clearedScope := DebugScope()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, []string{"!boguspkg."}, scope)
+	assert.Nil(t, clearedScope)
+	assert.Equal(t, "Note: not debug or trace\nDebug: regexp matches\nTrace: only negated patterns\nDebug: invalid regexp ignored\n", screenBuf.String())
+}
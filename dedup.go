@@ -0,0 +1,212 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The dedup display modes, see SetDedupMode():
+const (
+	DedupDeferred = iota // "last message repeated N times" when the message changes (default)
+	DedupInline          // rewrite the repeated screen line with a count, terminals only
+)
+
+// inlineRewrite is the ANSI escape that moves the cursor back up to the
+// start of the line just written and clears it, see DedupInline
+const inlineRewrite = "\x1b[1A\r\x1b[2K"
+
+// dedupNow is the clock used for deduplication, time.Now() except when
+// testing
+var dedupNow = time.Now
+
+// dedupTerminal reports if a screen writer is a terminal that can have a
+// line rewritten for DedupInline, a var so tests can fake a terminal
+var dedupTerminal = func(w io.Writer) bool {
+	return isTerminal(w) && enableVirtualTerminal(unwrapWriter(w).(*os.File)) == nil
+}
+
+// dedupMode is how repeats are shown (DedupDeferred, ..), see SetDedupMode()
+var dedupMode int32 = DedupDeferred
+
+// repeatedMsg is the line written for collapsed duplicate messages
+const repeatedMsg = "last message repeated %d times\n"
+
+// dedupWindow is the SetDedup() window as a time.Duration, 0 if off
+var dedupWindow int64
+
+// dedupState tracks the last message written for SetDedup(), protected by
+// the dedupMu mutex
+var (
+	dedupMu       sync.Mutex
+	dedupHash     uint64     // hash of the last message written
+	dedupOut      *LvlOutput // the level the last message was written at
+	dedupCategory string     // and its category (if any)
+	dedupSince    time.Time  // when the last message was written
+	dedupCount    int        // duplicates of it dropped since then
+	dedupInlined  bool       // the count is being shown inline on the screen
+)
+
+// SetDedup collapses identical consecutive messages written within the
+// given window into one, the classic syslog behavior: the first message is
+// written, repeats of it are dropped and counted, and when a different
+// message comes along (or a repeat after the window has passed) a "last
+// message repeated N times" line is written at the level of the repeated
+// message first, eg: out.SetDedup(30 * time.Second).  Messages match if the
+// level, category and text all match.  Any pending count is also written
+// when the tool exits via this pkg or FlushDedup() is called.  Dying (Fatal)
+// output is never dropped.  A window of 0 (the default) turns this off.
+func SetDedup(window time.Duration) {
+	if window < 0 {
+		window = 0
+	}
+	if window == 0 {
+		FlushDedup()
+	}
+	atomic.StoreInt64(&dedupWindow, int64(window))
+}
+
+// Dedup returns the window set via SetDedup(), 0 if deduplication is off
+func Dedup() time.Duration {
+	return time.Duration(atomic.LoadInt64(&dedupWindow))
+}
+
+// DedupMode returns how repeated messages are shown, see SetDedupMode()
+func DedupMode() int {
+	return int(atomic.LoadInt32(&dedupMode))
+}
+
+// SetDedupMode controls how the repeats dropped by SetDedup() are shown, by
+// default (DedupDeferred) a "last message repeated N times" line is written
+// when a different message comes along.  With DedupInline, if the screen
+// writer for the level is a terminal, each repeat instead rewrites the
+// screen line of the message in place (via '\r' and the ANSI cursor up and
+// clear line escapes) with a running count, eg:
+//
+//	Note: connection refused (x42)
+//
+// which suits watching a tool in real time.  The logfile (and a screen that
+// isn't a terminal) still gets the deferred "last message repeated N times"
+// line.  Messages that span multiple lines are never rewritten inline.
+func SetDedupMode(mode int) {
+	if mode != DedupInline {
+		mode = DedupDeferred
+	}
+	atomic.StoreInt32(&dedupMode, int32(mode))
+}
+
+// FlushDedup writes the "last message repeated N times" line for any
+// duplicate messages dropped so far (see SetDedup()), the next message is
+// then written even if it repeats the last one
+func FlushDedup() {
+	dedupMu.Lock()
+	o, category, count, inlined := dedupOut, dedupCategory, dedupCount, dedupInlined
+	dedupHash, dedupOut, dedupCategory, dedupCount, dedupInlined = 0, nil, "", 0, false
+	dedupMu.Unlock()
+	writeRepeated(o, category, count, inlined)
+}
+
+// writeRepeated writes the "last message repeated N times" line if count is
+// above zero, only to the logfile if the count was shown inline on the screen
+func writeRepeated(o *LvlOutput, category string, count int, inlined bool) {
+	if o == nil || count == 0 {
+		return
+	}
+	outputTgt := ForBoth
+	if inlined {
+		outputTgt = ForLogfile
+	}
+	if _, err := o.stringOutput(fmt.Sprintf(repeatedMsg, count), false, 0, outputTgt, category, nil, 0); err != nil {
+		outputFailed(err)
+	}
+}
+
+// inlineRepeat returns the message with the repeat count added for showing
+// inline (see DedupInline) or "" if it can't be shown inline, ie: the mode is
+// DedupDeferred, the message isn't a single line, the screen writer isn't a
+// terminal or the last screen output didn't end in a newline (so the screen
+// line just written isn't the message)
+func (o *LvlOutput) inlineRepeat(s string, count int, screenShown bool) string {
+	if atomic.LoadInt32(&dedupMode) != DedupInline || !screenShown || strings.Count(s, "\n") != 1 || !strings.HasSuffix(s, "\n") {
+		return ""
+	}
+	o.mu.RLock()
+	hndl := o.screenHndl
+	o.mu.RUnlock()
+	mutex.RLock()
+	onNewline := o.parent().screenNewline
+	mutex.RUnlock()
+	if !onNewline || !dedupTerminal(hndl) {
+		return ""
+	}
+	return fmt.Sprintf("%s (x%d)\n", strings.TrimSuffix(s, "\n"), count+1)
+}
+
+// writeInline rewrites the last screen line with the given (prefixed) line
+// showing the repeat count, see DedupInline
+func (o *LvlOutput) writeInline(line string) {
+	o.mu.RLock()
+	hndl := o.screenHndl
+	o.mu.RUnlock()
+	mutex.Lock()
+	hndl.Write([]byte(inlineRewrite + line))
+	mutex.Unlock()
+}
+
+// dedupHashOf hashes the message text for the dedup check, FNV-1a is cheap
+// and good enough as a match also requires the same level and category
+func dedupHashOf(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// deduplicated returns true if the given message repeats the last message
+// within the SetDedup() window and should be dropped, if not the message
+// becomes the last one and any pending "last message repeated N times" line
+// is written first.  Dying output is never dropped.  If the repeat is to be
+// shown inline on the screen (see DedupInline) the message with its count
+// is returned too, screenShown is true if the message goes to the screen.
+func (o *LvlOutput) deduplicated(s string, category string, dying bool, screenShown bool) (bool, string) {
+	window := time.Duration(atomic.LoadInt64(&dedupWindow))
+	if window == 0 || strings.HasPrefix(s, "last message repeated ") {
+		return false, ""
+	}
+	hash := dedupHashOf(s)
+	now := dedupNow()
+	dedupMu.Lock()
+	if !dying && o == dedupOut && hash == dedupHash && category == dedupCategory && now.Sub(dedupSince) < window {
+		dedupCount++
+		inline := ""
+		if dedupCount == 1 || dedupInlined {
+			// only shown inline if the first repeat could be
+			inline = o.inlineRepeat(s, dedupCount, screenShown)
+		}
+		dedupInlined = inline != ""
+		dedupMu.Unlock()
+		return true, inline
+	}
+	prevOut, prevCategory, count, inlined := dedupOut, dedupCategory, dedupCount, dedupInlined
+	dedupHash, dedupOut, dedupCategory, dedupSince, dedupCount, dedupInlined = hash, o, category, now, 0, false
+	dedupMu.Unlock()
+	writeRepeated(prevOut, prevCategory, count, inlined)
+	return false, ""
+}
@@ -0,0 +1,129 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/dedup.go
+//   Checks SetDedup() collapses identical consecutive messages within the
+//   window into a "last message repeated N times" line, using a fake clock,
+//   and that DedupInline rewrites the screen line with a count on a terminal.
+
+package out
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	origNow := dedupNow
+	dedupNow = func() time.Time { return now }
+	defer func() { dedupNow = origNow }()
+
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+
+	// Off by default, every message is written
+	Noteln("retrying")
+	Noteln("retrying")
+	off := screenBuf.String()
+	screenBuf.Reset()
+
+	SetDedup(10 * time.Second)
+	window := Dedup()
+	for i := 0; i < 4; i++ {
+		Noteln("retrying")
+	}
+	Issueln("retrying")
+	Noteln("gave up")
+	different := screenBuf.String()
+	screenBuf.Reset()
+
+	// A repeat after the window flushes the count and is written again
+	Noteln("gave up")
+	now = now.Add(11 * time.Second)
+	Noteln("gave up")
+	Noteln("gave up")
+	FlushDedup()
+	elapsed := screenBuf.String()
+	screenBuf.Reset()
+
+	// Turning it off writes any pending count
+	Noteln("done")
+	Noteln("done")
+	SetDedup(0)
+	Noteln("done")
+	turnedOff := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: retrying\nNote: retrying\n", off)
+	assert.Equal(t, 10*time.Second, window)
+	assert.Equal(t, "Note: retrying\nNote: last message repeated 3 times\nIssue: retrying\nNote: gave up\n", different)
+	assert.Equal(t, "Note: last message repeated 1 times\nNote: gave up\nNote: last message repeated 1 times\n", elapsed)
+	assert.Equal(t, "Note: done\nNote: last message repeated 1 times\nNote: done\n", turnedOff)
+}
+
+func TestDedupInline(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForBoth)
+	SetThreshold(LevelInfo, ForBoth)
+	SetDedup(10 * time.Second)
+	SetDedupMode(DedupInline)
+	mode := DedupMode()
+
+	// Not a terminal, the screen gets the deferred line too
+	Noteln("retrying")
+	Noteln("retrying")
+	Noteln("next")
+	notTerminal := screenBuf.String()
+	screenBuf.Reset()
+	logBuf.Reset()
+
+	origTerminal := dedupTerminal
+	dedupTerminal = func(w io.Writer) bool { return w == screenBuf }
+	defer func() { dedupTerminal = origTerminal }()
+	Noteln("connection refused")
+	Noteln("connection refused")
+	Noteln("connection refused")
+	Noteln("connected")
+	Noteln("multi\nline")
+	Noteln("multi\nline")
+	Noteln("done")
+	inlineScreen := screenBuf.String()
+	inlineLog := logBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, DedupInline, mode)
+	assert.Equal(t, "Note: retrying\nNote: last message repeated 1 times\nNote: next\n", notTerminal)
+	assert.Equal(t, "Note: connection refused\n"+
+		inlineRewrite+"Note: connection refused (x2)\n"+
+		inlineRewrite+"Note: connection refused (x3)\n"+
+		"Note: connected\n"+
+		"Note: multi\nNote: line\nNote: last message repeated 1 times\nNote: done\n", inlineScreen)
+	assert.Equal(t, "Note: connection refused\nNote: last message repeated 2 times\nNote: connected\n"+
+		"Note: multi\nNote: line\nNote: last message repeated 1 times\nNote: done\n", inlineLog)
+	assert.Equal(t, DedupDeferred, DedupMode())
+}
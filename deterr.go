@@ -21,6 +21,10 @@ import (
 	"sync/atomic"
 )
 
+// defaultErrCodeValue is the starting value of defaultErrCode, Reset() also
+// puts it back to this
+const defaultErrCodeValue int32 = 100
+
 var (
 	// defaultErrCode ties into assigning an error code to all errors so if
 	// you aren't using codes (or haven't set them in some err scenarios, which
@@ -28,7 +32,7 @@ var (
 	// which is unlikely).  Anyhow, the pkg will use this default error code
 	// for any error that has no code (mostly internal, if this is an errors
 	// code it will not be shown typically)
-	defaultErrCode int32 = 100
+	defaultErrCode = defaultErrCodeValue
 )
 
 // DetailedError (interface) exposes additional information about a BaseError.
@@ -91,7 +95,7 @@ type BaseError struct {
 // the DetailedError mechanism.  If not then don't worry about it.  Please
 // pass in an int32 (the starting default is 100).
 func DefaultErrCode() int32 {
-	return defaultErrCode
+	return atomic.LoadInt32(&defaultErrCode)
 }
 
 // SetDefaultErrCode can change the default error code so if you want your
@@ -155,7 +159,7 @@ func Code(err interface{}) int {
 		code := 0
 		for detErr != nil {
 			code = detErr.Code()
-			if code != 0 && code != int(defaultErrCode) {
+			if code != 0 && code != int(DefaultErrCode()) {
 				break
 			}
 			i := detErr.Inner()
@@ -169,11 +173,11 @@ func Code(err interface{}) int {
 			}
 		}
 		if code == 0 {
-			code = int(defaultErrCode)
+			code = int(DefaultErrCode())
 		}
 		return code
 	default:
-		return int(defaultErrCode)
+		return int(DefaultErrCode())
 	}
 }
 
@@ -205,7 +209,7 @@ func (e *BaseError) Stack() string {
 // that functionality (vs. this being called via "detErr.Code()")
 func (e *BaseError) Code() int {
 	if e.code == 0 {
-		e.code = int(defaultErrCode)
+		e.code = int(DefaultErrCode())
 	}
 	return e.code
 }
@@ -220,6 +224,37 @@ func (e *BaseError) Inner() error {
 	return e.inner
 }
 
+// Unwrap returns the wrapped error (if there is one) so the Go 1.13+ errors
+// pkg routines see through a BaseError, eg: errors.Is(detErr, io.EOF) is true
+// if io.EOF is wrapped at any depth and errors.As(detErr, &pathErr) finds a
+// wrapped *os.PathError (or a *BaseError or DetailedError, which is the error
+// itself or the next one down the chain)
+func (e *BaseError) Unwrap() error {
+	return e.inner
+}
+
+// Is adds error code matching to errors.Is(), ie: a DetailedError target with
+// an error code set (not 0 or the default error code) matches any error in the
+// chain with the same code, eg: with a sentinel of:
+//
+//	var ErrNoRepo = out.NewErr("no repo found", 2001)
+//
+// errors.Is(err, ErrNoRepo) is true for a WrapErr(..., 2001) error anywhere in
+// the chain, not just for ErrNoRepo itself.  This is like the code matching of
+// IsError().  Targets without a code only match themselves (errors.Is() does
+// that comparison, and the walk down the wrapped errors, itself).
+func (e *BaseError) Is(target error) bool {
+	detErr, ok := target.(DetailedError)
+	if !ok {
+		return false
+	}
+	code := detErr.Code()
+	if code == 0 || code == int(DefaultErrCode()) {
+		return false
+	}
+	return e.code == code
+}
+
 // LvlOut returns the currently configured output level struct
 func (e *BaseError) LvlOut() *LvlOutput {
 	if e.lvlOut == nil {
@@ -232,7 +267,7 @@ func (e *BaseError) LvlOut() *LvlOutput {
 // that error level out must be ISSUE, ERROR or FATAL otherwise this will
 // revert to using ERROR as the output level for this error
 func (e *BaseError) SetLvlOut(lvlOut *LvlOutput) {
-	if lvlOut.level < LevelIssue {
+	if lvlOut.level.Severity() < LevelIssue {
 		e.lvlOut = ERROR
 	} else {
 		e.lvlOut = lvlOut
@@ -354,6 +389,45 @@ func fillErrorInfo(err error, shallow bool, errLines *[]string, origStack *strin
 	//TESTING: verify the shallow functionality, add tests
 }
 
+// fieldsError is an optional interface for errors (eg: your own DetailedError
+// implementation) that carry structured key/value data, see the routine
+// detailedErrorFields() for how those fields make it into the output
+type fieldsError interface {
+	Fields() map[string]interface{}
+}
+
+// detailedErrorFields returns the structured fields for a DetailedError being
+// output: "err_code" (see Code()) and "stack" (the innermost stack trace, if
+// any) plus the key/values of any error in the chain with a Fields() method
+// (outer errors win if the same key is used at more than one level)
+func detailedErrorFields(detErr DetailedError) map[string]interface{} {
+	fields := make(map[string]interface{})
+	var chain []error
+	for err := error(detErr); err != nil && len(chain) < 500; {
+		chain = append(chain, err)
+		inner, ok := err.(DetailedError)
+		if !ok {
+			break
+		}
+		err = inner.Inner()
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if fe, ok := chain[i].(fieldsError); ok {
+			for k, v := range fe.Fields() {
+				fields[k] = v
+			}
+		}
+	}
+	var errLines []string
+	var origStack string
+	fillErrorInfo(detErr, true, &errLines, &origStack)
+	if origStack != "" {
+		fields["stack"] = origStack
+	}
+	fields["err_code"] = Code(detErr)
+	return fields
+}
+
 // unwrapError returns a wrapped error or nil if there is none.
 func unwrapError(ierr error) (nerr error) {
 	// Internal errors have a well defined bit of context.
@@ -433,7 +507,7 @@ func IsError(err, errConst error, codes ...int) bool {
 	validCodes := make(map[int]bool)
 	if codes != nil {
 		for _, val := range codes {
-			if val != 0 && val != int(defaultErrCode) {
+			if val != 0 && val != int(DefaultErrCode()) {
 				validCodes[val] = true
 			}
 		}
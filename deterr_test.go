@@ -19,7 +19,10 @@ package out
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"strings"
 	"syscall"
@@ -176,6 +179,9 @@ func (e databaseError) Message() string {
 	return fmt.Sprintf(e.msg, e.code, e.extra)
 }
 func (e databaseError) Stack() string { return e.stack }
+func (e databaseError) Fields() map[string]interface{} {
+	return map[string]interface{}{"extra": e.extra, "db_code": e.code}
+}
 func (e databaseError) Code() int {
 	if e.code == 0 {
 		e.code = 100
@@ -285,3 +291,70 @@ func TestRootError(t *testing.T) {
 		t.Fatalf("expected ECONNREFUSED on valid nested error: %T %v", err, err)
 	}
 }
+
+// fieldsCapture is a Formatter that records the structured fields in the
+// metadata of each message formatted and leaves the message itself alone
+type fieldsCapture struct {
+	fields []map[string]interface{}
+}
+
+func (f *fieldsCapture) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	f.fields = append(f.fields, mdata.Fields)
+	return msg, ForBoth, 0, false
+}
+
+func TestDetailedErrorFields(t *testing.T) {
+	dbError := newDatabaseError("database error %d [%d]", 1205, -1)
+	outerError := WrapErr(dbError, "outer msg", 300)
+
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	capture := &fieldsCapture{}
+	SetFormatter(LevelAll, capture)
+	Error(outerError)
+	Errorln("no detailed error here")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	if !assert.Equal(t, 2, len(capture.fields)) {
+		return
+	}
+	fields := capture.fields[0]
+	assert.Equal(t, 300, fields["err_code"])
+	assert.Equal(t, -1, fields["extra"])
+	assert.Equal(t, 1205, fields["db_code"])
+	assert.Contains(t, fields["stack"], "out.TestDetailedErrorFields")
+	assert.Nil(t, capture.fields[1])
+	assert.NotContains(t, screenBuf.String(), "Stack Trace")
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	// a sentinel error wrapped several layers deep
+	pathErr := &os.PathError{Op: "open", Path: "/no/such", Err: io.EOF}
+	loadErr := WrapErrf(WrapErr(pathErr, "read config"), 2001, "load %s", "cfg")
+	err := WrapErr(loadErr, "startup")
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.False(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Equal(t, loadErr, errors.Unwrap(err))
+
+	var foundPathErr *os.PathError
+	if assert.True(t, errors.As(err, &foundPathErr)) {
+		assert.Equal(t, "/no/such", foundPathErr.Path)
+	}
+	var detErr DetailedError
+	if assert.True(t, errors.As(err, &detErr)) {
+		assert.Equal(t, "startup", detErr.Message())
+	}
+
+	// DetailedError sentinels with an error code match that code at any depth
+	errNoCfg := NewErr("no config", 2001)
+	errOther := NewErr("other", 2002)
+	errNoCode := NewErr("no code")
+	assert.True(t, errors.Is(err, errNoCfg))
+	assert.False(t, errors.Is(err, errOther))
+	assert.False(t, errors.Is(err, errNoCode))
+	assert.True(t, errors.Is(errNoCode, errNoCode))
+	assert.False(t, errors.Is(WrapErr(pathErr, "plain"), errNoCode))
+}
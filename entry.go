@@ -0,0 +1,280 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fields is a set of key/value pairs (structured fields) to attach to output,
+// see WithFields() and Entry
+type Fields map[string]interface{}
+
+// Entry is a logrus style set of fields to attach to output, meant to make
+// moving from logrus (github.com/sirupsen/logrus) mostly a search and replace
+// of the import, eg:
+//   out.WithFields(out.Fields{"user": name, "attempt": 3}).Warn("login failed")
+//   entry := out.WithField("request", reqID)
+//   entry.Infof("handling %s", path)
+// The logrus levels map to this pkg's levels like so:
+//   Trace -> LevelTrace, Debug -> LevelDebug, Info|Print -> LevelInfo,
+//   Warn|Warning -> LevelIssue, Error -> LevelError, Fatal -> LevelFatal,
+//   Panic -> LevelError (and then a panic, as with logrus)
+// The fields are added to the structured fields in the output's metadata (so
+// formatters and metadata aware writers, eg: JSON or journald output, get
+// them) and are also added to the end of the message text as key=value pairs
+// (sorted by key, like logrus's text formatter) for plain text output.  The
+// usual thresholds, prefixes, flags and such all apply.  Entries are never
+// modified once created (WithField() and such return a new Entry) so they
+// can be shared across goroutines.
+type Entry struct {
+	// Data holds the fields of the entry, don't modify it directly
+	Data Fields
+}
+
+// WithField returns an Entry with the given field set, see Entry
+func WithField(key string, value interface{}) *Entry {
+	return &Entry{Data: Fields{key: value}}
+}
+
+// WithFields returns an Entry with the given fields set, see Entry
+func WithFields(fields Fields) *Entry {
+	return (&Entry{}).WithFields(fields)
+}
+
+// WithError returns an Entry with the "error" field set to the given error,
+// see Entry
+func WithError(err error) *Entry {
+	return WithField("error", err)
+}
+
+// WithField returns a new Entry with the fields of this Entry plus the given
+// field (which replaces any field with the same key)
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+	return e.WithFields(Fields{key: value})
+}
+
+// WithFields returns a new Entry with the fields of this Entry plus the given
+// fields (which replace any fields with the same keys)
+func (e *Entry) WithFields(fields Fields) *Entry {
+	data := make(Fields, len(e.Data)+len(fields))
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return &Entry{Data: data}
+}
+
+// WithError returns a new Entry with the "error" field set to the given error
+func (e *Entry) WithError(err error) *Entry {
+	return e.WithField("error", err)
+}
+
+// text returns the message with the entry's fields added (before any ending
+// newline) as key=value pairs, values with spaces (or empty) are quoted
+func (e *Entry) text(msg string) string {
+	if len(e.Data) == 0 {
+		return msg
+	}
+	body := strings.TrimSuffix(msg, "\n")
+	var pairs []string
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := fmt.Sprint(e.Data[k])
+		if val == "" || strings.ContainsAny(val, " \t\n\"=") {
+			val = fmt.Sprintf("%q", val)
+		}
+		pairs = append(pairs, k+"="+val)
+	}
+	if body != "" {
+		body += " "
+	}
+	body += strings.Join(pairs, " ")
+	if len(body) != len(msg) && strings.HasSuffix(msg, "\n") {
+		body += "\n"
+	}
+	return body
+}
+
+// entryOutput sends an Entry's message (fields already added to the text) to
+// the screen and/or logfile, the args are checked for a detailed error
+func (o *LvlOutput) entryOutput(terminal bool, exitVal int, msg string, fields Fields, v []interface{}) {
+	detErrs := getAnyDetailedErrors(v...)
+	var detErr DetailedError
+	if detErrs != nil {
+		detErr = detErrs[0]
+	}
+	_, err := o.stringOutput(msg, terminal, exitVal, ForBoth, "", fields, 0, detErr)
+	if err != nil {
+		outputFailed(err)
+	}
+}
+
+// Trace is like Trace() with the entry's fields added, see Entry
+func (e *Entry) Trace(args ...interface{}) {
+	TRACE.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Tracef is like Tracef() with the entry's fields added, see Entry
+func (e *Entry) Tracef(format string, args ...interface{}) {
+	TRACE.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Traceln is like Traceln() with the entry's fields added, see Entry
+func (e *Entry) Traceln(args ...interface{}) {
+	TRACE.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Debug is like Debug() with the entry's fields added, see Entry
+func (e *Entry) Debug(args ...interface{}) {
+	DEBUG.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Debugf is like Debugf() with the entry's fields added, see Entry
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	DEBUG.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Debugln is like Debugln() with the entry's fields added, see Entry
+func (e *Entry) Debugln(args ...interface{}) {
+	DEBUG.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Info is like Info() with the entry's fields added, see Entry
+func (e *Entry) Info(args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Infof is like Infof() with the entry's fields added, see Entry
+func (e *Entry) Infof(format string, args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Infoln is like Infoln() with the entry's fields added, see Entry
+func (e *Entry) Infoln(args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Print is like Print() with the entry's fields added, see Entry
+func (e *Entry) Print(args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Printf is like Printf() with the entry's fields added, see Entry
+func (e *Entry) Printf(format string, args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Println is like Println() with the entry's fields added, see Entry
+func (e *Entry) Println(args ...interface{}) {
+	INFO.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Warn is like Issue() with the entry's fields added, see Entry
+func (e *Entry) Warn(args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Warnf is like Issuef() with the entry's fields added, see Entry
+func (e *Entry) Warnf(format string, args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Warnln is like Issueln() with the entry's fields added, see Entry
+func (e *Entry) Warnln(args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Warning is like Issue() with the entry's fields added, see Entry
+func (e *Entry) Warning(args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Warningf is like Issuef() with the entry's fields added, see Entry
+func (e *Entry) Warningf(format string, args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Warningln is like Issueln() with the entry's fields added, see Entry
+func (e *Entry) Warningln(args ...interface{}) {
+	ISSUE.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Error is like Error() with the entry's fields added, see Entry
+func (e *Entry) Error(args ...interface{}) {
+	ERROR.entryOutput(false, 0, e.text(fmt.Sprint(renderArgs(args)...)), e.Data, args)
+}
+
+// Errorf is like Errorf() with the entry's fields added, see Entry
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	ERROR.entryOutput(false, 0, e.text(fmt.Sprintf(format, args...)), e.Data, args)
+}
+
+// Errorln is like Errorln() with the entry's fields added, see Entry
+func (e *Entry) Errorln(args ...interface{}) {
+	ERROR.entryOutput(false, 0, e.text(fmt.Sprintln(renderArgs(args)...)), e.Data, args)
+}
+
+// Fatal is like Fatal() with the entry's fields added, it exits after the
+// output
+func (e *Entry) Fatal(args ...interface{}) {
+	msg := e.text(fmt.Sprint(renderArgs(args)...))
+	FATAL.entryOutput(true, int(ErrorExitVal()), msg, e.Data, args)
+}
+
+// Fatalf is like Fatalf() with the entry's fields added, it exits after the
+// output
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	msg := e.text(fmt.Sprintf(format, args...))
+	FATAL.entryOutput(true, int(ErrorExitVal()), msg, e.Data, args)
+}
+
+// Fatalln is like Fatalln() with the entry's fields added, it exits after the
+// output
+func (e *Entry) Fatalln(args ...interface{}) {
+	msg := e.text(fmt.Sprintln(renderArgs(args)...))
+	FATAL.entryOutput(true, int(ErrorExitVal()), msg, e.Data, args)
+}
+
+// Panic is like Error() with the entry's fields added, after the output it
+// panics with the message (as logrus does)
+func (e *Entry) Panic(args ...interface{}) {
+	msg := e.text(fmt.Sprint(renderArgs(args)...))
+	ERROR.entryOutput(false, 0, msg, e.Data, args)
+	panic(msg)
+}
+
+// Panicf is like Errorf() with the entry's fields added, after the output it
+// panics with the message (as logrus does)
+func (e *Entry) Panicf(format string, args ...interface{}) {
+	msg := e.text(fmt.Sprintf(format, args...))
+	ERROR.entryOutput(false, 0, msg, e.Data, args)
+	panic(msg)
+}
+
+// Panicln is like Errorln() with the entry's fields added, after the output it
+// panics with the message (as logrus does)
+func (e *Entry) Panicln(args ...interface{}) {
+	msg := e.text(fmt.Sprintln(renderArgs(args)...))
+	ERROR.entryOutput(false, 0, msg, e.Data, args)
+	panic(msg)
+}
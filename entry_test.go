@@ -0,0 +1,51 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/entry.go
+//   Checks the logrus style Entry API adds fields to the text output and
+//   to the structured fields, and maps the levels as documented.
+
+package out
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestEntry(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	capture := &fieldsCapture{}
+	SetFormatter(LevelIssue, capture)
+
+	entry := WithField("request", 42)
+	entry.Infoln("handling request")
+	entry.WithFields(Fields{"user": "bob smith", "attempt": 3}).Warnf("login failed\n")
+	WithError(errors.New("disk full")).Error("save failed")
+	Println()
+	assert.Equal(t, Fields{"request": 42}, entry.Data)
+	assert.Panics(t, func() { WithField("k", "v").Panicln("giving up") })
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "handling request request=42\n"+
+		"Issue: login failed attempt=3 request=42 user=\"bob smith\"\n"+
+		"Error: save failed error=\"disk full\"\n"+
+		"Error: giving up k=v\n", screenBuf.String())
+	assert.Equal(t, []map[string]interface{}{{"attempt": 3, "request": 42, "user": "bob smith"}}, capture.fields)
+}
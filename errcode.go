@@ -0,0 +1,94 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// errorCodeNames maps error codes to their names (map[int]string), see
+	// RegisterErrorCode(), it's replaced (not changed) when a name is added
+	// so it can be read without locking
+	errorCodeNames   atomic.Value
+	errorCodeNamesMu sync.Mutex
+
+	// showErrorCodeNames, if non-zero (the default), means the name of an
+	// error code is added after the code in the prefix, eg: "Error #42(DiskFull): "
+	showErrorCodeNames int32 = 1
+)
+
+// RegisterErrorCode gives an error code (see DetailedError) a name that is
+// shown after the code in the output prefix, eg: for an error with code 42
+// "Error #42: disk full" becomes "Error #42(DiskFull): disk full" after:
+//
+//	out.RegisterErrorCode(42, "DiskFull")
+//
+// which helps when going through logs.  An empty name removes the name for
+// the code.  The codes without a name are shown as before, see the routine
+// SetShowErrorCodeNames() to leave the names out of the prefix.
+func RegisterErrorCode(code int, name string) {
+	errorCodeNamesMu.Lock()
+	defer errorCodeNamesMu.Unlock()
+	old, _ := errorCodeNames.Load().(map[int]string)
+	names := make(map[int]string, len(old)+1)
+	for c, n := range old {
+		names[c] = n
+	}
+	if name == "" {
+		delete(names, code)
+	} else {
+		names[code] = name
+	}
+	errorCodeNames.Store(names)
+}
+
+// ErrorCodeName returns the name registered for the error code via the
+// RegisterErrorCode() routine, "" if it has none
+func ErrorCodeName(code int) string {
+	names, _ := errorCodeNames.Load().(map[int]string)
+	return names[code]
+}
+
+// ShowErrorCodeNames returns true if the registered error code names are
+// shown in the prefix, see SetShowErrorCodeNames()
+func ShowErrorCodeNames() bool {
+	return atomic.LoadInt32(&showErrorCodeNames) != 0
+}
+
+// SetShowErrorCodeNames controls if the name of an error code (see the routine
+// RegisterErrorCode()) is added after the code in the prefix, by default it
+// is, eg: "Error #42(DiskFull): ", set to false for just "Error #42: "
+func SetShowErrorCodeNames(show bool) {
+	val := int32(0)
+	if show {
+		val = 1
+	}
+	atomic.StoreInt32(&showErrorCodeNames, val)
+}
+
+// errorCodeLabel returns the error code as it goes in the prefix, ie: the
+// code with the name after it (if there is one and names are shown)
+func errorCodeLabel(code int) string {
+	label := strconv.Itoa(code)
+	if !ShowErrorCodeNames() {
+		return label
+	}
+	if name := ErrorCodeName(code); name != "" {
+		label += "(" + name + ")"
+	}
+	return label
+}
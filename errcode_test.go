@@ -0,0 +1,69 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/errcode.go
+//   Checks registered error code names show up in the prefix (and only for
+//   codes with a name), can be turned off and are safe to use concurrently.
+
+package out
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestErrorCodeNames(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	RegisterErrorCode(42, "DiskFull")
+	Error(NewErr("disk full\n", 42))
+	Issue(NewErr("unnamed\n", 43))
+	SetShowErrorCodeNames(false)
+	Error(NewErr("names off\n", 42))
+	SetShowErrorCodeNames(true)
+	name := ErrorCodeName(42)
+	RegisterErrorCode(42, "")
+	Error(NewErr("name removed\n", 42))
+	removedName := ErrorCodeName(42)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(code int) {
+			defer wg.Done()
+			RegisterErrorCode(code, "Code"+strconv.Itoa(code))
+			_ = ErrorCodeName(code)
+		}(1000 + i)
+	}
+	wg.Wait()
+	concurrentName := ErrorCodeName(1005)
+	for i := 0; i < 10; i++ {
+		RegisterErrorCode(1000+i, "")
+	}
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Error #42(DiskFull): disk full\n"+
+		"Issue #43: unnamed\n"+
+		"Error #42: names off\n"+
+		"Error #42: name removed\n", screenBuf.String())
+	assert.Equal(t, "DiskFull", name)
+	assert.Equal(t, "", removedName)
+	assert.Equal(t, "Code1005", concurrentName)
+}
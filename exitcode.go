@@ -0,0 +1,72 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"sync/atomic"
+)
+
+var (
+	// accumulateExitCode is the exit code that becomes pending when output
+	// at or above accumulateExitLevel happens (0 means the feature is off),
+	// see SetAccumulateExitCode()
+	accumulateExitCode int32
+
+	// accumulateExitLevel is the lowest level that sets the pending exit code
+	accumulateExitLevel = int32(LevelIssue)
+
+	// pendingExitCode is the exit code a tool should use at its natural end
+	pendingExitCode int32
+)
+
+// SetAccumulateExitCode is for the "report all the problems then fail at the
+// end" pattern (eg: a linter or build tool).  Once set to a non-zero code any
+// output at or above the accumulate level (LevelIssue by default, see the
+// SetAccumulateExitLevel() routine) makes that code pending but processing
+// carries on, at the end the tool exits via:
+//
+//	out.Exit(out.PendingExitCode())
+//
+// Setting this to 0 turns accumulation off (any pending code is kept, see
+// ClearPendingExitCode()).  The code is set whether or not the message is
+// actually shown on the screen or in the logfile.
+func SetAccumulateExitCode(code int) {
+	atomic.StoreInt32(&accumulateExitCode, int32(code))
+}
+
+// SetAccumulateExitLevel sets the lowest output level that makes the code
+// from SetAccumulateExitCode() pending, eg: LevelError to ignore issues
+func SetAccumulateExitLevel(level Level) {
+	atomic.StoreInt32(&accumulateExitLevel, int32(levelCheck(level)))
+}
+
+// PendingExitCode returns the exit code made pending by output at or above
+// the accumulate level since accumulation was set up, 0 if there was none
+func PendingExitCode() int {
+	return int(atomic.LoadInt32(&pendingExitCode))
+}
+
+// ClearPendingExitCode resets the pending exit code back to 0
+func ClearPendingExitCode() {
+	atomic.StoreInt32(&pendingExitCode, 0)
+}
+
+// accumulateExit marks the accumulate exit code as pending if it is in use
+// and output at the given level qualifies
+func accumulateExit(level Level) {
+	code := atomic.LoadInt32(&accumulateExitCode)
+	if code != 0 && level != LevelDiscard && int32(level) >= atomic.LoadInt32(&accumulateExitLevel) {
+		atomic.StoreInt32(&pendingExitCode, code)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// exitFunc holds the func used to exit the tool (a func(int)), os.Exit() if
+// it isn't set, see SetExitFunc()
+var exitFunc atomic.Value
+
+// SetExitFunc sets the func used to exit the tool after fatal (or other
+// dying) output, an Exit() call or a failed write, by default os.Exit().  A
+// server or test that must never end the process can give a func that does
+// nothing, or a host tool can turn fatals into recoverable panics, eg:
+//
+//	out.SetExitFunc(func(code int) { panic(fmt.Sprintf("exit %d", code)) })
+//
+// The cleanup before exiting (the defer func, exit summary and such) is done
+// before the func is called.  Note that the PKG_OUT_NO_EXIT env set to "1"
+// (and CaptureOutput()) still means there is no exit at all, ie: the func
+// isn't called.  Passing nil puts os.Exit() back.
+func SetExitFunc(fn func(int)) {
+	if fn == nil {
+		fn = os.Exit
+	}
+	exitFunc.Store(fn)
+}
+
+// exitTool exits the tool with the given exit value via the exit func (see
+// SetExitFunc()) unless exiting is turned off, see exitAllowed()
+func exitTool(exitVal int) {
+	if !exitAllowed() {
+		return
+	}
+	fn, _ := exitFunc.Load().(func(int))
+	if fn == nil {
+		fn = os.Exit
+	}
+	fn(exitVal)
+}
@@ -0,0 +1,63 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/exitfunc.go
+//   Checks fatals, Exit() and such exit via the func from SetExitFunc(), a
+//   panicking exit func can be recovered from and PKG_OUT_NO_EXIT still wins.
+
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetExitFunc(t *testing.T) {
+	// other tests leave the env override set, exits must be allowed here
+	t.Setenv("PKG_OUT_NO_EXIT", "0")
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(0)
+	var codes []int
+	SetExitFunc(func(code int) { codes = append(codes, code) })
+	Fatalln("fatal")
+	Exit(3)
+	Exit(0)
+
+	// a panic based exit func turns the fatal into something recoverable
+	SetExitFunc(func(code int) { panic(fmt.Sprintf("exit %d", code)) })
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		Errorln("recoverable")
+		Exit(4)
+	}()
+	Println("still going")
+
+	// the env override means no exit at all
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	assert.NotPanics(t, func() { Exit(5) })
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, []int{int(ErrorExitVal()), 3, 0}, codes)
+	assert.Equal(t, "exit 4", recovered)
+	assert.Equal(t, "Fatal: fatal\nError: recoverable\nstill going\n", screenBuf.String())
+}
@@ -0,0 +1,156 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The formats available for the exit summary, see SetExitSummary():
+const (
+	ExitSummaryJSON = iota // A single line JSON object
+	ExitSummaryText        // A single line of key=value text
+)
+
+// ExitSummary is the record written at exit if SetExitSummary() is used
+type ExitSummary struct {
+	Counts          map[string]int64 `json:"counts"`             // messages per level, eg: "ERROR": 2
+	HighestSeverity string           `json:"highest_severity"`   // highest level with output, "" if none
+	DurationSecs    float64          `json:"duration_secs"`      // time since the pkg was loaded
+	ExitValue       int              `json:"exit_value"`         // the exit value being used
+	LogFile         string           `json:"log_file,omitempty"` // log file name, if any
+}
+
+var (
+	// levelCounts counts the messages output at each level (whether or not
+	// they passed the thresholds), indexed by level
+	levelCounts [LevelDiscard]int64
+
+	// startTime is when the pkg was loaded, close enough to the start of
+	// the run for the exit summary and the Lreltime flag
+	startTime = time.Now()
+
+	// exitSummaryMu protects exitSummaryWriter and exitSummaryFormat
+	exitSummaryMu     sync.Mutex
+	exitSummaryWriter io.Writer
+	exitSummaryFormat int
+)
+
+// countOutput notes a message at the given level for the exit summary
+func countOutput(level Level) {
+	if countCustomOutput(level) {
+		return
+	}
+	if level >= LevelTrace && level < LevelDiscard {
+		atomic.AddInt64(&levelCounts[level], 1)
+	}
+}
+
+// SetExitSummary has a final record summarizing the run written to the given
+// writer when the tool exits via this pkg (ie: out.Exit(), including Exit(0),
+// or a Fatal), after any defer func (see SetDeferFunc()) runs, eg: so a CI
+// system can decide pass/fail and show error counts:
+//   out.SetExitSummary(os.Stderr, out.ExitSummaryJSON)
+// With ExitSummaryJSON it looks like (on one line):
+//   {"counts":{"ERROR":2,"INFO":31},"highest_severity":"ERROR",
+//    "duration_secs":12.5,"exit_value":1,"log_file":"/tmp/tool.log"}
+// and with ExitSummaryText:
+//   exit_value=1 highest_severity=ERROR duration_secs=12.500 ERROR=2 INFO=31 log_file=/tmp/tool.log
+// The counts include all messages at each level (even those below the screen
+// and logfile thresholds), the duration is from when this pkg was loaded.
+// Use a nil writer to turn the summary off (the default).
+func SetExitSummary(w io.Writer, format int) {
+	exitSummaryMu.Lock()
+	defer exitSummaryMu.Unlock()
+	exitSummaryWriter = w
+	exitSummaryFormat = format
+}
+
+// CurrentExitSummary returns the summary that would be written at exit if
+// the tool exited now with the given exit value, see SetExitSummary()
+func CurrentExitSummary(exitVal int) ExitSummary {
+	summary := ExitSummary{
+		Counts:       make(map[string]int64),
+		DurationSecs: time.Since(startTime).Seconds(),
+		ExitValue:    exitVal,
+		LogFile:      LogFileName(),
+	}
+	highest := Level(-1)
+	for level := LevelTrace; level < LevelDiscard; level++ {
+		if count := atomic.LoadInt64(&levelCounts[level]); count != 0 {
+			summary.Counts[level.String()] = count
+			summary.HighestSeverity = level.String()
+			highest = level
+		}
+	}
+	for _, custom := range customLevelCounts() {
+		summary.Counts[custom.name] = atomic.LoadInt64(custom.count)
+		if custom.severity > highest {
+			summary.HighestSeverity = custom.name
+			highest = custom.severity
+		}
+	}
+	return summary
+}
+
+// String returns the summary as a single line of key=value text
+func (s ExitSummary) String() string {
+	fields := []string{
+		fmt.Sprintf("exit_value=%d", s.ExitValue),
+		fmt.Sprintf("highest_severity=%s", s.HighestSeverity),
+		fmt.Sprintf("duration_secs=%.3f", s.DurationSecs),
+	}
+	for level := LevelFatal; level >= LevelTrace; level-- {
+		if count, ok := s.Counts[level.String()]; ok {
+			fields = append(fields, fmt.Sprintf("%s=%d", level, count))
+		}
+	}
+	var custom []string
+	for name, count := range s.Counts {
+		if _, ok := builtinLevels[name]; !ok {
+			custom = append(custom, fmt.Sprintf("%s=%d", name, count))
+		}
+	}
+	sort.Strings(custom)
+	fields = append(fields, custom...)
+	if s.LogFile != "" {
+		fields = append(fields, "log_file="+s.LogFile)
+	}
+	return strings.Join(fields, " ")
+}
+
+// writeExitSummary writes the exit summary, if one was asked for, errors are
+// ignored as we're on our way out
+func writeExitSummary(exitVal int) {
+	exitSummaryMu.Lock()
+	defer exitSummaryMu.Unlock()
+	if exitSummaryWriter == nil {
+		return
+	}
+	summary := CurrentExitSummary(exitVal)
+	if exitSummaryFormat == ExitSummaryText {
+		fmt.Fprintln(exitSummaryWriter, summary)
+		return
+	}
+	if record, err := json.Marshal(summary); err == nil {
+		fmt.Fprintf(exitSummaryWriter, "%s\n", record)
+	}
+}
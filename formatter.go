@@ -29,7 +29,8 @@ package out
 // The return data is essentially:
 //	msg (string): the update message or just the passed in msg if no updates
 //	applyMask (int): which output stream will the returned message be used for:
-//	                 ForLogfile, ForScreen, ForBoth or 0 (meaning neither)
+//	                 ForLogfile, ForScreen, ForBoth or 0 (meaning neither),
+//	                 FormatterOwnsNewlines can be or'd in (see below)
 //	supressOut (int): 0 if not suppressing any output otherwise, if set, one
 //	supressNativePrefixing (bool): timestamps and prefixes are still applied
 // to the result of a Formatter unless this is set to true, then not applied
@@ -60,6 +61,17 @@ package out
 // can screen, if desired, just like all output coming through 'out'... if you
 // don't want it you can see when your tool is in JSON mode and flip that stuff
 // all off, no problem, well before getting here).
+//
+// Formatters producing framed, length-prefixed or JSON records may not want
+// the newline handling done for line-oriented output getting in the way, if
+// FormatterOwnsNewlines is or'd into the applyMask then, for the targets in
+// the mask, the returned message is written exactly as given: the message is
+// not checked for a trailing newline (so the newline state of the target, see
+// ResetNewline(), is left alone and a record not ending in a newline won't
+// cause the next message's prefix to be skipped), no newline is added when
+// dying and any prefixes and flags (unless suppressed) are put on the start
+// of each line without regard to how the previous output ended.  Stack
+// traces, if configured for the target, are still written after the message.
 type Formatter interface {
 	// This returns the error message without the stack trace.
 	FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool)
@@ -69,29 +81,15 @@ type Formatter interface {
 // can be pre-formatted (or cleared) before being dumped to the screen/logfile,
 // see the description of the Formatter interface.
 func SetFormatter(level Level, formatter Formatter) {
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		if level == LevelAll || o.level == level {
-			o.formatter = formatter
-			if o.level == level {
-				break
-			}
-		}
-	}
+	std.SetFormatter(level, formatter)
 }
 
+// FormatterOwnsNewlines can be or'd into the applyMask returned by a Formatter
+// to have the message written exactly as formatted, see Formatter above
+const FormatterOwnsNewlines = 1 << 8
+
 // ClearFormatter clears the formatters on a given level or all levels
 // if the LevelAll level is used.
 func ClearFormatter(level Level) {
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		if level == LevelAll || o.level == level {
-			o.formatter = nil
-			if o.level == level {
-				break
-			}
-		}
-	}
+	std.ClearFormatter(level)
 }
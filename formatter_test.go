@@ -22,9 +22,12 @@ package out
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dvln/testify/assert"
 )
@@ -33,6 +36,7 @@ type killScreenOut struct{}
 type replaceMsg struct{}
 type detectDying struct{}
 type logOnlyFormatMsg struct{}
+type jsonRecords struct{}
 
 // FormatMessage in this context is to test the formatting "feature" of
 // the 'out' package.  In this case we're suppressing all screen output
@@ -76,6 +80,16 @@ func (f detectDying) FormatMessage(msg string, outLevel Level, code int, dying b
 	return msg, applyMask, suppressOutputMask, suppressNativePrefixing
 }
 
+// FormatMessage in this context is to test a formatter that owns newline
+// handling, each message becomes a JSON record with no trailing newline
+func (f jsonRecords) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	record, _ := json.Marshal(map[string]interface{}{"level": outLevel.String(), "msg": msg, "dying": dying})
+	applyMask := ForBoth | FormatterOwnsNewlines
+	suppressOutputMask := 0
+	suppressNativePrefixing := true
+	return string(record), applyMask, suppressOutputMask, suppressNativePrefixing
+}
+
 func TestFormatter(t *testing.T) {
 	// Aside: if you want to see nested error messages one could create errors
 	// something like this for each level (ie: extend DetailedError with your
@@ -194,3 +208,92 @@ func TestFormatter(t *testing.T) {
 	// func will operate sanely as if we're coming in fresh
 	ResetOutPkg()
 }
+
+func TestFormatterOwnsNewlines(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFormatter(LevelNote, jsonRecords{})
+	SetFormatter(LevelFatal, jsonRecords{})
+	SetStackTraceConfig(0)
+
+	Noteln("first")
+	Noteln("second")
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	Fatal("giving up")
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+	ClearFormatter(LevelAll)
+	Issueln("plain output is still prefixed")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, `{"dying":false,"level":"NOTE","msg":"first\n"}`+
+		`{"dying":false,"level":"NOTE","msg":"second\n"}`+
+		`{"dying":true,"level":"FATAL","msg":"giving up"}`+
+		"Issue: plain output is still prefixed\n", screenBuf.String())
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logfileBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logfileBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForScreen)
+	SetStackTraceConfig(0)
+	SetFormatter(LevelAll, LogfmtFormatter{TimeFormat: "2006"})
+
+	Errorln("no such \"file\"")
+	WithFields(Fields{"user id": "bob", "count": 3}).Info("done")
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	Fatal("giving up")
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	// The screen is left alone, the logfile gets logfmt records
+	assert.Contains(t, screenBuf.String(), "Error: no such \"file\"\n")
+	lines := strings.Split(strings.TrimSuffix(logfileBuf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 3) {
+		year := fmt.Sprint(time.Now().Year())
+		assert.Regexp(t, `^ts=`+year+` level=error file=formatter_test.go:\d+ func=TestLogfmtFormatter msg="no such \\"file\\""$`, lines[0])
+		assert.Regexp(t, ` level=info .* msg="done .*" count=3 user_id=bob$`, lines[1])
+		assert.Regexp(t, ` level=fatal .* msg="giving up" fatal=true$`, lines[2])
+	}
+	assert.Equal(t, `""`, logfmtValue(""))
+	assert.Equal(t, `"a=b"`, logfmtValue("a=b"))
+	assert.Equal(t, `"tab\there"`, logfmtValue("tab\there"))
+	assert.Equal(t, "plain", logfmtValue("plain"))
+}
+
+func TestCLFFormatter(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logfileBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logfileBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForScreen)
+	SetFormatter(LevelInfo, CLFFormatter{})
+
+	WithFields(Fields{"remote": "127.0.0.1", "authuser": "bob", "method": "GET", "path": "/index.html",
+		"proto": "HTTP/1.1", "status": 200, "bytes": 2326}).Info("served")
+	WithFields(Fields{"request": `GET /a"b`, "status": 404}).Info("not found")
+	Println("no fields")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	// The screen is left alone, the logfile gets access log lines
+	assert.Contains(t, screenBuf.String(), "served")
+	lines := strings.Split(strings.TrimSuffix(logfileBuf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 3) {
+		date := `\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\]`
+		assert.Regexp(t, `^127\.0\.0\.1 - bob `+date+` "GET /index\.html HTTP/1\.1" 200 2326$`, lines[0])
+		assert.Regexp(t, `^- - - `+date+` "GET /a\\"b" 404 -$`, lines[1])
+		assert.Regexp(t, `^- - - `+date+` "-" - -$`, lines[2])
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// groupIndentWidth is the number of spaces output is indented per open group
+const groupIndentWidth = 2
+
+// groupDepth is the number of open groups, see Group()
+var groupDepth int32
+
+// GroupDepth returns the number of groups currently open, see Group()
+func GroupDepth() int {
+	return int(atomic.LoadInt32(&groupDepth))
+}
+
+// Group outputs the title (at the Info level) and then indents all further
+// output by two more spaces until the matching GroupEnd(), so related output
+// can be bracketed, eg:
+//
+//	out.Group("Building")
+//	out.Infoln("compiling pkg a")  // shows as "  compiling pkg a"
+//	out.GroupEnd()
+//
+// Groups nest.  When running under GitHub Actions (the GITHUB_ACTIONS env is
+// "true") the outermost group is written as "::group::<title>" fold markers
+// on the screen so the group is collapsible in the workflow log (the title
+// still goes to the logfile as usual), nested groups are only indented as
+// the UI doesn't nest them.  Note that the group depth is global, not per
+// goroutine, so groups opened from concurrent goroutines indent each other's
+// output, bracket output from a single goroutine (the usual case for CLI
+// progress and CI logs).
+func Group(title string) {
+	title = strings.TrimSuffix(title, "\n")
+	if atomic.LoadInt32(&groupDepth) == 0 && githubActions() {
+		writeGroupMarker("::group::" + title + "\n")
+		INFO.outputln(false, 0, ForLogfile, title)
+	} else {
+		INFO.outputln(false, 0, ForBoth, title)
+	}
+	atomic.AddInt32(&groupDepth, 1)
+}
+
+// GroupEnd closes the most recent Group() so output is indented two spaces
+// less, extra calls are tolerated (the depth never goes below zero)
+func GroupEnd() {
+	for {
+		depth := atomic.LoadInt32(&groupDepth)
+		if depth == 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&groupDepth, depth, depth-1) {
+			if depth == 1 && githubActions() {
+				writeGroupMarker("::endgroup::\n")
+			}
+			return
+		}
+	}
+}
+
+// githubActions returns true if running under GitHub Actions
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// writeGroupMarker writes a CI fold marker as is (no prefix, flags or such)
+// to the Info level's screen writer
+func writeGroupMarker(marker string) {
+	INFO.mu.RLock()
+	hndl := INFO.screenHndl
+	INFO.mu.RUnlock()
+	mutex.Lock()
+	_, err := fmt.Fprint(hndl, marker)
+	mutex.Unlock()
+	if err != nil {
+		outputFailed(err)
+	}
+}
+
+// groupIndent returns the indentation for the open groups, empty if none
+func groupIndent() string {
+	return strings.Repeat(" ", int(atomic.LoadInt32(&groupDepth))*groupIndentWidth)
+}
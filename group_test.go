@@ -0,0 +1,77 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/group.go
+//   Checks Group() and GroupEnd() indent the output between them, nest, clamp
+//   extra GroupEnd() calls and write GitHub Actions fold markers.
+
+package out
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	origGHA, hadGHA := os.LookupEnv("GITHUB_ACTIONS")
+	os.Unsetenv("GITHUB_ACTIONS")
+	defer func() {
+		if hadGHA {
+			os.Setenv("GITHUB_ACTIONS", origGHA)
+		}
+	}()
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+
+	Group("Building")
+	Infoln("compiling a\ncompiling b")
+	Group("Tests")
+	Noteln("3 skipped")
+	GroupEnd()
+	depth := GroupDepth()
+	GroupEnd()
+	GroupEnd() // extra, ignored
+	Infoln("done")
+	plain := screenBuf.String()
+	logfile := logBuf.String()
+	screenBuf.Reset()
+	logBuf.Reset()
+
+	os.Setenv("GITHUB_ACTIONS", "true")
+	Group("Building")
+	Infoln("compiling")
+	Group("Nested")
+	Infoln("deeper")
+	GroupEnd()
+	GroupEnd()
+	folded := screenBuf.String()
+	foldedLog := logBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 1, depth)
+	assert.Equal(t, "Building\n  compiling a\n  compiling b\n  Tests\nNote:     3 skipped\ndone\n", plain)
+	assert.Equal(t, plain, logfile)
+	assert.Equal(t, "::group::Building\n  compiling\n  Nested\n    deeper\n::endgroup::\n", folded)
+	assert.Equal(t, "Building\n  compiling\n  Nested\n    deeper\n", foldedLog)
+	assert.Equal(t, 0, GroupDepth())
+}
@@ -0,0 +1,102 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// hook is a callback added via AddHook()
+type hook struct {
+	minLevel Level
+	fn       func(level Level, msg string, meta FlagMetadata)
+}
+
+var (
+	// hooks holds the []hook added via AddHook() in registration order, it's
+	// replaced (not changed) when a hook is added so it can be read without
+	// locking
+	hooks   atomic.Value
+	hooksMu sync.Mutex
+
+	// hookRunners holds the ids of the goroutines running hooks right now,
+	// output from within a hook doesn't fire the hooks again
+	hookRunners sync.Map
+)
+
+// AddHook registers a callback that is called for each message written (to
+// the screen and/or logfile) at or above the given level, eg: to bump a
+// metrics counter or post errors to a chat channel without writing a full
+// Formatter:
+//
+//	out.AddHook(out.LevelError, func(level out.Level, msg string, meta out.FlagMetadata) {
+//		errorCounter.Inc()
+//	})
+//
+// Hooks run synchronously in the goroutine doing the output, after the write
+// (and before exiting for a Fatal), in the order they were added, so keep
+// them quick.  The msg is the message without the prefix or flag metadata,
+// the meta has the time, level, caller info and any stack trace and fields.
+// Hooks may use this pkg for output but that output won't fire any hooks
+// (no recursion), and no 'out' locks are held while a hook runs.  Output
+// below the thresholds or otherwise suppressed doesn't fire the hooks.
+func AddHook(minLevel Level, fn func(level Level, msg string, meta FlagMetadata)) {
+	if fn == nil {
+		return
+	}
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	old, _ := hooks.Load().([]hook)
+	newHooks := make([]hook, len(old), len(old)+1)
+	copy(newHooks, old)
+	hooks.Store(append(newHooks, hook{minLevel: minLevel, fn: fn}))
+}
+
+// ClearHooks removes all the hooks added via AddHook(), eg: between tests
+func ClearHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks.Store([]hook(nil))
+}
+
+// hooksWanted returns the hooks that want output at the given level, nil if
+// there are none (the common case)
+func hooksWanted(level Level) []hook {
+	all, _ := hooks.Load().([]hook)
+	if len(all) == 0 || level == LevelDiscard {
+		return nil
+	}
+	var wanted []hook
+	for _, h := range all {
+		if level.Severity() >= h.minLevel.Severity() {
+			wanted = append(wanted, h)
+		}
+	}
+	return wanted
+}
+
+// runHooks calls the given hooks with the message written, unless the
+// calling goroutine is already running hooks (ie: the output came from a
+// hook)
+func runHooks(wanted []hook, level Level, msg string, mdata FlagMetadata) {
+	gid := goroutineID()
+	if _, running := hookRunners.LoadOrStore(gid, true); running {
+		return
+	}
+	defer hookRunners.Delete(gid)
+	for _, h := range wanted {
+		h.fn(level, msg, mdata)
+	}
+}
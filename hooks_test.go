@@ -0,0 +1,89 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/hooks.go
+//   Checks hooks added via AddHook() run in order for written messages at or
+//   above their level, that output from a hook doesn't fire the hooks again
+//   and that ClearHooks() removes them.
+
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	SetStackTraceConfig(0)
+	var calls []string
+	var meta FlagMetadata
+	AddHook(LevelWarn, func(level Level, msg string, mdata FlagMetadata) {
+		calls = append(calls, fmt.Sprintf("first %s %q", level, msg))
+		meta = mdata
+		Noteln("from a hook")
+	})
+	AddHook(LevelInfo, func(level Level, msg string, mdata FlagMetadata) {
+		calls = append(calls, fmt.Sprintf("second %s %q", level, msg))
+	})
+	Debugln("below the threshold")
+	Println("info")
+	Warnln("warning")
+	hookCalls := calls
+	hookMeta := meta
+	screenOutput := screenBuf.String()
+	ClearHooks()
+	calls = nil
+	Warnln("no hooks")
+	clearedCalls := calls
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, []string{
+		`second INFO "info\n"`,
+		`first WARN "warning\n"`,
+		`second WARN "warning\n"`,
+	}, hookCalls)
+	assert.Equal(t, "WARN", hookMeta.Level)
+	assert.Equal(t, "hooks_test.go", hookMeta.File)
+	assert.Equal(t, "info\nWarning: warning\nNote: from a hook\n", screenOutput)
+	assert.Nil(t, clearedCalls)
+}
+
+func TestHookLazyStack(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	SetLazyStackTrace(true)
+	AddRedaction("TestHookLazyStack")
+	var stack string
+	AddHook(LevelIssue, func(level Level, msg string, mdata FlagMetadata) {
+		stack = mdata.Stack
+	})
+	Issueln("disk full")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Contains(t, stack, "hooks_test.go", "the hook gets a symbolized stack")
+	assert.NotContains(t, stack, lazyStackMarker)
+	assert.NotContains(t, stack, "TestHookLazyStack", "and a redacted one")
+}
@@ -0,0 +1,57 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/host.go
+//   Checks the Lhost flag puts the (padded) host name in the output metadata,
+//   that it can be turned on via the "host" flag string and that the host is
+//   in the metadata given to formatters.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestHostFlag(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Lhost|Llevel, ForScreen)
+	SetHostNameLength(int32(len(hostName) + 3))
+	Println("hello")
+	paddedOutput := screenBuf.String()
+	screenBuf.Reset()
+	SetHostNameLength(0)
+	Println("again")
+	unpaddedOutput := screenBuf.String()
+	hostFlags := determineFlags("host,level")
+	var mdata FlagMetadata
+	SetPrefixFunc(LevelNote, func(level Level, meta FlagMetadata) string {
+		mdata = meta
+		return "Note: "
+	})
+	Noteln("note")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.NotEqual(t, "", hostName)
+	assert.Equal(t, hostName+"    INFO    hello\n", paddedOutput)
+	assert.Equal(t, hostName+" INFO    again\n", unpaddedOutput)
+	assert.Equal(t, Lhost|Llevel, hostFlags)
+	assert.Equal(t, hostName, mdata.Host)
+	assert.Equal(t, int32(12), HostNameLength())
+}
@@ -0,0 +1,181 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// journalSocket is where systemd's journald listens for native protocol
+// datagrams (see systemd.journal-fields(7) and sd_journal_sendv(3))
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalWriter is an io.Writer (and metadataWriter) that ships each write
+// to journald as a single journal entry using the native protocol so that
+// the level and caller details land in structured journal fields
+type journalWriter struct {
+	mu    sync.Mutex
+	conn  *net.UnixConn
+	ident string
+}
+
+// SetJournald points the logfile output stream for every level at the
+// systemd journal.  Each message is sent via the journald native protocol
+// with these fields: MESSAGE, PRIORITY (mapped from the output level),
+// SYSLOG_IDENTIFIER (the tool name) and CODE_FILE, CODE_LINE and CODE_FUNC
+// (from the same metadata used for the file/func flags).  As the journal
+// records its own timestamp, pid and such the logfile flags are cleared for
+// all levels (use SetFlags() after this if you want some of them back).
+// Note: as with SetLogFile() remember to set a logfile threshold so that
+// something is actually logged, eg: SetThreshold(LevelInfo, ForLogfile)
+func SetJournald() error {
+	raddr := &net.UnixAddr{Name: journalSocket, Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, raddr)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to journald socket %s: %v", journalSocket, err)
+	}
+	jw := &journalWriter{conn: conn, ident: filepath.Base(os.Args[0])}
+	for _, o := range outputters {
+		o.mu.Lock()
+		o.logfileHndl = jw
+		o.logFlags = 0
+		o.mu.Unlock()
+	}
+	return nil
+}
+
+// journalPriority maps an output level to a syslog(3) style priority as
+// used by the journald PRIORITY field
+func journalPriority(level Level) int {
+	level = level.Severity()
+	switch {
+	case level <= LevelDebug:
+		return 7 // LOG_DEBUG
+	case level <= LevelInfo:
+		return 6 // LOG_INFO
+	case level == LevelNote:
+		return 5 // LOG_NOTICE
+	case level == LevelWarn || level == LevelIssue:
+		return 4 // LOG_WARNING
+	case level == LevelError:
+		return 3 // LOG_ERR
+	default:
+		return 2 // LOG_CRIT
+	}
+}
+
+// appendJournalField adds a KEY=value pair to a native protocol datagram,
+// values with newlines in them must use the length prefixed binary form
+func appendJournalField(b []byte, key, value string) []byte {
+	if !strings.ContainsRune(value, '\n') {
+		b = append(b, key...)
+		b = append(b, '=')
+		b = append(b, value...)
+		return append(b, '\n')
+	}
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b = append(b, key...)
+	b = append(b, '\n')
+	b = append(b, size[:]...)
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+// journalFieldName turns a structured field key into a valid journal field
+// name (uppercase letters, digits and '_', not starting with '_' as those
+// are trusted fields set by journald itself), eg: "err_code" -> "ERR_CODE"
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	return strings.TrimLeft(string(name), "_0123456789")
+}
+
+// WriteMetadata sends one journal entry for the given output, note that
+// whitespace only writes (eg: the newline added on dying) are dropped as
+// they would only produce empty journal entries
+func (jw *journalWriter) WriteMetadata(p []byte, level Level, mdata *FlagMetadata) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if strings.TrimSpace(msg) == "" {
+		return len(p), nil
+	}
+	var b []byte
+	b = appendJournalField(b, "MESSAGE", msg)
+	b = appendJournalField(b, "PRIORITY", strconv.Itoa(journalPriority(level)))
+	b = appendJournalField(b, "SYSLOG_IDENTIFIER", jw.ident)
+	if mdata.File != "" {
+		b = appendJournalField(b, "CODE_FILE", filepath.Join(mdata.Path, mdata.File))
+		b = appendJournalField(b, "CODE_LINE", strconv.Itoa(mdata.LineNo))
+	}
+	if mdata.Func != "" {
+		b = appendJournalField(b, "CODE_FUNC", mdata.Func)
+	}
+	for _, key := range sortedFieldKeys(mdata.Fields) {
+		if name := journalFieldName(key); name != "" {
+			b = appendJournalField(b, name, fmt.Sprint(mdata.Fields[key]))
+		}
+	}
+
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	_, err := jw.conn.Write(b)
+	if err != nil && (errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		err = jw.sendViaFile(b)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write satisfies the io.Writer interface, without level info the entry is
+// sent at the info priority
+func (jw *journalWriter) Write(p []byte) (int, error) {
+	return jw.WriteMetadata(p, LevelInfo, &FlagMetadata{})
+}
+
+// sendViaFile handles entries too large for a single datagram, the protocol
+// allows passing an (unlinked) file descriptor holding the entry instead
+func (jw *journalWriter) sendViaFile(b []byte) error {
+	dir := "/dev/shm"
+	if _, err := os.Stat(dir); err != nil {
+		dir = os.TempDir()
+	}
+	file, err := ioutil.TempFile(dir, "journal.")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	os.Remove(file.Name())
+	if _, err = file.Write(b); err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(file.Fd()))
+	_, _, err = jw.conn.WriteMsgUnix(nil, rights, nil)
+	return err
+}
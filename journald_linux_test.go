@@ -0,0 +1,82 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/journald_linux.go
+//   Checks the journald native protocol encoding by pointing the journal
+//   writer at a fake journald socket of our own.
+
+package out
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestJournalFieldEncoding(t *testing.T) {
+	b := appendJournalField(nil, "MESSAGE", "simple")
+	assert.Equal(t, "MESSAGE=simple\n", string(b))
+
+	b = appendJournalField(nil, "MESSAGE", "two\nlines")
+	assert.Equal(t, "MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n", string(b))
+
+	assert.Equal(t, 7, journalPriority(LevelTrace))
+	assert.Equal(t, 6, journalPriority(LevelInfo))
+	assert.Equal(t, 4, journalPriority(LevelIssue))
+	assert.Equal(t, 2, journalPriority(LevelFatal))
+
+	assert.Equal(t, "ERR_CODE", journalFieldName("err_code"))
+	assert.Equal(t, "REQUEST_ID", journalFieldName("_request-id"))
+}
+
+func TestJournalWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sockName := filepath.Join(dir, "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockName, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unable to create unixgram socket: %v", err)
+	}
+	defer server.Close()
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sockName, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to dial fake journald socket: %v", err)
+	}
+	jw := &journalWriter{conn: conn, ident: "mytool"}
+	SetWriter(LevelAll, jw, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetThreshold(LevelDiscard, ForScreen)
+
+	Issueln("disk is nearly full")
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	ResetOutPkg()
+	if err != nil {
+		t.Fatalf("Failed to read journal entry: %v", err)
+	}
+	entry := string(buf[:n])
+	assert.Contains(t, entry, "MESSAGE=Issue: disk is nearly full\n")
+	assert.Contains(t, entry, "PRIORITY=4\n")
+	assert.Contains(t, entry, "SYSLOG_IDENTIFIER=mytool\n")
+	assert.Contains(t, entry, "journald_linux_test.go\n")
+	assert.Contains(t, entry, "CODE_FUNC=github.com/dvln/out.TestJournalWriter\n")
+}
@@ -0,0 +1,27 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package out
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetJournald is only available on linux (systemd), here it just returns
+// an unsupported error and leaves the logfile output stream alone
+func SetJournald() error {
+	return fmt.Errorf("journald output is not supported on %s", runtime.GOOS)
+}
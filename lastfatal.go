@@ -0,0 +1,118 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// FatalInfo is a structured snapshot of the most recent Error or Fatal level
+// message, suitable for serializing into a JSON API response (eg: a web
+// handler whose request triggered an out.Error() can return a clean 500).
+type FatalInfo struct {
+	Message  string       `json:"message"`
+	Level    string       `json:"level"`
+	Code     int          `json:"code"`
+	Dying    bool         `json:"dying"`
+	Stack    string       `json:"stack,omitempty"`
+	Metadata FlagMetadata `json:"metadata"`
+}
+
+var (
+	// lastFatals holds the latest *FatalInfo for each goroutine (keyed by
+	// the goroutine id) so concurrent requests don't see each others errors
+	lastFatals sync.Map
+
+	// captureLastFatal, if non-zero, means errors and fatals are captured
+	// for LastFatal(), see SetCaptureLastFatal() (off by default)
+	captureLastFatal int32
+)
+
+// CaptureLastFatal returns true if errors and fatals are being captured for
+// LastFatal(), see SetCaptureLastFatal()
+func CaptureLastFatal() bool {
+	return atomic.LoadInt32(&captureLastFatal) != 0
+}
+
+// SetCaptureLastFatal turns on (or off) the capture of the latest Error or
+// Fatal level message of each goroutine for LastFatal(), it's off by default
+// as every captured message costs a goroutine id lookup and is kept until
+// ClearLastFatal() is called.  Turning the capture off forgets all the
+// captured messages.
+func SetCaptureLastFatal(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&captureLastFatal, val)
+	if !on {
+		lastFatals.Range(func(key, value interface{}) bool {
+			lastFatals.Delete(key)
+			return true
+		})
+	}
+}
+
+// LastFatal returns the most recent Error or Fatal level message emitted by
+// the calling goroutine (nil if there hasn't been one or the capture is off,
+// see SetCaptureLastFatal()).  The capture happens whether or not the message
+// passed the screen/logfile thresholds.  Note: as these are kept per
+// goroutine (and goroutine ids aren't reused), long lived servers should
+// ClearLastFatal() when done with a request (eg: via defer in the handler) so
+// that entries don't pile up for goroutines that have gone away.
+func LastFatal() *FatalInfo {
+	gid := goroutineID()
+	if val, ok := lastFatals.Load(gid); ok {
+		info := val.(*FatalInfo)
+		if strings.Contains(info.Stack, lazyStackMarker) {
+			// a lazy stack trace, symbolize it now that someone wants it
+			symbolized := *info
+			symbolized.Stack = SymbolizeStack(info.Stack)
+			symbolized.Metadata.Stack = symbolized.Stack
+			lastFatals.Store(gid, &symbolized)
+			info = &symbolized
+		}
+		return info
+	}
+	return nil
+}
+
+// ClearLastFatal forgets any error/fatal captured for the calling goroutine
+func ClearLastFatal() {
+	lastFatals.Delete(goroutineID())
+}
+
+// recordFatal stashes the given error/fatal details for the current goroutine
+func recordFatal(info *FatalInfo) {
+	lastFatals.Store(goroutineID(), info)
+}
+
+// goroutineID parses the current goroutine's id out of the header line
+// of a stack trace ("goroutine 18 [running]:"), a bit of a hack as Go
+// purposely doesn't expose goroutine ids but it's cheap and stable
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,118 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// lazyStackMarker starts (and ends) an unsymbolized stack trace token, the
+// token holds the goroutine id and the raw program counters, eg:
+//   "\x00lazystack:18:4a1b2c,4a1b3d,...\x00"
+const lazyStackMarker = "\x00lazystack:"
+
+// lazyStackMaxDepth is the most frames captured for a lazy stack trace
+const lazyStackMaxDepth = 64
+
+// lazyStackTrace, if non-zero, means stack traces are captured as raw
+// program counters and only symbolized if actually output
+var lazyStackTrace int32
+
+// LazyStackTrace returns true if stack traces are captured lazily, see the
+// SetLazyStackTrace() routine
+func LazyStackTrace() bool {
+	return atomic.LoadInt32(&lazyStackTrace) != 0
+}
+
+// SetLazyStackTrace can be used to defer the expensive part of stack traces,
+// by default the stack trace for Issue, Error and Fatal level output is fully
+// gathered (file, func and line# for each frame) when the output happens in
+// case it is needed (see SetStackTraceConfig()).  With this set to true only
+// the program counters are captured (cheap) and they are only symbolized
+// into file/func/line# info if the trace is actually written to the screen
+// or logfile (or when LastFatal() is used).  For error heavy services where
+// most traces are never read this saves quite a bit of time.  Notes:
+// - lazy traces have the goroutine id and one "func(...)" and "file:line#"
+// line pair per frame (no args or "created by" info like the regular ones)
+// - formatters get the raw token in FlagMetadata.Stack, if a formatter uses
+// the stack it should run it through SymbolizeStack() first
+// - traces from detailed errors (see deterr.go) are already gathered when the
+// error is created so they aren't effected by this setting
+func SetLazyStackTrace(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&lazyStackTrace, val)
+}
+
+// lazyStack captures the program counters of the current goroutine's stack
+// and returns them as a token for SymbolizeStack(), skip is the number of
+// frames to skip relative to the caller of this routine
+func lazyStack(skip int) string {
+	var pcs [lazyStackMaxDepth]uintptr
+	if StackTraceTrimInternal() {
+		skip = 0 // the internal frames are left out when symbolized
+	}
+	n := runtime.Callers(skip+2, pcs[:])
+	b := make([]byte, 0, len(lazyStackMarker)+n*8+24)
+	b = append(b, lazyStackMarker...)
+	b = strconv.AppendUint(b, goroutineID(), 10)
+	b = append(b, ':')
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(pcs[i]), 16)
+	}
+	b = append(b, 0)
+	return string(b)
+}
+
+// SymbolizeStack turns any lazy stack trace token in the given string (see
+// SetLazyStackTrace()) into a readable stack trace, strings without a token
+// are returned as-is so this is safe to use on any FlagMetadata.Stack value
+func SymbolizeStack(s string) string {
+	for {
+		start := strings.Index(s, lazyStackMarker)
+		if start == -1 {
+			return s
+		}
+		tokenLen := strings.IndexByte(s[start+len(lazyStackMarker):], 0)
+		if tokenLen == -1 {
+			return s
+		}
+		end := start + len(lazyStackMarker) + tokenLen
+		s = s[:start] + symbolizeToken(s[start+len(lazyStackMarker):end]) + s[end+1:]
+	}
+}
+
+// symbolizeToken takes the body of a lazy stack token ("<gid>:<pc>,<pc>,..")
+// and returns a trace in roughly the same form as the regular stack traces
+func symbolizeToken(token string) string {
+	gid := token
+	var pcs []uintptr
+	if i := strings.IndexByte(token, ':'); i != -1 {
+		gid = token[:i]
+		for _, hexPC := range strings.Split(token[i+1:], ",") {
+			if pc, err := strconv.ParseUint(hexPC, 16, 64); err == nil {
+				pcs = append(pcs, uintptr(pc))
+			}
+		}
+	}
+	return formatStackFrames(gid, callerFrames(pcs))
+}
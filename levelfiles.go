@@ -0,0 +1,202 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// levelFile is the io.Writer used for a file set up via SetLevelFiles(), it
+// is shared by all the levels that map to the same path and allows the file
+// to be reopened or rotated without touching the levels' handles
+type levelFile struct {
+	lock sync.Mutex
+	path string
+	fp   *os.File
+}
+
+var (
+	// levelFilesMu protects levelFiles
+	levelFilesMu sync.Mutex
+
+	// levelFiles are the files opened by SetLevelFiles(), keyed by path
+	levelFiles = make(map[string]*levelFile)
+)
+
+// Write satisfies the io.Writer interface
+func (f *levelFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.fp == nil {
+		return 0, &os.PathError{Op: "write", Path: f.path, Err: os.ErrClosed}
+	}
+	return f.fp.Write(p)
+}
+
+// open (re)opens the file in append mode, closing any existing handle first,
+// if rotate is true the existing file is renamed with a timestamp suffix
+// before it is reopened (same naming as RotateWriter)
+func (f *levelFile) open(rotate bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.fp != nil {
+		err := f.fp.Close()
+		f.fp = nil
+		if err != nil {
+			return err
+		}
+	}
+	if rotate {
+		if _, err := os.Stat(f.path); err == nil {
+			if err = os.Rename(f.path, f.path+"."+time.Now().Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+	}
+	fp, err := os.OpenFile(f.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	f.fp = fp
+	return nil
+}
+
+// close closes the file, further writes will fail until it is reopened
+func (f *levelFile) close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.fp == nil {
+		return nil
+	}
+	err := f.fp.Close()
+	f.fp = nil
+	return err
+}
+
+// SetLevelFiles sends the logfile output for each level in the given map to
+// its own file, eg: to put errors in one file and debug output in another:
+//   err := out.SetLevelFiles(map[out.Level]string{
+//       out.LevelError: "/var/log/mytool/errors.log",
+//       out.LevelFatal: "/var/log/mytool/errors.log",
+//       out.LevelAll:   "/var/log/mytool/mytool.log",
+//   })
+//   out.SetThreshold(out.LevelDebug, out.ForLogfile)
+// Levels that map to the same path share a single open file handle.  If
+// LevelAll is in the map its file is used for every level not otherwise in
+// the map, else levels not in the map are left alone.  Files are opened in
+// append mode (created if needed), files from a previous SetLevelFiles() call
+// that are no longer used are closed.  Note that the logfile threshold still
+// applies, see SetThreshold().  See ReopenLevelFiles(), RotateLevelFiles()
+// and CloseLevelFiles() for managing the files after this.  If any file can't
+// be opened an error is returned and no levels are changed.
+func SetLevelFiles(files map[Level]string) error {
+	levelFilesMu.Lock()
+	defer levelFilesMu.Unlock()
+	newFiles := make(map[string]*levelFile)
+	for level, path := range files {
+		if level != LevelAll && levelCheck(level) != level {
+			return fmt.Errorf("invalid level %d given for log file %s", level, path)
+		}
+		if _, ok := newFiles[path]; ok {
+			continue
+		}
+		f, ok := levelFiles[path]
+		if !ok {
+			f = &levelFile{path: path}
+			if err := f.open(false); err != nil {
+				for p, nf := range newFiles {
+					if _, existing := levelFiles[p]; !existing {
+						nf.close()
+					}
+				}
+				return err
+			}
+		}
+		newFiles[path] = f
+	}
+	for _, o := range outputters {
+		path, ok := files[o.level]
+		if !ok {
+			path, ok = files[LevelAll]
+		}
+		if ok {
+			SetWriter(o.level, newFiles[path], ForLogfile)
+		}
+	}
+	var closeErr error
+	for path, f := range levelFiles {
+		if _, ok := newFiles[path]; !ok {
+			if err := f.close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+	}
+	levelFiles = newFiles
+	return closeErr
+}
+
+// ReopenLevelFiles closes and reopens the files set up by SetLevelFiles(),
+// for use after an external tool (eg: logrotate) has moved the files aside
+// (typically done when the tool gets a SIGHUP)
+func ReopenLevelFiles() error {
+	return reopenLevelFiles(false)
+}
+
+// RotateLevelFiles renames each file set up by SetLevelFiles() with a time
+// stamp suffix (like RotateWriter does) and starts a fresh file in its place
+func RotateLevelFiles() error {
+	return reopenLevelFiles(true)
+}
+
+// reopenLevelFiles reopens (and optionally rotates) all the level files, the
+// first error is returned but all files are tried
+func reopenLevelFiles(rotate bool) error {
+	levelFilesMu.Lock()
+	defer levelFilesMu.Unlock()
+	var firstErr error
+	for _, f := range levelFiles {
+		if err := f.open(rotate); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// CloseLevelFiles closes the files set up by SetLevelFiles() and points the
+// logfile output for the levels that were using them at ioutil.Discard, it
+// is meant to be used when shutting down (eg: via a defer in main())
+func CloseLevelFiles() error {
+	levelFilesMu.Lock()
+	defer levelFilesMu.Unlock()
+	for _, o := range outputters {
+		o.mu.RLock()
+		f, ok := o.logfileHndl.(*levelFile)
+		o.mu.RUnlock()
+		if ok && levelFiles[f.path] == f {
+			SetWriter(o.level, ioutil.Discard, ForLogfile)
+		}
+	}
+	var firstErr error
+	for _, f := range levelFiles {
+		if err := f.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	levelFiles = make(map[string]*levelFile)
+	return firstErr
+}
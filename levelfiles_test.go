@@ -0,0 +1,84 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/levelfiles.go
+//   Checks that per level log files are shared, reopened and closed right.
+
+package out
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestLevelFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "levelfiles")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	errorsLog := filepath.Join(dir, "errors.log")
+	debugLog := filepath.Join(dir, "debug.log")
+	mainLog := filepath.Join(dir, "main.log")
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelDebug, ForLogfile)
+	err = SetLevelFiles(map[Level]string{
+		LevelError: errorsLog,
+		LevelFatal: errorsLog,
+		LevelDebug: debugLog,
+		LevelAll:   mainLog,
+	})
+	assert.Nil(t, err)
+	assert.True(t, Writer(LevelError, ForLogfile) == Writer(LevelFatal, ForLogfile), "same path should share a handle")
+	assert.True(t, Writer(LevelInfo, ForLogfile) == Writer(LevelNote, ForLogfile))
+
+	Debugln("debugging")
+	Println("info")
+	Errorln("oops")
+
+	// move the errors file aside and reopen, new output goes to a new file
+	os.Rename(errorsLog, errorsLog+".old")
+	assert.Nil(t, ReopenLevelFiles())
+	Errorln("oops again")
+
+	// a file missing from a later call is closed, others are reused
+	mainWriter := Writer(LevelInfo, ForLogfile)
+	assert.Nil(t, SetLevelFiles(map[Level]string{LevelInfo: mainLog}))
+	assert.True(t, mainWriter == Writer(LevelInfo, ForLogfile))
+	_, err = Writer(LevelDebug, ForLogfile).Write([]byte("closed"))
+	assert.NotNil(t, err)
+
+	assert.Nil(t, CloseLevelFiles())
+	assert.Equal(t, ioutil.Discard, Writer(LevelInfo, ForLogfile))
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	contents := func(path string) string {
+		b, _ := ioutil.ReadFile(path)
+		return string(b)
+	}
+	if traceEnabled {
+		assert.Equal(t, "Debug: debugging\n", contents(debugLog))
+	}
+	assert.Equal(t, "info\n", contents(mainLog))
+	assert.Equal(t, "Error: oops\n", contents(errorsLog+".old"))
+	assert.Equal(t, "Error: oops again\n", contents(errorsLog))
+}
@@ -0,0 +1,105 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LogfmtFormatter is a Formatter (see SetFormatter()) that writes each message
+// as a logfmt record of key=value pairs, eg: for ops tools like Loki:
+//
+//	ts=2016-01-02T03:04:05.123456Z level=error file=get.go:42 func=get msg="no such file"
+//
+// The record has the time, level, file:line#, short func name and message,
+// followed by fatal=true if the tool is dying, the category (if any) and
+// any structured fields (eg: from an Entry or a DetailedError's err_code)
+// sorted by key.
+// Values with spaces, quotes, '=' or control chars are quoted and escaped.
+// The native prefixes and flags aren't added to the record, by default it
+// applies to the logfile only so the screen output is left as is:
+//
+//	out.SetFormatter(out.LevelAll, out.LogfmtFormatter{})
+type LogfmtFormatter struct {
+	// Target is where the records go: ForLogfile (the default if 0),
+	// ForScreen or ForBoth
+	Target int
+	// TimeFormat is the time.Format() layout for the ts field, the default
+	// (if empty) is time.RFC3339Nano
+	TimeFormat string
+}
+
+// FormatMessage implements the Formatter interface, see LogfmtFormatter
+func (f LogfmtFormatter) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	target := f.Target
+	if target&ForBoth == 0 {
+		target = ForLogfile
+	}
+	layout := f.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	var pairs []string
+	if mdata.Time != nil {
+		pairs = append(pairs, "ts="+logfmtValue(mdata.Time.Format(layout)))
+	}
+	pairs = append(pairs, "level="+logfmtValue(strings.ToLower(outLevel.String())))
+	if mdata.File != "" {
+		pairs = append(pairs, "file="+logfmtValue(fmt.Sprintf("%s:%d", mdata.File, mdata.LineNo)))
+	}
+	if mdata.Func != "" {
+		parts := strings.Split(mdata.Func, ".")
+		pairs = append(pairs, "func="+logfmtValue(parts[len(parts)-1]))
+	}
+	pairs = append(pairs, "msg="+logfmtValue(strings.TrimSuffix(msg, "\n")))
+	if dying {
+		pairs = append(pairs, "fatal=true")
+	}
+	if mdata.Category != "" {
+		pairs = append(pairs, "category="+logfmtValue(mdata.Category))
+	}
+	for _, k := range sortedFieldKeys(mdata.Fields) {
+		pairs = append(pairs, logfmtKey(k)+"="+logfmtValue(fmt.Sprint(mdata.Fields[k])))
+	}
+	return strings.Join(pairs, " ") + "\n", target | FormatterOwnsNewlines, 0, true
+}
+
+// logfmtValue returns the given value quoted and escaped if it needs to be
+// for logfmt, ie: if it is empty or has spaces, quotes, '=' or control chars
+func logfmtValue(val string) string {
+	if val == "" || strings.IndexFunc(val, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == '\\' || unicode.IsControl(r) || !unicode.IsPrint(r)
+	}) != -1 {
+		return strconv.Quote(val)
+	}
+	return val
+}
+
+// logfmtKey returns the given field name usable as a logfmt key, ie: any
+// spaces, quotes, '=' or control chars are replaced with '_'
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '"' || r == '=' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
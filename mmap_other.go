@@ -0,0 +1,37 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !darwin
+
+package out
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetMmapLogFile is only available on linux and darwin, here it just returns
+// an unsupported error and leaves the logfile output stream alone
+func SetMmapLogFile(path string, size int64) error {
+	return fmt.Errorf("memory mapped log files are not supported on %s", runtime.GOOS)
+}
+
+// FlushMmapLogFile does nothing here, memory mapped log files aren't supported
+func FlushMmapLogFile() error {
+	return nil
+}
+
+// CloseMmapLogFile does nothing here, memory mapped log files aren't supported
+func CloseMmapLogFile() error {
+	return nil
+}
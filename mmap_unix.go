@@ -0,0 +1,252 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// MmapFlushInterval is how often a memory mapped log file (see the routine
+// SetMmapLogFile()) is asynchronously flushed to disk if it has been written
+const MmapFlushInterval = time.Second
+
+// mmapFile is the io.Writer used for a memory mapped log file, writes are a
+// copy into the mapped region at the current offset
+type mmapFile struct {
+	lock  sync.Mutex
+	path  string
+	size  int64
+	fp    *os.File
+	data  []byte
+	off   int64
+	dirty bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+var (
+	// mmapMu protects mmapLog
+	mmapMu sync.Mutex
+
+	// mmapLog is the memory mapped log file in use, if any
+	mmapLog *mmapFile
+)
+
+// SetMmapLogFile points the logfile output for all levels at a memory mapped
+// log file for low latency logging, each write is just a copy into memory (no
+// write syscall with the pkg mutex held, which can add jitter in latency
+// sensitive services).  The file is pre-allocated at the given size, when a
+// write won't fit in what is left it is renamed with a time stamp suffix
+// (like RotateWriter does) and a fresh one is started.  The mapped region is
+// flushed to disk in the background (every MmapFlushInterval) and when
+// exiting via this pkg, use the FlushMmapLogFile() routine to flush at other
+// times and CloseMmapLogFile() when shutting down (eg: via a defer in
+// main()), closing also trims the file to what was written.  Until then the
+// file is its full size with the unused part zero filled so tools like
+// "tail -f" won't behave as usual.  Any existing file at the path is renamed
+// aside (as when rolling) first.  Only available on linux and darwin,
+// elsewhere an error is returned.  Note that the logfile threshold still
+// applies, see SetThreshold().
+func SetMmapLogFile(path string, size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid memory mapped log file size %d for %s", size, path)
+	}
+	mf := &mmapFile{path: path, size: size, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := mf.open(); err != nil {
+		return err
+	}
+	mmapMu.Lock()
+	prev := mmapLog
+	mmapLog = mf
+	mmapMu.Unlock()
+	SetWriter(LevelAll, mf, ForLogfile)
+	mutex.Lock()
+	std.logFileName = path
+	std.dailyLog = nil
+	mutex.Unlock()
+	go mf.flusher()
+	if prev != nil {
+		return prev.close()
+	}
+	return nil
+}
+
+// FlushMmapLogFile synchronously flushes the memory mapped log file (if one
+// is in use, see SetMmapLogFile()) to disk
+func FlushMmapLogFile() error {
+	mmapMu.Lock()
+	mf := mmapLog
+	mmapMu.Unlock()
+	if mf == nil {
+		return nil
+	}
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+	return mf.sync(syscall.MS_SYNC)
+}
+
+// CloseMmapLogFile flushes and closes the memory mapped log file (if one is
+// in use, see SetMmapLogFile()), trimming it to what was written, and points
+// the logfile output for the levels using it at ioutil.Discard
+func CloseMmapLogFile() error {
+	mmapMu.Lock()
+	mf := mmapLog
+	mmapLog = nil
+	mmapMu.Unlock()
+	if mf == nil {
+		return nil
+	}
+	for _, o := range outputters {
+		o.mu.RLock()
+		using := o.logfileHndl == mf
+		o.mu.RUnlock()
+		if using {
+			SetWriter(o.level, ioutil.Discard, ForLogfile)
+		}
+	}
+	return mf.close()
+}
+
+// Write satisfies the io.Writer interface, the bytes are copied into the
+// mapped region, rolling to a new file first if they don't fit (writes
+// bigger than the whole region are split across files)
+func (mf *mmapFile) Write(p []byte) (int, error) {
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+	if mf.data == nil {
+		return 0, &os.PathError{Op: "write", Path: mf.path, Err: os.ErrClosed}
+	}
+	if mf.off != 0 && mf.off+int64(len(p)) > mf.size && int64(len(p)) <= mf.size {
+		if err := mf.roll(); err != nil {
+			return 0, err
+		}
+	}
+	written := 0
+	for written < len(p) {
+		if mf.off == mf.size {
+			if err := mf.roll(); err != nil {
+				return written, err
+			}
+		}
+		n := copy(mf.data[mf.off:], p[written:])
+		mf.off += int64(n)
+		written += n
+		mf.dirty = true
+	}
+	return written, nil
+}
+
+// open moves any existing file aside, then creates, sizes and maps the file
+func (mf *mmapFile) open() error {
+	if fi, err := os.Stat(mf.path); err == nil && fi.Size() != 0 {
+		if err = os.Rename(mf.path, mf.path+"."+time.Now().Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+	}
+	fp, err := os.OpenFile(mf.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if err = fp.Truncate(mf.size); err != nil {
+		fp.Close()
+		return err
+	}
+	data, err := syscall.Mmap(int(fp.Fd()), 0, int(mf.size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	mf.fp = fp
+	mf.data = data
+	mf.off = 0
+	mf.dirty = false
+	return nil
+}
+
+// unmap flushes and unmaps the region then trims and closes the file, the
+// first error hit is returned (but all the steps are tried)
+func (mf *mmapFile) unmap() error {
+	if mf.data == nil {
+		return nil
+	}
+	firstErr := mf.sync(syscall.MS_SYNC)
+	if err := syscall.Munmap(mf.data); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	mf.data = nil
+	if err := mf.fp.Truncate(mf.off); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := mf.fp.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	mf.fp = nil
+	return firstErr
+}
+
+// roll finishes off the current (full) file and starts a fresh one
+func (mf *mmapFile) roll() error {
+	if err := mf.unmap(); err != nil {
+		return err
+	}
+	return mf.open()
+}
+
+// sync flushes the mapped region to disk if it has been written since the
+// last flush, flags is syscall.MS_SYNC or syscall.MS_ASYNC
+func (mf *mmapFile) sync(flags int) error {
+	if mf.data == nil || !mf.dirty {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&mf.data[0])), uintptr(len(mf.data)), uintptr(flags))
+	if errno != 0 {
+		return errno
+	}
+	mf.dirty = false
+	return nil
+}
+
+// flusher flushes the region every MmapFlushInterval until closed
+func (mf *mmapFile) flusher() {
+	defer close(mf.done)
+	ticker := time.NewTicker(MmapFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mf.lock.Lock()
+			mf.sync(syscall.MS_ASYNC)
+			mf.lock.Unlock()
+		case <-mf.stop:
+			return
+		}
+	}
+}
+
+// close stops the background flushing and unmaps the file
+func (mf *mmapFile) close() error {
+	close(mf.stop)
+	<-mf.done
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+	return mf.unmap()
+}
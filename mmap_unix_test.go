@@ -0,0 +1,63 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+
+// Package test for: out/mmap_unix.go
+//   Checks output lands in the memory mapped log file, that it rolls to a
+//   new file when full and that closing trims the file to what was written.
+
+package out
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestMmapLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmaplog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	assert.NotNil(t, SetMmapLogFile(logPath, 0))
+	assert.Nil(t, SetMmapLogFile(logPath, 32))
+	assert.Equal(t, logPath, LogFileName())
+	Noteln("first line")         // 17 bytes
+	Noteln("second, rolls over") // 25 bytes, doesn't fit so rolls
+	assert.Nil(t, FlushMmapLogFile())
+	assert.Nil(t, CloseMmapLogFile())
+	Noteln("after close, discarded")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	current, err := ioutil.ReadFile(logPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "Note: second, rolls over\n", string(current))
+	matches, _ := filepath.Glob(logPath + ".*")
+	assert.Equal(t, 1, len(matches))
+	rolled, err := ioutil.ReadFile(matches[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "Note: first line\n", string(rolled))
+	assert.False(t, strings.Contains(string(current), "discarded"))
+}
@@ -0,0 +1,138 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// callerLookups counts the runtime.Caller() lookups done for output, it's
+// only used to show how many lookups a message costs (see the benchmarks)
+var callerLookups int64
+
+// msgMetadata holds the metadata for one message (eg: one Println() call)
+// that is the same for the screen and the logfile, ie: the timestamp and
+// the callers file/line#/func.  It's set up once per message by stringOutput()
+// and shared by the screen and logfile prefixing, formatting and such so
+// the (relatively slow) caller lookup is done once, and only if needed.
+type msgMetadata struct {
+	category string                 // any category the output was tagged with
+	fields   map[string]interface{} // the Outputter's fields, see WithFields()
+	replay   *heldOutput            // held output being replayed, nil if none
+	pc       uintptr                // the callers pc if known up front, else 0
+	now      time.Time              // when the message was output
+	frames   []StackFrame           // the stack trace frames, if any
+
+	resolved bool   // the caller info below has been looked up
+	ok       bool   // the caller lookup worked
+	file     string // full path to the callers file
+	line     int    // callers line#
+	funcName string // callers full func name, empty if unknown
+	goid     int    // the goroutine id, 0 until looked up (see goroutineID())
+
+	prefixSet bool   // the prefix below is set, see funcPrefix()
+	prefix    string // the PrefixFunc's prefix for the message
+}
+
+// newMsgMetadata sets up the shared metadata for a new message, if held
+// output is being replayed its original time and caller info is used
+func newMsgMetadata(category string) *msgMetadata {
+	m := &msgMetadata{category: category, replay: strictReplay()}
+	if m.replay != nil {
+		m.now = *m.replay.meta.Time
+	} else {
+		m.now = time.Now()
+	}
+	return m
+}
+
+// funcPrefix returns the prefix from the level's PrefixFunc for the message,
+// the func is only called once per message so every target (and any stack
+// trace or wrapped lines) gets the same prefix, the depth is as for caller()
+func (m *msgMetadata) funcPrefix(fn PrefixFunc, level Level, depth int) string {
+	if !m.prefixSet {
+		m.prefixSet = true
+		m.prefix = fn(level, m.flagMetadata(level, depth+1))
+	}
+	return m.prefix
+}
+
+// caller returns the callers file, line# and func for the message, looking
+// it up on first use (the depth is relative to the caller of this routine,
+// later calls just return what was found then).  If the lookup fails then
+// ok is false, if the func is unknown it is returned empty.
+func (m *msgMetadata) caller(depth int) (file string, line int, funcName string, ok bool) {
+	if !m.resolved {
+		m.resolved = true
+		if m.replay != nil {
+			m.file = filepath.Join(m.replay.meta.Path, m.replay.meta.File)
+			m.line = m.replay.meta.LineNo
+			m.funcName = m.replay.meta.Func
+			m.ok = m.funcName != ""
+		} else if m.pc != 0 {
+			frame, _ := runtime.CallersFrames([]uintptr{m.pc}).Next()
+			m.file, m.line, m.funcName = frame.File, frame.Line, frame.Function
+			m.ok = frame.File != ""
+		} else {
+			var pc uintptr
+			atomic.AddInt64(&callerLookups, 1)
+			pc, m.file, m.line, m.ok = runtime.Caller(depth + 1)
+			if m.ok {
+				if f := runtime.FuncForPC(pc); f != nil {
+					m.funcName = f.Name()
+				}
+			}
+		}
+	}
+	return m.file, m.line, m.funcName, m.ok
+}
+
+// goroutineID returns the id of the goroutine that did the output (see the
+// Lgoid flag), looking it up on first use as that isn't free, held output
+// being replayed gives the id it was originally output from
+func (m *msgMetadata) goroutineID() int {
+	if m.goid == 0 {
+		if m.replay != nil {
+			m.goid = m.replay.meta.GoID
+		} else {
+			m.goid = int(goroutineID())
+		}
+	}
+	return m.goid
+}
+
+// flagMetadata gathers the basic metadata for the message at the given level
+// (time, pid, level, category and the callers file/line#/func info) without
+// any of the flag or env handling done in insertFlagMetadata(), the depth is
+// relative to the caller of this routine
+func (m *msgMetadata) flagMetadata(level Level, depth int) FlagMetadata {
+	if m.replay != nil {
+		meta := m.replay.meta
+		meta.Level = level.String()
+		return meta
+	}
+	now := m.now
+	meta := FlagMetadata{Time: &now, Level: level.String(), PID: os.Getpid(), Host: hostName, Category: m.category, Fields: m.fields, Frames: m.frames}
+	if file, line, funcName, ok := m.caller(depth + 1); ok {
+		meta.File = filepath.Base(file)
+		meta.Path = filepath.Dir(file)
+		meta.LineNo = line
+		meta.Func = funcName
+	}
+	return meta
+}
@@ -0,0 +1,119 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/msgmeta.go
+//   Checks output to the screen and logfile with caller metadata flags on
+//   against a golden file (the metadata is looked up once per message and
+//   shared by both targets) and benchmarks the metadata lookups.
+
+package out
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update the testdata golden files")
+
+type metaFormatter struct{}
+
+// FormatMessage in this context adds the metadata given to formatters so the
+// golden file shows it matches the native prefixing metadata
+func (f metaFormatter) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	msg = fmt.Sprintf("{%s %s:%d %s %s} %s", mdata.Level, mdata.File, mdata.LineNo, filepath.Base(mdata.Func), mdata.Category, msg)
+	return msg, ForLogfile, 0, false
+}
+
+func TestSharedMetadataGolden(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelVerbose, ForBoth)
+	SetFlags(LevelAll, Llevel|Lshortfile|Lshortfunc|Lcategory, ForScreen)
+	SetFlags(LevelAll, Llevel|Lshortfile|Llongfunc|Lcategory, ForLogfile)
+	SetPrefixFunc(LevelNote, func(level Level, mdata FlagMetadata) string {
+		return fmt.Sprintf("Note(%s:%d): ", mdata.File, mdata.LineNo)
+	})
+	SetFormatter(LevelVerbose, metaFormatter{})
+
+	Println("plain info")
+	Noteln("a note\nacross lines")
+	Print("partial ")
+	Println("line")
+	IssueCatf("net", "tagged %s\n", "issue")
+	Verboseln("formatted for the logfile")
+	WithField("user", "bob").Infoln("with a field")
+	fmt.Fprintf(INFO, "via the io.Writer\n")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	got := "screen:\n" + screenBuf.String() + "logfile:\n" + logBuf.String()
+	golden := filepath.Join("testdata", "msgmeta.golden")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(golden, []byte(got), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), got)
+}
+
+func TestSharedMetadataLookups(t *testing.T) {
+	SetWriter(LevelAll, ioutil.Discard, ForScreen)
+	SetWriter(LevelAll, ioutil.Discard, ForLogfile)
+	SetFlags(LevelAll, LlogfileFlags, ForScreen)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetPrefixFunc(LevelIssue, func(level Level, mdata FlagMetadata) string {
+		return mdata.Func + ": "
+	})
+	SetFormatter(LevelIssue, metaFormatter{})
+	before := atomic.LoadInt64(&callerLookups)
+	Println("one lookup for both targets")
+	afterPrint := atomic.LoadInt64(&callerLookups)
+	Issueln("still one lookup with a prefix func and formatter")
+	afterIssue := atomic.LoadInt64(&callerLookups)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, int64(1), afterPrint-before)
+	assert.Equal(t, int64(1), afterIssue-afterPrint)
+}
+
+func BenchmarkSharedMetadata(b *testing.B) {
+	SetWriter(LevelAll, ioutil.Discard, ForScreen)
+	SetWriter(LevelAll, ioutil.Discard, ForLogfile)
+	SetFlags(LevelAll, LlogfileFlags, ForScreen)
+	SetThreshold(LevelInfo, ForLogfile)
+	before := atomic.LoadInt64(&callerLookups)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Println("benchmark message to the screen and logfile")
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&callerLookups)-before)/float64(b.N), "callers/op")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
@@ -0,0 +1,74 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+)
+
+// Issues outputs a set of errors together as one issue, eg: all the problems
+// found while validating a config file, each error is on its own line (any
+// lines after the first of a multi-line error, eg: the wrapped errors of a
+// DetailedError, are indented under it) and they share the "Issue: " prefix:
+//
+//	Issue: missing "name" setting
+//	Issue: bad "port" value: strconv.Atoi: parsing "x": invalid syntax
+//
+// If any of the errors is a DetailedError the first one with an error code
+// (see Code()) gives the code for the prefix (eg: "Issue #42: ") and its
+// stack trace is the one used (if stack traces are on), as for Issue(detErr)
+// the output is at the issue level.  Nil errors are skipped and if there are
+// no errors left nothing is output.
+func Issues(errs []error) {
+	ISSUE.outputErrors(errs)
+}
+
+// Errors is the same as Issues() but outputs the errors as one error, with
+// the "Error: " prefix
+func Errors(errs []error) {
+	ERROR.outputErrors(errs)
+}
+
+// outputErrors outputs the errors given as one message at this level, see
+// Issues() for details
+func (o *LvlOutput) outputErrors(errs []error) {
+	if o.skipQuietOutput(false) {
+		return
+	}
+	var detErr DetailedError
+	var lines []string
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if currErr, ok := err.(DetailedError); ok {
+			if detErr == nil || (Code(detErr) == int(DefaultErrCode()) && Code(currErr) != int(DefaultErrCode())) {
+				detErr = currErr
+			}
+		}
+		msg := strings.TrimRight(Message(err), "\n")
+		lines = append(lines, strings.Replace(msg, "\n", "\n  ", -1))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if detErr != nil {
+		// as for output(), the error goes out at the level used
+		detErr.SetLvlOut(o)
+	}
+	_, err := o.stringOutput(strings.Join(lines, "\n")+"\n", false, 0, ForBoth, "", nil, 0, detErr)
+	if err != nil {
+		outputFailed(err)
+	}
+}
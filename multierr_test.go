@@ -0,0 +1,61 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/multierr.go
+//   Checks Issues() and Errors() output a set of errors as one message with
+//   the prefix (and any DetailedError code) on every line, aligned.
+
+package out
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestIssuesErrors(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	Issues([]error{
+		errors.New(`missing "name" setting`),
+		nil,
+		WrapErr(errors.New("no such file"), "bad include", 42),
+	})
+	issuesOutput := screenBuf.String()
+	screenBuf.Reset()
+	SetStackTraceConfig(0)
+	Errors([]error{errors.New("first"), errors.New("second\nmore on second")})
+	errorsOutput := screenBuf.String()
+	screenBuf.Reset()
+	Issues(nil)
+	Errors([]error{nil})
+	emptyOutput := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Contains(t, issuesOutput, "Issue #42: missing \"name\" setting\n"+
+		"Issue #42: bad include\n"+
+		"Issue #42:   no such file\n"+
+		"Issue #42: \n"+
+		"Issue #42: Stack Trace: goroutine ")
+	assert.Equal(t, 1, bytes.Count([]byte(issuesOutput), []byte("Stack Trace:")))
+	assert.Equal(t, "Error: first\n"+
+		"Error: second\n"+
+		"Error:   more on second\n", errorsOutput)
+	assert.Equal(t, "", emptyOutput)
+}
@@ -0,0 +1,62 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// newIDFallback is bumped to keep the IDs from NewID() unique in the (very
+// unlikely) case crypto/rand fails
+var newIDFallback uint32
+
+// NewID returns a random 8 hex char ID (eg: "3f9a0c1e"), short enough to
+// read in the output but unique enough to tell requests apart, see the
+// WithNewID() routines which attach one as a field to all output
+func NewID() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		n := uint32(time.Now().UnixNano()) ^ atomic.AddUint32(&newIDFallback, 1)<<16
+		s := strconv.FormatUint(uint64(n), 16)
+		return "00000000"[len(s):] + s
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithNewID returns an Entry with a freshly generated ID (see NewID()) in the
+// given field so all the output from it can be correlated, eg: in request
+// handling middleware:
+//
+//	reqLog := out.WithNewID("reqID")
+//	reqLog.Infof("handling %s", path)   // handling /login reqID=3f9a0c1e
+func WithNewID(fieldName string) *Entry {
+	return WithField(fieldName, NewID())
+}
+
+// WithNewID returns a new Entry with the fields of this Entry plus a freshly
+// generated ID (see NewID()) in the given field
+func (e *Entry) WithNewID(fieldName string) *Entry {
+	return e.WithField(fieldName, NewID())
+}
+
+// WithNewID returns a child of the Outputter (see WithFields()) with a
+// freshly generated ID (see NewID()) in the given field, eg: for a request
+// scoped Outputter whose output all shares one correlatable ID
+func (op *Outputter) WithNewID(fieldName string) *Outputter {
+	return op.WithFields(map[string]interface{}{fieldName: NewID()})
+}
@@ -0,0 +1,53 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/newid.go
+//   Checks NewID() gives distinct 8 hex char IDs and that the WithNewID()
+//   routines attach one ID to all the output of the Entry or Outputter.
+
+package out
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestNewID(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	first, second := NewID(), NewID()
+	reqLog := WithNewID("reqID")
+	reqLog.Infoln("one")
+	reqLog.WithField("user", "bob").Infoln("two")
+	entryID, _ := reqLog.Data["reqID"].(string)
+	chained := WithField("user", "bob").WithNewID("reqID").Data
+	reqOut := NewOutputter().WithNewID("reqID")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	idPattern := regexp.MustCompile(`^[0-9a-f]{8}$`)
+	assert.Regexp(t, idPattern, first)
+	assert.Regexp(t, idPattern, second)
+	assert.NotEqual(t, first, second)
+	assert.Regexp(t, idPattern, entryID)
+	assert.Equal(t, "one reqID="+entryID+"\ntwo reqID="+entryID+" user=bob\n", screenBuf.String())
+	assert.Equal(t, "bob", chained["user"])
+	assert.Regexp(t, idPattern, chained["reqID"])
+	assert.Regexp(t, idPattern, reqOut.fields["reqID"])
+}
@@ -0,0 +1,75 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import "sync"
+
+// onceKeys holds the keys already seen by NoteOnce(), IssueOnce() and such
+var onceKeys sync.Map
+
+// firstOnce returns true the first time the given key is seen (across all
+// the *Once() funcs and goroutines), false after that
+func firstOnce(key string) bool {
+	_, seen := onceKeys.LoadOrStore(key, struct{}{})
+	return !seen
+}
+
+// ResetOnce forgets all keys seen by NoteOnce(), IssueOnce() and such so
+// the next call with any key outputs again (mostly of use in tests)
+func ResetOnce() {
+	onceKeys.Range(func(key, _ interface{}) bool {
+		onceKeys.Delete(key)
+		return true
+	})
+}
+
+// NoteOnce is like Note() but only outputs the first time the given key is
+// seen, later calls with the same key are dropped (eg: in a retry loop use
+// out.NoteOnce("fetch-retry", "retrying fetch of ", url) to get one note
+// instead of hundreds).  The key is explicit so the caller picks how fine
+// grained the dedupe is (eg: add the url to the key to note once per url).
+// Note that the key is used up even if the Note threshold hides the output.
+func NoteOnce(key string, v ...interface{}) {
+	if !firstOnce(key) {
+		return
+	}
+	NOTE.output(false, 0, ForBoth, v...)
+}
+
+// WarnOnce is like Warn() but only outputs the first time the given key is
+// seen, see NoteOnce()
+func WarnOnce(key string, v ...interface{}) {
+	if !firstOnce(key) {
+		return
+	}
+	WARN.output(false, 0, ForBoth, v...)
+}
+
+// IssueOnce is like Issue() but only outputs the first time the given key is
+// seen, see NoteOnce()
+func IssueOnce(key string, v ...interface{}) {
+	if !firstOnce(key) {
+		return
+	}
+	ISSUE.output(false, 0, ForBoth, v...)
+}
+
+// ErrorOnce is like Error() but only outputs the first time the given key is
+// seen, see NoteOnce()
+func ErrorOnce(key string, v ...interface{}) {
+	if !firstOnce(key) {
+		return
+	}
+	ERROR.output(false, 0, ForBoth, v...)
+}
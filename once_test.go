@@ -0,0 +1,65 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/once.go
+//   Checks NoteOnce() and friends output once per key, even when called
+//   from many goroutines, and that ResetOnce() clears the seen keys.
+
+package out
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestOnceOutput(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NoteOnce("retry", "retrying fetch\n")
+			IssueOnce("bad-config", "bad config entry\n")
+		}()
+	}
+	wg.Wait()
+	NoteOnce("other", "another note\n")
+	WarnOnce("retry-warn", "still retrying\n")
+	ErrorOnce("failed", "fetch failed\n")
+	ErrorOnce("failed", "fetch failed\n")
+	once := screenBuf.String()
+
+	screenBuf.Reset()
+	ResetOnce()
+	NoteOnce("retry", "retrying fetch\n")
+	afterReset := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 1, strings.Count(once, "Note: retrying fetch\n"))
+	assert.Equal(t, 1, strings.Count(once, "Issue: bad config entry\n"))
+	assert.Equal(t, 1, strings.Count(once, "Note: another note\n"))
+	assert.Equal(t, 1, strings.Count(once, "Warning: still retrying\n"))
+	assert.Equal(t, 1, strings.Count(once, "Error: fetch failed\n"))
+	assert.Equal(t, "Note: retrying fetch\n", afterReset)
+}
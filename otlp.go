@@ -0,0 +1,489 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLPOption is used to adjust the OTLP exporter set up by SetOTLPExporter()
+type OTLPOption func(*otlpConfig)
+
+// otlpConfig holds the OTLP exporter settings, see the OTLPWith*() options
+type otlpConfig struct {
+	serviceName   string
+	headers       map[string]string
+	batchSize     int
+	maxQueue      int
+	flushInterval time.Duration
+	maxRetries    int
+	client        *http.Client
+}
+
+// OTLPWithServiceName sets the "service.name" resource attribute sent with
+// the log records, the default is the base name of the running program
+func OTLPWithServiceName(name string) OTLPOption {
+	return func(cfg *otlpConfig) {
+		cfg.serviceName = name
+	}
+}
+
+// OTLPWithHeaders adds HTTP headers to each export request, eg: for an
+// "Authorization" header needed by a hosted collector
+func OTLPWithHeaders(headers map[string]string) OTLPOption {
+	return func(cfg *otlpConfig) {
+		for k, v := range headers {
+			cfg.headers[k] = v
+		}
+	}
+}
+
+// OTLPWithBatchSize sets how many records are queued before an export is
+// kicked off early (default 512), smaller batches still go out at each
+// flush interval
+func OTLPWithBatchSize(size int) OTLPOption {
+	return func(cfg *otlpConfig) {
+		if size > 0 {
+			cfg.batchSize = size
+		}
+	}
+}
+
+// OTLPWithFlushInterval sets how often queued records are exported (the
+// default is every 2 seconds)
+func OTLPWithFlushInterval(d time.Duration) OTLPOption {
+	return func(cfg *otlpConfig) {
+		if d > 0 {
+			cfg.flushInterval = d
+		}
+	}
+}
+
+// OTLPWithMaxRetries sets how many times a failed export is retried (with a
+// growing backoff) before the batch is dropped, the default is 3
+func OTLPWithMaxRetries(retries int) OTLPOption {
+	return func(cfg *otlpConfig) {
+		if retries >= 0 {
+			cfg.maxRetries = retries
+		}
+	}
+}
+
+// OTLPWithHTTPClient sets the http.Client used for exports (eg: for TLS
+// settings), the default is a client with a 10 second timeout
+func OTLPWithHTTPClient(client *http.Client) OTLPOption {
+	return func(cfg *otlpConfig) {
+		if client != nil {
+			cfg.client = client
+		}
+	}
+}
+
+// The OTLP/HTTP JSON encoding of an export request, only the parts we use
+// (see opentelemetry-proto logs/v1 and the OTLP spec for the JSON mapping,
+// note that 64 bit ints are encoded as strings there)
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      map[string]string `json:"scope"`
+	LogRecords []otlpLogRecord   `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  map[string][]otlpKeyValue `json:"resource"`
+	ScopeLogs []otlpScopeLogs           `json:"scopeLogs"`
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpExporter is the io.Writer (and metadataWriter) installed as the logfile
+// handle by SetOTLPExporter(), records are queued and shipped in batches by
+// a background goroutine
+type otlpExporter struct {
+	url string
+	cfg otlpConfig
+
+	mu      sync.Mutex
+	queue   []otlpLogRecord
+	dropped int
+	closed  bool
+
+	kick  chan struct{}
+	flush chan chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+var (
+	// otlpMu protects otlpExp
+	otlpMu sync.Mutex
+
+	// otlpExp is the current OTLP exporter, if any
+	otlpExp *otlpExporter
+)
+
+// SetOTLPExporter sends the logfile output stream to an OpenTelemetry
+// collector using OTLP over HTTP (JSON encoding), eg:
+//   err := out.SetOTLPExporter("http://localhost:4318",
+//       out.OTLPWithServiceName("mytool"))
+//   out.SetThreshold(out.LevelInfo, out.ForLogfile)
+//   defer out.CloseOTLPExporter()
+// The endpoint is the collector's base URL ("/v1/logs" is added if it isn't
+// already there).  Each message that passes the logfile threshold becomes an
+// OTLP LogRecord with the severity from the level, the message as the body,
+// the time of the message, the file, line# and func of the caller as the
+// "code.*" attributes and any structured fields (eg: "err_code" for detailed
+// errors) as attributes.  Like SetJournald() the logfile flags are cleared (all
+// that info is in the record already) and the logfile handle for all levels
+// is replaced, ie: the log file (if any) is no longer written.  Records are
+// batched and exported in the background, failed exports are retried (with
+// backoff) and then dropped with a note on stderr.  Queued records are sent
+// when the tool exits via this pkg (eg: out.Exit() or a Fatal) or when one
+// calls FlushOTLPExporter() or CloseOTLPExporter().  Any existing exporter is
+// closed (after flushing it) and replaced.  An error is returned if the
+// endpoint isn't a valid http(s) URL.
+func SetOTLPExporter(endpoint string, opts ...OTLPOption) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q, expected an http(s) URL", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/logs") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/logs"
+	}
+	cfg := otlpConfig{
+		serviceName:   filepath.Base(os.Args[0]),
+		headers:       make(map[string]string),
+		batchSize:     512,
+		maxQueue:      8192,
+		flushInterval: 2 * time.Second,
+		maxRetries:    3,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	exp := &otlpExporter{
+		url:   u.String(),
+		cfg:   cfg,
+		kick:  make(chan struct{}, 1),
+		flush: make(chan chan struct{}),
+		done:  make(chan struct{}),
+	}
+	exp.wg.Add(1)
+	go exp.run()
+
+	otlpMu.Lock()
+	old := otlpExp
+	otlpExp = exp
+	otlpMu.Unlock()
+	SetWriter(LevelAll, exp, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	if old != nil {
+		old.close()
+	}
+	return nil
+}
+
+// FlushOTLPExporter exports any queued records now, returning once that has
+// been tried (it does nothing if there is no OTLP exporter)
+func FlushOTLPExporter() {
+	otlpMu.Lock()
+	exp := otlpExp
+	otlpMu.Unlock()
+	if exp != nil {
+		exp.flushNow()
+	}
+}
+
+// CloseOTLPExporter flushes and stops the OTLP exporter, the logfile handle
+// for all levels is set back to ioutil.Discard if it was the exporter
+func CloseOTLPExporter() {
+	otlpMu.Lock()
+	exp := otlpExp
+	otlpExp = nil
+	otlpMu.Unlock()
+	if exp == nil {
+		return
+	}
+	for _, o := range outputters {
+		o.mu.Lock()
+		if o.logfileHndl == exp {
+			o.logfileHndl = ioutil.Discard
+		}
+		o.mu.Unlock()
+	}
+	exp.close()
+}
+
+// otlpSeverity maps our levels to OTLP severity numbers
+func otlpSeverity(level Level) int {
+	switch level.Severity() {
+	case LevelTrace:
+		return 1 // TRACE
+	case LevelDebug:
+		return 5 // DEBUG
+	case LevelVerbose:
+		return 8 // DEBUG4
+	case LevelInfo:
+		return 9 // INFO
+	case LevelNote:
+		return 10 // INFO2
+	case LevelWarn, LevelIssue:
+		return 13 // WARN
+	case LevelError:
+		return 17 // ERROR
+	case LevelFatal:
+		return 21 // FATAL
+	default:
+		return 0 // UNSPECIFIED
+	}
+}
+
+// otlpString and otlpInt build the OTLP JSON AnyValue forms we use
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+func otlpInt(i int64) otlpAnyValue {
+	s := strconv.FormatInt(i, 10)
+	return otlpAnyValue{IntValue: &s}
+}
+
+// otlpValue converts a structured field value, integers are sent as such and
+// anything else is sent as its string form
+func otlpValue(val interface{}) otlpAnyValue {
+	switch v := val.(type) {
+	case int:
+		return otlpInt(int64(v))
+	case int32:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case string:
+		return otlpString(v)
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+// Write satisfies the io.Writer interface, output written without metadata
+// is sent as Info level with the current time
+func (e *otlpExporter) Write(p []byte) (int, error) {
+	return e.WriteMetadata(p, LevelInfo, nil)
+}
+
+// WriteMetadata satisfies the metadataWriter interface, it queues a record
+// for the output (whitespace only output, like a lone newline, is dropped as
+// is any output after the exporter is closed)
+func (e *otlpExporter) WriteMetadata(p []byte, level Level, mdata *FlagMetadata) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if strings.TrimSpace(msg) == "" {
+		return len(p), nil
+	}
+	now := time.Now()
+	when := now
+	rec := otlpLogRecord{
+		SeverityNumber: otlpSeverity(level),
+		SeverityText:   level.String(),
+		Body:           otlpString(msg),
+	}
+	if mdata != nil {
+		if mdata.Time != nil {
+			when = *mdata.Time
+		}
+		if mdata.File != "" {
+			rec.Attributes = append(rec.Attributes,
+				otlpKeyValue{Key: "code.filepath", Value: otlpString(filepath.Join(mdata.Path, mdata.File))},
+				otlpKeyValue{Key: "code.lineno", Value: otlpInt(int64(mdata.LineNo))})
+		}
+		if mdata.Func != "" {
+			rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: "code.function", Value: otlpString(mdata.Func)})
+		}
+		for _, key := range sortedFieldKeys(mdata.Fields) {
+			rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: key, Value: otlpValue(mdata.Fields[key])})
+		}
+	}
+	rec.TimeUnixNano = strconv.FormatInt(when.UnixNano(), 10)
+	rec.ObservedTimeUnixNano = strconv.FormatInt(now.UnixNano(), 10)
+
+	e.mu.Lock()
+	if e.closed {
+		// a late write after shutdown (eg: via a writer grabbed earlier) is
+		// dropped, failing it would exit the tool via the write error path
+		e.mu.Unlock()
+		return len(p), nil
+	}
+	if len(e.queue) >= e.cfg.maxQueue {
+		e.queue = e.queue[1:]
+		e.dropped++
+	}
+	e.queue = append(e.queue, rec)
+	full := len(e.queue) >= e.cfg.batchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// run is the background goroutine that exports queued records
+func (e *otlpExporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.cfg.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.export()
+		case <-e.kick:
+			e.export()
+		case ack := <-e.flush:
+			e.export()
+			close(ack)
+		case <-e.done:
+			e.export()
+			return
+		}
+	}
+}
+
+// flushNow asks the background goroutine to export and waits for it
+func (e *otlpExporter) flushNow() {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+		<-ack
+	case <-e.done:
+	}
+}
+
+// close stops the background goroutine after a final export
+func (e *otlpExporter) close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	e.mu.Unlock()
+	close(e.done)
+	e.wg.Wait()
+}
+
+// export sends all queued records (in batches), retrying failures with a
+// growing backoff, batches that still fail are dropped with a note on stderr
+func (e *otlpExporter) export() {
+	for {
+		e.mu.Lock()
+		n := len(e.queue)
+		if n > e.cfg.batchSize {
+			n = e.cfg.batchSize
+		}
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+		if dropped != 0 {
+			fmt.Fprintf(os.Stderr, "Issue: OTLP export queue full, dropped %d log record(s)\n", dropped)
+		}
+		if n == 0 {
+			return
+		}
+		var err error
+		backoff := 100 * time.Millisecond
+		for try := 0; try <= e.cfg.maxRetries; try++ {
+			if try != 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			var retry bool
+			if retry, err = e.send(batch); err == nil || !retry {
+				break
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Issue: OTLP export to %s failed, dropped %d log record(s): %s\n", e.url, n, err)
+		}
+	}
+}
+
+// send posts one batch of records to the collector, returning any error and
+// whether the failure is worth retrying (network errors, 429 and 5xx's)
+func (e *otlpExporter) send(batch []otlpLogRecord) (bool, error) {
+	req := otlpExportRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: map[string][]otlpKeyValue{"attributes": {
+			{Key: "service.name", Value: otlpString(e.cfg.serviceName)},
+		}},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      map[string]string{"name": "github.com/dvln/out"},
+			LogRecords: batch,
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	httpReq, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := e.cfg.client.Do(httpReq)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("collector returned %s", resp.Status)
+}
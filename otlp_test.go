@@ -0,0 +1,110 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/otlp.go
+//   Checks the OTLP log records sent to a fake collector, including a retry,
+//   and that writes after the exporter is closed are dropped.
+
+package out
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpExportRequest
+	var paths []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			// make the first export fail so we see a retry
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req otlpExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Bad OTLP request: %v", err)
+		}
+		requests = append(requests, req)
+		paths = append(paths, r.URL.Path+" "+r.Header.Get("X-Token"))
+	}))
+	defer server.Close()
+
+	assert.NotNil(t, SetOTLPExporter("localhost:4318"))
+	err := SetOTLPExporter(server.URL, OTLPWithServiceName("mytool"),
+		OTLPWithHeaders(map[string]string{"X-Token": "secret"}),
+		OTLPWithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	Discard(ForScreen)
+	SetThreshold(LevelInfo, ForLogfile)
+	before := time.Now()
+	Println("hello collector")
+	Issueln("disk is nearly full")
+	otlpMu.Lock()
+	exp := otlpExp
+	otlpMu.Unlock()
+	CloseOTLPExporter()
+	lateLen, lateErr := exp.WriteMetadata([]byte("after close\n"), LevelError, nil)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	// writes after the close are dropped without an error
+	assert.Equal(t, 12, lateLen)
+	assert.Nil(t, lateErr)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, calls)
+	if !assert.Equal(t, 1, len(requests)) {
+		return
+	}
+	assert.Equal(t, "/v1/logs secret", paths[0])
+	resLogs := requests[0].ResourceLogs[0]
+	assert.Equal(t, "service.name", resLogs.Resource["attributes"][0].Key)
+	assert.Equal(t, "mytool", *resLogs.Resource["attributes"][0].Value.StringValue)
+	records := resLogs.ScopeLogs[0].LogRecords
+	if assert.Equal(t, 2, len(records)) {
+		assert.Equal(t, "hello collector", *records[0].Body.StringValue)
+		assert.Equal(t, 9, records[0].SeverityNumber)
+		assert.Equal(t, "INFO", records[0].SeverityText)
+		assert.Equal(t, "Issue: disk is nearly full", *records[1].Body.StringValue)
+		assert.Equal(t, 13, records[1].SeverityNumber)
+		assert.True(t, records[0].TimeUnixNano >= itoaNano(before))
+		attrs := make(map[string]otlpAnyValue)
+		for _, kv := range records[1].Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		assert.Contains(t, *attrs["code.filepath"].StringValue, "otlp_test.go")
+		assert.Equal(t, "github.com/dvln/out.TestOTLPExporter", *attrs["code.function"].StringValue)
+		assert.NotNil(t, attrs["code.lineno"].IntValue)
+	}
+}
+
+// itoaNano gives the OTLP string form of the given time (same length as
+// any time around now so a string compare works)
+func itoaNano(t time.Time) string {
+	b, _ := json.Marshal(t.UnixNano())
+	return string(b)
+}
@@ -53,16 +53,16 @@
 //
 // - Future: github.com/dvln/in for prompting/paging
 //
-// The 'out' package is designed as a singleton (currently) although one could
-// make it more generic... but as I have no need for that currently I've avoided
-// that effort.  If done maybe group []*LvlOutput in an "Outputter" struct, add
-// methods for all desired functions like 'out.Print()' on (*Outputter) and move
-// the logic into that and have the singleton function call these methods.  Then
-// perhaps clean up the *Newline stuff (should be done anyhow) so it drives off
-// the io.Writers targets (consider os.Stdout and os.Stderr to be the same tgt
-// no matter how many writers point at it, and consider any other io.Writer
-// like a file or a buffer to be the same if the same "handle"... anyhow, needs
-// to be better than what's here now).  What could go wrong?  ;)
+// The 'out' package is mostly used as a singleton, the pkg level functions
+// like 'out.Print()' work on a default Outputter (a group of []*LvlOutput
+// with its own thresholds, log file and newline tracking).  If independent
+// output settings are needed (eg: a library that doesn't want to clobber the
+// host tools thresholds) use NewOutputter() and its methods, eg: op.Print().
+// Perhaps clean up the *Newline stuff one day (should be done anyhow) so it
+// drives off the io.Writers targets (consider os.Stdout and os.Stderr to be
+// the same tgt no matter how many writers point at it, and consider any other
+// io.Writer like a file or a buffer to be the same if the same "handle"...
+// anyhow, needs to be better than what's here now).  What could go wrong?  ;)
 //
 // Anyhow, for true screen mirroring to logfile type controls it's pretty
 // effective as a singleton so have some fun.  Also, as a more powerful error
@@ -86,6 +86,9 @@
 //	// For key notes for the user to consider (ideally), "Note: " prefix
 //	out.Note[f|ln](..)
 //
+//	// For warnings the user should heed (but not errors), "Warning: " prefix
+//	out.Warn[f|ln](..)
+//
 //	// For "expected" usage issues/errors (eg: bad flag value), "Issue: " prefix
 //	out.Issue[f|ln](..)
 //
@@ -101,7 +104,7 @@
 // to control tool output verbosity, ie: "-Dv" (both) is the "output everything"
 // mode via the Trace level, just "-D" is the Debug level and all levels below,
 // only "-v" sets the Verbose level and all levels below and Info/Print is the
-// default level with none of those options.
+// default level with none of those options, see SetVerbosity() for that.
 //
 // Quick Plug: I like spf13's viper&cobra pkgs for CLI and config file mgmt
 package out
@@ -112,12 +115,16 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // mutex is used for writing to global vars and Writing to what might
@@ -147,14 +154,20 @@ const (
 	Lshortfunc                            // just short func signature, trimmed to just get
 	Lpid                                  // add in the pid to the output
 	Llevel                                // add in the output level "raw" string (eg: TRACE,DEBUG,..)
+	Lbuildinfo                            // add in the build info (eg: commit), see SetBuildInfo()
+	Lcategory                             // add in any message category (eg: [net]), see PrintCat()
+	Lreltime                              // seconds since the program started: [   0.001234]
+	Luser                                 // add in the user name (padded), see SetUserNameLength()
+	Lhost                                 // add in the host name (padded), see SetHostNameLength()
+	Lgoid                                 // add in the goroutine id: g18 (debugging aid, off by default)
 	LstdFlags     = Ldate | Ltime         // for those used to Go 'log' flag settings
 	LscreenFlags  = Ltime | Lmicroseconds // values for "std" screen and log file flags
 	LlogfileFlags = Lpid | Llevel | Ldate | Ltime | Lmicroseconds | Lshortfile | Lshortfunc
 )
 
-// FEATURE: add in userid to the list of goodies, turn on by default for logfile
-// so it looks like this:
-// [616]   brady INFO    2015/07/25 01:05:01.886736 get.go:75:get                 : Look up codebase
+// With the Luser flag added to the logfile flags the output looks like this:
+// [616]    brady INFO    2015/07/25 01:05:01.886736 get.go:75:get                 : Look up codebase
+// FEATURE: turn on the Luser flag by default for the logfile
 // FEATURE: clean up the out.go file a bit:
 // - migrate detailed error stuff "mostly" into deterr.go module in this dir
 // - migrate formatter stuff "mostly" into fmt.go module in this dir
@@ -162,13 +175,16 @@ const (
 // Available output and logging levels to this package, by
 // default "normal" info output and any notes/issues/errs/fatal/etc
 // will be dumped to stdout and, by default, file logging for that output
-// is inactive to start with til a log file is set up
+// is inactive to start with til a log file is set up.  Use the names, the
+// integer values can change when levels are added (LevelWarn shifted the
+// levels from LevelIssue up by one), see LevelString2Level() to store them.
 const (
 	LevelTrace   Level = iota // Very high amount of debug output
 	LevelDebug                // Standard debug output level
 	LevelVerbose              // Verbose output if user wants it
 	LevelInfo                 // Standard output info/print to user
 	LevelNote                 // Likely a heads up, "Note: <blah>"
+	LevelWarn                 // A user facing warning, "Warning: <blah>"
 	LevelIssue                // Typically a normal user/usage error
 	LevelError                // Recoverable sys or unexpected error
 	LevelFatal                // Very bad, we need to exit non-zero
@@ -229,19 +245,27 @@ type Level int
 // and newline tracking, etc.  Aside: below there is a also an io.Writer that
 // corresponds to each level, ie: fmt.Fprintf(TRACE, "%s", someStr), as a 2nd
 // way to push output through the screen/log writers that are set up.
-// Note: should create a LvlOutputter interface one of these days, no?
+// Each set of levels belongs to an Outputter, the TRACE, DEBUG, .. writers
+// are the levels of the default Outputter (see NewOutputter() for others).
 type LvlOutput struct {
 	mu          sync.RWMutex // ensures atomic writes; protects these fields:
 	level       Level        // below data tells how each logging level works
 	prefix      string       // prefix for this logging level (if any)
-	buf         []byte       // for accumulating text to write at this level
 	screenHndl  io.Writer    // io.Writer for "screen" output
 	screenFlags int          // flags: additional metadata on screen output
 	logfileHndl io.Writer    // io.Writer for "logfile" output
 	logFlags    int          // flags: additional metadata on logfile output
 	formatter   Formatter    // optional output formatting extension/plugin
+	prefixFunc  PrefixFunc   // optional dynamic prefix, overrides 'prefix'
+	outputter   *Outputter   // the Outputter this level belongs to
 }
 
+// PrefixFunc is a callback that can compute the prefix for a level at write
+// time (instead of the static prefix), see SetPrefixFunc().  It is given the
+// output level and the metadata known at the time (level, time, pid and the
+// callers file/line#/func) and should return the prefix to use, eg: "[step 3] "
+type PrefixFunc func(level Level, meta FlagMetadata) string
+
 // FlagMetadata stores the various log add-on fields that a client can request
 // such as a timestamp, the log level, the package, routine and line number
 // information, pid, etc
@@ -254,56 +278,138 @@ type FlagMetadata struct {
 	Level  string     `json:"level,omitempty"`
 	PID    int        `json:"pid,omitempty"`
 	Stack  string     `json:"stack,omitempty"`
+
+	// Host is the name of the host the tool is running on ("unknown" if it
+	// couldn't be looked up), filled in whether or not Lhost is set
+	Host string `json:"host,omitempty"`
+
+	// GoID is the id of the goroutine that did the output, only filled in
+	// if the Lgoid flag is set (0 otherwise)
+	GoID int `json:"goid,omitempty"`
+
+	// Frames is the stack trace (see Stack) broken out into its frames,
+	// innermost first, eg: for JSON consumers (nil if there is no stack
+	// trace or it is a lazy one, see SetLazyStackTrace())
+	Frames []StackFrame `json:"frames,omitempty"`
+
+	// Category is the subsystem category the output was tagged with (eg:
+	// "net" or "db"), empty unless PrintCat() or friends were used
+	Category string `json:"category,omitempty"`
+
+	// Fields holds structured key/value data for the output, eg: the fields
+	// of an Entry or an Outputter (see WithFields()) or, when a DetailedError
+	// is being output (see detailedErrorFields()), its code and stack so that
+	// structured formatters and targets get them as first class fields (nil
+	// if there are none)
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// metadataWriter is an optional extension of io.Writer for output targets
+// that want more than the raw bytes, eg: journald wants the output level
+// (to map to a priority) and the file/line#/func of the caller so it can
+// store those as structured fields.  If a screen or logfile handle for a
+// level implements this the 'out' pkg will call WriteMetadata() instead of
+// Write() and will resolve caller details even if no file/func flags are set.
+type metadataWriter interface {
+	WriteMetadata(p []byte, level Level, mdata *FlagMetadata) (int, error)
+}
+
+// writeHandle writes the given bytes to the given handle, using the richer
+// metadataWriter interface if the handle supports it
+func writeHandle(hndl io.Writer, p []byte, level Level, mdata *FlagMetadata) (int, error) {
+	if mw, ok := hndl.(metadataWriter); ok {
+		if mdata == nil {
+			mdata = &FlagMetadata{Level: level.String()}
+		}
+		return mw.WriteMetadata(p, level, mdata)
+	}
+	return hndl.Write(p)
 }
 
+// handleWantsMetadata returns true if the handle implements metadataWriter,
+// a child Outputter's shared writer (see Child()) only wants the metadata if
+// the parent's current writer does
+func handleWantsMetadata(hndl io.Writer) bool {
+	if sw, ok := hndl.(*sharedWriter); ok {
+		return handleWantsMetadata(sw.target())
+	}
+	_, ok := hndl.(metadataWriter)
+	return ok
+}
+
+// fieldsText returns the given fields as key=value pairs sorted by key (each
+// followed by a space) for plain text output, values are quoted if needed
+func fieldsText(fields map[string]interface{}) string {
+	text := ""
+	for _, k := range sortedFieldKeys(fields) {
+		text += logfmtKey(k) + "=" + logfmtValue(fmt.Sprint(fields[k])) + " "
+	}
+	return text
+}
+
+// sortedFieldKeys returns the keys of the given structured fields sorted, so
+// targets that emit fields do so in a stable order
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The starting values of the settings below, Reset() also puts them back to
+// these (see the settings themselves for what the values mean)
+const (
+	defaultLogfileStackTrace         = StackTraceNonZeroErrorExit
+	defaultShortFileNameLength int32 = 16
+	defaultLongFileNameLength  int32 = 55
+	defaultShortFuncNameLength int32 = 14
+	defaultLongFuncNameLength  int32 = 30
+	defaultUserNameLength      int32 = 8
+	defaultHostNameLength      int32 = 12
+	defaultCallDepth           int32 = 5
+	defaultErrorExitVal        int32 = -1
+)
+
 var (
-	// Set up each output level, ie: level, prefix, screen/log hndl, flags, ...
+	// Each output level (ie: level, prefix, screen/log hndl, flags, ...) is
+	// set up by NewOutputter() for the default Outputter
 
 	// TRACE can be used as an io.Writer for trace level output
-	TRACE = &LvlOutput{level: LevelTrace, prefix: "Trace: ", screenHndl: os.Stdout, screenFlags: LscreenFlags, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	TRACE = std.outputters[LevelTrace]
 	// DEBUG can be used as an io.Writer for debug level output
-	DEBUG = &LvlOutput{level: LevelDebug, prefix: "Debug: ", screenHndl: os.Stdout, screenFlags: LscreenFlags, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	DEBUG = std.outputters[LevelDebug]
 	// VERBOSE can be used as an io.Writer for verbose level output
-	VERBOSE = &LvlOutput{level: LevelVerbose, prefix: "", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	VERBOSE = std.outputters[LevelVerbose]
 	// INFO can be used as an io.Writer for info|print level output
-	INFO = &LvlOutput{level: LevelInfo, prefix: "", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	INFO = std.outputters[LevelInfo]
 	// NOTE can be used as an io.Writer for note level output
-	NOTE = &LvlOutput{level: LevelNote, prefix: "Note: ", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	NOTE = std.outputters[LevelNote]
+	// WARN can be used as an io.Writer for warning level output
+	WARN = std.outputters[LevelWarn]
 	// ISSUE can be used as an io.Writer for issue level output
-	ISSUE = &LvlOutput{level: LevelIssue, prefix: "Issue: ", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	ISSUE = std.outputters[LevelIssue]
 	// ERROR can be used as an io.Writer for error level output
-	ERROR = &LvlOutput{level: LevelError, prefix: "Error: ", screenHndl: os.Stderr, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	ERROR = std.outputters[LevelError]
 	// FATAL can be used as an io.Writer for fatal level output
-	FATAL = &LvlOutput{level: LevelFatal, prefix: "Fatal: ", screenHndl: os.Stderr, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags}
+	FATAL = std.outputters[LevelFatal]
 
 	// Set up all the LvlOutput level details in one array (except discard),
-	// the idea that one can control these pretty flexibly (if needed)
-	outputters = []*LvlOutput{TRACE, DEBUG, VERBOSE, INFO, NOTE, ISSUE, ERROR, FATAL}
-
-	// Set up default/starting logging threshold settings, see SetThreshold()
-	// if you wish to change these threshold settings
-	screenThreshold = defaultScreenThreshold
-	logThreshold    = defaultLogThreshold
-	logFileName     string
-
-	// As output is displayed track if last message ended in a newline or not,
-	// both to the screen and to the log (as levels may cause output to differ)
-	// Note: this is tracked across *all* output levels so if you have done
-	// something "interesting" like redirecting to different writers for logfile
-	// output (eg: pointing at different log files for different levels) then
-	// the below globs don't really work since they treat screen output (all
-	// levels as visible in the same "stream" and log output the same way).
-	// If you're doing this then you may need to re-work the package a bit,
-	// you could track *NewLines for each level independently for example.
-	screenNewline  = true
-	logfileNewline = true
-
-	// stackTraceConfig is used to ask for stack traces to be dumped on various
-	// classes of errors (or issues), the default is to dump stack traces to
-	// the logfile output stream on error/exit (assuming the 'out' package is
-	// being used for that non-zero exit process via Fatal, Exit(<non-zero>),
-	// ErrorExit or IssueExit).  See SetStackTraceConfig() to change.
-	stackTraceConfig = StackTraceExitToLogfile
+	// the idea that one can control these pretty flexibly (if needed), the
+	// default Outputter's thresholds and newline tracking are also used for
+	// the pkg level routines (see Outputter)
+	outputters = std.outputters
+
+	// screenStackTrace and logfileStackTrace are used to ask for stack traces
+	// to be dumped on various classes of errors (or issues) to each target,
+	// they hold the StackTrace* trigger flags.  The default is to dump stack
+	// traces to the logfile output stream on error/exit (assuming the 'out'
+	// package is being used for that non-zero exit process via Fatal,
+	// Exit(<non-zero>), ErrorExit or IssueExit).  See SetStackTraceConfig()
+	// and SetStackTraceConfigForTarget() to change.
+	screenStackTrace  = 0
+	logfileStackTrace = defaultLogfileStackTrace
 
 	// The below "<..>NameLength" flags help to aligh the output when dumping
 	// filenames, line #'s' and function names to a log file in front of the
@@ -320,23 +426,33 @@ var (
 	// then the trailing colon, so we'll go with 16).  If you have longer
 	// filenames then you can change this setting so your output alignment
 	// improves (or the below settings)
-	shortFileNameLength int32 = 16
+	shortFileNameLength = defaultShortFileNameLength
 
 	// longFileNameLength is the full path and filename plus the line # and
 	// a trailing colon after that... this is hand-wavy but we'll give it
 	// some space for now, adjust as needed for your paths/filenames:
-	longFileNameLength int32 = 55
+	longFileNameLength = defaultLongFileNameLength
 
 	// shortFuncNameLength ties into function names (if those have been added
 	// to your output metadata via the Lshortfunc flag), right now it expects
 	// method names of around 12 or 13 chars, followed by a colon, adjust as
 	// needed for your own method names
-	shortFuncNameLength int32 = 14
+	shortFuncNameLength = defaultShortFuncNameLength
 
 	// longFuncNameLength is the full function name which includes the package
 	// name (full path) followed by a dot and then the function name, this may
 	// be a bit short for some folks so adjust as needed.
-	longFuncNameLength int32 = 30
+	longFuncNameLength = defaultLongFuncNameLength
+
+	// userNameLength is the width the user name (see the Luser flag) is
+	// padded to, the name is right aligned within it, 8 chars covers most
+	// user names
+	userNameLength = defaultUserNameLength
+
+	// hostNameLength is the width the host name (see the Lhost flag) is
+	// padded to, the name is left aligned within it and longer names are
+	// not cut short
+	hostNameLength = defaultHostNameLength
 
 	// callDepth is for runtime.Caller() to identify where a Noteln() or Print()
 	// or Issuef() (etc) was called from (so meta-data dumped in "extended"
@@ -344,11 +460,11 @@ var (
 	// value is correct *but* if you choose to further wrap 'out' methods in
 	// some extra method layer (or two) in your own modules then you might
 	// want to increase it via this public package global.
-	callDepth int32 = 5
+	callDepth = defaultCallDepth
 
 	// errorExitVal is the default exit value used by Fatal()* routines which
 	// are not given an exit value to use
-	errorExitVal int32 = -1
+	errorExitVal = defaultErrorExitVal
 
 	// deferFunc is a func pointer to a func that takes no params and returns
 	// nothing of use, if set it is called immediately before exit (often used
@@ -356,10 +472,42 @@ var (
 	// a temp output logfile name so it's visible at the end of a run, etc),
 	// See DeferFunc() and SetDeferFunc() to get and set this if desired.
 	deferFunc func(exitVal int)
+
+	// exitTimeout is how long (a time.Duration) the cleanup before exiting
+	// can take, 0 means no limit, see SetExitTimeout()
+	exitTimeout int64
+
+	// emptyPrefixSeparator, if non-zero, means levels with no prefix (eg:
+	// Verbose and Info by default) get a ": " between their flag metadata
+	// and the message even if no file/func metadata is shown, see the
+	// SetEmptyPrefixSeparator() routine for details
+	emptyPrefixSeparator int32
+
+	// panicOnWriteFail, if non-zero, means a panic is used when writing
+	// output fails *and* the error can't even be reported on stderr, see
+	// the SetPanicOnUnrecoverableWriteError() routine for details
+	panicOnWriteFail int32
+
+	// writerExitsOnFatal, if non-zero, means writes via the FATAL io.Writer
+	// exit as Fatal() does, see SetWriterExitsOnFatal()
+	writerExitsOnFatal int32
+
+	// metadataSeparator holds the string (if any) used between the fields of
+	// the flag metadata block, see SetMetadataSeparator()
+	metadataSeparator atomic.Value
+
+	// screenTimeFormat and logfileTimeFormat hold the time.Format() layout
+	// (if any) used for the date/time flags, see SetTimeFormat()
+	screenTimeFormat  atomic.Value
+	logfileTimeFormat atomic.Value
 )
 
-// levelCheck insures valid log level "values" are provided
+// levelCheck insures valid log level "values" are provided (levels added
+// via RegisterLevel() are valid)
 func levelCheck(level Level) Level {
+	if _, ok := customLevelInfo(level); ok {
+		return level
+	}
 	switch {
 	case level <= LevelTrace:
 		return LevelTrace
@@ -391,38 +539,169 @@ func SetDeferFunc(dFunc func(exitVal int)) {
 	mutex.Unlock()
 }
 
+// ExitTimeout returns how long the exit cleanup (the defer func and such) is
+// given to finish before the tool exits anyway, 0 means no limit
+func ExitTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&exitTimeout))
+}
+
+// SetExitTimeout limits how long the cleanup done right before this pkg exits
+// (ie: the defer func set via SetDeferFunc() and flushing any OTLP exporter)
+// can take.  By default (0) there is no limit so a defer func that hangs (eg:
+// on a DB connection that is gone) hangs the tool forever instead of letting
+// it die.  With a timeout set the cleanup is run in a goroutine and, if it
+// hasn't finished within the given duration, a warning is written to stderr
+// and the tool exits anyway, eg:
+//   out.SetDeferFunc(closeDBAndSendFinalMetrics)
+//   out.SetExitTimeout(5 * time.Second)
+func SetExitTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.StoreInt64(&exitTimeout, int64(d))
+}
+
+// exitCleanup runs the cleanup needed right before this pkg exits the tool
+// (flushing any OTLP exporter and then calling any defer func), honoring
+// any exit timeout that has been set (see SetExitTimeout())
+func exitCleanup(exitVal int) {
+	mutex.RLock()
+	dFunc := deferFunc
+	mutex.RUnlock()
+	cleanup := func() {
+		// note any duplicate messages dropped via SetDedup()
+		FlushDedup()
+		// ship anything queued for an OTLP collector before we go
+		FlushOTLPExporter()
+		if dFunc != nil {
+			dFunc(exitVal)
+		}
+		writeExitSummary(exitVal)
+		// and get any memory mapped log file output onto the disk
+		FlushMmapLogFile()
+		// and any buffered screen or logfile output out of the writers
+		syncOutputHandles()
+	}
+	timeout := ExitTimeout()
+	if timeout == 0 {
+		cleanup()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cleanup()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		fmt.Fprintf(os.Stderr, "Issue: cleanup before exit did not finish within %s, exiting anyway\n", timeout)
+	}
+}
+
+// syncOutputHandles flushes and syncs the screen and logfile writers of the
+// default Outputter's levels before exiting so buffered output isn't lost,
+// ie: Flush() is called for writers with a Flush() error method (eg: a
+// bufio.Writer) and then Sync() for those with a Sync() error method (eg: an
+// os.File), errors are ignored as we're on the way out anyhow
+func syncOutputHandles() {
+	var handles []io.Writer
+	for _, o := range std.outputters {
+		o.mu.RLock()
+		screenHndl, logfileHndl := o.screenHndl, o.logfileHndl
+		o.mu.RUnlock()
+		for _, hndl := range []io.Writer{screenHndl, logfileHndl} {
+			if hndl == nil || hndl == ioutil.Discard {
+				continue
+			}
+			seen := false
+			if reflect.TypeOf(hndl).Comparable() {
+				for _, h := range handles {
+					if reflect.TypeOf(h).Comparable() && h == hndl {
+						seen = true
+						break
+					}
+				}
+			}
+			if !seen {
+				handles = append(handles, hndl)
+			}
+		}
+	}
+	for _, hndl := range handles {
+		if f, ok := hndl.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+		if s, ok := hndl.(interface{ Sync() error }); ok {
+			s.Sync()
+		}
+	}
+}
+
 // Threshold returns the current screen or logfile output threshold level
 // depending upon which is requested, either out.ForScreen or out.ForLogfile
 func Threshold(outputTgt int) Level {
-	mutex.Lock()
-	defer mutex.Unlock()
-	var threshold Level
-	if outputTgt&ForScreen != 0 {
-		threshold = screenThreshold
-	} else if outputTgt&ForLogfile != 0 {
-		threshold = logThreshold
-	} else {
-		Fatalln("Invalid screen/logfile given for Threshold()")
-	}
-	return threshold
+	return std.Threshold(outputTgt)
 }
 
 // SetThreshold sets the screen and or logfile output threshold(s) to the given
 // level, outputTgt can be set to out.ForScreen, out.ForLogfile or both |'d
 // together, level is out.LevelInfo for example (any valid level)
 func SetThreshold(level Level, outputTgt int) {
-	if outputTgt&ForScreen != 0 {
-		lc := levelCheck(level)
-		mutex.Lock()
-		screenThreshold = lc
-		mutex.Unlock()
+	std.SetThreshold(level, outputTgt)
+}
+
+// SetThresholdFunc sets a func that decides, for each message, if it is shown
+// on the screen and/or logfile (outputTgt of ForScreen, ForLogfile or ForBoth)
+// instead of comparing the level to the threshold, the func returns true to
+// show the message.  This allows dynamic levels without calling SetThreshold()
+// all the time, eg: to only show debug output while a flag file exists:
+//   out.SetThresholdFunc(out.ForScreen, func(level out.Level) bool {
+//       if level >= out.LevelInfo {
+//           return true
+//       }
+//       return level == out.LevelDebug && debugFlagFileExists()
+//   })
+// The func is called on *every* message for the target (including those the
+// threshold would have filtered out) so keep it cheap, eg: check an atomic
+// that a background goroutine updates rather than checking the file system
+// on each call.  It must not produce output via this pkg.  LevelDiscard
+// output is never shown.  Use nil to go back to the threshold set via the
+// SetThreshold() routine (the default).
+func SetThresholdFunc(outputTgt int, fn func(level Level) bool) {
+	std.SetThresholdFunc(outputTgt, fn)
+}
+
+// passesThreshold returns true if output at the given level passes the given
+// threshold or, if a threshold func is given, if the func says it does
+func passesThreshold(level Level, threshold Level, fn func(level Level) bool) bool {
+	if level == LevelDiscard {
+		return false
 	}
-	if outputTgt&ForLogfile != 0 {
-		lc := levelCheck(level)
-		mutex.Lock()
-		logThreshold = lc
-		mutex.Unlock()
+	if fn != nil {
+		return fn(level)
 	}
+	return level.Severity() >= threshold
+}
+
+// SetLevel is a convenience routine for the most common case of wanting the
+// screen and logfile output to show the same level of output, it's the same
+// as SetThreshold(level, ForBoth), eg: for a --log-level=debug option:
+//   out.SetLevel(out.LevelString2Level(strings.ToUpper(logLevel)))
+// Note that logfile output is still only written if a log file (or writer)
+// has been set up, see SetLogFile() and SetWriter().
+func SetLevel(level Level) {
+	std.SetLevel(level)
+}
+
+// CurrentLevel returns the more verbose of the screen and logfile output
+// thresholds, ie: the lowest level of output that shows up somewhere.  With
+// the defaults (logfile output off) this is just the screen threshold.  Use
+// Threshold() to get the screen and logfile thresholds individually.
+func CurrentLevel() Level {
+	return std.CurrentLevel()
 }
 
 // ShortFileNameLength returns the current "assumed" padding around short
@@ -481,6 +760,34 @@ func SetLongFuncNameLength(length int32) {
 	atomic.StoreInt32(&longFuncNameLength, length)
 }
 
+// UserNameLength returns the current "assumed" padding around the user
+// name (see Luser) within the "padded" flags output.  If you don't like
+// the default adjust via SetUserNameLength()
+func UserNameLength() int32 {
+	return atomic.LoadInt32(&userNameLength)
+}
+
+// SetUserNameLength will set the "assumed" padding around the user name
+// (see Luser) within the "padded" flags output.  To get the current
+// setting see UserNameLength()
+func SetUserNameLength(length int32) {
+	atomic.StoreInt32(&userNameLength, length)
+}
+
+// HostNameLength returns the current "assumed" padding around the host
+// name (see Lhost) within the "padded" flags output.  If you don't like
+// the default adjust via SetHostNameLength()
+func HostNameLength() int32 {
+	return atomic.LoadInt32(&hostNameLength)
+}
+
+// SetHostNameLength will set the "assumed" padding around the host name
+// (see Lhost) within the "padded" flags output.  To get the current
+// setting see HostNameLength()
+func SetHostNameLength(length int32) {
+	atomic.StoreInt32(&hostNameLength, length)
+}
+
 // CallDepth is to retrieve the current call depth... see SetCallDepth for
 // details if needed.
 func CallDepth() int32 {
@@ -498,6 +805,36 @@ func SetCallDepth(depth int32) {
 	atomic.StoreInt32(&callDepth, depth)
 }
 
+// EmptyPrefixSeparator returns true if levels with an empty prefix get a
+// ": " separator between flag metadata and message, see the routine
+// SetEmptyPrefixSeparator() for details
+func EmptyPrefixSeparator() bool {
+	return atomic.LoadInt32(&emptyPrefixSeparator) != 0
+}
+
+// SetEmptyPrefixSeparator controls the separator between the flag metadata
+// and the message for levels with an empty prefix.  The spacing contract for
+// the flag metadata block (see getFlagString()) is:
+//   - with file/func flags on the block always ends in ": ", eg:
+//       "01:23:23.123123 get.go:75:get   : msg"
+//   - without them the block ends in a single space, eg:
+//       "01:23:23.123123 Note: msg"  or  "01:23:23.123123 msg"
+// The second form is clear for prefixed levels (the prefix marks where the
+// message starts) but for empty prefix levels the metadata runs into the
+// message.  If this is set to true then, for levels with an empty prefix,
+// the final space of a block that doesn't already end in ": " becomes ": "
+// (so the width of the block is the same +1), eg:
+//       "01:23:23.123123: msg"  or  "INFO   : msg" (Llevel only)
+// Prefixed levels and blocks that end in ": " are never changed, nothing is
+// added if no flags are set and the default is false (the original spacing).
+func SetEmptyPrefixSeparator(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&emptyPrefixSeparator, val)
+}
+
 // ErrorExitVal returns the current preferred "failure" exit value for the
 // out package (defaults to -1).  Returns an int32 due to use of sync/atomic
 // for setting this internally.
@@ -512,81 +849,182 @@ func SetErrorExitVal(val int32) {
 	atomic.StoreInt32(&errorExitVal, val)
 }
 
+// MetadataSeparator returns the separator used between the fields of the
+// flag metadata block, "" means the original spacing is in use
+func MetadataSeparator() string {
+	if sep, ok := metadataSeparator.Load().(string); ok {
+		return sep
+	}
+	return ""
+}
+
+// SetMetadataSeparator sets the separator used between the fields of the
+// flag metadata block (the pid, level, date/time and file:line#:func fields)
+// and at the end of the block, the default ("") is the original spacing where
+// the level and file fields are padded for alignment and the block ends with
+// ": " if file info is on, else a space.  This is a middle ground for simple
+// log pipelines that split lines on a separator without going all the way
+// to a structured Formatter, eg: with all flags on and " | " one would get:
+//   [1234] | NOTE | 2016/01/16 | 20:11:31.123456 | get.go:75:get | Note: msg
+// Fields aren't padded when a custom separator is in use, note that the date
+// and time are a single field (space separated) and that the file, line# and
+// func are also one field (':' separated).  The empty prefix separator (see
+// SetEmptyPrefixSeparator()) isn't used with a custom separator as the block
+// always ends with the separator.
+func SetMetadataSeparator(sep string) {
+	metadataSeparator.Store(sep)
+}
+
+// TimeFormat returns the time.Format() layout used for the date and time
+// flags for the screen or logfile target (out.ForScreen or out.ForLogfile),
+// "" means the original "2006/01/02 15:04:05[.000000]" format is in use
+func TimeFormat(outputTgt int) string {
+	timeFormat := &logfileTimeFormat
+	if outputTgt&ForScreen != 0 {
+		timeFormat = &screenTimeFormat
+	}
+	if layout, ok := timeFormat.Load().(string); ok {
+		return layout
+	}
+	return ""
+}
+
+// SetTimeFormat sets a time.Format() layout to use for the date/time in the
+// flag metadata for the screen and/or logfile target (ForScreen, ForLogfile
+// or ForBoth), eg: for RFC3339 timestamps in the logfile for log ingestion:
+//   out.SetTimeFormat(time.RFC3339Nano, out.ForLogfile)
+// The layout is used in place of the date and time fields whenever any of
+// the Ldate, Ltime or Lmicroseconds flags are on (so the layout decides what
+// is shown, not the flags).  Use "" (the default) to go back to the original
+// format controlled by the flags.
+func SetTimeFormat(layout string, outputTgt int) {
+	if outputTgt&ForScreen != 0 {
+		screenTimeFormat.Store(layout)
+	}
+	if outputTgt&ForLogfile != 0 {
+		logfileTimeFormat.Store(layout)
+	}
+}
+
+// PanicOnUnrecoverableWriteError returns true if a panic is used when output
+// fails and the failure can't be reported on stderr either
+func PanicOnUnrecoverableWriteError() bool {
+	return atomic.LoadInt32(&panicOnWriteFail) != 0
+}
+
+// SetPanicOnUnrecoverableWriteError controls what happens when a write to a
+// screen or logfile output handle fails and then reporting that failure on
+// stderr *also* fails (eg: stderr has been closed).  By default any defer
+// func is called and then the tool exits with the ErrorExitVal() as usual
+// (so the tool just vanishes, no clue as to why).  If set to true then this
+// pkg will instead panic with the original write error and the stderr error
+// so the details make it into a crash dump or the supervisor's logs.
+func SetPanicOnUnrecoverableWriteError(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&panicOnWriteFail, val)
+}
+
+// unrecoverableWriteError panics if the client has asked for that when the
+// original output error couldn't be reported on stderr (stderrErr non-nil)
+func unrecoverableWriteError(origErr error, stderrErr error) {
+	if stderrErr == nil || !PanicOnUnrecoverableWriteError() {
+		return
+	}
+	panic(fmt.Errorf("out: unable to report output failure on stderr (%v), original error:\n%v", stderrErr, origErr))
+}
+
 // String implements a stringer for the Level type so we can print out string
 // representations for the level setting, these names map to the "code" names
 // for these settings (not the prefixes for the setting since some levels have
 // no output prefix by default).  Client still has full control over "primary"
 // out prefix separately from this, see SetPrefix and such.
 func (l Level) String() string {
-	lvl2String := map[Level]string{
-		LevelTrace:   "TRACE",
-		LevelDebug:   "DEBUG",
-		LevelVerbose: "VERBOSE",
-		LevelInfo:    "INFO",
-		LevelNote:    "NOTE",
-		LevelIssue:   "ISSUE",
-		LevelError:   "ERROR",
-		LevelFatal:   "FATAL",
-		LevelDiscard: "DISCARD",
+	if custom, ok := customLevelInfo(l); ok {
+		return custom.name
 	}
 	l = levelCheck(l)
-	return lvl2String[l]
+	return builtinLevelNames[l]
 }
 
 // LevelString2Level takes the string representation of a level and turns
-// it back into a Level type (integer type/iota)
+// it back into a Level type (integer type/iota), this includes any levels
+// added via RegisterLevel()
 func LevelString2Level(s string) Level {
-	string2Lvl := map[string]Level{
-		"TRACE":   LevelTrace,
-		"DEBUG":   LevelDebug,
-		"VERBOSE": LevelVerbose,
-		"INFO":    LevelInfo,
-		"NOTE":    LevelNote,
-		"ISSUE":   LevelIssue,
-		"ERROR":   LevelError,
-		"FATAL":   LevelFatal,
-		"DISCARD": LevelDiscard,
+	if level, ok := builtinLevels[s]; ok {
+		return level
 	}
-	if _, ok := string2Lvl[s]; !ok {
-		Fatalln("Invalid string level:", s, ", unable to map to Level type")
+	if level, ok := registeredLevels().byName[s]; ok {
+		return level
 	}
-	return string2Lvl[s]
+	Fatalln("Invalid string level:", s, ", unable to map to Level type")
+	return LevelTrace
 }
 
-// Prefix returns the current prefix for the given log level
-func Prefix(level Level) string {
-	level = levelCheck(level)
-	if level == LevelDiscard {
-		Fatalln("Prefix is not defined for level discard, should never be requested")
-	}
-	var prefix string
-	for _, o := range outputters {
-		o.mu.RLock()
-		defer o.mu.RUnlock()
-		if o.level == level {
-			prefix = o.prefix
-			break
+// levelAliases maps other common names for levels to the levels, for the
+// ParseLevel() routine
+var levelAliases = map[string]Level{
+	"WARNING": LevelWarn,
+	"ERR":     LevelError,
+	"PRINT":   LevelInfo,
+	"OFF":     LevelDiscard,
+}
+
+// ParseLevel is like LevelString2Level() but meant for levels given by users
+// (eg: a --log-level option), it ignores case and surrounding whitespace and
+// takes some common aliases too ("warning" for warn, "err" for error, "print"
+// for info and "off" for discard).  Instead of exiting on a level it doesn't
+// know it returns an error that lists the valid levels, eg:
+//
+//	level, err := out.ParseLevel(logLevelOpt)
+//	if err != nil {
+//		out.IssueExitln(2, err)
+//	}
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	if level, ok := builtinLevels[name]; ok {
+		return level, nil
+	}
+	if level, ok := levelAliases[name]; ok {
+		return level, nil
+	}
+	reg := registeredLevels()
+	for _, custom := range reg.levels {
+		if strings.ToUpper(custom.name) == name {
+			return reg.byName[custom.name], nil
 		}
 	}
-	return prefix
+	var valid []string
+	for level := LevelTrace; level <= LevelDiscard; level++ {
+		valid = append(valid, strings.ToLower(level.String()))
+	}
+	for _, custom := range reg.levels {
+		valid = append(valid, strings.ToLower(custom.name))
+	}
+	return LevelInfo, fmt.Errorf("invalid level %q, valid levels are: %s", s, strings.Join(valid, ", "))
+}
+
+// Prefix returns the current prefix for the given log level
+func Prefix(level Level) string {
+	return std.Prefix(level)
 }
 
 // SetPrefix sets screen and logfile output prefix to given string, note that
 // it is recommended to have a trailing space on the prefix, eg: "Myprefix: "
 // unless no prefix is desired then just "" will do
 func SetPrefix(level Level, prefix string) {
-	level = levelCheck(level)
-	if level == LevelDiscard {
-		return
-	}
-	// loop through the levels and reset the prefix of the specified level
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		if o.level == level {
-			o.prefix = prefix
-		}
-	}
+	std.SetPrefix(level, prefix)
+}
+
+// SetPrefixFunc registers a function that computes the prefix for the given
+// level (or all levels if LevelAll is used) each time output is written, eg:
+// to include the current step # or subsystem name.  It is called once per
+// message, the screen, logfile and any stack trace all get that prefix.  Set
+// it to nil to go back to the static prefix as set via SetPrefix().
+func SetPrefixFunc(level Level, fn PrefixFunc) {
+	std.SetPrefixFunc(level, fn)
 }
 
 // Discard disables all screen and/or logfile output, can be done via
@@ -595,37 +1033,22 @@ func SetPrefix(level Level, prefix string) {
 // Anyhow, this is a quick way to disable output (if outputTgt is not set
 // to out.ForScreen or out.ForLogfile or both | together nothing happens)
 func Discard(outputTgt int) {
-	if outputTgt&ForScreen != 0 {
-		SetThreshold(LevelDiscard, ForScreen)
-	}
-	if outputTgt&ForLogfile != 0 {
-		SetThreshold(LevelDiscard, ForLogfile)
-	}
+	std.Discard(outputTgt)
 }
 
 // Flags gets the screen or logfile output flags (Ldate, Ltime, .. above),
 // you must give one or the other (out.ForScreen or out.ForLogfile) only.
 func Flags(level Level, outputTgt int) int {
-	level = levelCheck(level)
-	var flags int
-	for _, o := range outputters {
-		o.mu.RLock()
-		sF := o.screenFlags
-		lF := o.logFlags
-		outLvl := o.level
-		o.mu.RUnlock()
-		if outLvl == level {
-			if outputTgt&ForScreen != 0 {
-				flags = sF
-			} else if outputTgt&ForLogfile != 0 {
-				flags = lF
-			} else {
-				Fatalln("Invalid identification of screen or logfile target for Flags()")
-			}
-			break
-		}
-	}
-	return flags
+	return std.Flags(level, outputTgt)
+}
+
+// EffectiveFlags returns the screen or logfile output flags that would be used
+// right now for the given level, unlike Flags() this accounts for any env
+// override via PKG_OUT_SCREEN_FLAGS or PKG_OUT_LOGFILE_FLAGS (which win over
+// the flags set via SetFlags()), handy for diagnostics or a --show-config
+// type option.  As with Flags() outputTgt is out.ForScreen or out.ForLogfile.
+func EffectiveFlags(level Level, outputTgt int) int {
+	return std.EffectiveFlags(level, outputTgt)
 }
 
 // SetFlags sets the screen and/or logfile output flags (Ldate, Ltime, .. above)
@@ -634,62 +1057,40 @@ func Flags(level Level, outputTgt int) int {
 // and the 3rd is what to set them on (out.ForScreen, out.ForLogfile, or
 // out.ForBoth)
 func SetFlags(level Level, flags int, outputTgt int) {
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		if level == LevelAll || o.level == level {
-			if outputTgt&ForScreen != 0 {
-				o.screenFlags = flags
-			}
-			if outputTgt&ForLogfile != 0 {
-				o.logFlags = flags
-			}
-			if level != LevelAll {
-				break
-			}
-		}
-	}
+	std.SetFlags(level, flags, outputTgt)
 }
 
 // Writer gets the screen or logfile output io.Writer for the given log
 // level, outputTgt is out.ForScreen or out.ForLogfile depending upon which
 // writer you want to grab for the given logging level
 func Writer(level Level, outputTgt int) io.Writer {
-	level = levelCheck(level)
-	writer := ioutil.Discard
-	for _, o := range outputters {
-		o.mu.RLock()
-		defer o.mu.RUnlock()
-		if o.level == level {
-			if outputTgt&ForScreen != 0 {
-				writer = o.screenHndl
-			}
-			if outputTgt&ForLogfile != 0 {
-				writer = o.logfileHndl
-			}
-		}
-	}
-	return writer
+	return std.Writer(level, outputTgt)
 }
 
 // SetWriter sets the screen and/or logfile output io.Writer for every log
 // level to the given writer
 func SetWriter(level Level, w io.Writer, outputTgt int) {
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		if level == LevelAll || o.level == level {
-			if outputTgt&ForScreen != 0 {
-				o.screenHndl = w
-			}
-			if outputTgt&ForLogfile != 0 {
-				o.logfileHndl = w
-			}
-			if level != LevelAll {
-				break
-			}
-		}
-	}
+	std.SetWriter(level, w, outputTgt)
+}
+
+// SetOutput is for folks moving over from Go's std 'log' package, it works
+// like log.SetOutput(w) in that all output gets pointed at the given writer,
+// but do note that this pkg has two output targets (screen and logfile) and
+// this only touches the screen target (for every level), ie: it is the same
+// as calling SetWriter(LevelAll, w, ForScreen).  The logfile target, which
+// is off by default, is left alone (see SetOutputFor() or SetLogFile()).
+// Also note that the thresholds still apply, by default only Info and higher
+// levels show up on the screen (see SetThreshold()), unlike the std 'log' pkg
+// where everything written goes to the writer.
+func SetOutput(w io.Writer) {
+	std.SetOutput(w)
+}
+
+// SetOutputFor is the same as SetOutput() but one can choose the target(s)
+// to point at the given writer via outputTgt (ForScreen, ForLogfile or both
+// via ForBoth), every level's writer for the target(s) is set
+func SetOutputFor(w io.Writer, outputTgt int) {
+	std.SetOutputFor(w, outputTgt)
 }
 
 // ResetNewline allows one to reset the screen and/or logfile LvlOutput so the
@@ -705,25 +1106,24 @@ func SetWriter(level Level, w io.Writer, outputTgt int) {
 //   out.ResetNewline(true, out.ForScreen|out.ForLogfile)
 // Note: for any *output* running through this module this is auto-handled
 func ResetNewline(val bool, outputTgt int) {
-	// Safely adjust these settings
-	mutex.Lock()
-	{
-		if outputTgt&ForScreen != 0 {
-			screenNewline = val
-		}
-		if outputTgt&ForLogfile != 0 {
-			logfileNewline = val
-		}
-	}
-	mutex.Unlock()
+	std.ResetNewline(val, outputTgt)
+}
+
+// EnsureNewline makes sure the screen and/or logfile output stream is on a
+// fresh line, ie: if the last output to that target (as tracked by this pkg)
+// did not end in a newline then a single newline is written to it (via the
+// INFO level handle for that target) and the newline state is set, otherwise
+// nothing is written.  Unlike ResetNewline(), which only adjusts the tracked
+// state, this actually "cleans up" the stream, eg: before printing a summary
+// or prompt after output that may have left the cursor mid-line.  It returns
+// the first write error that occurs (if any).
+func EnsureNewline(outputTgt int) error {
+	return std.EnsureNewline(outputTgt)
 }
 
 // LogFileName returns any known log file name (if none returns "")
 func LogFileName() string {
-	mutex.Lock()
-	safeLogFileName := logFileName
-	mutex.Unlock()
-	return safeLogFileName
+	return std.LogFileName()
 }
 
 // SetLogFile uses a log file path (passed in) to result in the log file
@@ -732,21 +1132,84 @@ func LogFileName() string {
 // logging level of course (default: LevelDiscard).  Please remember to set
 // a log level to turn logging on, eg: SetLogThreshold(LevelInfo)
 func SetLogFile(path string) {
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		Fatalln("Failed to open log file:", path, "Err:", err)
-	}
-	// Safely adjust this global settings
-	mutex.Lock()
-	{
-		logFileName = file.Name()
-	}
-	mutex.Unlock()
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		o.logfileHndl = file
-	}
+	std.SetLogFile(path)
+}
+
+// SetLogFileWithRotation is like SetLogFile() but the log file is rotated by
+// size so long running tools don't fill the disk, eg: to keep the log file
+// under 10MB with up to 5 older ones gzip'd (mytool.log.1.gz is the newest):
+//
+//	out.SetLogFileWithRotation("/var/log/mytool.log", 10<<20, 5, true)
+//
+// When a write would take the log file over maxBytes it is renamed to path.1
+// (path.1 goes to path.2 and so on up to path.<keep>) and a fresh log file is
+// started, rotated files beyond keep are deleted (with a keep of 0 the log
+// file is just started over).  A maxBytes of 0 turns rotation off.  If the
+// compress flag is set path.1 is gzip'd to path.1.gz in the background after
+// each rotation (compression errors are shown via Error(), the uncompressed
+// file is kept then), keep counts compressed and uncompressed files alike.
+// Writes to the log file from all the levels go through one writer so that
+// rotation is safe with concurrent output.  Errors opening the log file result
+// in Fatalln().
+func SetLogFileWithRotation(path string, maxBytes int64, keep int, compress bool) {
+	std.SetLogFileWithRotation(path, maxBytes, keep, compress)
+}
+
+// SetLogFileDailyRotation is like SetLogFile() but the log file moves to a
+// new dated file each day, eg: for audit logs:
+//
+//	out.SetLogFileDailyRotation("/var/log/mytool/audit-%Y-%m-%d.log")
+//
+// The %Y, %m and %d tokens in the path pattern are replaced with the (local)
+// year, month and day (use %% for a '%').  The first write after midnight
+// closes the old file and opens the file for the new date, if nothing was
+// written for several days the file for the current date is used (no empty
+// files for the days in between).  LogFileName() returns the dated file in
+// use.  Errors opening the log file result in Fatalln().
+func SetLogFileDailyRotation(pathPattern string) {
+	std.SetLogFileDailyRotation(pathPattern)
+}
+
+// ReopenLogFile reopens the log file (see SetLogFile() and friends) and points
+// the logfile output for every level that used it at the new handle, for use
+// after an external tool (eg: logrotate) has moved the log file aside so the
+// output doesn't keep going to the old file (see HandleReopenSignal()).  This
+// is a no-op if no log file was configured.  If the log file can't be opened
+// the error is returned and the previous handle is kept.
+func ReopenLogFile() error {
+	return std.ReopenLogFile()
+}
+
+// CloseLogFile closes the log file (see SetLogFile() and friends), points the
+// logfile output for every level that used it at ioutil.Discard and clears
+// the log file name, eg: in a defer func so the log file is closed on exit:
+//
+//	out.SetDeferFunc(func(exitVal int) { out.CloseLogFile() })
+//
+// This is a no-op if no log file was configured.  Output racing with the
+// close goes nowhere (rather than failing).  Returns any error from closing
+// the log file.
+func CloseLogFile() error {
+	return std.CloseLogFile()
+}
+
+// HandleReopenSignal installs a handler that calls ReopenLogFile() each time
+// the given signal arrives, eg: for logrotate's usual postrotate step:
+//
+//	out.HandleReopenSignal(syscall.SIGHUP)
+//
+// Reopen errors are shown via Error() and the previous handle is kept.  Each
+// call adds a handler, so only call it once per signal.
+func HandleReopenSignal(sig os.Signal) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, sig)
+	go func() {
+		for range sigs {
+			if err := ReopenLogFile(); err != nil {
+				Errorln("Failed to reopen log file:", LogFileName(), "Err:", err)
+			}
+		}
+	}()
 }
 
 // UseTempLogFile creates a temp file and "points" the fileLogger logger at that
@@ -756,48 +1219,12 @@ func SetLogFile(path string) {
 // Note: to finish enabling logging remember to set the logging level to a valid
 // level (LevelDiscard is the fileLog default), eg: SetLogThreshold(LevelInfo)
 func UseTempLogFile(prefix string) string {
-	file, err := ioutil.TempFile(os.TempDir(), prefix)
-	if err != nil {
-		Fatalln(err)
-	}
-	// Safely adjust these settings
-	mutex.Lock()
-	logFileName = file.Name()
-	safeLogFileName := logFileName
-	mutex.Unlock()
-	for _, o := range outputters {
-		o.mu.Lock()
-		defer o.mu.Unlock()
-		o.logfileHndl = file
-	}
-	return safeLogFileName
+	return std.UseTempLogFile(prefix)
 }
 
 // Next we head into the <Level>() class methods which don't add newlines
 // and simply space separate the options sent to them:
 
-// Trace is the most verbose debug level, space separate opts with no newline
-// added and is by default prefixed with "Trace: <date/time> <msg>" for each
-// line but you can use flags and remove the timestamp, can also drop the prefix
-func Trace(v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	TRACE.output(terminate, exitVal, v...)
-}
-
-// Debug is meant for basic debugging, space separate opts with no newline added
-// and is, by default, prefixed with "Debug: <date/time> <your msg>" for each
-// line but you can use flags and remove the timestamp, can also drop the prefix
-func Debug(v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	DEBUG.output(terminate, exitVal, v...)
-}
-
 // Verbose meant for verbose user seen screen output, space separated
 // opts printed with no newline added, no output prefix is added by default
 func Verbose(v ...interface{}) {
@@ -805,7 +1232,7 @@ func Verbose(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	VERBOSE.output(terminate, exitVal, v...)
+	VERBOSE.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Print is meant for "normal" user output, space separated opted
@@ -815,7 +1242,7 @@ func Print(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.output(terminate, exitVal, v...)
+	INFO.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Info is the same as Print: meant for "normal" user output, space separated
@@ -825,7 +1252,7 @@ func Info(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.output(terminate, exitVal, v...)
+	INFO.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Note is meant for output of key "note" the user should pay attention to, opts
@@ -836,7 +1263,18 @@ func Note(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	NOTE.output(terminate, exitVal, v...)
+	NOTE.output(terminate, exitVal, ForBoth, v...)
+}
+
+// Warn is meant for warnings the user should heed (but that aren't usage
+// issues or errors), opts space separated and printed with no newline added,
+// "Warning: <msg>" prefix is also added by default
+func Warn(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	WARN.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Issue is meant for "normal" user error output, space separated opts
@@ -847,7 +1285,7 @@ func Issue(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ISSUE.output(terminate, exitVal, v...)
+	ISSUE.output(terminate, exitVal, ForBoth, v...)
 }
 
 // IssueExit is meant for "normal" user error output, space separated opts
@@ -858,7 +1296,7 @@ func IssueExit(exitVal int, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ISSUE.output(terminate, exitVal, v...)
+	ISSUE.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Error is meant for "unexpected"/system error output, space separated
@@ -871,7 +1309,7 @@ func Error(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ERROR.output(terminate, exitVal, v...)
+	ERROR.output(terminate, exitVal, ForBoth, v...)
 }
 
 // ErrorExit is meant for "unexpected"/system error output, space separated
@@ -884,7 +1322,7 @@ func ErrorExit(exitVal int, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ERROR.output(terminate, exitVal, v...)
+	ERROR.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Fatal is meant for "unexpected"/system fatal error output, space separated
@@ -895,34 +1333,12 @@ func Fatal(v ...interface{}) {
 	terminate := true
 	exitVal := int(atomic.LoadInt32(&errorExitVal))
 	mutex.Unlock()
-	FATAL.output(terminate, exitVal, v...)
+	FATAL.output(terminate, exitVal, ForBoth, v...)
 }
 
 // Next we head into the <Level>ln() class methods which add newlines
 // and space separate the options sent to them:
 
-// Traceln is the most verbose debug level, space separate opts with newline
-// added and is, by default, prefixed with "Trace: <your output>" for each line
-// but you can use flags and remove the timestamp, can also drop the prefix
-func Traceln(v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	TRACE.outputln(terminate, exitVal, v...)
-}
-
-// Debugln is meant for basic debugging, space separate opts with newline added
-// and is, by default, prefixed with "Debug: <date/time> <yourmsg>" for each
-// line but you can use flags and remove the timestamp, can also drop the prefix
-func Debugln(v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	DEBUG.outputln(terminate, exitVal, v...)
-}
-
 // Verboseln is meant for verbose user seen screen output, space separated
 // opts printed with newline added, no output prefix is added by default
 func Verboseln(v ...interface{}) {
@@ -930,7 +1346,7 @@ func Verboseln(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	VERBOSE.outputln(terminate, exitVal, v...)
+	VERBOSE.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Println is the same as Infoln: meant for "normal" user output, space
@@ -941,7 +1357,7 @@ func Println(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.outputln(terminate, exitVal, v...)
+	INFO.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Infoln is the same as Println: meant for "normal" user output, space
@@ -952,7 +1368,7 @@ func Infoln(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.outputln(terminate, exitVal, v...)
+	INFO.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Noteln is meant for output of key items the user should pay attention to,
@@ -963,7 +1379,18 @@ func Noteln(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	NOTE.outputln(terminate, exitVal, v...)
+	NOTE.outputln(terminate, exitVal, ForBoth, v...)
+}
+
+// Warnln is meant for warnings the user should heed, opts are space separated
+// and printed with a newline added, "Warning: <msg>" prefix is also added by
+// default
+func Warnln(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	WARN.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Issueln is meant for "normal" user error output, space separated
@@ -976,7 +1403,7 @@ func Issueln(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ISSUE.outputln(terminate, exitVal, v...)
+	ISSUE.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // IssueExitln is meant for "normal" user error output, space separated opts
@@ -989,7 +1416,7 @@ func IssueExitln(exitVal int, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ISSUE.outputln(terminate, exitVal, v...)
+	ISSUE.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Errorln is meant for "unexpected"/system error output, space separated
@@ -1001,7 +1428,7 @@ func Errorln(v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ERROR.outputln(terminate, exitVal, v...)
+	ERROR.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // ErrorExitln is meant for "unexpected"/system error output, space separated
@@ -1016,7 +1443,7 @@ func ErrorExitln(exitVal int, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ERROR.outputln(terminate, exitVal, v...)
+	ERROR.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Fatalln is meant for "unexpected"/system fatal error output, space separated
@@ -1029,34 +1456,12 @@ func Fatalln(v ...interface{}) {
 	terminate := true
 	exitVal := int(atomic.LoadInt32(&errorExitVal))
 	mutex.Unlock()
-	FATAL.outputln(terminate, exitVal, v...)
+	FATAL.outputln(terminate, exitVal, ForBoth, v...)
 }
 
 // Next we head into the <Level>f() class methods which take a standard
 // format string for go (see 'godoc fmt' and look at Printf() if needed):
 
-// Tracef is the most verbose debug level, format string followed by args and
-// output is, by default, prefixed with "Trace: <date/time> <your msg>" for each
-// line but you can use flags and remove the timestamp, can also drop the prefix
-func Tracef(format string, v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	TRACE.outputf(terminate, exitVal, format, v...)
-}
-
-// Debugf is meant for basic debugging, format string followed by args and
-// output is by default prefixed with "Debug: <date/time> <your msg>" for each
-// line but you can use flags and remove the timestamp, can also drop the prefix
-func Debugf(format string, v ...interface{}) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	DEBUG.outputf(terminate, exitVal, format, v...)
-}
-
 // Verbosef is meant for verbose user seen screen output, format string
 // followed by args (and no output prefix is added by default)
 func Verbosef(format string, v ...interface{}) {
@@ -1064,7 +1469,7 @@ func Verbosef(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	VERBOSE.outputf(terminate, exitVal, format, v...)
+	VERBOSE.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Printf is the same as Infoln: meant for "normal" user output, format string
@@ -1074,7 +1479,7 @@ func Printf(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.outputf(terminate, exitVal, format, v...)
+	INFO.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Infof is the same as Printf: meant for "normal" user output, format string
@@ -1084,7 +1489,7 @@ func Infof(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	INFO.outputf(terminate, exitVal, format, v...)
+	INFO.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Notef is meant for output of key "note" the user should pay attention to,
@@ -1094,7 +1499,17 @@ func Notef(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	NOTE.outputf(terminate, exitVal, format, v...)
+	NOTE.outputf(terminate, exitVal, ForBoth, format, v...)
+}
+
+// Warnf is meant for warnings the user should heed, format string followed
+// by args, "Warning: <yourmsg>" prefixed by default
+func Warnf(format string, v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	WARN.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Issuef is meant for "normal" user error output, format string followed
@@ -1105,7 +1520,7 @@ func Issuef(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ISSUE.outputf(terminate, exitVal, format, v...)
+	ISSUE.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // IssueExitf is meant for "normal" user error output, format string followed
@@ -1116,7 +1531,7 @@ func IssueExitf(exitVal int, format string, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ISSUE.outputf(terminate, exitVal, format, v...)
+	ISSUE.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Errorf is meant for "unexpected"/system error output, format string
@@ -1128,7 +1543,7 @@ func Errorf(format string, v ...interface{}) {
 	terminate := false
 	exitVal := 0
 	mutex.Unlock()
-	ERROR.outputf(terminate, exitVal, format, v...)
+	ERROR.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // ErrorExitf is meant for "unexpected"/system error output, format string
@@ -1138,7 +1553,7 @@ func ErrorExitf(exitVal int, format string, v ...interface{}) {
 	mutex.Lock()
 	terminate := true
 	mutex.Unlock()
-	ERROR.outputf(terminate, exitVal, format, v...)
+	ERROR.outputf(terminate, exitVal, ForBoth, format, v...)
 }
 
 // Fatalf is meant for "unexpected"/system fatal error output, format string
@@ -1149,7 +1564,28 @@ func Fatalf(format string, v ...interface{}) {
 	terminate := true
 	exitVal := int(atomic.LoadInt32(&errorExitVal))
 	mutex.Unlock()
-	FATAL.outputf(terminate, exitVal, format, v...)
+	FATAL.outputf(terminate, exitVal, ForBoth, format, v...)
+}
+
+// EmitRaw sends a pre-formatted message verbatim through the given level,
+// ie: there is no fmt processing so '%' in the message can't be mistaken
+// for a formatting verb (as it would be with Printf(msg)).  Prefixes, flags,
+// formatters and thresholds all still apply.  This is meant for forwarding
+// log entries from other systems so, even at LevelFatal, it will not exit.
+func EmitRaw(level Level, msg string) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	LevelWriter(level).outputRaw(terminate, exitVal, ForBoth, msg)
+}
+
+// Verbosek is like Verbose() but the message comes from calling fn, which
+// is only called if the output isn't below the screen and logfile output
+// thresholds, eg: out.Verbosek(func() string { return dumpCfg(cfg) })
+// avoids building the string at all if verbose output is not enabled
+func Verbosek(fn func() string) {
+	VERBOSE.output(false, 0, ForBoth, lazyString(fn))
 }
 
 // Exit is meant for terminating without messaging but supporting stack trace
@@ -1178,26 +1614,74 @@ func Exit(exitVal int) {
 // One can also use the env PKG_OUT_STACK_TRACE_CONFIG set to comma separated
 // settings, eg: "screen,nonzeroerrorexit" or "both,allissues", if invalid
 // it will be ignored and no stack traces will dump based on the env settings.
-// Note: One cannot, now, differentiate stack trace settings between screen and
-// logfile settings (ie: you currently cannot have stack traces to the logfile
-// for say any issue while having stack traces to the screen for non-zero exit
-// issues... although one could extend this module if desired for that).
+// Note: this sets the screen and logfile settings together, any target not
+// given gets no stack traces, see SetStackTraceConfigForTarget() to set up the
+// screen and logfile differently (eg: stack traces to the logfile for any
+// issue while only having stack traces to the screen for non-zero exits).
 func SetStackTraceConfig(cfg int) {
 	// Safely adjust these settings, switch to atomic perhaps
 	mutex.Lock()
 	{
-		stackTraceConfig = cfg
+		screenStackTrace, logfileStackTrace = 0, 0
+		if cfg&ForScreen != 0 {
+			screenStackTrace = cfg &^ ForBoth
+		}
+		if cfg&ForLogfile != 0 {
+			logfileStackTrace = cfg &^ ForBoth
+		}
+	}
+	mutex.Unlock()
+}
+
+// SetStackTraceConfigForTarget sets when stack traces are dumped for just the
+// given output target(s), ForScreen, ForLogfile or ForBoth, leaving any other
+// target as it is.  The trigger is one of the StackTraceNonZeroErrorExit,
+// StackTraceErrorExit or StackTraceAllIssues flags (see SetStackTraceConfig())
+// or 0 for no stack traces, eg: to get stack traces in the logfile for all
+// issues and errors but on the screen only for non-zero exits:
+//
+//	out.SetStackTraceConfigForTarget(out.ForLogfile, out.StackTraceAllIssues)
+//	out.SetStackTraceConfigForTarget(out.ForScreen, out.StackTraceNonZeroErrorExit)
+//
+// The env PKG_OUT_STACK_TRACE_CONFIG, if set and valid, overrides this.
+func SetStackTraceConfigForTarget(outputTgt int, trigger int) {
+	trigger &^= ForBoth
+	mutex.Lock()
+	{
+		if outputTgt&ForScreen != 0 {
+			screenStackTrace = trigger
+		}
+		if outputTgt&ForLogfile != 0 {
+			logfileStackTrace = trigger
+		}
 	}
 	mutex.Unlock()
 }
 
+// StackTraceConfigForTarget returns the stack trace trigger flags in use for
+// the given output target, ForScreen or ForLogfile (0 if stack traces are off
+// for the target), see SetStackTraceConfigForTarget()
+func StackTraceConfigForTarget(outputTgt int) int {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if outputTgt&ForScreen != 0 {
+		return screenStackTrace
+	}
+	if outputTgt&ForLogfile != 0 {
+		return logfileStackTrace
+	}
+	return 0
+}
+
 // getStackTrace will get a stack trace (of the desired depth) and return
-// it.  Currently callDepth is used assuming this is being called from the
-// defined routes into the 'out' pkg (ie: this will map to where 'out' was
-// called or used from basically, ignoring the various methods in this pkg
-// so as to give a stack trace relative to the users code).
-func getStackTrace(detErr DetailedError, depth ...int) string {
+// it along with its frames (nil for lazy stack traces).  Currently callDepth
+// is used assuming this is being called from the defined routes into the
+// 'out' pkg (ie: this will map to where 'out' was called or used from
+// basically, ignoring the various methods in this pkg so as to give a stack
+// trace relative to the users code), unless SetStackTraceTrimInternal() is on.
+func getStackTrace(detErr DetailedError, depth ...int) (string, []StackFrame) {
 	var myStack string
+	var frames []StackFrame
 	if detErr != nil {
 		// If we have a DetailedError we can get the innermost stack tarce so
 		// we have the most detail possible in our stack trace:
@@ -1205,7 +1689,9 @@ func getStackTrace(detErr DetailedError, depth ...int) string {
 		var origStack string
 		shallow := false
 		fillErrorInfo(detErr, shallow, &errLines, &origStack)
+		origStack = trimStackFrames(origStack)
 		myStack = "\nStack Trace: " + origStack + "\n"
+		frames = parseStackFrames(origStack)
 	} else {
 		// Not a DetailedError, lets get a stack trace relative to the call
 		// to the 'out' pkg API (eg: out.Error("whatever"), where user called)
@@ -1213,10 +1699,21 @@ func getStackTrace(detErr DetailedError, depth ...int) string {
 		if depth != nil {
 			myDepth = depth[0]
 		}
-		trace, _ := stackTrace(myDepth)
+		if LazyStackTrace() {
+			// just grab the PC's, symbolized later only if output, note
+			// that depth here is relative to us (not stackTrace())
+			return "\nStack Trace: " + lazyStack(myDepth-1) + "\n", nil
+		}
+		var trace string
+		if StackTraceTrimInternal() {
+			trace, frames = trimmedStackTrace()
+		} else {
+			trace, _ = stackTrace(myDepth)
+			frames = parseStackFrames(trace)
+		}
 		myStack = fmt.Sprintf("\nStack Trace: %s\n", trace)
 	}
-	return myStack
+	return myStack, frames
 }
 
 // InsertPrefix takes a multiline string (potentially) and for each
@@ -1230,8 +1727,12 @@ func getStackTrace(detErr DetailedError, depth ...int) string {
 //     BlankInsert             // Only spaces inserted (same length as prefix)
 //     SkipFirstLine           // 1st line in multi-line string has no prefix
 //     SmartInsert             // See doPrefixing(), only handled there now
+// Note that the "length" of the prefix for BlankInsert is its display width,
+// ie: tabs in the prefix are expanded to the tab width (see SetTabWidth())
 // - errCode: attempt to insert any valid error code into the prefix, eg:
-//     // a prefix of "Error: " would become "Error #<errcode>: "
+//     // a prefix of "Error: " would become "Error #<errcode>: ", or
+//     // "Error #<errcode>(<name>): " if the code has a name registered
+//     // via RegisterErrorCode()
 func InsertPrefix(s string, prefix string, ctrl int, errCode int) string {
 	// FEATURE: add ability to prefix the 1st line only (smartly or always) and
 	//          then "blank prefix" the rest of the lines (readability better?)
@@ -1245,33 +1746,44 @@ func InsertPrefix(s string, prefix string, ctrl int, errCode int) string {
 	// If there is an error code of interest then insert it into the message
 	// if possible... braindead, must be something like "Error: " or "Issue: "
 	// and so a split on ":" results in two strings, results: "Error #<code>: "
-	if errCode > 0 && errCode != int(defaultErrCode) {
+	if errCode > 0 && errCode != int(DefaultErrCode()) {
 		parts := strings.Split(prefix, ":")
 		if len(parts) == 2 {
-			prefix = parts[0] + fmt.Sprintf(" #%d:", errCode) + parts[1]
-		}
-	}
-	pfxLength := len(prefix)
-	format := "%" + fmt.Sprintf("%d", pfxLength) + "s"
-	spacePrefix := fmt.Sprintf(format, "")
-	lines := strings.Split(s, "\n")
-	numLines := len(lines)
-	newLines := []string{}
-	for idx, line := range lines {
-		if (idx == numLines-1 && line == "") ||
-			(idx == 0 && ctrl&SkipFirstLine != 0) {
+			prefix = parts[0] + " #" + errorCodeLabel(errCode) + ":" + parts[1]
+		}
+	}
+	pfxLength := 0
+	if ctrl&BlankInsert != 0 {
+		pfxLength = displayWidth(prefix)
+	}
+	buf := getOutputBuf()
+	for idx := 0; ; idx++ {
+		line := s
+		end := strings.IndexByte(s, '\n')
+		if end >= 0 {
+			line = s[:end]
+		}
+		if idx > 0 {
+			*buf = append(*buf, '\n')
+		}
+		if (end < 0 && line == "") || (idx == 0 && ctrl&SkipFirstLine != 0) {
 			// if last line and it's empty don't prefix it, add empty line or if
-			// it's the 1st line and we are to skip prefixing the 1st line:
-			newLines = append(newLines, line)
+			// it's the 1st line and we are to skip prefixing the 1st line
 		} else if ctrl&BlankInsert != 0 {
 			// if blank-only prefix desired then go with that for all lines
-			newLines = append(newLines, spacePrefix+line)
+			appendPadding(buf, pfxLength)
 		} else {
 			// otherwise prefix every line with given prefix
-			newLines = append(newLines, prefix+line)
+			*buf = append(*buf, prefix...)
 		}
+		*buf = append(*buf, line...)
+		if end < 0 {
+			break
+		}
+		s = s[end+1:]
 	}
-	newstr := strings.Join(newLines, "\n")
+	newstr := string(*buf)
+	putOutputBuf(buf)
 	return newstr
 }
 
@@ -1290,9 +1802,51 @@ func getAnyDetailedErrors(v ...interface{}) []DetailedError {
 	return detErrs
 }
 
+// skipQuietOutput returns true if output at this level can't go anywhere so
+// the caller can skip formatting it, ie: the level is below both the screen
+// and logfile thresholds and nothing else wants the output (strict mode, the
+// replay buffer, a package threshold or LastFatal() for errors).  Skipped
+// output is still counted for the exit summary and any accumulated exit
+// code.  Output that exits the tool (terminal) is never skipped.
+func (o *LvlOutput) skipQuietOutput(terminal bool) bool {
+	if terminal || !o.quiet() {
+		return false
+	}
+	countOutput(o.level)
+	accumulateExit(o.level.Severity())
+	return true
+}
+
+// quiet returns true if output at this level can't go anywhere, see
+// skipQuietOutput() (this one has no side effects)
+func (o *LvlOutput) quiet() bool {
+	level := o.level.Severity() // never changes once the level is set up
+	p := o.parent()
+	if level >= LevelError || int32(level) >= atomic.LoadInt32(&p.quietBelow) {
+		return false
+	}
+	if p == std && (atomic.LoadInt32(&strictState) != strictOff || atomic.LoadInt32(&replayBufSize) != 0 || passesPackageThreshold(level)) {
+		return false
+	}
+	return true
+}
+
+// lazyString is a fmt.Stringer that calls the func to get the string, it
+// is used by the <Level>k() routines so the func is only called if the
+// output isn't skipped by skipQuietOutput() above
+type lazyString func() string
+
+// String calls the func to get the string
+func (f lazyString) String() string {
+	return f()
+}
+
 // output is similar to fmt.Print(), it'll space separate args with no newline
 // and output them to the screen and/or log file loggers based on levels
-func (o *LvlOutput) output(terminal bool, exitVal int, v ...interface{}) {
+func (o *LvlOutput) output(terminal bool, exitVal int, outputTgt int, v ...interface{}) {
+	if o.skipQuietOutput(terminal) {
+		return
+	}
 	detErrs := getAnyDetailedErrors(v...)
 	var detErr DetailedError
 	if detErrs != nil {
@@ -1304,33 +1858,47 @@ func (o *LvlOutput) output(terminal bool, exitVal int, v ...interface{}) {
 		// output level always
 		detErr.SetLvlOut(o)
 	}
-	// set up the message to dump
-	msg := fmt.Sprint(v...)
+	// set up the message to dump (honoring any nil/error rendering settings)
+	msg := fmt.Sprint(renderArgs(v)...)
 
 	// dump msg based on screen and log output levels
-	_, err := o.stringOutput(msg, terminal, exitVal, detErr)
+	_, err := o.stringOutput(msg, terminal, exitVal, outputTgt, "", nil, 0, detErr)
 	if err != nil {
-		mutex.Lock()
-		{
-			fmt.Fprintf(os.Stderr, "%s", err)
-		}
-		mutex.Unlock()
-		mutex.RLock()
-		if deferFunc != nil {
-			deferFunc(int(atomic.LoadInt32(&errorExitVal)))
-		}
-		mutex.RUnlock()
-		if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-			os.Exit(int(atomic.LoadInt32(&errorExitVal)))
-		}
+		outputFailed(err)
+	}
+}
+
+// outputFailed is used when writing output failed, there isn't much else
+// to do but report the error on stderr and exit (unless exit is overridden)
+func outputFailed(err error) {
+	var stderrErr error
+	mutex.Lock()
+	{
+		_, stderrErr = fmt.Fprintf(os.Stderr, "%s", err)
+	}
+	mutex.Unlock()
+	unrecoverableWriteError(err, stderrErr)
+	exitCleanup(int(atomic.LoadInt32(&errorExitVal)))
+	exitTool(int(atomic.LoadInt32(&errorExitVal)))
+}
+
+// outputRaw sends the given message as-is (no fmt processing at all) to the
+// screen and/or log file loggers based on levels
+func (o *LvlOutput) outputRaw(terminal bool, exitVal int, outputTgt int, msg string) {
+	_, err := o.stringOutput(msg, terminal, exitVal, outputTgt, "", nil, 0)
+	if err != nil {
+		outputFailed(err)
 	}
 }
 
 // outputln is similar to fmt.Println(), it'll space separate args with no
 // newline and output them to the screen and/or log file loggers based on levels
-func (o *LvlOutput) outputln(terminal bool, exitVal int, v ...interface{}) {
-	// set up the message to dump
-	msg := fmt.Sprintln(v...)
+func (o *LvlOutput) outputln(terminal bool, exitVal int, outputTgt int, v ...interface{}) {
+	if o.skipQuietOutput(terminal) {
+		return
+	}
+	// set up the message to dump (honoring any nil/error rendering settings)
+	msg := fmt.Sprintln(renderArgs(v)...)
 
 	detErrs := getAnyDetailedErrors(v...)
 	var detErr DetailedError
@@ -1339,27 +1907,18 @@ func (o *LvlOutput) outputln(terminal bool, exitVal int, v ...interface{}) {
 	}
 
 	// dump msg based on screen and log output levels
-	_, err := o.stringOutput(msg, terminal, exitVal, detErr)
+	_, err := o.stringOutput(msg, terminal, exitVal, outputTgt, "", nil, 0, detErr)
 	if err != nil {
-		mutex.Lock()
-		{
-			fmt.Fprintf(os.Stderr, "%s", err)
-		}
-		mutex.Unlock()
-		mutex.RLock()
-		if deferFunc != nil {
-			deferFunc(int(atomic.LoadInt32(&errorExitVal)))
-		}
-		mutex.RUnlock()
-		if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-			os.Exit(int(atomic.LoadInt32(&errorExitVal)))
-		}
+		outputFailed(err)
 	}
 }
 
 // outputf is similar to fmt.Printf(), it takes a format and args and outputs
 // the resulting string to the screen and/or log file loggers based on levels
-func (o *LvlOutput) outputf(terminal bool, exitVal int, format string, v ...interface{}) {
+func (o *LvlOutput) outputf(terminal bool, exitVal int, outputTgt int, format string, v ...interface{}) {
+	if o.skipQuietOutput(terminal) {
+		return
+	}
 	// set up the message to dump
 	msg := fmt.Sprintf(format, v...)
 
@@ -1370,21 +1929,9 @@ func (o *LvlOutput) outputf(terminal bool, exitVal int, format string, v ...inte
 	}
 
 	// dump msg based on screen and log output levels
-	_, err := o.stringOutput(msg, terminal, exitVal, detErr)
+	_, err := o.stringOutput(msg, terminal, exitVal, outputTgt, "", nil, 0, detErr)
 	if err != nil {
-		mutex.Lock()
-		{
-			fmt.Fprintf(os.Stderr, "%s", err)
-		}
-		mutex.Unlock()
-		mutex.RLock()
-		if deferFunc != nil {
-			deferFunc(int(atomic.LoadInt32(&errorExitVal)))
-		}
-		mutex.RUnlock()
-		if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-			os.Exit(int(atomic.LoadInt32(&errorExitVal)))
-		}
+		outputFailed(err)
 	}
 }
 
@@ -1395,7 +1942,7 @@ func (o *LvlOutput) outputf(terminal bool, exitVal int, format string, v ...inte
 // been set up by the client (via API or env settings, env takes precendence)
 func (o *LvlOutput) stackTraceWanted(terminal bool, exitVal int, outputTgt int) bool {
 	mutex.Lock()
-	stackCfg := stackTraceConfig
+	screenCfg, logfileCfg := screenStackTrace, logfileStackTrace
 	defer mutex.Unlock()
 	val := os.Getenv("PKG_OUT_STACK_TRACE_CONFIG")
 	if val != "" {
@@ -1422,16 +1969,30 @@ func (o *LvlOutput) stackTraceWanted(terminal bool, exitVal int, outputTgt int)
 				default:
 				}
 			}
-			stackCfg = newCfg
+			// the env setting is for both targets, like SetStackTraceConfig()
+			screenCfg, logfileCfg = 0, 0
+			if newCfg&ForScreen != 0 {
+				screenCfg = newCfg &^ ForBoth
+			}
+			if newCfg&ForLogfile != 0 {
+				logfileCfg = newCfg &^ ForBoth
+			}
 		}
 	}
-	// See if our output target (screen|logfile) wants a stack trace or not...
-	if stackCfg&outputTgt == 0 {
-		return false
-	}
 	o.mu.RLock()
-	level := o.level
+	level := o.level.Severity()
 	o.mu.RUnlock()
+	// See if our output target(s) (screen|logfile) want a stack trace or not...
+	if outputTgt&ForScreen != 0 && stackTraceTriggered(screenCfg, terminal, exitVal, level) {
+		return true
+	}
+	return outputTgt&ForLogfile != 0 && stackTraceTriggered(logfileCfg, terminal, exitVal, level)
+}
+
+// stackTraceTriggered returns true if the stack trace trigger flags given
+// (see SetStackTraceConfigForTarget()) call for a stack trace for output of
+// the given level severity, terminal (exiting) or not with the exit value
+func stackTraceTriggered(stackCfg int, terminal bool, exitVal int, level Level) bool {
 	// Now see if the detailed config really implies a stack trace is wanted...
 	if stackCfg&StackTraceNonZeroErrorExit != 0 {
 		// config indicates only terminal non-zero exit should have stack trace
@@ -1465,52 +2026,48 @@ func (o *LvlOutput) stackTraceWanted(terminal bool, exitVal int, outputTgt int)
 // dump that stacktrace as well (honoring all log levels and such),
 // see getStackTrace() for the env and package settings honored.
 func (o *LvlOutput) exit(exitVal int) {
+	// send out any held strict mode output before we go
+	FlushStrictMode()
 	// get the stacktrace if it's configured, note that the depth is
 	// a little shallower if coming straight through Exit() to here:
 	mutex.Lock()
-	stacktrace := getStackTrace(nil, int(CallDepth())-1)
+	stacktrace, _ := getStackTrace(nil, int(CallDepth())-1)
+	stacktrace = SymbolizeStack(stacktrace)
 	terminal := true
-	safeLogThreshold := logThreshold
-	safeScreenThreshold := screenThreshold
+	p := o.parent()
+	safeLogThreshold := p.logThresh()
+	safeScreenThreshold := p.screenThresh()
+	screenThreshFunc := p.screenThresholdFunc
+	logThreshFunc := p.logThresholdFunc
 	mutex.Unlock()
 	o.mu.RLock()
 	level := o.level
 	o.mu.RUnlock()
-	if stacktrace != "" && o.stackTraceWanted(terminal, exitVal, ForScreen) && level >= safeScreenThreshold && level != LevelDiscard {
-		msg, _, suppressOutput := o.doPrefixing(stacktrace, ForScreen, SmartInsert, nil, false)
+	mmeta := newMsgMetadata("")
+	if stacktrace != "" && o.stackTraceWanted(terminal, exitVal, ForScreen) && passesThreshold(level, safeScreenThreshold, screenThreshFunc) {
+		msg, mdata, suppressOutput := o.doPrefixing(stacktrace, ForScreen, SmartInsert, mmeta, nil, false)
 		if !suppressOutput && msg != "" {
 			mutex.Lock()
-			_, err := o.screenHndl.Write([]byte(msg))
+			_, err := writeHandle(o.screenHndl, []byte(msg), level, mdata)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%sError writing stacktrace to screen output handle:\n%+v\n", o.prefix, err)
+				_, stderrErr := fmt.Fprintf(os.Stderr, "%sError writing stacktrace to screen output handle:\n%+v\n", o.prefix, err)
 				mutex.Unlock()
-				mutex.RLock()
-				if deferFunc != nil {
-					deferFunc(int(atomic.LoadInt32(&errorExitVal)))
-				}
-				mutex.RUnlock()
-				if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-					os.Exit(int(atomic.LoadInt32(&errorExitVal)))
-				}
+				unrecoverableWriteError(err, stderrErr)
+				exitCleanup(int(atomic.LoadInt32(&errorExitVal)))
+				exitTool(int(atomic.LoadInt32(&errorExitVal)))
 				mutex.Lock()
 			}
 			mutex.Unlock()
 		}
 	}
-	if stacktrace != "" && o.stackTraceWanted(terminal, exitVal, ForLogfile) && level >= safeLogThreshold && level != LevelDiscard {
-		msg, _, suppressOutput := o.doPrefixing(stacktrace, ForLogfile, SmartInsert, nil, false)
+	if stacktrace != "" && o.stackTraceWanted(terminal, exitVal, ForLogfile) && passesThreshold(level, safeLogThreshold, logThreshFunc) {
+		msg, mdata, suppressOutput := o.doPrefixing(stacktrace, ForLogfile, SmartInsert, mmeta, nil, false)
 		if !suppressOutput && msg != "" {
-			o.logfileHndl.Write([]byte(msg))
+			writeHandle(o.logfileHndl, []byte(msg), level, mdata)
 		}
 	}
-	mutex.RLock()
-	if deferFunc != nil {
-		deferFunc(exitVal)
-	}
-	mutex.RUnlock()
-	if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-		os.Exit(exitVal)
-	}
+	exitCleanup(exitVal)
+	exitTool(exitVal)
 }
 
 // itoa converts an int to fixed-width decimal ASCII.  Give a negative width to
@@ -1533,23 +2090,129 @@ func itoa(buf *[]byte, i int, wid int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
+// appendRelTime adds the time since the program started (see Lreltime) as
+// seconds with microseconds, eg: "[   0.001234]" (the seconds are padded to
+// 4 wide so the output lines up for a few hours)
+func appendRelTime(buf *[]byte, elapsed time.Duration) {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	secs := int(elapsed / time.Second)
+	digits := 1
+	for n := secs; n >= 10; n /= 10 {
+		digits++
+	}
+	*buf = append(*buf, '[')
+	appendPadding(buf, 4-digits)
+	itoa(buf, secs, 1)
+	*buf = append(*buf, '.')
+	itoa(buf, int(elapsed%time.Second/time.Microsecond), 6)
+	*buf = append(*buf, ']')
+}
+
+// appendPadding adds n spaces to the buffer (none if n isn't positive)
+func appendPadding(buf *[]byte, n int) {
+	for ; n > 0; n-- {
+		*buf = append(*buf, ' ')
+	}
+}
+
+// maxPooledBufSize is the largest buffer kept in outputBufPool, the odd huge
+// message shouldn't pin a huge buffer
+const maxPooledBufSize = 64 * 1024
+
+// outputBufPool holds the buffers used to build the flag metadata and the
+// prefixed output, see getOutputBuf() and putOutputBuf()
+var outputBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+// getOutputBuf returns an empty buffer from the pool, hand it back via the
+// putOutputBuf() routine once done with it
+func getOutputBuf() *[]byte {
+	buf := outputBufPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putOutputBuf returns a buffer to the pool, nothing may use the buffer after
+// this (strings built from it with string(*buf) are copies so they're fine)
+func putOutputBuf(buf *[]byte) {
+	if cap(*buf) <= maxPooledBufSize {
+		outputBufPool.Put(buf)
+	}
+}
+
 // getFlagString takes the time the output func was called and tries
 // to construct a string to put in the log file (uses the flags settings
 // to decide what metadata to print, ie: one can "or" together different
 // flags to identify what should be dumped, like the Go 'log' package but
-// more flags are available, see top of file)
-func getFlagString(buf *[]byte, flags int, level Level, funcName string, file string, line int, t time.Time) string {
+// more flags are available, see top of file), the date/time is written with
+// the timeFormat layout instead if one is given, see SetTimeFormat()
+func getFlagString(buf *[]byte, flags int, level Level, category string, funcName string, file string, line int, goid int, t time.Time, timeFormat string) string {
+	// a custom separator goes between the fields and at the end of the block,
+	// see SetMetadataSeparator(), else the original spacing is used
+	sep := MetadataSeparator()
 	if flags&Lpid != 0 {
 		pid := os.Getpid()
 		*buf = append(*buf, '[')
 		itoa(buf, pid, 1)
-		*buf = append(*buf, "] "...)
+		*buf = append(*buf, ']')
+		if sep == "" {
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
+		}
+	}
+	if flags&Lhost != 0 {
+		*buf = append(*buf, hostName...)
+		if sep == "" {
+			appendPadding(buf, int(atomic.LoadInt32(&hostNameLength))-utf8.RuneCountInString(hostName))
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
+		}
+	}
+	if flags&Lgoid != 0 {
+		*buf = append(*buf, 'g')
+		itoa(buf, goid, 1)
+		if sep == "" {
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
+		}
+	}
+	if flags&Luser != 0 {
+		name := currentUserName()
+		if sep == "" {
+			appendPadding(buf, int(atomic.LoadInt32(&userNameLength))-utf8.RuneCountInString(name))
+			*buf = append(*buf, name...)
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, name...)
+			*buf = append(*buf, sep...)
+		}
 	}
 	if flags&Llevel != 0 {
-		lvl := fmt.Sprintf("%-8s", level)
-		*buf = append(*buf, lvl...)
+		if sep == "" {
+			*buf = append(*buf, level.String()...)
+			appendPadding(buf, 8-len(level.String()))
+		} else {
+			*buf = append(*buf, level.String()...)
+			*buf = append(*buf, sep...)
+		}
 	}
-	if flags&(Ldate|Ltime|Lmicroseconds) != 0 {
+	if flags&(Ldate|Ltime|Lmicroseconds) != 0 && timeFormat != "" {
+		*buf = t.AppendFormat(*buf, timeFormat)
+		if sep == "" {
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
+		}
+	} else if flags&(Ldate|Ltime|Lmicroseconds) != 0 {
 		if flags&Ldate != 0 {
 			year, month, day := t.Date()
 			itoa(buf, year, 4)
@@ -1557,7 +2220,12 @@ func getFlagString(buf *[]byte, flags int, level Level, funcName string, file st
 			itoa(buf, int(month), 2)
 			*buf = append(*buf, '/')
 			itoa(buf, day, 2)
-			*buf = append(*buf, ' ')
+			if sep == "" || flags&(Ltime|Lmicroseconds) != 0 {
+				// date and time are one field, always space separated
+				*buf = append(*buf, ' ')
+			} else {
+				*buf = append(*buf, sep...)
+			}
 		}
 		if flags&(Ltime|Lmicroseconds) != 0 {
 			hour, min, sec := t.Clock()
@@ -1570,7 +2238,39 @@ func getFlagString(buf *[]byte, flags int, level Level, funcName string, file st
 				*buf = append(*buf, '.')
 				itoa(buf, t.Nanosecond()/1e3, 6)
 			}
+			if sep == "" {
+				*buf = append(*buf, ' ')
+			} else {
+				*buf = append(*buf, sep...)
+			}
+		}
+	}
+	if flags&Lreltime != 0 {
+		appendRelTime(buf, t.Sub(startTime))
+		if sep == "" {
+			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
+		}
+	}
+	if flags&Lbuildinfo != 0 {
+		if build := buildInfoSummary(); build != "" {
+			*buf = append(*buf, build...)
+			if sep == "" {
+				*buf = append(*buf, ' ')
+			} else {
+				*buf = append(*buf, sep...)
+			}
+		}
+	}
+	if flags&Lcategory != 0 && category != "" {
+		*buf = append(*buf, '[')
+		*buf = append(*buf, category...)
+		*buf = append(*buf, ']')
+		if sep == "" {
 			*buf = append(*buf, ' ')
+		} else {
+			*buf = append(*buf, sep...)
 		}
 	}
 	if flags&(Lshortfile|Llongfile) != 0 {
@@ -1586,11 +2286,12 @@ func getFlagString(buf *[]byte, flags int, level Level, funcName string, file st
 			}
 			file = short
 		}
-		var tmpbuf []byte
-		tmpslice := &tmpbuf
-		*tmpslice = append(*tmpslice, file...)
-		*tmpslice = append(*tmpslice, ':')
-		itoa(tmpslice, line, -1)
+		// the file:line#:func field goes straight into the buffer, it's
+		// padded below (if no custom separator is used)
+		start := len(*buf)
+		*buf = append(*buf, file...)
+		*buf = append(*buf, ':')
+		itoa(buf, line, -1)
 		if flags&Lshortfunc != 0 {
 			formatLen = formatLen + int(atomic.LoadInt32(&shortFuncNameLength))
 			parts := strings.Split(funcName, ".")
@@ -1600,25 +2301,29 @@ func getFlagString(buf *[]byte, flags int, level Level, funcName string, file st
 			} else {
 				justFunc = "???"
 			}
-			*tmpslice = append(*tmpslice, ':')
-			*tmpslice = append(*tmpslice, justFunc...)
+			*buf = append(*buf, ':')
+			*buf = append(*buf, justFunc...)
 		} else if flags&Llongfunc != 0 {
 			formatLen = formatLen + int(atomic.LoadInt32(&longFuncNameLength))
-			*tmpslice = append(*tmpslice, ':')
-			*tmpslice = append(*tmpslice, funcName...)
-		} else {
-			*tmpslice = append(*tmpslice, ' ')
+			*buf = append(*buf, ':')
+			*buf = append(*buf, funcName...)
+		} else if sep == "" {
+			*buf = append(*buf, ' ')
 		}
 
+		if sep != "" {
+			// no padding with a custom separator, it's meant for splitting
+			*buf = append(*buf, sep...)
+			return string(*buf)
+		}
 		// Note that this length stuff is weak, if you have long filenames,
 		// long func names or long paths to func's it won't do much good as
 		// it's currently written (or if you have different flags across
 		// different log levels... but if consistent then it can help a bit)
-		formatStr := "%-" + fmt.Sprintf("%d", formatLen) + "s: "
-		str := fmt.Sprintf(formatStr, string(*tmpslice))
-		*buf = append(*buf, str...)
+		appendPadding(buf, formatLen-utf8.RuneCount((*buf)[start:]))
+		*buf = append(*buf, ": "...)
 	}
-	return fmt.Sprintf("%s", *buf)
+	return string(*buf)
 }
 
 // determineFlags takes a set of flags defined in an env var (string) that
@@ -1640,6 +2345,18 @@ func determineFlags(flagStr string) int {
 			flags |= Lpid
 		case "level":
 			flags |= Llevel
+		case "buildinfo":
+			flags |= Lbuildinfo
+		case "category":
+			flags |= Lcategory
+		case "reltime":
+			flags |= Lreltime
+		case "user":
+			flags |= Luser
+		case "host":
+			flags |= Lhost
+		case "goid":
+			flags |= Lgoid
 		case "date":
 			flags |= Ldate
 		case "time":
@@ -1663,13 +2380,27 @@ func determineFlags(flagStr string) int {
 	return flags
 }
 
+// envFlags returns the flags to use for the given screen or logfile target,
+// ie: the flags from any PKG_OUT_SCREEN_FLAGS or PKG_OUT_LOGFILE_FLAGS env
+// setting (see determineFlags()) if set, otherwise the given flags
+func envFlags(flags int, outputTgt int) int {
+	envVar := "PKG_OUT_LOGFILE_FLAGS"
+	if outputTgt&ForScreen != 0 {
+		envVar = "PKG_OUT_SCREEN_FLAGS"
+	}
+	if str := os.Getenv(envVar); str != "" {
+		return determineFlags(str)
+	}
+	return flags
+}
+
 // insertFlagMetadata basically checks to see what flags are set for
 // the current screen or logfile output and inserts the meta-data in
 // front of the string, see InsertPrefix for ctrl description, outputTgt
 // here is either ForScreen or ForLogfile (constants) for output.  Note that
 // it will also return a boolean to indicate if the output should be supressed
 // or not (typically not but one can filter debug/trace output and if one has
-// set PKG_OUT_DEBUG_SCOPE, see env var elsewhere in this pkg for doc), params:
+// set PKG_OUT_DEBUG_SCOPE or used SetDebugScope(), see debugscope.go), params:
 //	s (string): the string to insert flag meta-data into
 //	outputTgt (int): where output goes, ForScreen, ForLogfile or ForBoth
 //	ctrl (int): how to insert the prefix (can be combined via 'or')
@@ -1681,8 +2412,11 @@ func determineFlags(flagStr string) int {
 //	ignoreEnv (bool): ignore any env overrides/filters (eg: formatter wants all)
 // Returns the update msg string, any flag metadata available and if the output
 // should be suppressed (such as if debug scope doesn't include this module)
-func (o *LvlOutput) insertFlagMetadata(s string, outputTgt int, ctrl int, overrideFlags *int, ignoreEnv bool, depth ...int) (string, *FlagMetadata, bool) {
-	now := time.Now() // do this before Caller below, can take some time
+func (o *LvlOutput) insertFlagMetadata(s string, outputTgt int, ctrl int, mmeta *msgMetadata, overrideFlags *int, ignoreEnv bool, depth ...int) (string, *FlagMetadata, bool) {
+	if mmeta == nil {
+		mmeta = newMsgMetadata("")
+	}
+	now := mmeta.now
 	var file, funcName string
 	var line, flags int
 	var suppressOutput bool
@@ -1702,75 +2436,81 @@ func (o *LvlOutput) insertFlagMetadata(s string, outputTgt int, ctrl int, overri
 		sF = *overrideFlags
 		lF = *overrideFlags
 	}
+	hndl := o.logfileHndl
+	if outputTgt&ForScreen != 0 {
+		hndl = o.screenHndl
+	}
+	emptyPrefix := o.prefix == "" && o.prefixFunc == nil
 	o.mu.RUnlock()
+	wantsMetadata := handleWantsMetadata(hndl)
 	flagMetadata.Level = fmt.Sprintf("%s", lvlOutLevel)
 	flagMetadata.Time = &now
+	flagMetadata.Category = mmeta.category
+	flagMetadata.Fields = mmeta.fields
 	// if printing to the screen target use those flags, else use logfile flags
 	if outputTgt&ForScreen != 0 {
-		if str := os.Getenv("PKG_OUT_SCREEN_FLAGS"); !ignoreEnv && str != "" {
-			flags = determineFlags(str)
-		} else {
-			flags = sF
+		flags = sF
+		if !ignoreEnv {
+			flags = envFlags(sF, ForScreen)
 		}
 		level = lvlOutLevel
 	} else if outputTgt&ForLogfile != 0 {
-		if str := os.Getenv("PKG_OUT_LOGFILE_FLAGS"); !ignoreEnv && str != "" {
-			flags = determineFlags(str)
-		} else {
-			flags = lF
+		flags = lF
+		if !ignoreEnv {
+			flags = envFlags(lF, ForLogfile)
 		}
 		level = lvlOutLevel
 	} else {
 		Fatalln("Invalid target passed to insertFlagMetadata():", outputTgt)
 	}
 	suppressOutput = false
-	if flags&(Lshortfile|Llongfile|Lshortfunc|Llongfunc) != 0 ||
-		(!ignoreEnv && os.Getenv("PKG_OUT_DEBUG_SCOPE") != "") {
+	var scope *debugScope
+	if !ignoreEnv && (lvlOutLevel == LevelDebug || lvlOutLevel == LevelTrace) {
+		scope = currentDebugScope()
+	}
+	if flags&(Lshortfile|Llongfile|Lshortfunc|Llongfunc) != 0 || wantsMetadata || scope != nil {
+		// the callers info is looked up once per message and shared by the
+		// screen and logfile targets, held output has the original callers
 		var ok bool
-		var pc uintptr
-		pc, file, line, ok = runtime.Caller(callerDepth)
+		file, line, funcName, ok = mmeta.caller(callerDepth)
 		if !ok {
 			file = "???"
 			line = 0
 			funcName = "???"
-		} else {
-			f := runtime.FuncForPC(pc)
-			if f == nil {
-				funcName = "???"
-			} else {
-				funcName = f.Name()
-			}
+		} else if funcName == "" {
+			funcName = "???"
 		}
-		if !ignoreEnv {
-			// If the user has restricted debugging output to specific packages
-			// or methods (funcname might be "github.com/dvln/out.MethodName")
-			// then suppress all debug output outside of the desired scope and
-			// only show those packages or methods of interest... simple substr
-			// match is done currently
-			if debugScope := os.Getenv("PKG_OUT_DEBUG_SCOPE"); funcName != "???" && debugScope != "" && (lvlOutLevel == LevelDebug || lvlOutLevel == LevelTrace) {
-				scopeParts := strings.Split(debugScope, ",")
-				suppressOutput = true
-				for _, scopePart := range scopeParts {
-					if strings.Contains(funcName, scopePart) {
-						suppressOutput = false
-						break
-					}
-				}
-			}
+		// If the user has restricted debugging output to specific packages
+		// or methods (funcname might be "github.com/dvln/out.MethodName")
+		// then suppress all debug output outside of the desired scope and
+		// only show those packages or methods of interest, see SetDebugScope()
+		if scope != nil && funcName != "???" {
+			suppressOutput = scope.suppresses(funcName)
 		}
 		flagMetadata.Func = funcName
 		flagMetadata.File = filepath.Base(file)
 		flagMetadata.Path = filepath.Dir(file)
 		flagMetadata.LineNo = line
 	}
-	o.mu.Lock()
-	o.buf = o.buf[:0]
-	leader := getFlagString(&o.buf, flags, level, funcName, file, line, now)
+	if flags&Lbuildinfo != 0 {
+		flagMetadata.Fields = mergeFields(flagMetadata.Fields, buildInfoFields())
+	}
+	var goid int
+	if flags&Lgoid != 0 {
+		goid = mmeta.goroutineID()
+		flagMetadata.GoID = goid
+	}
+	buf := getOutputBuf()
+	leader := getFlagString(buf, flags, level, mmeta.category, funcName, file, line, goid, now, TimeFormat(outputTgt))
+	putOutputBuf(buf)
 	flagMetadata.PID = os.Getpid()
-	o.mu.Unlock()
+	flagMetadata.Host = hostName
 	if leader == "" {
 		return s, flagMetadata, suppressOutput
 	}
+	if emptyPrefix && EmptyPrefixSeparator() && MetadataSeparator() == "" && !strings.HasSuffix(leader, ": ") {
+		leader = strings.TrimSuffix(leader, " ") + ": "
+	}
 	// Use 0 as the error code as we don't want to try and insert any error
 	// code in standard flags prefix (that's only needed for errs/warnings),
 	// so just do a full prefixing of the flags data
@@ -1792,6 +2532,8 @@ func (o *LvlOutput) insertFlagMetadata(s string, outputTgt int, ctrl int, overri
 //                             // (Sceen|Log) and only prefixes the 1st line if
 //                             // it is on a fresh new line (ie: will "or" in
 //                             // SkipFirstLine to AlwaysInsert if not on fresh)
+// - mmeta: the time and callers info shared by the screen and logfile output
+// for the message (nil looks it up just for this call), see msgMetadata
 // - detErr: a detailed error *if* one is available, else nil
 // - checkSuppressOnly: basically says skip all prefixing but still do the
 // calculation to see if we should dump this line based on trace/debug scope
@@ -1845,15 +2587,16 @@ func (o *LvlOutput) insertFlagMetadata(s string, outputTgt int, ctrl int, overri
 //   <date/time> myfile.go:37: Fatal: Severe error, giving up
 //   <date/time> myfile.go:37: Fatal:
 //   <date/time> myfile.go:37: Fatal: Stack Trace: <multiline stacktrace here>
-func (o *LvlOutput) doPrefixing(s string, outputTgt int, ctrl int, detErr DetailedError, checkSuppressOnly bool) (string, *FlagMetadata, bool) {
+func (o *LvlOutput) doPrefixing(s string, outputTgt int, ctrl int, mmeta *msgMetadata, detErr DetailedError, checkSuppressOnly bool) (string, *FlagMetadata, bool) {
 	// Where we check out if we previously had no newline and if so the
 	// first line (if multiline) will not have the prefix, see example
 	// in function header around username
 	origString := s
 	var onNewline bool
+	p := o.parent()
 	mutex.Lock()
-	scrNewline := screenNewline
-	logNewline := logfileNewline
+	scrNewline := p.screenNewline
+	logNewline := p.logfileNewline
 	mutex.Unlock()
 	if outputTgt&ForScreen != 0 {
 		onNewline = scrNewline
@@ -1865,14 +2608,36 @@ func (o *LvlOutput) doPrefixing(s string, outputTgt int, ctrl int, detErr Detail
 	if !onNewline && ctrl&SmartInsert != 0 {
 		ctrl = ctrl | SkipFirstLine
 	}
-	errCode := int(defaultErrCode)
+	errCode := int(DefaultErrCode())
 	if detErr != nil {
 		errCode = Code(detErr)
 	}
+	if mmeta == nil {
+		mmeta = newMsgMetadata("")
+	}
 	o.mu.RLock()
+	level := o.level
 	prefix := o.prefix
+	prefixFunc := o.prefixFunc
+	screenHndl := o.screenHndl
 	o.mu.RUnlock()
-	// Insert prefix for this logging level
+	if prefixFunc != nil {
+		// callDepth is relative to insertFlagMetadata(), we're one frame up
+		prefix = mmeta.funcPrefix(prefixFunc, level, int(atomic.LoadInt32(&callDepth))-1)
+	}
+	if outputTgt&ForScreen != 0 {
+		// a terminal gets the prefix in the level's color, see SetLevelColor()
+		prefix = colorPrefix(level, prefix, screenHndl)
+	}
+	if outputTgt&ForScreen == 0 && len(mmeta.fields) != 0 {
+		// plain logfile lines get the Outputter's fields, see WithFields()
+		prefix += fieldsText(mmeta.fields)
+	}
+	// Indent the message for any open groups (see Group()) and then insert
+	// the prefix for this logging level
+	if indent := groupIndent(); indent != "" {
+		s = InsertPrefix(s, indent, ctrl, 0)
+	}
 	s = InsertPrefix(s, prefix, ctrl, errCode)
 
 	if os.Getenv("PKG_OUT_SMART_FLAGS_PREFIX") == "off" {
@@ -1882,7 +2647,7 @@ func (o *LvlOutput) doPrefixing(s string, outputTgt int, ctrl int, detErr Detail
 	// it has the brains to not add in a prefix if not needed or wanted
 	var suppressOutput bool
 	var flagMetadata *FlagMetadata
-	s, flagMetadata, suppressOutput = o.insertFlagMetadata(s, outputTgt, ctrl, nil, false)
+	s, flagMetadata, suppressOutput = o.insertFlagMetadata(s, outputTgt, ctrl, mmeta, nil, false)
 	if checkSuppressOnly {
 		s = origString // use non-pfx string *but* return suppressOutput result
 	}
@@ -1899,17 +2664,22 @@ func (o *LvlOutput) doPrefixing(s string, outputTgt int, ctrl int, detErr Detail
 // - exitVal (int): what exit value is (only used if dying is true)
 // - stacktrace (string): if given and stack requested it will be added, note
 // that it is already pre-formatted
+// - mdata (*FlagMetadata): metadata for the output, passed along to handles
+// that implement the metadataWriter interface (can be nil)
+// - ownsNewlines (bool): a formatter owns newline handling for this output
+// (see FormatterOwnsNewlines), so no newline tracking or adding is done
 // Returns:
 // - int: number of bytes written to the io.Writer associated with outputTgt
 // - error: if any unexpected write error occurred this will be a raw Go error
-func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int, stacktrace string) (int, error) {
+func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int, stacktrace string, mdata *FlagMetadata, ownsNewlines bool) (int, error) {
 	tgtString := "logfile"
 	o.mu.RLock()
+	level := o.level
 	prefix := o.prefix
 	hndl := o.logfileHndl
 	o.mu.RUnlock()
 	mutex.Lock()
-	tgtStreamNewline := &logfileNewline
+	tgtStreamNewline := &o.parent().logfileNewline
 	mutex.Unlock()
 	if outputTgt&ForScreen == 1 {
 		tgtString = "screen"
@@ -1917,15 +2687,38 @@ func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int
 		hndl = o.screenHndl
 		o.mu.RUnlock()
 		mutex.Lock()
-		tgtStreamNewline = &screenNewline
+		tgtStreamNewline = &o.parent().screenNewline
 		mutex.Unlock()
 	}
 	writeLength := 0
 
 	// Safely do writes and adjust settings as needed
 	mutex.Lock()
-	n, err := hndl.Write([]byte(s))
+	n, err := writeHandle(hndl, []byte(s), level, mdata)
 	mutex.Unlock()
+	if err != nil && outputTgt&ForLogfile != 0 && isClosedFileErr(err) {
+		o.mu.RLock()
+		currHndl := o.logfileHndl
+		o.mu.RUnlock()
+		// logfile was closed, if it was swapped out (see ReopenLogFile()) just
+		// use the new handle, else see SetClosedFilePolicy() for the options
+		switch {
+		case currHndl != hndl:
+			hndl = currHndl
+			mutex.Lock()
+			n, err = writeHandle(hndl, []byte(s), level, mdata)
+			mutex.Unlock()
+		case ClosedFilePolicy() == ClosedFileDrop:
+			return 0, nil
+		case ClosedFilePolicy() == ClosedFileReopen:
+			if newHndl := reopenClosedLogfile(hndl); newHndl != nil {
+				hndl = newHndl
+				mutex.Lock()
+				n, err = writeHandle(hndl, []byte(s), level, mdata)
+				mutex.Unlock()
+			}
+		}
+	}
 	writeLength += n
 	if err != nil {
 		mutex.Lock()
@@ -1934,14 +2727,17 @@ func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int
 		return writeLength, writeErr
 	}
 	mutex.Lock()
-	if s[len(s)-1] == 0x0A { // if last char is a newline..
+	if ownsNewlines || s == "" {
+		// formatter handles newlines (or nothing was written), leave the
+		// newline state alone
+	} else if s[len(s)-1] == 0x0A { // if last char is a newline..
 		*tgtStreamNewline = true
 	} else {
 		*tgtStreamNewline = false
 	}
-	if dying && !*tgtStreamNewline {
+	if dying && !ownsNewlines && !*tgtStreamNewline {
 		// ignore errors, just quick "prettyup" attempt:
-		n, err = hndl.Write([]byte("\n"))
+		n, err = writeHandle(hndl, []byte("\n"), level, mdata)
 		writeLength += n
 		if err != nil {
 			writeErr := fmt.Errorf("%sError writing newline to %s output handler:\n%+v\n", prefix, tgtString, err)
@@ -1956,7 +2752,7 @@ func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int
 	// See if stack trace is needed...
 	if o.stackTraceWanted(dying, exitVal, outputTgt) {
 		mutex.Lock()
-		n, err = hndl.Write([]byte(stacktrace))
+		n, err = writeHandle(hndl, []byte(stacktrace), level, mdata)
 		mutex.Unlock()
 		writeLength += n
 		if err != nil {
@@ -1976,39 +2772,77 @@ func (o *LvlOutput) writeOutput(s string, outputTgt int, dying bool, exitVal int
 // if it succeeds... and note that the length will include additional meta-data
 // that the user has requested be added) and an error if one occurred (only
 // one error will be considered if you pass in multiples, just the 1st).
+// The outputTgt mask restricts the message to the screen and/or logfile
+// targets (ForBoth normally, see PrintTo() and friends for single targets).
+// Any fields given are added to the structured fields in the metadata (see
+// Entry), nil if there are none.  The category is any subsystem category the
+// output was tagged with (see PrintCat()), empty if none.  A non-zero pc is
+// the callers program counter (eg: from a slog.Record) to use for the file,
+// line# and func info instead of looking up the caller via the call depth.
 // WARNING: this will silently ignore multiple detailed errors if you give it
 // more than one and simply use the 1st one given (that syntax is just used
 // to make the parameter optional to the stringOutput() method)
-func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, detErrs ...DetailedError) (int, error) {
+func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, outputTgt int, category string, fields map[string]interface{}, pc uintptr, detErrs ...DetailedError) (int, error) {
 	// print to the screen output writer first...
 	var detErr DetailedError
 	if detErrs != nil {
 		detErr = detErrs[0]
 	}
+	if detErr != nil {
+		// a detailed error brings its code, stack, etc as structured fields
+		fields = mergeFields(fields, detailedErrorFields(detErr))
+	}
 	var err error
 	var screenLength int
 	var logfileLength int
+	var written bool
+
+	// Scrub any secrets from the message up front, see AddRedaction()
+	s = redact(s)
 
 	// Try and insure goroutine safety as we read and write *LvlOutput
 	o.mu.RLock()
 	level := o.level
 	formatter := o.formatter
+	buildInfoFlag := (o.screenFlags | o.logFlags) & Lbuildinfo
 	o.mu.RUnlock()
 
-	mutex.Lock()
+	p := o.parent()
 	forScreen := ForScreen
 	forLogfile := ForLogfile
 	smartInsert := SmartInsert
-	safeScreenThreshold := screenThreshold
-	safeLogThreshold := logThreshold
-	mutex.Unlock()
+	safeScreenThreshold := p.screenThresh()
+	safeLogThreshold := p.logThresh()
+	mutex.RLock()
+	screenThreshFunc := p.screenThresholdFunc
+	logThreshFunc := p.logThresholdFunc
+	mutex.RUnlock()
 
 	// Grab the best stack trace we can find to use in case it's needed, but
 	// only for Issue, Error and Fatal levels of output (currently)... pass
 	// through any detailed error given by the user
+	// The time and callers info for the message are shared by both targets
+	mmeta := newMsgMetadata(category)
+	mmeta.pc = pc
+	if o.outputter != nil {
+		mmeta.fields = o.outputter.fields
+	}
+	if p == std {
+		safeScreenThreshold, safeLogThreshold = packageThresholds(mmeta, int(atomic.LoadInt32(&callDepth))-2, safeScreenThreshold, safeLogThreshold)
+	}
+	replay := mmeta.replay
+
 	var stackStr, screenStackTrace, logfileStackTrace string
-	if level >= LevelIssue {
-		stackStr = getStackTrace(detErr)
+	severity := level.Severity()
+	if severity >= LevelIssue {
+		if replay != nil {
+			stackStr = replay.meta.Stack
+			mmeta.frames = replay.meta.Frames
+		} else {
+			stackStr, mmeta.frames = getStackTrace(detErr)
+			stackStr = redact(stackStr)
+			redactFrames(mmeta.frames)
+		}
 		screenStackTrace = stackStr
 		logfileStackTrace = stackStr
 		if !o.stackTraceWanted(dying, exitVal, forScreen) {
@@ -2018,6 +2852,70 @@ func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, detErrs ...D
 			logfileStackTrace = ""
 		}
 	}
+	// Note any failing exit code for SetAccumulateExitCode() users and count
+	// the output for the exit summary (held output was counted already)
+	accumulateExit(severity)
+	if replay == nil {
+		countOutput(level)
+	}
+
+	// Keep a structured copy of errors and fatals around for LastFatal() if
+	// asked to (see SetCaptureLastFatal()), held strict mode output was
+	// already recorded when first emitted
+	if severity >= LevelError && severity != LevelDiscard && replay == nil && CaptureLastFatal() {
+		code := int(DefaultErrCode())
+		if detErr != nil {
+			code = Code(detErr)
+		}
+		// callDepth is relative to insertFlagMetadata(), we're two frames up
+		mdata := mmeta.flagMetadata(level, int(atomic.LoadInt32(&callDepth))-2)
+		mdata.Stack = stackStr
+		recordFatal(&FatalInfo{Message: s, Level: level.String(), Code: code, Dying: dying, Stack: stackStr, Metadata: mdata})
+	}
+
+	// In strict mode output is held back until the pkg has been configured,
+	// callDepth is relative to insertFlagMetadata(), we're two frames up
+	// (strict mode and the replay buffer are for the default Outputter)
+	if p == std && atomic.LoadInt32(&strictState) != strictOff && holdStrictOutput(level, s, outputTgt, mmeta, fields, stackStr, dying, int(atomic.LoadInt32(&callDepth))-2) {
+		return len(s), nil
+	}
+
+	// Keep a copy for ReplayTo() if the replay ring buffer is on
+	if p == std {
+		keepForReplay(level, s, outputTgt, mmeta, fields, stackStr, int(atomic.LoadInt32(&callDepth))-2)
+	}
+
+	// Output tagged with a category that's been filtered out isn't written,
+	// but a dying message still exits below, see SetCategoryFilter()
+	filtered := categoryFiltered(category)
+
+	// Drop the output if it loses the SetSampling() draw, if it repeats the
+	// last message and SetDedup() is on, or if the level is over its
+	// SetRateLimit() rate, a dying message is always written
+	if !filtered && !dying && replay == nil {
+		var sampled bool
+		if sampled, s = sampledOut(level, s); sampled {
+			return len(s), nil
+		}
+	}
+	if !filtered && replay == nil && Dedup() != 0 {
+		screenShown := outputTgt&forScreen != 0 && passesThreshold(level, safeScreenThreshold, screenThreshFunc)
+		if dup, inline := o.deduplicated(s, category, dying, screenShown); dup {
+			if inline != "" {
+				// rewrite the screen line with the count, see DedupInline
+				pfxInline, _, _ := o.doPrefixing(inline, forScreen, AlwaysInsert, mmeta, detErr, false)
+				o.writeInline(pfxInline)
+			}
+			return len(s), nil
+		}
+	}
+	if !filtered && !dying && replay == nil {
+		var limited bool
+		if limited, s = rateLimited(level, s); limited {
+			return len(s), nil
+		}
+	}
+
 	// Allow any plugin formatter to independently format only one type of
 	// output if desired (screen only or log only), or both.  From here on we
 	// start independently tracking the screen and logfile output details
@@ -2027,6 +2925,9 @@ func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, detErrs ...D
 	logfileNoOutputMask := 0
 	screenSkipNativePfx := false
 	logfileSkipNativePfx := false
+	screenOwnsNewlines := false
+	logfileOwnsNewlines := false
+	formattedMask := 0 // the targets getting the formatter's output
 	if formatter != nil {
 		// If the client has registered a formatting interface method then
 		// lets give it a spin, may adjust the output or suppress it alltogether
@@ -2046,72 +2947,128 @@ func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, detErrs ...D
 		var resultStr string
 		// Cheat a little and grab detailed output flags metadata for formatter,
 		// note that it will include the pid, level and date info automatically
-		flags := Llongfile | Llongfunc
-		_, flagMetadata, _ := o.insertFlagMetadata(s, forScreen, AlwaysInsert, &flags, true, 4)
+		// (and the build info if the level uses it, see SetBuildInfo())
+		flags := Llongfile | Llongfunc | buildInfoFlag
+		_, flagMetadata, _ := o.insertFlagMetadata(s, forScreen, AlwaysInsert, mmeta, &flags, true, 4)
 		if stackStr != "" {
 			flagMetadata.Stack = stackStr
+			flagMetadata.Frames = mmeta.frames
 		}
+		flagMetadata.Fields = mergeFields(fields, flagMetadata.Fields)
 		resultStr, applyMask, noOutputMask, skipNativePfx = formatter.FormatMessage(s, level, code, dying, *flagMetadata)
+		resultStr = redact(resultStr)
+		formattedMask = applyMask
 		// Based on formatter results set up screen and logfile output & controls
 		if applyMask&forScreen != 0 {
 			screenNoOutputMask = noOutputMask
 			screenSkipNativePfx = skipNativePfx
+			screenOwnsNewlines = applyMask&FormatterOwnsNewlines != 0
 			screenStr = resultStr
 		}
 		if applyMask&forLogfile != 0 {
 			logfileNoOutputMask = noOutputMask
 			logfileSkipNativePfx = skipNativePfx
+			logfileOwnsNewlines = applyMask&FormatterOwnsNewlines != 0
 			logfileStr = resultStr
 		}
 	}
 
+	// Limit giant messages if asked to, see SetMaxMessageBytes(), formatter
+	// output is left whole as a cut record (eg: JSON) would be invalid
+	if formattedMask&forScreen == 0 {
+		screenStr = truncateMessage(screenStr, int(atomic.LoadInt32(&maxScreenMsgBytes)))
+	}
+	if formattedMask&forLogfile == 0 {
+		logfileStr = truncateMessage(logfileStr, int(atomic.LoadInt32(&maxLogfileMsgBytes)))
+	}
+
+	// Lazy stack traces (see SetLazyStackTrace()) are only symbolized if a
+	// trace is actually written out, and then only once for both targets
+	symbolizedStack := ""
+	symbolize := func(stack string) string {
+		if symbolizedStack == "" {
+			symbolizedStack = redact(SymbolizeStack(stack))
+		}
+		return symbolizedStack
+	}
+
 	// Lets see if screen (here) or logfile (below) output is active:
-	if level >= safeScreenThreshold && level != LevelDiscard && screenNoOutputMask&forScreen == 0 {
+	if outputTgt&forScreen != 0 && !filtered && passesThreshold(level, safeScreenThreshold, screenThreshFunc) && screenNoOutputMask&forScreen == 0 {
 		// Screen output active based on output levels (and formatters, if any)
-		pfxScreenStr, _, suppressOutput := o.doPrefixing(screenStr, forScreen, smartInsert, detErr, screenSkipNativePfx)
+		// a formatter owning newlines gets prefixes regardless of prior output
+		screenInsert := smartInsert
+		if screenOwnsNewlines {
+			screenInsert = AlwaysInsert
+		}
+		if width := o.screenWrapWidth(); width > 0 && !screenOwnsNewlines {
+			// wrap to the room left after the prefix, flag metadata and any
+			// group indent, see SetWrapWidth() (done here so the caller
+			// lookup depth is right)
+			pfx, _, _ := o.doPrefixing("\n", forScreen, AlwaysInsert, mmeta, detErr, screenSkipNativePfx)
+			screenStr = wrapText(screenStr, width-displayWidth(strings.TrimSuffix(pfx, "\n")))
+		}
+		pfxScreenStr, screenMetadata, suppressOutput := o.doPrefixing(screenStr, forScreen, screenInsert, mmeta, detErr, screenSkipNativePfx)
+		if screenMetadata != nil {
+			screenMetadata.Fields = mergeFields(fields, screenMetadata.Fields)
+		}
 
 		// Note that suppressOutput is for suppressing trace/debug output so
 		// only selected/desired packages have debug output dumped (currently)
 		if !suppressOutput {
 			pfxStackTrace := ""
 			if screenStackTrace != "" {
-				pfxStackTrace, _, _ = o.doPrefixing(screenStackTrace, forScreen, smartInsert, detErr, screenSkipNativePfx)
+				pfxStackTrace, _, _ = o.doPrefixing(symbolize(screenStackTrace), forScreen, screenInsert, mmeta, detErr, screenSkipNativePfx)
 			}
-			screenLength, err = o.writeOutput(pfxScreenStr, forScreen, dying, exitVal, pfxStackTrace)
+			screenLength, err = o.writeOutput(pfxScreenStr, forScreen, dying, exitVal, pfxStackTrace, screenMetadata, screenOwnsNewlines)
 			if err != nil {
 				return screenLength, err
 			}
+			written = true
 		}
 	}
 
 	// Print to the log file writer next (if needed):
-	if level >= safeLogThreshold && level != LevelDiscard && logfileNoOutputMask&forLogfile == 0 {
-		pfxLogfileStr, _, suppressOutput := o.doPrefixing(logfileStr, forLogfile, smartInsert, detErr, logfileSkipNativePfx)
+	if outputTgt&forLogfile != 0 && !filtered && passesThreshold(level, safeLogThreshold, logThreshFunc) && logfileNoOutputMask&forLogfile == 0 {
+		logfileInsert := smartInsert
+		if logfileOwnsNewlines {
+			logfileInsert = AlwaysInsert
+		}
+		pfxLogfileStr, logfileMetadata, suppressOutput := o.doPrefixing(logfileStr, forLogfile, logfileInsert, mmeta, detErr, logfileSkipNativePfx)
+		if logfileMetadata != nil {
+			logfileMetadata.Fields = mergeFields(fields, logfileMetadata.Fields)
+		}
 
 		// Note that suppressOutput is for suppressing trace/debug output so
 		// only selected/desired packages have debug output dumped (currently)
 		if !suppressOutput {
 			pfxStackTrace := ""
 			if logfileStackTrace != "" {
-				pfxStackTrace, _, _ = o.doPrefixing(logfileStackTrace, forLogfile, smartInsert, detErr, logfileSkipNativePfx)
+				pfxStackTrace, _, _ = o.doPrefixing(symbolize(logfileStackTrace), forLogfile, logfileInsert, mmeta, detErr, logfileSkipNativePfx)
 			}
-			logfileLength, err = o.writeOutput(pfxLogfileStr, forLogfile, dying, exitVal, pfxStackTrace)
+			logfileLength, err = o.writeOutput(pfxLogfileStr, forLogfile, dying, exitVal, pfxStackTrace, logfileMetadata, logfileOwnsNewlines)
 			if err != nil {
 				return logfileLength + screenLength, err
 			}
+			written = true
+		}
+	}
+	// Count the message for Counts() only once it has been written out and
+	// fire any hooks for it (see AddHook()), callDepth is relative to
+	// insertFlagMetadata(), we're two frames up
+	if written {
+		countEmitted(level)
+		if wanted := hooksWanted(level); wanted != nil {
+			mdata := mmeta.flagMetadata(level, int(atomic.LoadInt32(&callDepth))-2)
+			mdata.Stack = symbolize(stackStr)
+			mdata.Fields = mergeFields(fields, mdata.Fields)
+			runHooks(wanted, level, s, mdata)
 		}
 	}
 	// if we're dying off then we need to exit unless overrides in play,
 	// this env var should be used for test suites only really...
 	if dying {
-		mutex.RLock()
-		if deferFunc != nil {
-			deferFunc(int(atomic.LoadInt32(&errorExitVal)))
-		}
-		mutex.RUnlock()
-		if os.Getenv("PKG_OUT_NO_EXIT") != "1" {
-			os.Exit(int(atomic.LoadInt32(&errorExitVal)))
-		}
+		exitCleanup(int(atomic.LoadInt32(&errorExitVal)))
+		exitTool(int(atomic.LoadInt32(&errorExitVal)))
 	}
 	// if all good return all the bytes we wrote to *both* targets and nil err
 	return logfileLength + screenLength, nil
@@ -2125,29 +3082,7 @@ func (o *LvlOutput) stringOutput(s string, dying bool, exitVal int, detErrs ...D
 // to write at a given output level (but if you have a Level type and
 // want to get the associated io.Writer you can use this method)
 func LevelWriter(l Level) *LvlOutput {
-	var writeLevel *LvlOutput
-	l = levelCheck(l)
-	switch l {
-	case LevelTrace:
-		writeLevel = TRACE
-	case LevelDebug:
-		writeLevel = DEBUG
-	case LevelVerbose:
-		writeLevel = VERBOSE
-	case LevelInfo:
-		writeLevel = INFO
-	case LevelNote:
-		writeLevel = NOTE
-	case LevelIssue:
-		writeLevel = ISSUE
-	case LevelError:
-		writeLevel = ERROR
-	case LevelFatal:
-		writeLevel = FATAL
-	default:
-		writeLevel = INFO
-	}
-	return writeLevel
+	return std.LevelWriter(l)
 }
 
 // Write implements an io.Writer interface for any of the available output
@@ -2165,13 +3100,45 @@ func LevelWriter(l Level) *LvlOutput {
 // difference being that here the different target handles can be augmented with
 // independently controlled levels of additional meta-data, independent output
 // levels for each target handle, etc (and one could combine this io.Writer with
-// additional writers itself via io.MultiWriter even, crazy fun)
+// additional writers itself via io.MultiWriter even, crazy fun).  Note that,
+// by default, writing to the FATAL io.Writer does *not* exit as Fatal() does
+// (the output just gets the fatal prefix, flags and such), a write is usually
+// one piece of a larger message and exiting part way through would be rude,
+// use SetWriterExitsOnFatal(true) if writes to FATAL should exit as well.
 func (o *LvlOutput) Write(p []byte) (n int, err error) {
-	mutex.Lock()
-	terminate := false
-	exitVal := 0
-	mutex.Unlock()
-	return o.stringOutput(string(p), terminate, exitVal)
+	terminate, exitVal := o.writerExit()
+	return o.stringOutput(string(p), terminate, exitVal, ForBoth, "", nil, 0)
+}
+
+// writerExit returns if a write to the level's io.Writer should exit the tool
+// and the exit value to use if so, see SetWriterExitsOnFatal()
+func (o *LvlOutput) writerExit() (bool, int) {
+	o.mu.RLock()
+	level := o.level
+	o.mu.RUnlock()
+	if level == LevelFatal && WriterExitsOnFatal() {
+		return true, int(atomic.LoadInt32(&errorExitVal))
+	}
+	return false, 0
+}
+
+// WriterExitsOnFatal returns true if writes via the FATAL io.Writer exit the
+// tool as Fatal() does, see SetWriterExitsOnFatal()
+func WriterExitsOnFatal() bool {
+	return atomic.LoadInt32(&writerExitsOnFatal) != 0
+}
+
+// SetWriterExitsOnFatal controls if writing to the Fatal level's io.Writer,
+// eg: fmt.Fprintf(out.FATAL, "can't continue: %s\n", err), exits the tool
+// (with the same exit value, stack trace handling and defer func as Fatal()
+// uses) after the write, by default it doesn't (see Write() above).  If the
+// exit is turned on each write exits so use a single write for the message.
+func SetWriterExitsOnFatal(exits bool) {
+	val := int32(0)
+	if exits {
+		val = 1
+	}
+	atomic.StoreInt32(&writerExitsOnFatal, val)
 }
 
 // stackTrace returns a copy of the error with the stack trace field populated
@@ -2181,8 +3148,15 @@ func (o *LvlOutput) Write(p []byte) (n int, err error) {
 // 'errors' package and frankly I'm not clear as to if 'context' is ever
 // non-empty (based on stack traces I've seen and the parsing below I think
 // it will always be empty but I might be missing something)
+// If SetStackTraceTrimInternal() is on (the default) 'skip' isn't used, the
+// 'out' pkg and Go runtime/testing frames are left out by name instead.
 // NOTE: This can panic if any error (eg: runtime stack trace gathering issue)
 func stackTrace(skip int) (string, string) {
+	if StackTraceTrimInternal() {
+		// the frames to leave out are picked by name, see internalFrame()
+		trace, _ := trimmedStackTrace()
+		return trace, ""
+	}
 	// grow buf until it's large enough to store entire stack trace
 	buf := make([]byte, 128)
 	for {
@@ -2225,10 +3199,21 @@ func stackTrace(skip int) (string, string) {
 		index = indexNewline(buf, index+1)
 	}
 
+	// Keep lines until the blank line ending the trace, stopping after the
+	// max frames (func and file:line# line pairs) if SetStackTraceDepth() set
+	// one, the context is still what follows the full trace
+	maxLines := StackTraceDepth() * 2
 	isDone := false
 	startIndex := index
 	lastIndex := index
-	for !isDone {
+	cutIndex := -1
+	for lines := 0; !isDone; lines++ {
+		if lines == maxLines && maxLines != 0 && cutIndex == -1 {
+			cutIndex = index
+			if cutIndex < len(buf) {
+				cutIndex++
+			}
+		}
 		index = indexNewline(buf, index+1)
 		if (index - lastIndex) <= 1 {
 			isDone = true
@@ -2236,6 +3221,9 @@ func stackTrace(skip int) (string, string) {
 			lastIndex = index
 		}
 	}
-	strippedBuf.Write(buf[startIndex:index])
+	if cutIndex == -1 {
+		cutIndex = index
+	}
+	strippedBuf.Write(buf[startIndex:cutIndex])
 	return strippedBuf.String(), string(buf[index:])
 }
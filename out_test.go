@@ -18,12 +18,21 @@
 package out
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dvln/testify/assert"
 )
@@ -37,24 +46,18 @@ import (
 //		t.Errorf("Trace should not write '%s'.", buf.String())
 //	}
 
-// ResetOutPkg is a rough 1st stab at resetting the 'out' pkg for testing
-// purposes so we can adjust settings, try things out then just "reset" them
-// before the next test.  See the TESTING comment below on current shortcomings.
+// ResetOutPkg resets the 'out' pkg for testing purposes so we can adjust
+// settings, try things out then just "reset" them before the next test
 func ResetOutPkg() {
-	// TESTING: currently prefixes, screenFlags, logfileFlags are not reset
-	//   via this reset routine (just do them yourself if you use them now),
-	//   but eventually that should be fixed to match the defaults (or perhaps
-	//   a deep copy of the TRACE, DEBUG, etc levels in init() and restore here)
-	ResetNewline(true, ForBoth)
-	SetThreshold(defaultScreenThreshold, ForScreen)
-	SetThreshold(defaultLogThreshold, ForLogfile)
-	SetStackTraceConfig(StackTraceExitToLogfile)
-	ClearFormatter(LevelAll)
-	// Clear the screen/log writers so they are set to the starting defaults
-	SetWriter(LevelAll, os.Stdout, ForScreen)
-	SetWriter(LevelFatal, os.Stderr, ForScreen)
-	SetWriter(LevelError, os.Stderr, ForScreen)
-	SetWriter(LevelAll, ioutil.Discard, ForLogfile)
+	Reset()
+}
+
+// skipWithoutTrace skips tests built around Trace() and Debug() output when
+// that output is compiled out (go test -tags notrace)
+func skipWithoutTrace(t *testing.T) {
+	if !traceEnabled {
+		t.Skip("skipping, Trace and Debug output is compiled out (notrace)")
+	}
 }
 
 func TestLevels(t *testing.T) {
@@ -67,6 +70,34 @@ func TestLevels(t *testing.T) {
 	assert.Equal(t, Threshold(ForScreen), LevelNote)
 }
 
+func TestWarnLevel(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelWarn, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	Noteln("below the threshold")
+	Warn("warn ")
+	Warnln("warnln")
+	Warnf("%s\n", "warnf")
+	WARN.Write([]byte("writer\n"))
+	warnOutput := screenBuf.String()
+	screenBuf.Reset()
+	Issueln("issue")
+	issueOutput := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Warning: warn warnln\nWarning: warnf\nWarning: writer\n", warnOutput, "no stack trace for warnings")
+	assert.Contains(t, issueOutput, "Stack Trace: ")
+	assert.True(t, LevelNote < LevelWarn && LevelWarn < LevelIssue)
+	assert.Equal(t, "WARN", LevelWarn.String())
+	assert.Equal(t, LevelWarn, LevelString2Level("WARN"))
+	assert.Equal(t, LevelWarn, LevelWriter(LevelWarn).level)
+	assert.Equal(t, "Warning: ", Prefix(LevelWarn))
+}
+
 func TestOutput(t *testing.T) {
 	currWriter := Writer(LevelFatal, ForScreen)
 	if currWriter != os.Stderr {
@@ -248,8 +279,10 @@ func TestOutputf(t *testing.T) {
 	fmt.Println("Logfile output:")
 	fmt.Println(logBuf.String()) */
 
-	assert.Contains(t, screenBuf.String(), "trace info\n")
-	assert.Contains(t, screenBuf.String(), "debugging info\n")
+	if traceEnabled {
+		assert.Contains(t, screenBuf.String(), "trace info\n")
+		assert.Contains(t, screenBuf.String(), "debugging info\n")
+	}
 	assert.Contains(t, screenBuf.String(), "verbose info\n")
 	assert.Contains(t, screenBuf.String(), "information\n")
 	assert.Contains(t, screenBuf.String(), "Note: key note\n")
@@ -258,10 +291,12 @@ func TestOutputf(t *testing.T) {
 	assert.Contains(t, screenBuf.String(), "FREAKOUT: fatal error\n")
 	assert.Contains(t, screenBuf.String(), "Stack Trace:")
 
-	assert.Contains(t, logBuf.String(), "trace info\n")
+	if traceEnabled {
+		assert.Contains(t, logBuf.String(), "trace info\n")
+		assert.Contains(t, logBuf.String(), "debugging info\n")
+	}
 	assert.Contains(t, logBuf.String(), "out_test.go:")
 	assert.Contains(t, logBuf.String(), "out.TestOutputf")
-	assert.Contains(t, logBuf.String(), "debugging info\n")
 	assert.Contains(t, logBuf.String(), "verbose info\n")
 	assert.Contains(t, logBuf.String(), "information\n")
 	assert.Contains(t, logBuf.String(), "key note\n")
@@ -346,8 +381,10 @@ func TestTempFileOutput(t *testing.T) {
 	//fmt.Println("Screen output:")
 	//fmt.Println(screenBuf.String())
 
-	assert.Contains(t, screenBuf.String(), "trace info, trace over multiple lines\n")
-	assert.Contains(t, screenBuf.String(), "debugging info\n")
+	if traceEnabled {
+		assert.Contains(t, screenBuf.String(), "trace info, trace over multiple lines\n")
+		assert.Contains(t, screenBuf.String(), "debugging info\n")
+	}
 	assert.Contains(t, screenBuf.String(), "verbose info\n")
 	assert.Contains(t, screenBuf.String(), "information\n")
 	assert.Contains(t, screenBuf.String(), "Note: key note\n")
@@ -370,8 +407,10 @@ func TestTempFileOutput(t *testing.T) {
 	// func will operate sanely as if we're coming in fresh
 	ResetOutPkg()
 
-	assert.Contains(t, string(logFileBuf), "trace info, trace over multiple lines")
-	assert.Contains(t, string(logFileBuf), "debugging info")
+	if traceEnabled {
+		assert.Contains(t, string(logFileBuf), "trace info, trace over multiple lines")
+		assert.Contains(t, string(logFileBuf), "debugging info")
+	}
 	assert.Contains(t, string(logFileBuf), "verbose info")
 	assert.Contains(t, string(logFileBuf), "information")
 	assert.Contains(t, string(logFileBuf), "key note")
@@ -434,6 +473,50 @@ func TestStackTrace2(t *testing.T) {
 	assert.Contains(t, screenBuf.String(), "/out.TestStackTrace2")
 }
 
+func TestStackTraceConfigForTarget(t *testing.T) {
+	// stack traces to the logfile for any issue, to the screen only on exit
+	screenBuf := new(bytes.Buffer)
+	logfileBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logfileBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetStackTraceConfigForTarget(ForLogfile, StackTraceAllIssues)
+	SetStackTraceConfigForTarget(ForScreen, StackTraceNonZeroErrorExit)
+	screenCfg := StackTraceConfigForTarget(ForScreen)
+	logfileCfg := StackTraceConfigForTarget(ForLogfile)
+	Issueln("not exiting")
+	issueScreen := screenBuf.String()
+	issueLogfile := logfileBuf.String()
+	screenBuf.Reset()
+	logfileBuf.Reset()
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	IssueExitln(2, "exiting")
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+	exitScreen := screenBuf.String()
+	exitLogfile := logfileBuf.String()
+
+	// the older single setting still sets both targets
+	SetStackTraceConfig(ForScreen | StackTraceErrorExit)
+	bothScreenCfg := StackTraceConfigForTarget(ForScreen)
+	bothLogfileCfg := StackTraceConfigForTarget(ForLogfile)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, StackTraceNonZeroErrorExit, screenCfg)
+	assert.Equal(t, StackTraceAllIssues, logfileCfg)
+	assert.Contains(t, issueScreen, "Issue: not exiting\n")
+	assert.NotContains(t, issueScreen, "Stack Trace:")
+	assert.Contains(t, issueLogfile, "Stack Trace:")
+	assert.Contains(t, exitScreen, "Stack Trace:")
+	assert.Contains(t, exitLogfile, "Stack Trace:")
+	assert.Equal(t, StackTraceErrorExit, bothScreenCfg)
+	assert.Equal(t, 0, bothLogfileCfg)
+	assert.Equal(t, 0, StackTraceConfigForTarget(ForScreen))
+	assert.Equal(t, StackTraceNonZeroErrorExit, StackTraceConfigForTarget(ForLogfile))
+}
+
 func TestLogfileNameSet(t *testing.T) {
 	currFileName := LogFileName()
 	tmpFileName := UseTempLogFile("dvln")
@@ -441,7 +524,87 @@ func TestLogfileNameSet(t *testing.T) {
 	if newFileName != tmpFileName {
 		t.Errorf("Temp log file name setting or retrieving broken, found: \"%s\", but expected: \"%s\"", newFileName, tmpFileName)
 	}
-	logFileName = currFileName
+	std.logFileName = currFileName
+}
+
+func TestReopenLogFile(t *testing.T) {
+	currFileName := LogFileName()
+	dir, err := ioutil.TempDir("", "reopen")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+	std.useLogFile("", ioutil.Discard)
+	assert.Nil(t, ReopenLogFile(), "no log file is a no-op")
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetLogFile(logPath)
+	Println("before logrotate")
+	os.Rename(logPath, logPath+".1")
+	assert.Nil(t, ReopenLogFile())
+	Println("after logrotate")
+	rotatedContents, _ := ioutil.ReadFile(logPath + ".1")
+	contents, _ := ioutil.ReadFile(logPath)
+
+	// a failed reopen keeps the current handle
+	hndl := Writer(LevelInfo, ForLogfile)
+	os.RemoveAll(dir)
+	reopenErr := ReopenLogFile()
+	sameHndl := hndl == Writer(LevelNote, ForLogfile)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+	std.useLogFile(currFileName, ioutil.Discard)
+
+	assert.Equal(t, "before logrotate\n", string(rotatedContents))
+	assert.Equal(t, "after logrotate\n", string(contents))
+	assert.NotNil(t, reopenErr)
+	assert.True(t, sameHndl)
+}
+
+func TestCloseLogFile(t *testing.T) {
+	currFileName := LogFileName()
+	dir, err := ioutil.TempDir("", "close")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+	std.useLogFile("", ioutil.Discard)
+	assert.Nil(t, CloseLogFile(), "no log file is a no-op")
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetLogFile(logPath)
+	file := Writer(LevelInfo, ForLogfile)
+	Println("before close")
+	var closeErr error
+	SetDeferFunc(func(exitVal int) { closeErr = CloseLogFile() })
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	Exit(0)
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+	SetDeferFunc(nil)
+	closedFileName := LogFileName()
+	closedWriter := Writer(LevelNote, ForLogfile)
+	Println("after close")
+	_, writeErr := file.Write([]byte("closed"))
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+	std.useLogFile(currFileName, ioutil.Discard)
+
+	contents, _ := ioutil.ReadFile(logPath)
+	assert.Nil(t, closeErr)
+	assert.Equal(t, "", closedFileName)
+	assert.Equal(t, ioutil.Discard, closedWriter)
+	assert.NotNil(t, writeErr)
+	assert.Equal(t, "before close\n", string(contents))
 }
 
 func TestSettingVals(t *testing.T) {
@@ -456,7 +619,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 1 {
 		t.Errorf("Setting new error exit val to 1 appears to have failed, found: %d", newVal)
 	}
-	SetErrorExitVal(errorExitVal)
+	SetErrorExitVal(origVal)
 
 	origVal = DefaultErrCode()
 	if origVal != defaultErrCode {
@@ -468,7 +631,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 1000 {
 		t.Errorf("Setting new default error code to 1000 appears to have failed, found: %d", newVal)
 	}
-	SetDefaultErrCode(defaultErrCode)
+	SetDefaultErrCode(origVal)
 
 	origVal = CallDepth()
 	if origVal != callDepth {
@@ -480,7 +643,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 6 {
 		t.Errorf("Setting new call depth to 6 appears to have failed, found: %d", newVal)
 	}
-	SetCallDepth(callDepth)
+	SetCallDepth(origVal)
 
 	origVal = ShortFileNameLength()
 	if origVal != shortFileNameLength {
@@ -492,7 +655,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 30 {
 		t.Errorf("Setting short file name length to 30 appears to have failed, found: %d", newVal)
 	}
-	SetShortFileNameLength(shortFileNameLength)
+	SetShortFileNameLength(origVal)
 
 	origVal = LongFileNameLength()
 	if origVal != longFileNameLength {
@@ -504,7 +667,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 69 {
 		t.Errorf("Setting long file name length to 69 appears to have failed, found: %d", newVal)
 	}
-	SetLongFileNameLength(longFileNameLength)
+	SetLongFileNameLength(origVal)
 
 	origVal = ShortFuncNameLength()
 	if origVal != shortFuncNameLength {
@@ -516,7 +679,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 30 {
 		t.Errorf("Setting short Func name length to 30 appears to have failed, found: %d", newVal)
 	}
-	SetShortFuncNameLength(shortFuncNameLength)
+	SetShortFuncNameLength(origVal)
 
 	origVal = LongFuncNameLength()
 	if origVal != longFuncNameLength {
@@ -528,7 +691,7 @@ func TestSettingVals(t *testing.T) {
 	if newVal != 69 {
 		t.Errorf("Setting long Func name length to 69 appears to have failed, found: %d", newVal)
 	}
-	SetLongFuncNameLength(longFuncNameLength)
+	SetLongFuncNameLength(origVal)
 }
 
 func TestLevelConversion(t *testing.T) {
@@ -544,3 +707,1260 @@ func TestLevelConversion(t *testing.T) {
 		t.Errorf("Failed to map error level to string and back")
 	}
 }
+
+func TestParseLevel(t *testing.T) {
+	for input, expected := range map[string]Level{
+		"trace":     LevelTrace,
+		"  Debug\n": LevelDebug,
+		"INFO":      LevelInfo,
+		"print":     LevelInfo,
+		"warn":      LevelWarn,
+		"Warning":   LevelWarn,
+		"issue":     LevelIssue,
+		"err":       LevelError,
+		"error":     LevelError,
+		"fatal":     LevelFatal,
+		"off":       LevelDiscard,
+		" discard ": LevelDiscard,
+	} {
+		level, err := ParseLevel(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, level, input)
+	}
+	_, err := ParseLevel("foo")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), `invalid level "foo", valid levels are: trace, debug, verbose, info, note, warn, issue, error, fatal, discard`)
+	}
+	_, err = ParseLevel("")
+	assert.NotNil(t, err)
+}
+
+func TestPrefixFunc(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	step := 3
+	SetPrefixFunc(LevelNote, func(level Level, meta FlagMetadata) string {
+		return fmt.Sprintf("[step %d %s %s] ", step, level, meta.File)
+	})
+	Noteln("first")
+	step++
+	Noteln("second")
+	calls := 0
+	SetPrefixFunc(LevelNote, func(level Level, meta FlagMetadata) string {
+		calls++
+		return fmt.Sprintf("[%d] ", calls)
+	})
+	Noteln("once per message")
+	SetPrefixFunc(LevelNote, nil)
+	Noteln("third")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Contains(t, screenBuf.String(), "[step 3 NOTE out_test.go] first\n")
+	assert.Contains(t, screenBuf.String(), "[step 4 NOTE out_test.go] second\n")
+	assert.Contains(t, screenBuf.String(), "[1] once per message\n")
+	assert.Contains(t, logBuf.String(), "[1] once per message\n")
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, screenBuf.String(), "Note: third\n")
+}
+
+func TestEnsureNewline(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+
+	Print("no newline here")
+	EnsureNewline(ForScreen)
+	EnsureNewline(ForScreen)
+	Println("fresh line")
+	Println("already fresh")
+	EnsureNewline(ForBoth)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "no newline here\nfresh line\nalready fresh\n", screenBuf.String())
+	assert.Equal(t, "no newline herefresh line\nalready fresh\n", logBuf.String())
+}
+
+func TestLastFatal(t *testing.T) {
+	Discard(ForBoth)
+	Errorln("not captured")
+	assert.Nil(t, LastFatal())
+	SetCaptureLastFatal(true)
+	assert.Nil(t, LastFatal())
+	Issueln("just an issue")
+	assert.Nil(t, LastFatal())
+	Errorln(NewErr("request failed", 503))
+
+	// other goroutines have their own (empty) capture
+	done := make(chan *FatalInfo)
+	go func() { done <- LastFatal() }()
+	assert.Nil(t, <-done)
+
+	info := LastFatal()
+	ClearLastFatal()
+	Errorln("forgotten")
+	SetCaptureLastFatal(false)
+	forgotten := LastFatal()
+	ResetOutPkg()
+
+	if assert.NotNil(t, info) {
+		assert.Equal(t, "request failed\n", info.Message)
+		assert.Equal(t, "ERROR", info.Level)
+		assert.Equal(t, 503, info.Code)
+		assert.False(t, info.Dying)
+		assert.Contains(t, info.Stack, "Stack Trace:")
+		assert.Equal(t, "out_test.go", info.Metadata.File)
+		assert.Contains(t, info.Metadata.Func, "TestLastFatal")
+	}
+	assert.Nil(t, forgotten)
+	assert.Nil(t, LastFatal())
+}
+
+func TestEmitRaw(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+
+	EmitRaw(LevelInfo, "")
+	VLogf(0, "")
+	EmitRaw(LevelNote, "disk 100% full, 50%d free\n")
+	EmitRaw(LevelDebug, "not shown\n")
+	EmitRaw(LevelFatal, "forwarded fatal\n")
+
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: disk 100% full, 50%d free\nFatal: forwarded fatal\n", screenBuf.String())
+	assert.Contains(t, logBuf.String(), "out_test.go:")
+	assert.Contains(t, logBuf.String(), "TestEmitRaw")
+}
+
+func TestEmptyPrefixSeparator(t *testing.T) {
+	pid := fmt.Sprintf("[%d] ", os.Getpid())
+	tests := []struct {
+		flags     int
+		separator bool
+		expected  string
+	}{
+		{0, false, "info\nNote: note\n"},
+		{0, true, "info\nNote: note\n"},
+		{Llevel, false, "INFO    info\nNOTE    Note: note\n"},
+		{Llevel, true, "INFO   : info\nNOTE    Note: note\n"},
+		{Lpid | Llevel, false, pid + "INFO    info\n" + pid + "NOTE    Note: note\n"},
+		{Lpid | Llevel, true, pid + "INFO   : info\n" + pid + "NOTE    Note: note\n"},
+		{Lpid, true, pid[:len(pid)-1] + ": info\n" + pid + "Note: note\n"},
+	}
+	for _, test := range tests {
+		screenBuf := new(bytes.Buffer)
+		SetWriter(LevelAll, screenBuf, ForScreen)
+		SetFlags(LevelAll, test.flags, ForScreen)
+		SetEmptyPrefixSeparator(test.separator)
+		Infoln("info")
+		Noteln("note")
+		assert.Equal(t, test.expected, screenBuf.String(), "flags: %d, separator: %v", test.flags, test.separator)
+	}
+
+	// time based (and file based) blocks can't be compared exactly
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Ltime, ForScreen)
+	Infoln("info")
+	Noteln("note")
+	SetFlags(LevelAll, Ltime|Lshortfile, ForScreen)
+	Infoln("info")
+	SetEmptyPrefixSeparator(false)
+	ResetOutPkg()
+
+	lines := strings.Split(screenBuf.String(), "\n")
+	assert.Regexp(t, `^\d\d:\d\d:\d\d: info$`, lines[0])
+	assert.Regexp(t, `^\d\d:\d\d:\d\d Note: note$`, lines[1])
+	assert.Regexp(t, `^\d\d:\d\d:\d\d out_test.go:\d+ +: info$`, lines[2])
+}
+
+func TestTabWidth(t *testing.T) {
+	assert.Equal(t, 8, displayWidth("\t"))
+	assert.Equal(t, 16, displayWidth("abc\tdefghij\t"))
+	assert.Equal(t, 5, displayWidth("héllo"))
+	SetTabWidth(4)
+	assert.Equal(t, 8, displayWidth("ab\tcd\t"))
+
+	result := InsertPrefix("one\ntwo\n", "x\t: ", BlankInsert, 0)
+	assert.Equal(t, "      one\n      two\n", result)
+	SetTabWidth(8)
+	result = InsertPrefix("one\ntwo\n", "x\t: ", BlankInsert|SkipFirstLine, 0)
+	assert.Equal(t, "one\n          two\n", result)
+}
+
+func TestAccumulateExitCode(t *testing.T) {
+	Discard(ForBoth)
+	Errorln("before accumulating")
+	assert.Equal(t, 0, PendingExitCode())
+	SetAccumulateExitCode(3)
+	Noteln("just a note")
+	assert.Equal(t, 0, PendingExitCode())
+	Issueln("an issue")
+	assert.Equal(t, 3, PendingExitCode())
+	ClearPendingExitCode()
+	SetAccumulateExitLevel(LevelError)
+	Issueln("an issue")
+	assert.Equal(t, 0, PendingExitCode())
+	Errorln("an error")
+	assert.Equal(t, 3, PendingExitCode())
+
+	SetAccumulateExitCode(0)
+	SetAccumulateExitLevel(LevelIssue)
+	ClearPendingExitCode()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+func TestSetOutput(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetOutput(screenBuf)
+	SetOutputFor(logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	assert.Equal(t, screenBuf, Writer(LevelError, ForScreen))
+	assert.Equal(t, logBuf, Writer(LevelTrace, ForLogfile))
+
+	Println("hello")
+	Errorln("oops")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "hello\nError: oops\n", screenBuf.String())
+	assert.Equal(t, "hello\nError: oops\n", logBuf.String())
+}
+
+// failWriter is an io.Writer that always fails
+type failWriter struct{}
+
+func (w failWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("disk on fire")
+}
+
+func TestPanicOnUnrecoverableWriteError(t *testing.T) {
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	origStderr := os.Stderr
+	closedFile, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(closedFile.Name())
+	closedFile.Close()
+	os.Stderr = closedFile
+	SetWriter(LevelAll, failWriter{}, ForScreen)
+
+	// by default the failure is silent (the exit is suppressed for testing)
+	assert.False(t, PanicOnUnrecoverableWriteError())
+	assert.NotPanics(t, func() { Println("lost") })
+
+	SetPanicOnUnrecoverableWriteError(true)
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		Println("lost again")
+	}()
+	SetPanicOnUnrecoverableWriteError(false)
+	os.Stderr = origStderr
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	if assert.NotNil(t, recovered) {
+		assert.Contains(t, fmt.Sprint(recovered), "disk on fire")
+		assert.Contains(t, fmt.Sprint(recovered), "lost again")
+	}
+}
+
+func TestMetadataSeparator(t *testing.T) {
+	pid := regexp.QuoteMeta(fmt.Sprintf("[%d]", os.Getpid()))
+	date := `\d\d\d\d/\d\d/\d\d`
+	clock := `\d\d:\d\d:\d\d`
+	tests := []struct {
+		flags    int
+		sep      string
+		expected string
+	}{
+		{0, " | ", `^Note: note$`},
+		{Lpid, " | ", `^` + pid + ` \| Note: note$`},
+		{Llevel, " | ", `^NOTE \| Note: note$`},
+		{Lpid | Llevel, "\t", `^` + pid + "\tNOTE\tNote: note$"},
+		{Ldate, " | ", `^` + date + ` \| Note: note$`},
+		{Ldate | Ltime, " | ", `^` + date + ` ` + clock + ` \| Note: note$`},
+		{Ltime | Lmicroseconds, ",", `^` + clock + `\.\d{6},Note: note$`},
+		{Lshortfile, " | ", `^out_test\.go:\d+ \| Note: note$`},
+		{Lshortfile | Lshortfunc, " | ", `^out_test\.go:\d+:TestMetadataSeparator \| Note: note$`},
+		{Llongfile | Llongfunc, " | ", `^/\S+/out_test\.go:\d+:github\.com/dvln/out\.TestMetadataSeparator \| Note: note$`},
+		{Lpid | Llevel | Ldate | Ltime | Lshortfile | Lshortfunc, " | ",
+			`^` + pid + ` \| NOTE \| ` + date + ` ` + clock + ` \| out_test\.go:\d+:TestMetadataSeparator \| Note: note$`},
+		{Lpid | Llevel | Ltime | Lshortfile, "", `^` + pid + ` NOTE    ` + clock + ` out_test\.go:\d+ +: Note: note$`},
+	}
+	for _, test := range tests {
+		screenBuf := new(bytes.Buffer)
+		SetWriter(LevelAll, screenBuf, ForScreen)
+		SetFlags(LevelAll, test.flags, ForScreen)
+		SetMetadataSeparator(test.sep)
+		Noteln("note")
+		assert.Equal(t, test.sep, MetadataSeparator())
+		assert.Regexp(t, test.expected, strings.TrimSuffix(screenBuf.String(), "\n"), "flags: %d, sep: %q", test.flags, test.sep)
+	}
+
+	// the empty prefix separator doesn't apply with a custom separator
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Llevel, ForScreen)
+	SetEmptyPrefixSeparator(true)
+	SetMetadataSeparator(" | ")
+	Infoln("info")
+	SetEmptyPrefixSeparator(false)
+	SetMetadataSeparator("")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "INFO | info\n", screenBuf.String())
+}
+
+func TestSetTimeFormat(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetFlags(LevelAll, Ldate|Ltime|Lmicroseconds, ForScreen)
+	SetFlags(LevelAll, Llevel|Ldate|Ltime, ForLogfile)
+	SetTimeFormat(time.RFC3339, ForLogfile)
+	Noteln("note")
+	screenLayout := TimeFormat(ForScreen)
+	logLayout := TimeFormat(ForLogfile)
+	SetTimeFormat("", ForBoth)
+	SetFlags(LevelAll, Llevel, ForLogfile)
+	SetTimeFormat(time.RFC3339, ForLogfile)
+	Noteln("no date/time flags")
+	SetTimeFormat("", ForBoth)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "", screenLayout)
+	assert.Equal(t, time.RFC3339, logLayout)
+	assert.Equal(t, "", TimeFormat(ForLogfile))
+	assert.Regexp(t, `^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6} Note: note\n`, screenBuf.String())
+	assert.Regexp(t, `^NOTE    \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(Z|[+-]\d\d:\d\d) Note: note\nNOTE    Note: no date/time flags\n$`, logBuf.String())
+}
+
+func TestRelTime(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Lreltime, ForScreen)
+	Noteln("first")
+	time.Sleep(2 * time.Millisecond)
+	Noteln("second")
+	SetMetadataSeparator(" | ")
+	Noteln("third")
+	SetMetadataSeparator("")
+	os.Setenv("PKG_OUT_SCREEN_FLAGS", "reltime,level")
+	Noteln("fourth")
+	os.Setenv("PKG_OUT_SCREEN_FLAGS", "")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	linePat := regexp.MustCompile(`^(NOTE    )?\[ *(\d+\.\d{6})\]( | \| )Note: (first|second|third|fourth)$`)
+	lines := strings.Split(strings.TrimSuffix(screenBuf.String(), "\n"), "\n")
+	assert.Len(t, lines, 4)
+	var times []float64
+	for _, line := range lines {
+		match := linePat.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("unexpected reltime output line: %q", line)
+		}
+		secs, err := strconv.ParseFloat(match[2], 64)
+		assert.Nil(t, err)
+		times = append(times, secs)
+	}
+	assert.True(t, times[1]-times[0] >= 0.002, "times: %v", times)
+	for i := 1; i < len(times); i++ {
+		assert.True(t, times[i] >= times[i-1], "times: %v", times)
+	}
+	buf := []byte{}
+	appendRelTime(&buf, 12345*time.Second+42*time.Microsecond)
+	assert.Equal(t, "[12345.000042]", string(buf))
+}
+
+func TestSetLevel(t *testing.T) {
+	assert.Equal(t, LevelInfo, CurrentLevel())
+	SetLevel(LevelDebug)
+	assert.Equal(t, LevelDebug, Threshold(ForScreen))
+	assert.Equal(t, LevelDebug, Threshold(ForLogfile))
+	assert.Equal(t, LevelDebug, CurrentLevel())
+	SetThreshold(LevelTrace, ForLogfile)
+	assert.Equal(t, LevelTrace, CurrentLevel())
+	SetThreshold(LevelDiscard, ForLogfile)
+	assert.Equal(t, LevelDebug, CurrentLevel())
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+// stackCapture is a Formatter that records the stack in the metadata of
+// each message formatted and leaves the message itself alone
+type stackCapture struct {
+	stacks []string
+}
+
+func (f *stackCapture) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	f.stacks = append(f.stacks, mdata.Stack)
+	return msg, ForBoth, 0, false
+}
+
+func TestLazyStackTrace(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	capture := &stackCapture{}
+	SetFormatter(LevelAll, capture)
+	SetLazyStackTrace(true)
+	SetCaptureLastFatal(true)
+
+	Errorln("lazy error")
+	fatal := LastFatal()
+	SetLazyStackTrace(false)
+	ClearLastFatal()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	output := screenBuf.String()
+	assert.Contains(t, output, "Error: lazy error\n")
+	assert.Contains(t, output, "Error: Stack Trace: goroutine ")
+	assert.Contains(t, output, "Error: github.com/dvln/out.TestLazyStackTrace(...)\n")
+	assert.Regexp(t, `Error: \s+/\S+/out_test\.go:\d+\n`, output)
+	assert.NotContains(t, output, lazyStackMarker)
+	assert.NotContains(t, output, "stringOutput")
+
+	// the formatter gets the unsymbolized trace, which can be symbolized
+	if assert.Equal(t, 1, len(capture.stacks)) {
+		assert.Contains(t, capture.stacks[0], lazyStackMarker)
+		assert.Contains(t, SymbolizeStack(capture.stacks[0]), "out.TestLazyStackTrace(...)")
+	}
+	if assert.NotNil(t, fatal) {
+		assert.Contains(t, fatal.Stack, "out.TestLazyStackTrace(...)")
+		assert.Equal(t, fatal.Stack, fatal.Metadata.Stack)
+	}
+	assert.Equal(t, "plain", SymbolizeStack("plain"))
+}
+
+func TestNilAndErrorRendering(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	var nilErr error
+	var nilPtr *int
+
+	Println("err:", nilErr, nilPtr)
+	SetNilRendering("(none)")
+	Println("err:", nilErr, nilPtr, 0)
+	Printf("fmt: %v\n", nilErr)
+	SetNilRendering("")
+	Println("err:", nilErr)
+	SetNilRendering("<nil>")
+	assert.Equal(t, "<nil>", NilRendering())
+
+	err := NewErr("config file unreadable", 209)
+	SetExpandErrors(true)
+	Issueln(err)
+	Issueln(fmt.Errorf("plain"))
+	Issueln("not lone", err)
+	SetExpandErrors(false)
+	Issueln(err)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	lines := strings.Split(screenBuf.String(), "\n")
+	assert.Equal(t, "err: <nil> <nil>", lines[0])
+	assert.Equal(t, "err: (none) (none) 0", lines[1])
+	assert.Equal(t, "fmt: <nil>", lines[2])
+	assert.Equal(t, "err: ", lines[3])
+	assert.Regexp(t, `^Issue #209: config file unreadable \[code: 209, at out\.TestNilAndErrorRendering \(out_test\.go:\d+\)\]$`, lines[4])
+	assert.Equal(t, "Issue: plain [code: 100]", lines[5])
+	assert.Equal(t, "Issue #209: not lone config file unreadable", lines[6])
+	assert.Equal(t, "Issue #209: config file unreadable", lines[7])
+}
+
+func TestMeasureOverhead(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetAccumulateExitCode(2)
+	SetCaptureLastFatal(true)
+
+	cheap := MeasureOverhead(LevelDebug, 200)
+	SetFlags(LevelAll, Lpid|Llevel|Ldate|Ltime|Lmicroseconds|Llongfile|Llongfunc, ForScreen)
+	costly := MeasureOverhead(LevelError, 200)
+	Print("partial")
+	MeasureOverhead(LevelInfo, 10)
+	Println(" line")
+	pending := PendingExitCode()
+	fatal := LastFatal()
+	SetAccumulateExitCode(0)
+	SetFlags(LevelAll, 0, ForScreen)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.False(t, cheap.ScreenActive)
+	assert.False(t, cheap.LogfileActive)
+	assert.Equal(t, 200, cheap.Iterations)
+	assert.True(t, costly.ScreenActive)
+	assert.True(t, costly.AllocsPerOp > cheap.AllocsPerOp, "full metadata should allocate more than filtered output")
+	assert.Contains(t, costly.String(), "ERROR: ")
+	assert.Contains(t, costly.String(), "(screen: on, logfile: off)")
+
+	// nothing leaks out of the measurements
+	assert.Regexp(t, `^\[\d+\] INFO .*: partial line\n$`, screenBuf.String())
+	assert.Equal(t, 0, pending)
+	assert.Nil(t, fatal)
+}
+
+// syncWriter is an io.Writer that counts the Sync() calls made on it
+type syncWriter struct {
+	bytes.Buffer
+	syncs int
+}
+
+func (w *syncWriter) Sync() error {
+	w.syncs++
+	return nil
+}
+
+func TestExitFlushesWriters(t *testing.T) {
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	origStderr := os.Stderr
+	stderrFile, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(stderrFile.Name())
+	os.Stderr = stderrFile
+
+	// a buffered logfile and a screen writer with Sync() on a fatal exit
+	logBuf := new(bytes.Buffer)
+	bufferedLog := bufio.NewWriter(logBuf)
+	screen := &syncWriter{}
+	SetWriter(LevelAll, screen, ForScreen)
+	SetWriter(LevelAll, bufferedLog, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetStackTraceConfig(0)
+	Println("before the end")
+	beforeExit := logBuf.String()
+	Fatalln("the end")
+	fatalLog := logBuf.String()
+	fatalSyncs := screen.syncs
+
+	// and on the way out after a failed write
+	logBuf.Reset()
+	Println("kept")
+	SetWriter(LevelAll, failWriter{}, ForScreen)
+	Println("lost")
+	failedLog := logBuf.String()
+
+	os.Stderr = origStderr
+	stderrFile.Close()
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "", beforeExit)
+	assert.Equal(t, "before the end\nFatal: the end\n", fatalLog)
+	assert.Equal(t, 1, fatalSyncs)
+	assert.Equal(t, "kept\n", failedLog)
+}
+
+func TestExitTimeout(t *testing.T) {
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	origStderr := os.Stderr
+	stderrFile, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(stderrFile.Name())
+	os.Stderr = stderrFile
+
+	gotVal := 0
+	SetDeferFunc(func(exitVal int) { gotVal = exitVal })
+	SetExitTimeout(time.Second)
+	Exit(3)
+	assert.Equal(t, 3, gotVal)
+
+	release := make(chan struct{})
+	SetDeferFunc(func(exitVal int) { <-release })
+	SetExitTimeout(20 * time.Millisecond)
+	start := time.Now()
+	Exit(4)
+	elapsed := time.Since(start)
+	close(release)
+
+	SetDeferFunc(nil)
+	SetExitTimeout(0)
+	os.Stderr = origStderr
+	stderrFile.Close()
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.True(t, elapsed < time.Second, "exit should not wait for a hung defer func")
+	stderr, _ := ioutil.ReadFile(stderrFile.Name())
+	assert.Equal(t, "Issue: cleanup before exit did not finish within 20ms, exiting anyway\n", string(stderr))
+}
+
+func TestEffectiveFlags(t *testing.T) {
+	SetFlags(LevelInfo, Ltime, ForScreen)
+	assert.Equal(t, Ltime, EffectiveFlags(LevelInfo, ForScreen))
+	assert.Equal(t, LlogfileFlags, EffectiveFlags(LevelInfo, ForLogfile))
+	os.Setenv("PKG_OUT_SCREEN_FLAGS", "pid,level")
+	assert.Equal(t, Lpid|Llevel, EffectiveFlags(LevelInfo, ForScreen))
+	assert.Equal(t, Ltime, Flags(LevelInfo, ForScreen))
+	assert.Equal(t, LlogfileFlags, EffectiveFlags(LevelInfo, ForLogfile))
+	os.Setenv("PKG_OUT_SCREEN_FLAGS", "")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+func TestOutputTo(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetFlags(LevelAll, 0, ForLogfile)
+
+	PrintlnTo(ForScreen, "Please enter your password:")
+	NotelnTo(ForLogfile, "password prompt issued")
+	IssuefTo(ForBoth, "%d retries left\n", 2)
+	DebuglnTo(ForLogfile, "below threshold")
+	PrintTo(ForLogfile, "caller is ")
+	PrintlnTo(ForLogfile, "TestOutputTo")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Please enter your password:\nIssue: 2 retries left\n", screenBuf.String())
+	assert.Equal(t, "Note: password prompt issued\nIssue: 2 retries left\ncaller is TestOutputTo\n", logBuf.String())
+}
+
+func TestConcurrentPrefixing(t *testing.T) {
+	// output is written under the pkg mutex so a plain buffer is fine here,
+	// the pooled buffers used for the prefixes must not be shared though
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, ioutil.Discard, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, Lshortfile|Llevel, ForLogfile)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				Notef("goroutine %d\nmessage %d\n", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	lines := strings.Split(strings.TrimSuffix(logBuf.String(), "\n"), "\n")
+	assert.Len(t, lines, 8*100*2)
+	linePat := regexp.MustCompile(`^NOTE    out_test.go:\d+ +: Note: (goroutine [0-7]|message \d+)$`)
+	for _, line := range lines {
+		if !linePat.MatchString(line) {
+			t.Fatalf("garbled output line: %q", line)
+		}
+	}
+}
+
+func TestConcurrentThresholds(t *testing.T) {
+	// thresholds are read without the pkg mutex, changing them while other
+	// goroutines output must be race free (run with -race)
+	SetWriter(LevelAll, ioutil.Discard, ForBoth)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				Noteln("message", i)
+				Threshold(ForScreen)
+				CurrentLevel()
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		SetThreshold(Level(i%int(LevelDiscard)), ForBoth)
+	}
+	wg.Wait()
+	SetThreshold(LevelIssue, ForScreen)
+	SetThreshold(LevelDebug, ForLogfile)
+	screenThresh := Threshold(ForScreen)
+	logThresh := Threshold(ForLogfile)
+	level := CurrentLevel()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, LevelIssue, screenThresh)
+	assert.Equal(t, LevelDebug, logThresh)
+	assert.Equal(t, LevelDebug, level)
+}
+
+func TestQuietOutput(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelDiscard, ForBoth)
+	traceCount := CurrentExitSummary(0).Counts["TRACE"]
+	formatted := 0
+	arg := lazyString(func() string { formatted++; return "arg" })
+
+	Traceln("skipped", arg)
+	Debugf("skipped %s\n", arg)
+	Issue("skipped", arg)
+	skippedCount := formatted
+	SetThresholdFunc(ForScreen, func(level Level) bool { return level == LevelTrace })
+	Traceln("passes the threshold func", arg)
+	SetThresholdFunc(ForScreen, nil)
+	// exits are never skipped, even if the output goes nowhere
+	exited := false
+	SetDeferFunc(func(val int) { exited = true })
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	IssueExit(3, "skipped but exits", arg)
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+	SetDeferFunc(nil)
+	counts := CurrentExitSummary(0).Counts
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 0, skippedCount)
+	assert.Equal(t, 2, formatted)
+	assert.Equal(t, "Trace: passes the threshold func arg\n", screenBuf.String()[strings.Index(screenBuf.String(), "Trace: "):])
+	assert.True(t, exited, "the defer func runs for a skipped exit")
+	assert.Equal(t, traceCount+2, counts["TRACE"], "skipped output is still counted")
+}
+
+func TestTargetWriters(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	captureBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetFlags(LevelAll, 0, ForLogfile)
+
+	PrintTo(ForScreen, "screen line started ")
+	w := io.MultiWriter(LogfileWriter(LevelNote), captureBuf)
+	n, err := fmt.Fprintf(w, "to the logfile only\n")
+	fmt.Fprintf(ScreenWriter(LevelDebug), "below the screen threshold\n")
+	fmt.Fprintf(ScreenWriter(LevelInfo), "and ended\n")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Nil(t, err)
+	assert.Equal(t, len("to the logfile only\n"), n)
+	assert.Equal(t, "to the logfile only\n", captureBuf.String())
+	assert.Equal(t, "screen line started and ended\n", screenBuf.String())
+	assert.Equal(t, "Note: to the logfile only\n", logBuf.String())
+}
+
+func TestMaxMessageBytes(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetMaxMessageBytes(10, ForScreen)
+	SetMaxMessageBytes(15, ForLogfile)
+	assert.Equal(t, 10, MaxMessageBytes(ForScreen))
+	assert.Equal(t, 15, MaxMessageBytes(ForLogfile))
+
+	Noteln("0123456789abcdefghij")
+	Print("short\n")
+	Println("012345678é is split") // é is 2 bytes, at bytes 9-10
+	// a formatter's record is never cut, only the screen is limited here
+	logOutput := logBuf.String()
+	logBuf.Reset()
+	SetFormatter(LevelIssue, LogfmtFormatter{TimeFormat: "-"})
+	Issueln("0123456789abcdefghij")
+	ClearFormatter(LevelAll)
+	formatted := logBuf.String()
+
+	SetMaxMessageBytes(0, ForBoth)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: 0123456789…(truncated, 10 bytes omitted)\nshort\n012345678…(truncated, 11 bytes omitted)\nIssue: 0123456789…(truncated, 10 bytes omitted)\n", screenBuf.String())
+	assert.Equal(t, "Note: 0123456789abcde…(truncated, 5 bytes omitted)\nshort\n012345678é is …(truncated, 5 bytes omitted)\n", logOutput)
+	assert.Regexp(t, `^ts=- level=issue .*msg=0123456789abcdefghij\n$`, formatted)
+}
+
+func TestReplayTo(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetReplayBuffer(2)
+	assert.Equal(t, 2, ReplayBufferSize())
+
+	Noteln("dropped, buffer only holds two")
+	Noteln("reading config")
+	Debugln("config parsed")
+	logBuf := new(bytes.Buffer)
+	SetFlags(LevelAll, Lshortfile, ForLogfile)
+	SetThreshold(LevelDebug, ForLogfile)
+	err := ReplayTo(logBuf, true)
+	assert.Nil(t, err)
+	replayed := logBuf.String()
+
+	Println("more output")
+	logBuf.Reset()
+	err = ReplayTo(logBuf, false)
+	assert.Nil(t, err)
+	replayedSince := logBuf.String()
+	SetReplayBuffer(0)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: dropped, buffer only holds two\nNote: reading config\nmore output\n", screenBuf.String())
+	assert.Regexp(t, regexp.MustCompile(`^out_test.go:\d+ *: Note: reading config\nout_test.go:\d+ *: Debug: config parsed\n$`), replayed)
+	assert.Regexp(t, regexp.MustCompile(`^out_test.go:\d+ *: more output\n$`), replayedSince)
+}
+
+// testLog is a TestLogger that keeps what was logged
+type testLog struct {
+	logged []string
+}
+
+func (l *testLog) Log(args ...interface{}) {
+	l.logged = append(l.logged, fmt.Sprint(args...))
+}
+
+// cleanupLog is a testLog that also has a Cleanup() method like testing.TB
+type cleanupLog struct {
+	testLog
+	cleanups []func()
+}
+
+func (l *cleanupLog) Cleanup(fn func()) {
+	l.cleanups = append(l.cleanups, fn)
+}
+
+func TestTestWriter(t *testing.T) {
+	tl := &testLog{}
+	SetTestWriter(tl, ForScreen)
+	Noteln("first note")
+	Issueln("a warning\nover two lines")
+	Print("no newline")
+	screenLogged := append([]string(nil), tl.logged...)
+
+	// With a Cleanup() method the writers are put back when the test ends
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	cl := &cleanupLog{}
+	SetTestWriter(cl, ForLogfile)
+	Noteln("to the test log")
+	for _, fn := range cl.cleanups {
+		fn()
+	}
+	Noteln("to the log buffer")
+	screenWriter := Writer(LevelNote, ForScreen)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, []string{"Note: first note", "Issue: a warning\nIssue: over two lines", "no newline"}, screenLogged)
+	assert.Equal(t, []string{"Note: to the test log"}, cl.logged)
+	assert.Equal(t, "Note: to the log buffer\n", logBuf.String())
+	assert.Equal(t, TestWriter(tl), screenWriter)
+}
+
+func TestClosedFilePolicy(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	logName := UseTempLogFile("dvln.")
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	Noteln("before close")
+	Writer(LevelNote, ForLogfile).(*os.File).Close()
+
+	SetClosedFilePolicy(ClosedFileDrop)
+	assert.Equal(t, ClosedFileDrop, ClosedFilePolicy())
+	Noteln("dropped from the log")
+	SetClosedFilePolicy(ClosedFileReopen)
+	Noteln("after reopen")
+	Writer(LevelNote, ForLogfile).(*os.File).Close()
+	SetClosedFilePolicy(ClosedFileError)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: before close\nNote: dropped from the log\nNote: after reopen\n", screenBuf.String())
+	logBuf, err := ioutil.ReadFile(logName)
+	assert.Nil(t, err)
+	assert.Equal(t, "Note: before close\nNote: after reopen\n", string(logBuf))
+	os.Remove(logName)
+}
+
+func TestBuildInfo(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetBuildInfo(map[string]string{"commit": "1a2b3c4d5e6f", "modified": "true", "build_time": "2016-01-02T03:04:05Z"})
+	assert.Equal(t, "1a2b3c4d5e6f", BuildInfo()["commit"])
+	SetFlags(LevelNote, Lbuildinfo, ForScreen)
+	Noteln("built from a dirty tree")
+	SetBuildInfo(map[string]string{"version": "v1.2.3"})
+	Noteln("built from a release")
+	SetBuildInfo(map[string]string{})
+	Noteln("no build info")
+	capture := &fieldsCapture{}
+	SetFormatter(LevelNote, capture)
+	SetBuildInfo(map[string]string{"commit": "abc"})
+	Noteln("structured")
+	SetBuildInfo(nil)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "1a2b3c4-dirty Note: built from a dirty tree\nv1.2.3 Note: built from a release\nNote: no build info\nabc Note: structured\n", screenBuf.String())
+	assert.Equal(t, []map[string]interface{}{{"commit": "abc"}}, capture.fields)
+}
+
+func TestThresholdFunc(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForBoth)
+	SetThreshold(LevelNote, ForLogfile)
+	debugOn := false
+	var calls int
+	SetThresholdFunc(ForScreen, func(level Level) bool {
+		calls++
+		return level >= LevelIssue || (level == LevelDebug && debugOn)
+	})
+
+	Debugln("debug off")
+	Println("info filtered")
+	debugOn = true
+	Debugln("debug on")
+	Issueln("issue shown")
+	SetThresholdFunc(ForScreen, nil)
+	Println("back to the threshold")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, "Debug: debug on\nIssue: issue shown\nback to the threshold\n", screenBuf.String())
+	assert.Equal(t, "Issue: issue shown\n", logBuf.String())
+}
+
+func TestRule(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	origColumns := os.Getenv("COLUMNS")
+	os.Setenv("COLUMNS", "20")
+
+	Rule(ForBoth)
+	Rulef('=', 5)
+	SetRuleInLogfile(false)
+	Rule(ForBoth)
+	SetRuleInLogfile(true)
+	os.Setenv("COLUMNS", origColumns)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	dashes := strings.Repeat("-", 20) + "\n"
+	assert.Equal(t, dashes+"=====\n"+dashes, screenBuf.String())
+	assert.Equal(t, strings.Repeat("-", DefaultRuleWidth)+"\n=====\n", logBuf.String())
+}
+
+func TestWriterExitsOnFatal(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(0)
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	exits := 0
+	SetDeferFunc(func(exitVal int) { exits++ })
+
+	fmt.Fprintf(FATAL, "no exit by default\n")
+	assert.Equal(t, 0, exits)
+	SetWriterExitsOnFatal(true)
+	assert.True(t, WriterExitsOnFatal())
+	fmt.Fprintf(FATAL, "now it exits\n")
+	assert.Equal(t, 1, exits)
+	fmt.Fprintf(ERROR, "other levels don't\n")
+	assert.Equal(t, 1, exits)
+
+	SetWriterExitsOnFatal(false)
+	SetDeferFunc(nil)
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Fatal: no exit by default\nFatal: now it exits\nError: other levels don't\n", screenBuf.String())
+}
+
+func TestRenderedWidth(t *testing.T) {
+	assert.Equal(t, 4, displayWidth("日本"))
+	assert.Equal(t, 1, displayWidth("e\u0301"))
+	assert.Equal(t, 2, displayWidth("🙂"))
+
+	assert.Equal(t, 11, RenderedWidth(LevelInfo, ForScreen, "hello world\nmore"))
+	assert.Equal(t, len("Note: ")+4, RenderedWidth(LevelNote, ForScreen, "日本"))
+	SetFlags(LevelNote, Ltime, ForScreen)
+	assert.Equal(t, len("01:23:45 Note: ")+2, RenderedWidth(LevelNote, ForScreen, "hi"))
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+func TestExitSummary(t *testing.T) {
+	Discard(ForBoth)
+	SetStackTraceConfig(0)
+	os.Setenv("PKG_OUT_NO_EXIT", "1")
+	before := CurrentExitSummary(0)
+	Println("counted even though discarded")
+	Errorln("an error")
+	summaryBuf := new(bytes.Buffer)
+	SetExitSummary(summaryBuf, ExitSummaryJSON)
+	Exit(2)
+	var summary ExitSummary
+	assert.Nil(t, json.Unmarshal(summaryBuf.Bytes(), &summary))
+	summaryBuf.Reset()
+	SetExitSummary(summaryBuf, ExitSummaryText)
+	Exit(0)
+	text := summaryBuf.String()
+	SetExitSummary(nil, ExitSummaryJSON)
+	os.Setenv("PKG_OUT_NO_EXIT", "0")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 2, summary.ExitValue)
+	assert.True(t, LevelString2Level(summary.HighestSeverity) >= LevelError)
+	assert.Equal(t, before.Counts["INFO"]+1, summary.Counts["INFO"])
+	assert.Equal(t, before.Counts["ERROR"]+1, summary.Counts["ERROR"])
+	assert.True(t, summary.DurationSecs > 0)
+	assert.True(t, strings.HasPrefix(text, "exit_value=0 highest_severity="+summary.HighestSeverity+" duration_secs="), text)
+	assert.True(t, strings.HasSuffix(text, "\n"))
+}
+
+func TestCategory(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, Lcategory, ForBoth)
+	SetThreshold(LevelInfo, ForLogfile)
+
+	InfoCat("net", "dialing ", "host\n")
+	PrintCatf("db", LevelNote, "%d rows\n", 3)
+	Println("untagged")
+	SetCategoryFilter(nil, []string{"db"})
+	IssueCatf("db", "slow query\n")
+	NoteCat("net", "still here\n")
+	SetCategoryFilter([]string{"auth"}, nil)
+	include, exclude := CategoryFilter()
+	InfoCat("net", "filtered\n")
+	PrintCatln("auth", LevelInfo, "login ok")
+	Println("untagged is never filtered")
+	SetCategoryFilter(nil, nil)
+	var categories []string
+	SetPrefixFunc(LevelIssue, func(level Level, meta FlagMetadata) string {
+		categories = append(categories, meta.Category)
+		return "Issue: "
+	})
+	IssueCat("auth", "bad password\n")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	expected := "[net] dialing host\n[db] Note: 3 rows\nuntagged\n[net] Note: still here\n[auth] login ok\nuntagged is never filtered\n[auth] Issue: bad password\n"
+	assert.Equal(t, expected, screenBuf.String())
+	assert.Equal(t, expected, logBuf.String())
+	assert.Equal(t, []string{"auth"}, include)
+	assert.Nil(t, exclude)
+	assert.Equal(t, []string{"auth"}, categories, "called once for both targets")
+}
+
+func TestLevelColor(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForBoth)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetColorMode(ColorAuto)
+
+	Errorln("not a terminal")
+	SetColorMode(ColorAlways)
+	Errorln("always")
+	Note("partial ")
+	Noteln("line")
+	Println("info has no prefix")
+	SetLevelColor(LevelError, "")
+	Errorln("no color")
+	SetLevelColor(LevelError, "31")
+	DisableColor()
+	Errorln("colors off")
+	mode := ColorMode()
+	EnableColor()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Error: not a terminal\n\x1b[31mError: \x1b[0malways\n\x1b[33mNote: \x1b[0mpartial line\ninfo has no prefix\nError: no color\nError: colors off\n", screenBuf.String())
+	assert.Equal(t, "Error: not a terminal\nError: always\nNote: partial line\ninfo has no prefix\nError: no color\nError: colors off\n", logBuf.String())
+	assert.Equal(t, ColorNever, mode)
+	assert.Equal(t, "31", LevelColor(LevelError))
+	assert.Equal(t, 7, displayWidth("\x1b[31mError: \x1b[0m"))
+}
+
+func TestTerminalColor(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "color")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	assert.False(t, terminalColor(tmpFile))
+	_, cached := terminalFiles[tmpFile]
+	assert.True(t, cached)
+	assert.False(t, terminalColor(new(bytes.Buffer)))
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		// the null device is a character device but not a terminal
+		defer devNull.Close()
+		assert.False(t, isTerminal(devNull))
+		assert.False(t, terminalColor(devNull))
+	}
+	// files opened over time don't grow the cache past its bound
+	for i := 0; i < maxTerminalFiles*2; i++ {
+		f, err := os.Open(tmpFile.Name())
+		assert.Nil(t, err)
+		assert.False(t, terminalColor(f))
+		f.Close()
+	}
+	assert.True(t, len(terminalFiles) <= maxTerminalFiles)
+	if ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0); err == nil && isTerminal(ptmx) {
+		// a pseudo terminal's master side is a real terminal (where there
+		// is one, ie: not on Windows)
+		defer ptmx.Close()
+		parent := NewOutputter()
+		parent.SetWriter(LevelAll, ptmx, ForScreen)
+		childHndl := parent.Child("[child] ").LevelWriter(LevelError).screenHndl
+		assert.True(t, isTerminal(childHndl), "a child sees the parent's terminal")
+		if os.Getenv("NO_COLOR") == "" {
+			assert.True(t, terminalColor(ptmx))
+			assert.True(t, terminalColor(childHndl))
+		}
+		origNoColor := os.Getenv("NO_COLOR")
+		os.Setenv("NO_COLOR", "1")
+		assert.False(t, terminalColor(ptmx))
+		os.Setenv("NO_COLOR", origNoColor)
+	}
+	if runtime.GOOS != "windows" {
+		// only Windows consoles need virtual terminal processing turned on
+		assert.Nil(t, EnableVirtualTerminal())
+		assert.Nil(t, enableVirtualTerminal(tmpFile))
+	}
+}
+
+func TestGoroutineIDFlag(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Lgoid|Llevel, ForScreen)
+	Println("main")
+	var otherID uint64
+	done := make(chan struct{})
+	go func() {
+		otherID = goroutineID()
+		Println("other")
+		close(done)
+	}()
+	<-done
+	goidOutput := screenBuf.String()
+	goidFlags := determineFlags("goid,level")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	mainID := goroutineID()
+	assert.NotEqual(t, mainID, otherID)
+	assert.Equal(t, fmt.Sprintf("g%d INFO    main\ng%d INFO    other\n", mainID, otherID), goidOutput)
+	assert.Equal(t, Lgoid|Llevel, goidFlags)
+}
@@ -0,0 +1,864 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Outputter is a complete, independent set of output levels (trace through
+// fatal) with their own screen and logfile writers, prefixes, flags and
+// formatters along with the screen and logfile thresholds, log file name and
+// newline tracking, eg: so a library can have its own output settings without
+// clobbering the host tools settings:
+//
+//	lib := out.NewOutputter()
+//	lib.SetThreshold(out.LevelDebug, out.ForScreen)
+//	lib.Debugln("only the lib's debug output is shown")
+//
+// The pkg level routines (eg: out.Println() or out.SetThreshold()) all work
+// on a default Outputter, whose levels are the TRACE, DEBUG, .. FATAL writers.
+// Settings that aren't on the Outputter are shared by all of them, eg: the
+// stack trace config, call depth, exit handling, strict mode and such (strict
+// mode and the replay buffer only hold and keep the default Outputter's output).
+// All Outputter fields are protected by the pkg mutex, except for those noted
+// as atomic which are read on every output call.
+type Outputter struct {
+	outputters          []*LvlOutput           // the output levels, indexed by Level
+	screenThreshold     int32                  // screen output threshold Level (atomic)
+	logThreshold        int32                  // logfile output threshold Level (atomic)
+	logFileName         string                 // log file name, if known
+	dailyLog            *dailyRotateWriter     // set if the log file rotates daily
+	screenThresholdFunc func(level Level) bool // overrides screenThreshold, if set
+	logThresholdFunc    func(level Level) bool // overrides logThreshold, if set
+	quietBelow          int32                  // output below this level goes nowhere (atomic), see skipQuietOutput()
+	screenNewline       bool                   // last screen output ended in a newline
+	logfileNewline      bool                   // last logfile output ended in a newline
+	parent              *Outputter             // set for a child, see Child()
+	childPrefix         string                 // all child prefixes, see Child()
+	fields              map[string]interface{} // fields for all output, see WithFields()
+}
+
+// std is the default Outputter used by all the pkg level routines
+var std = NewOutputter()
+
+// NewOutputter returns a new Outputter with the same starting settings as
+// the pkg has, ie: info and higher output to the screen (errors and fatals
+// to stderr, the rest to stdout) and logfile output off
+func NewOutputter() *Outputter {
+	op := &Outputter{
+		screenThreshold: int32(defaultScreenThreshold),
+		logThreshold:    int32(defaultLogThreshold),
+		screenNewline:   true,
+		logfileNewline:  true,
+	}
+	op.outputters = []*LvlOutput{
+		{level: LevelTrace, prefix: "Trace: ", screenHndl: os.Stdout, screenFlags: LscreenFlags, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelDebug, prefix: "Debug: ", screenHndl: os.Stdout, screenFlags: LscreenFlags, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelVerbose, prefix: "", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelInfo, prefix: "", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelNote, prefix: "Note: ", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelWarn, prefix: "Warning: ", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelIssue, prefix: "Issue: ", screenHndl: os.Stdout, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelError, prefix: "Error: ", screenHndl: os.Stderr, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+		{level: LevelFatal, prefix: "Fatal: ", screenHndl: os.Stderr, screenFlags: 0, logfileHndl: ioutil.Discard, logFlags: LlogfileFlags, outputter: op},
+	}
+	for i, custom := range registeredLevels().levels {
+		op.outputters = append(op.outputters, newCustomLvlOutput(op, firstCustomLevel+Level(i), custom.severity, custom.prefix))
+	}
+	op.updateQuietBelow()
+	return op
+}
+
+// parent returns the Outputter with the thresholds and newline tracking for
+// the output level, ie: the Outputter it belongs to (or the top parent of a
+// child, see Child()) or the default one if it isn't set (eg: a temporary
+// LvlOutput used for replaying)
+func (o *LvlOutput) parent() *Outputter {
+	if o.outputter == nil {
+		return std
+	}
+	return o.outputter.root()
+}
+
+// root returns the top most parent of a child Outputter (see Child()), it
+// holds the thresholds and newline tracking, returns itself if not a child
+func (op *Outputter) root() *Outputter {
+	for op.parent != nil {
+		op = op.parent
+	}
+	return op
+}
+
+// screenThresh returns the screen output threshold (no locking needed)
+func (op *Outputter) screenThresh() Level {
+	return Level(atomic.LoadInt32(&op.screenThreshold))
+}
+
+// logThresh returns the logfile output threshold (no locking needed)
+func (op *Outputter) logThresh() Level {
+	return Level(atomic.LoadInt32(&op.logThreshold))
+}
+
+// configured notes that the default Outputter has been configured so any
+// output held by strict mode is sent out, see SetStrictMode()
+func (op *Outputter) configured() {
+	if op.root() == std {
+		strictConfigured()
+	}
+}
+
+// Child returns a new Outputter for part of a tool (eg: a sub-command) that
+// writes via this Outputter's writers with the given prefix added in front of
+// each level's prefix, eg: for a "build" sub-command:
+//
+//	build := out.Child("[build] ")
+//	build.Println("compiling")     // "[build] compiling"
+//	build.Issueln("no tests")      // "[build] Issue: no tests"
+//
+// The writers are shared, so changing the parent's writers via SetWriter()
+// or SetLogFile() later is also seen by the child (unless the child has set
+// its own writers).  The thresholds, threshold funcs, log file name and the
+// newline tracking are shared with the parent too, ie: threshold changes on
+// the parent or the child propagate to both (and any other children).  The
+// flags, formatters, prefixes and prefix funcs are copied when the child is
+// created, so changes to those on the parent after that aren't seen by the
+// child and changes on the child only affect the child.  A child of a child
+// adds its prefix after its parent's prefix (eg: "[build] [test] ").
+func (op *Outputter) Child(prefix string) *Outputter {
+	child := &Outputter{parent: op, childPrefix: op.childPrefix + prefix, fields: op.fields}
+	for _, po := range op.outputters {
+		po.mu.RLock()
+		o := &LvlOutput{
+			level:       po.level,
+			prefix:      child.childPrefix + strings.TrimPrefix(po.prefix, op.childPrefix),
+			screenHndl:  &sharedWriter{lvl: po, outputTgt: ForScreen},
+			screenFlags: po.screenFlags,
+			logfileHndl: &sharedWriter{lvl: po, outputTgt: ForLogfile},
+			logFlags:    po.logFlags,
+			formatter:   po.formatter,
+			outputter:   child,
+		}
+		if fn := po.prefixFunc; fn != nil {
+			o.prefixFunc = func(level Level, meta FlagMetadata) string {
+				return child.childPrefix + strings.TrimPrefix(fn(level, meta), op.childPrefix)
+			}
+		}
+		po.mu.RUnlock()
+		child.outputters = append(child.outputters, o)
+	}
+	return child
+}
+
+// WithFields returns a child of the Outputter (see Child(), no prefix is
+// added) with the given key/value fields attached to all of its output, eg:
+// for per request context:
+//
+//	reqOut := out.NewOutputter().WithFields(map[string]interface{}{"request_id": id})
+//	reqOut.Noteln("handling request")
+//
+// The fields are added to the structured fields in the output's metadata, so
+// formatters and metadata aware writers (eg: JSON or journald output) get
+// them, and plain text logfile lines get them as key=value pairs (sorted by
+// key) right after the level prefix.  Fields given replace any the Outputter
+// already has with the same key, the map isn't used after the call.
+func (op *Outputter) WithFields(kv map[string]interface{}) *Outputter {
+	child := op.Child("")
+	fields := make(map[string]interface{}, len(op.fields)+len(kv))
+	for k, v := range op.fields {
+		fields[k] = v
+	}
+	for k, v := range kv {
+		fields[k] = v
+	}
+	child.fields = fields
+	return child
+}
+
+// Child returns a child of the default Outputter (the one the pkg level
+// routines use) with the given prefix, see (*Outputter).Child() for details
+func Child(prefix string) *Outputter {
+	return std.Child(prefix)
+}
+
+// sharedWriter is the screen or logfile writer for a child's output level,
+// it writes to whatever writer the parent's level currently has, see Child()
+type sharedWriter struct {
+	lvl       *LvlOutput // the parent's output level
+	outputTgt int        // ForScreen or ForLogfile
+}
+
+// target returns the parent level's current writer for the target
+func (w *sharedWriter) target() io.Writer {
+	w.lvl.mu.RLock()
+	defer w.lvl.mu.RUnlock()
+	if w.outputTgt&ForScreen != 0 {
+		return w.lvl.screenHndl
+	}
+	return w.lvl.logfileHndl
+}
+
+// Write writes to the parent level's current writer
+func (w *sharedWriter) Write(p []byte) (int, error) {
+	return w.target().Write(p)
+}
+
+// Unwrap returns the parent level's current writer, so terminal detection
+// (for colors, wrapping and such) sees the parent's screen file
+func (w *sharedWriter) Unwrap() io.Writer {
+	return w.target()
+}
+
+// WriteMetadata writes to the parent level's current writer, passing along
+// the metadata only if that writer wants it (see metadataWriter), callers
+// check handleWantsMetadata() first so the caller's file/line#/func isn't
+// looked up for a parent writer that would drop it
+func (w *sharedWriter) WriteMetadata(p []byte, level Level, mdata *FlagMetadata) (int, error) {
+	return writeHandle(w.target(), p, level, mdata)
+}
+
+// LevelWriter is like the pkg LevelWriter() but for this Outputter's levels
+func (op *Outputter) LevelWriter(l Level) *LvlOutput {
+	l = levelCheck(l)
+	if custom, ok := customLevelInfo(l); ok {
+		for _, o := range op.outputters[LevelFatal+1:] {
+			if o.level == l {
+				return o
+			}
+		}
+		// an Outputter from before the level was registered
+		return op.outputters[custom.severity]
+	}
+	if l < LevelTrace || l > LevelFatal {
+		l = LevelInfo
+	}
+	return op.outputters[l]
+}
+
+// ScreenWriter is like the pkg ScreenWriter() but for this Outputter
+func (op *Outputter) ScreenWriter(level Level) io.Writer {
+	return &targetWriter{o: op.LevelWriter(level), outputTgt: ForScreen}
+}
+
+// LogfileWriter is like the pkg LogfileWriter() but for this Outputter
+func (op *Outputter) LogfileWriter(level Level) io.Writer {
+	return &targetWriter{o: op.LevelWriter(level), outputTgt: ForLogfile}
+}
+
+// Threshold is like the pkg Threshold() but for this Outputter
+func (op *Outputter) Threshold(outputTgt int) Level {
+	var threshold Level
+	if outputTgt&ForScreen != 0 {
+		threshold = op.root().screenThresh()
+	} else if outputTgt&ForLogfile != 0 {
+		threshold = op.root().logThresh()
+	} else {
+		Fatalln("Invalid screen/logfile given for Threshold()")
+	}
+	return threshold
+}
+
+// SetThreshold is like the pkg SetThreshold() but for this Outputter
+func (op *Outputter) SetThreshold(level Level, outputTgt int) {
+	defer op.configured()
+	if outputTgt&ForScreen != 0 {
+		lc := levelCheck(level)
+		mutex.Lock()
+		atomic.StoreInt32(&op.root().screenThreshold, int32(lc))
+		op.root().updateQuietBelow()
+		mutex.Unlock()
+	}
+	if outputTgt&ForLogfile != 0 {
+		lc := levelCheck(level)
+		mutex.Lock()
+		atomic.StoreInt32(&op.root().logThreshold, int32(lc))
+		op.root().updateQuietBelow()
+		mutex.Unlock()
+	}
+}
+
+// SetThresholdFunc is like the pkg SetThresholdFunc() but for this Outputter
+func (op *Outputter) SetThresholdFunc(outputTgt int, fn func(level Level) bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if outputTgt&ForScreen != 0 {
+		op.root().screenThresholdFunc = fn
+	}
+	if outputTgt&ForLogfile != 0 {
+		op.root().logThresholdFunc = fn
+	}
+	op.root().updateQuietBelow()
+}
+
+// updateQuietBelow sets the level that output has to be at or above to get
+// past either threshold, with a threshold func in use any level might (the
+// pkg mutex must be held by the caller)
+func (op *Outputter) updateQuietBelow() {
+	quietBelow := op.screenThresh()
+	if logThresh := op.logThresh(); logThresh < quietBelow {
+		quietBelow = logThresh
+	}
+	if op.screenThresholdFunc != nil || op.logThresholdFunc != nil {
+		quietBelow = LevelTrace
+	}
+	atomic.StoreInt32(&op.quietBelow, int32(quietBelow))
+}
+
+// SetLevel is like the pkg SetLevel() but for this Outputter
+func (op *Outputter) SetLevel(level Level) {
+	op.SetThreshold(level, ForBoth)
+}
+
+// CurrentLevel is like the pkg CurrentLevel() but for this Outputter
+func (op *Outputter) CurrentLevel() Level {
+	screenThresh := op.root().screenThresh()
+	if logThresh := op.root().logThresh(); logThresh < screenThresh {
+		return logThresh
+	}
+	return screenThresh
+}
+
+// Discard is like the pkg Discard() but for this Outputter
+func (op *Outputter) Discard(outputTgt int) {
+	if outputTgt&ForScreen != 0 {
+		op.SetThreshold(LevelDiscard, ForScreen)
+	}
+	if outputTgt&ForLogfile != 0 {
+		op.SetThreshold(LevelDiscard, ForLogfile)
+	}
+}
+
+// Prefix is like the pkg Prefix() but for this Outputter
+func (op *Outputter) Prefix(level Level) string {
+	level = levelCheck(level)
+	if level == LevelDiscard {
+		Fatalln("Prefix is not defined for level discard, should never be requested")
+	}
+	var prefix string
+	for _, o := range op.outputters {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		if o.level == level {
+			prefix = o.prefix
+			break
+		}
+	}
+	return prefix
+}
+
+// SetPrefix is like the pkg SetPrefix() but for this Outputter
+func (op *Outputter) SetPrefix(level Level, prefix string) {
+	level = levelCheck(level)
+	if level == LevelDiscard {
+		return
+	}
+	// loop through the levels and reset the prefix of the specified level
+	for _, o := range op.outputters {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.level == level {
+			o.prefix = prefix
+		}
+	}
+}
+
+// SetPrefixFunc is like the pkg SetPrefixFunc() but for this Outputter
+func (op *Outputter) SetPrefixFunc(level Level, fn PrefixFunc) {
+	for _, o := range op.outputters {
+		if level == LevelAll || o.level == level {
+			o.mu.Lock()
+			o.prefixFunc = fn
+			o.mu.Unlock()
+			if level != LevelAll {
+				break
+			}
+		}
+	}
+}
+
+// Flags is like the pkg Flags() but for this Outputter
+func (op *Outputter) Flags(level Level, outputTgt int) int {
+	level = levelCheck(level)
+	var flags int
+	for _, o := range op.outputters {
+		o.mu.RLock()
+		sF := o.screenFlags
+		lF := o.logFlags
+		outLvl := o.level
+		o.mu.RUnlock()
+		if outLvl == level {
+			if outputTgt&ForScreen != 0 {
+				flags = sF
+			} else if outputTgt&ForLogfile != 0 {
+				flags = lF
+			} else {
+				Fatalln("Invalid identification of screen or logfile target for Flags()")
+			}
+			break
+		}
+	}
+	return flags
+}
+
+// EffectiveFlags is like the pkg EffectiveFlags() but for this Outputter
+func (op *Outputter) EffectiveFlags(level Level, outputTgt int) int {
+	return envFlags(op.Flags(level, outputTgt), outputTgt)
+}
+
+// SetFlags is like the pkg SetFlags() but for this Outputter
+func (op *Outputter) SetFlags(level Level, flags int, outputTgt int) {
+	for _, o := range op.outputters {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if level == LevelAll || o.level == level {
+			if outputTgt&ForScreen != 0 {
+				o.screenFlags = flags
+			}
+			if outputTgt&ForLogfile != 0 {
+				o.logFlags = flags
+			}
+			if level != LevelAll {
+				break
+			}
+		}
+	}
+}
+
+// Writer is like the pkg Writer() but for this Outputter
+func (op *Outputter) Writer(level Level, outputTgt int) io.Writer {
+	level = levelCheck(level)
+	writer := ioutil.Discard
+	for _, o := range op.outputters {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		if o.level == level {
+			if outputTgt&ForScreen != 0 {
+				writer = o.screenHndl
+			}
+			if outputTgt&ForLogfile != 0 {
+				writer = o.logfileHndl
+			}
+		}
+	}
+	return writer
+}
+
+// SetWriter is like the pkg SetWriter() but for this Outputter
+func (op *Outputter) SetWriter(level Level, w io.Writer, outputTgt int) {
+	defer op.configured() // deferred 1st so it runs after the unlocks
+	for _, o := range op.outputters {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if level == LevelAll || o.level == level {
+			if outputTgt&ForScreen != 0 {
+				o.screenHndl = w
+			}
+			if outputTgt&ForLogfile != 0 {
+				o.logfileHndl = w
+			}
+			if level != LevelAll {
+				break
+			}
+		}
+	}
+}
+
+// SetOutput is like the pkg SetOutput() but for this Outputter
+func (op *Outputter) SetOutput(w io.Writer) {
+	op.SetWriter(LevelAll, w, ForScreen)
+}
+
+// SetOutputFor is like the pkg SetOutputFor() but for this Outputter
+func (op *Outputter) SetOutputFor(w io.Writer, outputTgt int) {
+	op.SetWriter(LevelAll, w, outputTgt)
+}
+
+// SetFormatter is like the pkg SetFormatter() but for this Outputter
+func (op *Outputter) SetFormatter(level Level, formatter Formatter) {
+	for _, o := range op.outputters {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if level == LevelAll || o.level == level {
+			o.formatter = formatter
+			if o.level == level {
+				break
+			}
+		}
+	}
+}
+
+// ClearFormatter is like the pkg ClearFormatter() but for this Outputter
+func (op *Outputter) ClearFormatter(level Level) {
+	op.SetFormatter(level, nil)
+}
+
+// ResetNewline is like the pkg ResetNewline() but for this Outputter
+func (op *Outputter) ResetNewline(val bool, outputTgt int) {
+	// Safely adjust these settings
+	mutex.Lock()
+	{
+		if outputTgt&ForScreen != 0 {
+			op.root().screenNewline = val
+		}
+		if outputTgt&ForLogfile != 0 {
+			op.root().logfileNewline = val
+		}
+	}
+	mutex.Unlock()
+}
+
+// EnsureNewline is like the pkg EnsureNewline() but for this Outputter
+func (op *Outputter) EnsureNewline(outputTgt int) error {
+	info := op.outputters[LevelInfo]
+	info.mu.RLock()
+	screenHndl := info.screenHndl
+	logfileHndl := info.logfileHndl
+	info.mu.RUnlock()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if outputTgt&ForScreen != 0 && !op.root().screenNewline {
+		if _, err := screenHndl.Write([]byte("\n")); err != nil {
+			return err
+		}
+		op.root().screenNewline = true
+	}
+	if outputTgt&ForLogfile != 0 && !op.root().logfileNewline {
+		if _, err := logfileHndl.Write([]byte("\n")); err != nil {
+			return err
+		}
+		op.root().logfileNewline = true
+	}
+	return nil
+}
+
+// LogFileName is like the pkg LogFileName() but for this Outputter
+func (op *Outputter) LogFileName() string {
+	mutex.Lock()
+	safeLogFileName := op.root().logFileName
+	dailyLog := op.root().dailyLog
+	mutex.Unlock()
+	if dailyLog != nil {
+		// the dated file in use, see SetLogFileDailyRotation()
+		return dailyLog.currentName()
+	}
+	return safeLogFileName
+}
+
+// SetLogFile is like the pkg SetLogFile() but for this Outputter
+func (op *Outputter) SetLogFile(path string) {
+	defer op.configured() // deferred 1st so it runs after the unlocks
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		Fatalln("Failed to open log file:", path, "Err:", err)
+	}
+	op.useLogFile(path, file)
+}
+
+// SetLogFileWithRotation is like the pkg SetLogFileWithRotation() but for
+// this Outputter
+func (op *Outputter) SetLogFileWithRotation(path string, maxBytes int64, keep int, compress bool) {
+	defer op.configured() // deferred 1st so it runs after the unlocks
+	w, err := newSizeRotateWriter(path, maxBytes, keep, compress)
+	if err != nil {
+		Fatalln("Failed to open log file:", path, "Err:", err)
+	}
+	op.useLogFile(path, w)
+}
+
+// SetLogFileDailyRotation is like the pkg SetLogFileDailyRotation() but for
+// this Outputter
+func (op *Outputter) SetLogFileDailyRotation(pathPattern string) {
+	defer op.configured() // deferred 1st so it runs after the unlocks
+	w, err := newDailyRotateWriter(pathPattern)
+	if err != nil {
+		Fatalln("Failed to open log file:", pathPattern, "Err:", err)
+	}
+	op.useLogFile(w.currentName(), w)
+}
+
+// UseTempLogFile is like the pkg UseTempLogFile() but for this Outputter
+func (op *Outputter) UseTempLogFile(prefix string) string {
+	file, err := ioutil.TempFile(os.TempDir(), prefix)
+	if err != nil {
+		Fatalln(err)
+	}
+	op.useLogFile(file.Name(), file)
+	return file.Name()
+}
+
+// useLogFile points the logfile output of every level at the given log file
+// writer, name is the log file's name
+func (op *Outputter) useLogFile(name string, w io.Writer) {
+	// Safely adjust this setting
+	dailyLog, _ := w.(*dailyRotateWriter)
+	mutex.Lock()
+	op.root().logFileName = name
+	op.root().dailyLog = dailyLog
+	mutex.Unlock()
+	for _, o := range op.outputters {
+		o.mu.Lock()
+		o.logfileHndl = w
+		o.mu.Unlock()
+	}
+}
+
+// ReopenLogFile is like the pkg ReopenLogFile() but for this Outputter
+func (op *Outputter) ReopenLogFile() error {
+	name := op.LogFileName()
+	if name == "" {
+		return nil
+	}
+	var hndls []io.Writer
+	for _, o := range op.root().outputters {
+		o.mu.RLock()
+		hndls = append(hndls, o.logfileHndl)
+		o.mu.RUnlock()
+	}
+	var file *os.File
+	reopened := make(map[io.Writer]bool)
+	for _, hndl := range hndls {
+		if reopened[hndl] {
+			continue
+		}
+		switch w := hndl.(type) {
+		case interface{ reopen() error }:
+			// a rotating log file writer, it keeps its handle if this fails
+			if err := w.reopen(); err != nil {
+				return err
+			}
+		case *os.File:
+			if w.Name() != name {
+				continue
+			}
+			if file == nil {
+				var err error
+				file, err = os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+				if err != nil {
+					return err
+				}
+			}
+			// swap the handle for every level using the old one, then
+			// close it (writes are done under the pkg mutex)
+			mutex.Lock()
+			for _, lo := range op.root().outputters {
+				lo.mu.Lock()
+				if lo.logfileHndl == hndl {
+					lo.logfileHndl = file
+				}
+				lo.mu.Unlock()
+			}
+			w.Close()
+			mutex.Unlock()
+		}
+		reopened[hndl] = true
+	}
+	return nil
+}
+
+// CloseLogFile is like the pkg CloseLogFile() but for this Outputter
+func (op *Outputter) CloseLogFile() error {
+	root := op.root()
+	// writes are done under the pkg mutex so none are in flight while the
+	// log file is closed, any that had the old handle retry with the new one
+	mutex.Lock()
+	defer mutex.Unlock()
+	name := root.logFileName
+	root.logFileName = ""
+	root.dailyLog = nil
+	if name == "" {
+		return nil
+	}
+	var closeErr error
+	closed := make(map[io.Writer]bool)
+	for _, o := range root.outputters {
+		o.mu.Lock()
+		hndl := o.logfileHndl
+		var closer func() error
+		switch w := hndl.(type) {
+		case *sizeRotateWriter:
+			closer = w.close
+		case *dailyRotateWriter:
+			closer = w.close
+		case *os.File:
+			if w.Name() == name {
+				closer = w.Close
+			}
+		}
+		if closer != nil {
+			if !closed[hndl] {
+				closed[hndl] = true
+				if err := closer(); err != nil && closeErr == nil {
+					closeErr = err
+				}
+			}
+			o.logfileHndl = ioutil.Discard
+		}
+		o.mu.Unlock()
+	}
+	return closeErr
+}
+
+// Verbose is like the pkg Verbose() but for this Outputter
+func (op *Outputter) Verbose(v ...interface{}) {
+	op.outputters[LevelVerbose].output(false, 0, ForBoth, v...)
+}
+
+// Print is like the pkg Print() but for this Outputter
+func (op *Outputter) Print(v ...interface{}) {
+	op.outputters[LevelInfo].output(false, 0, ForBoth, v...)
+}
+
+// Info is like the pkg Info() but for this Outputter
+func (op *Outputter) Info(v ...interface{}) {
+	op.outputters[LevelInfo].output(false, 0, ForBoth, v...)
+}
+
+// Note is like the pkg Note() but for this Outputter
+func (op *Outputter) Note(v ...interface{}) {
+	op.outputters[LevelNote].output(false, 0, ForBoth, v...)
+}
+
+// Warn is like the pkg Warn() but for this Outputter
+func (op *Outputter) Warn(v ...interface{}) {
+	op.outputters[LevelWarn].output(false, 0, ForBoth, v...)
+}
+
+// Issue is like the pkg Issue() but for this Outputter
+func (op *Outputter) Issue(v ...interface{}) {
+	op.outputters[LevelIssue].output(false, 0, ForBoth, v...)
+}
+
+// IssueExit is like the pkg IssueExit() but for this Outputter
+func (op *Outputter) IssueExit(exitVal int, v ...interface{}) {
+	op.outputters[LevelIssue].output(true, exitVal, ForBoth, v...)
+}
+
+// Error is like the pkg Error() but for this Outputter
+func (op *Outputter) Error(v ...interface{}) {
+	op.outputters[LevelError].output(false, 0, ForBoth, v...)
+}
+
+// ErrorExit is like the pkg ErrorExit() but for this Outputter
+func (op *Outputter) ErrorExit(exitVal int, v ...interface{}) {
+	op.outputters[LevelError].output(true, exitVal, ForBoth, v...)
+}
+
+// Fatal is like the pkg Fatal() but for this Outputter, it exits the tool
+func (op *Outputter) Fatal(v ...interface{}) {
+	op.outputters[LevelFatal].output(true, int(atomic.LoadInt32(&errorExitVal)), ForBoth, v...)
+}
+
+// Verboseln is like the pkg Verboseln() but for this Outputter
+func (op *Outputter) Verboseln(v ...interface{}) {
+	op.outputters[LevelVerbose].outputln(false, 0, ForBoth, v...)
+}
+
+// Println is like the pkg Println() but for this Outputter
+func (op *Outputter) Println(v ...interface{}) {
+	op.outputters[LevelInfo].outputln(false, 0, ForBoth, v...)
+}
+
+// Infoln is like the pkg Infoln() but for this Outputter
+func (op *Outputter) Infoln(v ...interface{}) {
+	op.outputters[LevelInfo].outputln(false, 0, ForBoth, v...)
+}
+
+// Noteln is like the pkg Noteln() but for this Outputter
+func (op *Outputter) Noteln(v ...interface{}) {
+	op.outputters[LevelNote].outputln(false, 0, ForBoth, v...)
+}
+
+// Warnln is like the pkg Warnln() but for this Outputter
+func (op *Outputter) Warnln(v ...interface{}) {
+	op.outputters[LevelWarn].outputln(false, 0, ForBoth, v...)
+}
+
+// Issueln is like the pkg Issueln() but for this Outputter
+func (op *Outputter) Issueln(v ...interface{}) {
+	op.outputters[LevelIssue].outputln(false, 0, ForBoth, v...)
+}
+
+// IssueExitln is like the pkg IssueExitln() but for this Outputter
+func (op *Outputter) IssueExitln(exitVal int, v ...interface{}) {
+	op.outputters[LevelIssue].outputln(true, exitVal, ForBoth, v...)
+}
+
+// Errorln is like the pkg Errorln() but for this Outputter
+func (op *Outputter) Errorln(v ...interface{}) {
+	op.outputters[LevelError].outputln(false, 0, ForBoth, v...)
+}
+
+// ErrorExitln is like the pkg ErrorExitln() but for this Outputter
+func (op *Outputter) ErrorExitln(exitVal int, v ...interface{}) {
+	op.outputters[LevelError].outputln(true, exitVal, ForBoth, v...)
+}
+
+// Fatalln is like the pkg Fatalln() but for this Outputter, it exits the tool
+func (op *Outputter) Fatalln(v ...interface{}) {
+	op.outputters[LevelFatal].outputln(true, int(atomic.LoadInt32(&errorExitVal)), ForBoth, v...)
+}
+
+// Verbosef is like the pkg Verbosef() but for this Outputter
+func (op *Outputter) Verbosef(format string, v ...interface{}) {
+	op.outputters[LevelVerbose].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Printf is like the pkg Printf() but for this Outputter
+func (op *Outputter) Printf(format string, v ...interface{}) {
+	op.outputters[LevelInfo].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Infof is like the pkg Infof() but for this Outputter
+func (op *Outputter) Infof(format string, v ...interface{}) {
+	op.outputters[LevelInfo].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Notef is like the pkg Notef() but for this Outputter
+func (op *Outputter) Notef(format string, v ...interface{}) {
+	op.outputters[LevelNote].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Warnf is like the pkg Warnf() but for this Outputter
+func (op *Outputter) Warnf(format string, v ...interface{}) {
+	op.outputters[LevelWarn].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Issuef is like the pkg Issuef() but for this Outputter
+func (op *Outputter) Issuef(format string, v ...interface{}) {
+	op.outputters[LevelIssue].outputf(false, 0, ForBoth, format, v...)
+}
+
+// IssueExitf is like the pkg IssueExitf() but for this Outputter
+func (op *Outputter) IssueExitf(exitVal int, format string, v ...interface{}) {
+	op.outputters[LevelIssue].outputf(true, exitVal, ForBoth, format, v...)
+}
+
+// Errorf is like the pkg Errorf() but for this Outputter
+func (op *Outputter) Errorf(format string, v ...interface{}) {
+	op.outputters[LevelError].outputf(false, 0, ForBoth, format, v...)
+}
+
+// ErrorExitf is like the pkg ErrorExitf() but for this Outputter
+func (op *Outputter) ErrorExitf(exitVal int, format string, v ...interface{}) {
+	op.outputters[LevelError].outputf(true, exitVal, ForBoth, format, v...)
+}
+
+// Fatalf is like the pkg Fatalf() but for this Outputter, it exits the tool
+func (op *Outputter) Fatalf(format string, v ...interface{}) {
+	op.outputters[LevelFatal].outputf(true, int(atomic.LoadInt32(&errorExitVal)), ForBoth, format, v...)
+}
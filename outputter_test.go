@@ -0,0 +1,183 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/outputter.go
+//   Checks that an Outputter from NewOutputter() has its own writers,
+//   thresholds and newline tracking apart from the default Outputter used
+//   by the pkg level routines.
+
+package out
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestOutputter(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	libScreenBuf := new(bytes.Buffer)
+	libLogBuf := new(bytes.Buffer)
+	lib := NewOutputter()
+	lib.SetWriter(LevelAll, libScreenBuf, ForScreen)
+	lib.SetWriter(LevelAll, libLogBuf, ForLogfile)
+	lib.SetThreshold(LevelDebug, ForScreen)
+	lib.SetThreshold(LevelInfo, ForLogfile)
+	lib.SetFlags(LevelAll, 0, ForScreen)
+	lib.SetFlags(LevelAll, Lshortfile, ForLogfile)
+	lib.SetPrefix(LevelNote, "Lib note: ")
+
+	assert.Equal(t, LevelDebug, lib.Threshold(ForScreen))
+	assert.Equal(t, LevelInfo, Threshold(ForScreen))
+	assert.Equal(t, "Note: ", Prefix(LevelNote))
+
+	Print("host output with no newline ")
+	lib.Debugln("lib debug")
+	lib.Notef("lib %s\n", "note")
+	Debugln("host debug is still filtered")
+	Println("and the host line ends")
+	fmt.Fprintf(lib.LevelWriter(LevelIssue), "lib issue\n")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "host output with no newline and the host line ends\n", screenBuf.String())
+	libScreen := "Lib note: lib note\nIssue: lib issue\n"
+	if traceEnabled {
+		libScreen = "Debug: lib debug\n" + libScreen
+	}
+	assert.Equal(t, libScreen, libScreenBuf.String())
+	assert.Regexp(t, regexp.MustCompile(`^outputter_test.go:\d+ *: Lib note: lib note\noutputter_test.go:\d+ *: Issue: lib issue\n$`), libLogBuf.String())
+}
+
+func TestChild(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	build := Child("[build] ")
+	test := build.Child("[test] ")
+
+	Println("parent")
+	build.Println("compiling")
+	build.Issueln("no tests")
+	test.Error("multi\nline")
+	test.Println("")
+	newScreenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, newScreenBuf, ForScreen)
+	build.Noteln("the parent's new writer is used")
+	build.SetThreshold(LevelIssue, ForScreen)
+	threshold := Threshold(ForScreen)
+	Println("filtered via the child's threshold change")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "parent\n[build] compiling\n[build] Issue: no tests\n[build] [test] Error: multi\n[build] [test] Error: line\n", screenBuf.String())
+	assert.Equal(t, "[build] Note: the parent's new writer is used\n", newScreenBuf.String())
+	assert.Equal(t, LevelIssue, threshold)
+	assert.Equal(t, "[build] Note: ", build.Prefix(LevelNote))
+}
+
+// metaCapture is a metadataWriter that keeps the file of each write
+type metaCapture struct {
+	files []string
+}
+
+func (m *metaCapture) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (m *metaCapture) WriteMetadata(p []byte, level Level, mdata *FlagMetadata) (int, error) {
+	m.files = append(m.files, mdata.File)
+	return len(p), nil
+}
+
+func TestChildMetadata(t *testing.T) {
+	SetWriter(LevelAll, new(bytes.Buffer), ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	build := Child("[build] ")
+	hndl := build.LevelWriter(LevelInfo).screenHndl
+	plainWants := handleWantsMetadata(hndl)
+	capture := &metaCapture{}
+	SetWriter(LevelAll, capture, ForScreen)
+	captureWants := handleWantsMetadata(hndl)
+	build.Println("with metadata")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.False(t, plainWants, "a plain parent writer doesn't want metadata")
+	assert.True(t, captureWants, "the parent's new writer wants metadata")
+	assert.Equal(t, []string{"outputter_test.go"}, capture.files)
+}
+
+func TestWithFields(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	svc := NewOutputter()
+	svc.SetWriter(LevelAll, screenBuf, ForScreen)
+	svc.SetWriter(LevelAll, logBuf, ForLogfile)
+	svc.SetThreshold(LevelInfo, ForLogfile)
+	svc.SetFlags(LevelAll, 0, ForBoth)
+	req := svc.WithFields(map[string]interface{}{"request_id": 7, "user": "bob smith"})
+	amy := req.WithFields(map[string]interface{}{"user": "amy"})
+
+	req.Noteln("handling")
+	amy.Println("multi\nline")
+	svc.Println("no fields")
+	amy.SetFormatter(LevelIssue, LogfmtFormatter{Target: ForScreen, TimeFormat: "-"})
+	amy.Issueln("formatted")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	// The screen only gets the fields via a formatter, the logfile always
+	assert.Regexp(t, "^Note: handling\nmulti\nline\nno fields\nts=- level=issue .* msg=formatted request_id=7 user=amy\n$", screenBuf.String())
+	assert.Equal(t, "Note: request_id=7 user=\"bob smith\" handling\n"+
+		"request_id=7 user=amy multi\nrequest_id=7 user=amy line\n"+
+		"no fields\nIssue: request_id=7 user=amy formatted\n", logBuf.String())
+}
+
+func TestContext(t *testing.T) {
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	handle := func(ctx context.Context) {
+		FromContext(ctx).Noteln("handling")
+	}
+
+	handle(context.Background())
+	ctx := NewContext(context.Background(), Child("").WithFields(map[string]interface{}{"request_id": 42}))
+	handle(ctx)
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handle(childCtx) // still found in a derived context
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.True(t, FromContext(context.Background()) == std)
+	assert.Equal(t, "Note: handling\nNote: request_id=42 handling\nNote: request_id=42 handling\n", logBuf.String())
+}
@@ -0,0 +1,190 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import "io"
+
+// The routines here are the same as the basic output routines (eg: Noteln())
+// but the message only goes to the given output target(s), ForScreen or
+// ForLogfile (ForBoth works too but is the same as the basic routine).  This
+// is for code that normally writes to both the screen and the logfile but
+// wants one message to go to just one of them without reconfiguring writers,
+// eg: a prompt to the screen only and the gory details to the logfile only:
+//   out.PrintlnTo(out.ForScreen, "Unable to reach the server, retrying...")
+//   out.VerboselnTo(out.ForLogfile, "connect failed:", err, "resp:", resp)
+// The thresholds, flags, formatters and such for the target still apply.
+// There are no Fatal variants, a fatal error should be seen in both places.
+
+// VerboseTo is the same as Verbose() but only sends the verbose output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func VerboseTo(outputTgt int, v ...interface{}) {
+	VERBOSE.output(false, 0, outputTgt, v...)
+}
+
+// VerboselnTo is the same as Verboseln() but only sends the verbose output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func VerboselnTo(outputTgt int, v ...interface{}) {
+	VERBOSE.outputln(false, 0, outputTgt, v...)
+}
+
+// VerbosefTo is the same as Verbosef() but only sends the verbose output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func VerbosefTo(outputTgt int, format string, v ...interface{}) {
+	VERBOSE.outputf(false, 0, outputTgt, format, v...)
+}
+
+// PrintTo is the same as Print() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func PrintTo(outputTgt int, v ...interface{}) {
+	INFO.output(false, 0, outputTgt, v...)
+}
+
+// PrintlnTo is the same as Println() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func PrintlnTo(outputTgt int, v ...interface{}) {
+	INFO.outputln(false, 0, outputTgt, v...)
+}
+
+// PrintfTo is the same as Printf() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func PrintfTo(outputTgt int, format string, v ...interface{}) {
+	INFO.outputf(false, 0, outputTgt, format, v...)
+}
+
+// InfoTo is the same as Info() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func InfoTo(outputTgt int, v ...interface{}) {
+	INFO.output(false, 0, outputTgt, v...)
+}
+
+// InfolnTo is the same as Infoln() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func InfolnTo(outputTgt int, v ...interface{}) {
+	INFO.outputln(false, 0, outputTgt, v...)
+}
+
+// InfofTo is the same as Infof() but only sends the "normal" user output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func InfofTo(outputTgt int, format string, v ...interface{}) {
+	INFO.outputf(false, 0, outputTgt, format, v...)
+}
+
+// NoteTo is the same as Note() but only sends the note output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func NoteTo(outputTgt int, v ...interface{}) {
+	NOTE.output(false, 0, outputTgt, v...)
+}
+
+// NotelnTo is the same as Noteln() but only sends the note output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func NotelnTo(outputTgt int, v ...interface{}) {
+	NOTE.outputln(false, 0, outputTgt, v...)
+}
+
+// NotefTo is the same as Notef() but only sends the note output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func NotefTo(outputTgt int, format string, v ...interface{}) {
+	NOTE.outputf(false, 0, outputTgt, format, v...)
+}
+
+// WarnTo is the same as Warn() but only sends the warning output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func WarnTo(outputTgt int, v ...interface{}) {
+	WARN.output(false, 0, outputTgt, v...)
+}
+
+// WarnlnTo is the same as Warnln() but only sends the warning output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func WarnlnTo(outputTgt int, v ...interface{}) {
+	WARN.outputln(false, 0, outputTgt, v...)
+}
+
+// WarnfTo is the same as Warnf() but only sends the warning output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func WarnfTo(outputTgt int, format string, v ...interface{}) {
+	WARN.outputf(false, 0, outputTgt, format, v...)
+}
+
+// IssueTo is the same as Issue() but only sends the issue (warning) output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func IssueTo(outputTgt int, v ...interface{}) {
+	ISSUE.output(false, 0, outputTgt, v...)
+}
+
+// IssuelnTo is the same as Issueln() but only sends the issue (warning) output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func IssuelnTo(outputTgt int, v ...interface{}) {
+	ISSUE.outputln(false, 0, outputTgt, v...)
+}
+
+// IssuefTo is the same as Issuef() but only sends the issue (warning) output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func IssuefTo(outputTgt int, format string, v ...interface{}) {
+	ISSUE.outputf(false, 0, outputTgt, format, v...)
+}
+
+// ErrorTo is the same as Error() but only sends the error output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func ErrorTo(outputTgt int, v ...interface{}) {
+	ERROR.output(false, 0, outputTgt, v...)
+}
+
+// ErrorlnTo is the same as Errorln() but only sends the error output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func ErrorlnTo(outputTgt int, v ...interface{}) {
+	ERROR.outputln(false, 0, outputTgt, v...)
+}
+
+// ErrorfTo is the same as Errorf() but only sends the error output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func ErrorfTo(outputTgt int, format string, v ...interface{}) {
+	ERROR.outputf(false, 0, outputTgt, format, v...)
+}
+
+// ScreenWriter returns an io.Writer for the given output level that only
+// writes to the screen target, eg: to capture the exact bytes going to the
+// screen via io.MultiWriter:
+//
+//	w := io.MultiWriter(out.ScreenWriter(out.LevelInfo), captureBuf)
+//
+// Each write is output like a write to the level's io.Writer (see Write()),
+// with the screen threshold, flags, prefixes and such applied, the logfile
+// and its newline tracking are left alone.  Writes report the full length of
+// what was given on success (even if the threshold filtered it out) so the
+// writer plays nice with io.MultiWriter.
+func ScreenWriter(level Level) io.Writer {
+	return std.ScreenWriter(level)
+}
+
+// LogfileWriter returns an io.Writer for the given output level that only
+// writes to the logfile target, the logfile version of ScreenWriter()
+func LogfileWriter(level Level) io.Writer {
+	return std.LogfileWriter(level)
+}
+
+// targetWriter is an io.Writer for an output level that only writes to one
+// output target, see ScreenWriter() and LogfileWriter()
+type targetWriter struct {
+	o         *LvlOutput // the output level written to
+	outputTgt int        // ForScreen or ForLogfile
+}
+
+// Write outputs the data to the writer's level and target only
+func (w *targetWriter) Write(p []byte) (int, error) {
+	terminate, exitVal := w.o.writerExit()
+	if _, err := w.o.stringOutput(string(p), terminate, exitVal, w.outputTgt, "", nil, 0); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
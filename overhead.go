@@ -0,0 +1,125 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// OverheadReport is the result of MeasureOverhead(), the cost of a single
+// output call at the given level with the configuration at that time
+type OverheadReport struct {
+	Level         Level // the level measured
+	Iterations    int   // how many output calls were timed
+	NsPerOp       int64 // average time per output call in nanoseconds
+	AllocsPerOp   int64 // average heap allocations per output call
+	BytesPerOp    int64 // average heap bytes allocated per output call
+	ScreenActive  bool  // true if the level passed the screen threshold
+	LogfileActive bool  // true if the level passed the logfile threshold
+}
+
+// String gives a one line summary of the report, eg:
+//   DEBUG: 2113 ns/op, 21 allocs/op, 1201 B/op (screen: on, logfile: off)
+func (r OverheadReport) String() string {
+	onOff := func(active bool) string {
+		if active {
+			return "on"
+		}
+		return "off"
+	}
+	return fmt.Sprintf("%s: %d ns/op, %d allocs/op, %d B/op (screen: %s, logfile: %s)",
+		r.Level, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp, onOff(r.ScreenActive), onOff(r.LogfileActive))
+}
+
+// MeasureOverhead times a representative output call (a short message with
+// an arg, like out.Debugln("processed item", i)) at the given level for the
+// given number of iterations using the current configuration (thresholds,
+// flags, formatters, stack trace settings, env settings, etc) but with the
+// writers for that level swapped to ioutil.Discard, so it's the cost of the
+// formatting and prefixing work that is measured.  This makes it easy to see
+// what turning on, say, Llongfunc with a JSON formatter would cost before
+// doing it in production, eg:
+//   out.SetFlags(out.LevelAll, out.Llongfile|out.Llongfunc, out.ForLogfile)
+//   fmt.Println(out.MeasureOverhead(out.LevelInfo, 10000))
+// Note that if the level doesn't pass the screen or logfile thresholds then
+// the (small) cost of filtering it out is what is measured.  This is meant
+// to be run at a quiet time (eg: at startup or in a test) as any output at
+// the same level from other goroutines during the run is discarded, and
+// not while strict mode is holding output (see SetStrictMode()).  Fatal is
+// measured without exiting and any exit code accumulated or LastFatal()
+// info recorded by the timed calls is put back the way it was.
+func MeasureOverhead(level Level, iterations int) OverheadReport {
+	if iterations < 1 {
+		iterations = 1
+	}
+	o := LevelWriter(level)
+	report := OverheadReport{Level: o.level, Iterations: iterations}
+	mutex.RLock()
+	screenThresh, screenThreshFunc := std.screenThresh(), std.screenThresholdFunc
+	logThresh, logThreshFunc := std.logThresh(), std.logThresholdFunc
+	scrNewline := std.screenNewline
+	logNewline := std.logfileNewline
+	mutex.RUnlock()
+	report.ScreenActive = passesThreshold(o.level, screenThresh, screenThreshFunc)
+	report.LogfileActive = passesThreshold(o.level, logThresh, logThreshFunc)
+
+	// swap in the discard writers, saving state the timed calls will change
+	o.mu.Lock()
+	screenHndl := o.screenHndl
+	logfileHndl := o.logfileHndl
+	o.screenHndl = ioutil.Discard
+	o.logfileHndl = ioutil.Discard
+	o.mu.Unlock()
+	pendingCode := atomic.LoadInt32(&pendingExitCode)
+	var gid uint64
+	var lastFatal interface{}
+	var hadLastFatal bool
+	capturing := CaptureLastFatal()
+	if capturing {
+		gid = goroutineID()
+		lastFatal, hadLastFatal = lastFatals.Load(gid)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		o.outputln(false, 0, ForBoth, "measuring output overhead, iteration", i)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	o.mu.Lock()
+	o.screenHndl = screenHndl
+	o.logfileHndl = logfileHndl
+	o.mu.Unlock()
+	ResetNewline(scrNewline, ForScreen)
+	ResetNewline(logNewline, ForLogfile)
+	atomic.StoreInt32(&pendingExitCode, pendingCode)
+	if hadLastFatal {
+		lastFatals.Store(gid, lastFatal)
+	} else if capturing {
+		lastFatals.Delete(gid)
+	}
+
+	report.NsPerOp = elapsed.Nanoseconds() / int64(iterations)
+	report.AllocsPerOp = int64(after.Mallocs-before.Mallocs) / int64(iterations)
+	report.BytesPerOp = int64(after.TotalAlloc-before.TotalAlloc) / int64(iterations)
+	return report
+}
@@ -0,0 +1,172 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// pkgThreshold holds the thresholds set for a package via
+// SetPackageThreshold(), noPkgThreshold means it's not set for that target
+type pkgThreshold struct {
+	screen Level
+	log    Level
+}
+
+// noPkgThreshold marks a pkgThreshold target that hasn't been set
+const noPkgThreshold Level = -1
+
+var (
+	// pkgThresholds holds the map[string]pkgThreshold of package thresholds
+	// keyed by package path, replaced (not changed) when a threshold is set
+	pkgThresholds atomic.Value
+
+	// pkgQuietBelow is the lowest package threshold set (atomic), output
+	// below it and the Outputter thresholds goes nowhere
+	pkgQuietBelow int32 = int32(LevelDiscard)
+)
+
+// SetPackageThreshold sets the screen and/or logfile output threshold used
+// for output from the given package (and its sub-packages, the most specific
+// package path set wins) in place of the usual threshold, eg: to see debug
+// output from one package while everything else shows info and up:
+//
+//	out.SetThreshold(out.LevelInfo, out.ForScreen)
+//	out.SetPackageThreshold("github.com/me/tool/foo", out.LevelDebug, out.ForScreen)
+//
+// The package is found from the callers func name (like PKG_OUT_DEBUG_SCOPE
+// does, but matching the package path instead of any substring of the func
+// name) and this only applies to output via the default Outputter.  Note that
+// once any package threshold is set the caller has to be looked up (a
+// runtime.Caller() call) for all output that may pass a package threshold,
+// even if no file or func flags are in use, see ClearPackageThresholds().
+func SetPackageThreshold(pkgPath string, level Level, outputTgt int) {
+	level = levelCheck(level)
+	mutex.Lock()
+	defer mutex.Unlock()
+	old, _ := pkgThresholds.Load().(map[string]pkgThreshold)
+	thresholds := make(map[string]pkgThreshold, len(old)+1)
+	for path, threshold := range old {
+		thresholds[path] = threshold
+	}
+	threshold, ok := thresholds[pkgPath]
+	if !ok {
+		threshold = pkgThreshold{screen: noPkgThreshold, log: noPkgThreshold}
+	}
+	if outputTgt&ForScreen != 0 {
+		threshold.screen = level
+	}
+	if outputTgt&ForLogfile != 0 {
+		threshold.log = level
+	}
+	thresholds[pkgPath] = threshold
+	storePkgThresholds(thresholds)
+}
+
+// PackageThreshold returns the screen or logfile threshold set for the given
+// package path via SetPackageThreshold() and true, or false if none is set
+// (only the exact package path is checked, not any parent packages)
+func PackageThreshold(pkgPath string, outputTgt int) (Level, bool) {
+	thresholds, _ := pkgThresholds.Load().(map[string]pkgThreshold)
+	threshold, ok := thresholds[pkgPath]
+	if !ok {
+		return noPkgThreshold, false
+	}
+	level := threshold.log
+	if outputTgt&ForScreen != 0 {
+		level = threshold.screen
+	}
+	return level, level != noPkgThreshold
+}
+
+// ClearPackageThresholds removes all package thresholds, after this output
+// only uses the usual screen and logfile thresholds again
+func ClearPackageThresholds() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	storePkgThresholds(nil)
+}
+
+// storePkgThresholds stores the given package thresholds and updates the
+// lowest package threshold (the pkg mutex must be held by the caller)
+func storePkgThresholds(thresholds map[string]pkgThreshold) {
+	quietBelow := LevelDiscard
+	for _, threshold := range thresholds {
+		for _, level := range []Level{threshold.screen, threshold.log} {
+			if level != noPkgThreshold && level < quietBelow {
+				quietBelow = level
+			}
+		}
+	}
+	pkgThresholds.Store(thresholds)
+	atomic.StoreInt32(&pkgQuietBelow, int32(quietBelow))
+}
+
+// passesPackageThreshold returns true if output at the given level might get
+// past a package threshold (ie: if the callers package is the right one)
+func passesPackageThreshold(level Level) bool {
+	return level != LevelDiscard && int32(level) >= atomic.LoadInt32(&pkgQuietBelow)
+}
+
+// packageThresholds returns the screen and logfile thresholds to use for the
+// message, the ones given unless the callers package (or a parent package)
+// has a package threshold set, the depth is relative to the caller of this
+// routine (eg: the caller lookup is done only if package thresholds are set)
+func packageThresholds(mmeta *msgMetadata, depth int, screen, log Level) (Level, Level) {
+	thresholds, _ := pkgThresholds.Load().(map[string]pkgThreshold)
+	if len(thresholds) == 0 {
+		return screen, log
+	}
+	_, _, funcName, ok := mmeta.caller(depth + 1)
+	if !ok || funcName == "" {
+		return screen, log
+	}
+	screenSet, logSet := false, false
+	for pkgPath := funcPackage(funcName); pkgPath != "" && (!screenSet || !logSet); pkgPath = parentPackage(pkgPath) {
+		threshold, ok := thresholds[pkgPath]
+		if !ok {
+			continue
+		}
+		if !screenSet && threshold.screen != noPkgThreshold {
+			screen, screenSet = threshold.screen, true
+		}
+		if !logSet && threshold.log != noPkgThreshold {
+			log, logSet = threshold.log, true
+		}
+	}
+	return screen, log
+}
+
+// funcPackage returns the package path from a func name as given by
+// runtime.FuncForPC(), eg: "github.com/dvln/out" from the func name
+// "github.com/dvln/out.(*LvlOutput).Write" (dots in the last element of the
+// path are escaped in func names, eg: "gopkg.in/yaml%2ev2.Unmarshal")
+func funcPackage(funcName string) string {
+	slash := strings.LastIndex(funcName, "/")
+	pkgPath := funcName
+	if dot := strings.Index(funcName[slash+1:], "."); dot >= 0 {
+		pkgPath = funcName[:slash+1+dot]
+	}
+	return strings.Replace(pkgPath, "%2e", ".", -1)
+}
+
+// parentPackage returns the parent of the given package path, eg: "a/b" for
+// "a/b/c", or "" if there is none
+func parentPackage(pkgPath string) string {
+	if slash := strings.LastIndex(pkgPath, "/"); slash >= 0 {
+		return pkgPath[:slash]
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/pkgthreshold.go
+//   Checks package thresholds override the screen/logfile thresholds for
+//   output from the matching package (and sub-packages) only.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetPackageThreshold(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForBoth)
+	SetThreshold(LevelInfo, ForScreen)
+	SetThreshold(LevelNote, ForLogfile)
+
+	SetPackageThreshold("github.com/dvln/other", LevelTrace, ForScreen)
+	Debugln("other pkg threshold")
+	SetPackageThreshold("github.com/dvln/out", LevelDebug, ForScreen)
+	Debugln("pkg threshold")
+	Traceln("below pkg threshold")
+	level, ok := PackageThreshold("github.com/dvln/out", ForScreen)
+	_, logOK := PackageThreshold("github.com/dvln/out", ForLogfile)
+	SetPackageThreshold("github.com/dvln", LevelTrace, ForBoth)
+	Traceln("parent pkg logfile threshold")
+	SetPackageThreshold("github.com/dvln/out", LevelIssue, ForScreen)
+	Println("above global threshold, below pkg threshold")
+	screenOutput := screenBuf.String()
+	logOutput := logBuf.String()
+	ClearPackageThresholds()
+	Debugln("cleared")
+	_, clearedOK := PackageThreshold("github.com/dvln/out", ForScreen)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.True(t, ok)
+	assert.Equal(t, LevelDebug, level)
+	assert.False(t, logOK)
+	assert.False(t, clearedOK)
+	assert.Equal(t, "Debug: pkg threshold\n", screenOutput)
+	assert.Equal(t, "Trace: parent pkg logfile threshold\nabove global threshold, below pkg threshold\n", logOutput)
+	assert.Equal(t, screenOutput, screenBuf.String())
+}
+
+func TestFuncPackage(t *testing.T) {
+	assert.Equal(t, "github.com/dvln/out", funcPackage("github.com/dvln/out.(*LvlOutput).Write"))
+	assert.Equal(t, "github.com/dvln/out", funcPackage("github.com/dvln/out.TestFuncPackage.func1"))
+	assert.Equal(t, "main", funcPackage("main.main"))
+	assert.Equal(t, "gopkg.in/yaml.v2", funcPackage("gopkg.in/yaml%2ev2.Unmarshal"))
+	assert.Equal(t, "github.com/dvln", parentPackage("github.com/dvln/out"))
+	assert.Equal(t, "", parentPackage("main"))
+}
@@ -0,0 +1,132 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"strings"
+)
+
+// progressBarWidth is the number of chars inside the [ ] of a progress bar
+const progressBarWidth = 30
+
+var (
+	// progressActive is true while an in-place (terminal) progress line is
+	// on the screen, protected by the pkg mutex
+	progressActive bool
+
+	// progressReported tracks the last 10% step (or the last count if the
+	// total is unknown) reported for each label when the screen is not a
+	// terminal, protected by the pkg mutex
+	progressReported = make(map[string]int64)
+)
+
+// progressTerminal reports if the screen writer is a terminal that can have
+// the progress line rewritten in place, a var so tests can fake a terminal
+var progressTerminal = isTerminal
+
+// Progress reports how far along a long running operation is.  If the
+// screen output stream (INFO level handle) is a terminal a single line is
+// rewritten in place (via '\r') with the label, a bar and the percentage,
+// once current reaches total the line is finished off with a newline so the
+// next output starts on a fresh line.  If the screen isn't a terminal (eg:
+// piped to a file) then a Note level line is output every 10% instead, eg:
+//
+//	Note: download: 30% (300/1000)
+//
+// If the total isn't known (0 or less) only the count is shown, eg:
+// "download: 300 so far", and as there's no end the in-place line isn't
+// finished off (use EnsureNewline(ForScreen) once done), the Note lines
+// are output each time the count has at least doubled.
+//
+// The in-place line goes only to the screen (and only if Info level output
+// is shown there), the Note lines go to the screen and/or logfile per the
+// usual thresholds.  If other output is mixed in while an in-place progress
+// line is active call EnsureNewline(ForScreen) first.
+func Progress(label string, current, total int64) {
+	known := total > 0
+	pct := 0
+	if known {
+		pct = int(current * 100 / total)
+	}
+	if pct < 0 {
+		pct = 0
+	} else if pct > 100 {
+		pct = 100
+	}
+	done := known && current >= total
+
+	INFO.mu.RLock()
+	hndl := INFO.screenHndl
+	INFO.mu.RUnlock()
+	if !progressTerminal(hndl) {
+		mutex.Lock()
+		last, ok := progressReported[label]
+		var report bool
+		if known {
+			step := int64(pct / 10)
+			report = !ok || step > last || done
+			last = step
+		} else {
+			report = !ok || (current > last && current >= 2*last)
+			if report {
+				last = current
+			}
+		}
+		if done {
+			delete(progressReported, label)
+		} else {
+			progressReported[label] = last
+		}
+		mutex.Unlock()
+		if report && known {
+			Notef("%s: %d%% (%d/%d)\n", label, pct, current, total)
+		} else if report {
+			Notef("%s: %d so far\n", label, current)
+		}
+		return
+	}
+
+	mutex.Lock()
+	if std.screenThresh() > LevelInfo {
+		mutex.Unlock()
+		return
+	}
+	line := fmt.Sprintf("\r%s: %d so far", label, current)
+	if known {
+		line = progressBar(label, pct)
+	}
+	if !progressActive && !std.screenNewline {
+		line = "\n" + line
+	}
+	progressActive = !done
+	hndl.Write([]byte(line))
+	std.screenNewline = false
+	mutex.Unlock()
+	if done {
+		EnsureNewline(ForScreen)
+	}
+}
+
+// progressBar renders an in-place progress line, eg:
+//
+//	"\rbuild [=========>                    ]  33%"
+func progressBar(label string, pct int) string {
+	filled := pct * progressBarWidth / 100
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("\r%s [%s] %3d%%", label, bar, pct)
+}
@@ -0,0 +1,98 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/progress.go
+//   Checks the progress bar rendering, the in-place terminal line, the
+//   non-terminal fallback of periodic Note level lines and unknown totals.
+
+package out
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestProgressBar(t *testing.T) {
+	assert.Equal(t, "\rx [>                             ]   0%", progressBar("x", 0))
+	assert.Equal(t, "\rx [===============>              ]  50%", progressBar("x", 50))
+	assert.Equal(t, "\rx [==============================] 100%", progressBar("x", 100))
+}
+
+func TestProgressNonTerminal(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	for i := int64(0); i <= 200; i += 7 {
+		Progress("copy", i, 200)
+	}
+	Progress("copy", 200, 200)
+	Println("after")
+
+	ResetOutPkg()
+
+	lines := strings.Split(strings.TrimSpace(screenBuf.String()), "\n")
+	assert.Equal(t, "Note: copy: 0% (0/200)", lines[0])
+	assert.Equal(t, "Note: copy: 10% (21/200)", lines[1])
+	assert.Equal(t, "Note: copy: 91% (182/200)", lines[len(lines)-3])
+	assert.Equal(t, "Note: copy: 100% (200/200)", lines[len(lines)-2])
+	assert.Equal(t, "after", lines[len(lines)-1])
+	assert.Equal(t, 12, len(lines))
+}
+
+func TestProgressTerminal(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	origTerminal := progressTerminal
+	progressTerminal = func(w io.Writer) bool { return w == screenBuf }
+	defer func() { progressTerminal = origTerminal }()
+	Progress("x", 0, 4)
+	Progress("x", 2, 4)
+	Progress("x", 4, 4)
+	Println("after")
+	known := screenBuf.String()
+	screenBuf.Reset()
+	Progress("y", 5, -1)
+	Progress("y", 9, 0)
+	EnsureNewline(ForScreen)
+	unknown := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, progressBar("x", 0)+progressBar("x", 50)+progressBar("x", 100)+"\nafter\n", known)
+	assert.Equal(t, "\ry: 5 so far\ry: 9 so far\n", unknown)
+}
+
+func TestProgressUnknownTotal(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	for i := int64(1); i <= 10; i++ {
+		Progress("scan", i, 0)
+	}
+	Progress("scan", 5, -1)
+	_, pending := progressReported["scan"]
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: scan: 1 so far\nNote: scan: 2 so far\nNote: scan: 4 so far\nNote: scan: 8 so far\n", screenBuf.String())
+	assert.True(t, pending, "an unknown total is never done")
+	assert.Equal(t, 0, len(progressReported))
+}
@@ -0,0 +1,135 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitNow is the clock used for rate limiting, time.Now() except when
+// testing
+var rateLimitNow = time.Now
+
+// rateBucket is the token bucket for one level, see SetRateLimit()
+type rateBucket struct {
+	mu         sync.Mutex
+	max        float64       // bucket size, ie: messages per interval
+	interval   time.Duration // time to refill an empty bucket
+	tokens     float64       // messages that can be written right now
+	last       time.Time     // when tokens was last brought up to date
+	suppressed int           // messages dropped since the last one written
+}
+
+// rateLimits holds a map[Level]*rateBucket of the levels that are limited,
+// the map is replaced (not modified) when SetRateLimit() is called
+var rateLimits atomic.Value
+
+// rateLimitMu serializes SetRateLimit() calls updating rateLimits
+var rateLimitMu sync.Mutex
+
+// SetRateLimit limits output at the given level to maxPerInterval messages
+// per interval (eg: out.SetRateLimit(out.LevelError, 10, time.Second)) so a
+// tight failure loop can't flood the screen and logfile.  It is a token
+// bucket, a burst of up to maxPerInterval messages is fine and the bucket
+// refills evenly over the interval, once it's empty messages are dropped
+// and counted.  The next message written after that starts with a note of
+// how many were dropped, eg: "Error: (37 messages suppressed) db timeout".
+// Each level has its own limit (shared by any Outputters), LevelAll sets the
+// same limit for every level (each still with its own bucket).  A
+// maxPerInterval of 0 or less (or an interval of 0 or less) removes the
+// limit, levels are unlimited by default.  Dying (Fatal) output is never
+// dropped.
+func SetRateLimit(level Level, maxPerInterval int, interval time.Duration) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	levels := []Level{level}
+	if level == LevelAll {
+		levels = nil
+		for _, o := range outputters {
+			if o.level != LevelDiscard {
+				levels = append(levels, o.level)
+			}
+		}
+	}
+	old, _ := rateLimits.Load().(map[Level]*rateBucket)
+	limits := make(map[Level]*rateBucket, len(old)+len(levels))
+	for l, b := range old {
+		limits[l] = b
+	}
+	for _, l := range levels {
+		if maxPerInterval <= 0 || interval <= 0 {
+			delete(limits, l)
+			continue
+		}
+		limits[l] = &rateBucket{max: float64(maxPerInterval), interval: interval, tokens: float64(maxPerInterval), last: rateLimitNow()}
+	}
+	rateLimits.Store(limits)
+}
+
+// RateLimit returns the maxPerInterval and interval set for the given level
+// via SetRateLimit(), 0 and 0 if the level isn't limited
+func RateLimit(level Level) (int, time.Duration) {
+	limits, _ := rateLimits.Load().(map[Level]*rateBucket)
+	b := limits[level]
+	if b == nil {
+		return 0, 0
+	}
+	return int(b.max), b.interval
+}
+
+// take uses up a token if there is one, returning true and the number of
+// messages dropped since the last one written, otherwise the message is
+// counted as dropped and false is returned
+func (b *rateBucket) take() (bool, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := rateLimitNow()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += b.max * float64(elapsed) / float64(b.interval)
+		if b.tokens > b.max {
+			b.tokens = b.max
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.suppressed++
+		return false, 0
+	}
+	b.tokens--
+	suppressed := b.suppressed
+	b.suppressed = 0
+	return true, suppressed
+}
+
+// rateLimited returns true if the message at the given level should be
+// dropped due to SetRateLimit(), else the message to write (which notes
+// how many messages were dropped before it, if any)
+func rateLimited(level Level, s string) (bool, string) {
+	limits, _ := rateLimits.Load().(map[Level]*rateBucket)
+	b := limits[level]
+	if b == nil {
+		return false, s
+	}
+	ok, suppressed := b.take()
+	if !ok {
+		return true, s
+	}
+	if suppressed > 0 {
+		s = fmt.Sprintf("(%d messages suppressed) ", suppressed) + s
+	}
+	return false, s
+}
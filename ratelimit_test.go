@@ -0,0 +1,85 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/ratelimit.go
+//   Checks SetRateLimit() drops output over the rate per level and notes
+//   the number dropped, using a fake clock so the timing is deterministic.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	origNow := rateLimitNow
+	rateLimitNow = func() time.Time { return now }
+	defer func() { rateLimitNow = origNow }()
+
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+	SetRateLimit(LevelError, 2, time.Second)
+	maxPer, interval := RateLimit(LevelError)
+	noteMax, _ := RateLimit(LevelNote)
+
+	// A burst of two gets through, the rest is dropped (other levels and
+	// dying output aren't affected)
+	for i := 0; i < 5; i++ {
+		Errorln("db timeout")
+		Noteln("note")
+	}
+	burst := screenBuf.String()
+	screenBuf.Reset()
+
+	// Half the interval refills one token, the next message notes the drops
+	now = now.Add(500 * time.Millisecond)
+	Errorln("db timeout")
+	Errorln("db timeout")
+	refill := screenBuf.String()
+	screenBuf.Reset()
+
+	// A long wait only refills up to the bucket size
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		Errorln("disk full")
+	}
+	full := screenBuf.String()
+	screenBuf.Reset()
+
+	// Removing the limit lets everything through again
+	SetRateLimit(LevelError, 0, 0)
+	for i := 0; i < 3; i++ {
+		Errorln("unlimited")
+	}
+	unlimited := screenBuf.String()
+	clearedMax, _ := RateLimit(LevelError)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 2, maxPer)
+	assert.Equal(t, time.Second, interval)
+	assert.Equal(t, 0, noteMax)
+	assert.Equal(t, 0, clearedMax)
+	assert.Equal(t, "Error: db timeout\nNote: note\nError: db timeout\nNote: note\nNote: note\nNote: note\nNote: note\n", burst)
+	assert.Equal(t, "Error: (3 messages suppressed) db timeout\n", refill)
+	assert.Equal(t, "Error: (1 messages suppressed) disk full\nError: disk full\n", full)
+	assert.Equal(t, "Error: unlimited\nError: unlimited\nError: unlimited\n", unlimited)
+}
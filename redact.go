@@ -0,0 +1,108 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// redactedText replaces anything matching a redaction, see AddRedaction()
+const redactedText = "***"
+
+// redaction is one AddRedaction() pattern, a literal substring unless it had
+// a "re:" prefix
+type redaction struct {
+	literal string
+	re      *regexp.Regexp
+}
+
+// redactions holds the []redaction in use, the slice is replaced (never
+// modified) when a redaction is added or cleared so output can read it
+// without locking
+var redactions atomic.Value
+
+// redactionMu serializes AddRedaction() and ClearRedactions() updates
+var redactionMu sync.Mutex
+
+// AddRedaction scrubs the given pattern from all output, each match is
+// replaced with "***" in the message (before any formatter sees it), in the
+// formatter's output and in stack traces, for both the screen and logfile,
+// eg: to keep an API token out of the logs should it ever get printed:
+//
+//	out.AddRedaction(apiToken)
+//	out.AddRedaction(`re:(?i)password=\S+`)
+//
+// The pattern is a literal substring unless it starts with "re:", then the
+// rest is a regexp, an invalid regexp panics (as regexp.MustCompile() does)
+// so a typo can't quietly leave secrets unscrubbed, empty patterns are
+// ignored.  Structured fields (eg: from WithFields()) aren't scrubbed.
+func AddRedaction(pattern string) {
+	var r redaction
+	if strings.HasPrefix(pattern, "re:") {
+		if pattern == "re:" {
+			return
+		}
+		re, err := regexp.Compile(pattern[3:])
+		if err != nil {
+			panic(fmt.Sprintf("out: AddRedaction(%q): %v", pattern, err))
+		}
+		r.re = re
+	} else if pattern == "" {
+		return
+	} else {
+		r.literal = pattern
+	}
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+	old, _ := redactions.Load().([]redaction)
+	list := make([]redaction, len(old), len(old)+1)
+	copy(list, old)
+	redactions.Store(append(list, r))
+}
+
+// ClearRedactions removes all redactions added via AddRedaction()
+func ClearRedactions() {
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+	redactions.Store([]redaction(nil))
+}
+
+// redact returns the string with every redaction match replaced by "***"
+func redact(s string) string {
+	list, _ := redactions.Load().([]redaction)
+	if len(list) == 0 || s == "" {
+		return s
+	}
+	for _, r := range list {
+		if r.re != nil {
+			s = r.re.ReplaceAllLiteralString(s, redactedText)
+		} else {
+			s = strings.Replace(s, r.literal, redactedText, -1)
+		}
+	}
+	return s
+}
+
+// redactFrames scrubs the func and file names of the stack frames given (in
+// place), see AddRedaction()
+func redactFrames(frames []StackFrame) {
+	for i := range frames {
+		frames[i].Func = redact(frames[i].Func)
+		frames[i].File = redact(frames[i].File)
+	}
+}
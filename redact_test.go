@@ -0,0 +1,75 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/redact.go
+//   Checks AddRedaction() literal and regexp patterns are scrubbed from the
+//   message, formatter output and stack traces for both targets and that an
+//   invalid regexp panics.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestRedaction(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+
+	AddRedaction("s3cr3t")
+	AddRedaction(`re:password=\S+`)
+	assert.Panics(t, func() { AddRedaction("re:(invalid") }, "a bad regexp must not be ignored")
+	AddRedaction("")
+	Noteln("token s3cr3t used, password=hunter2 sent")
+	message := screenBuf.String()
+	logfile := logBuf.String()
+	screenBuf.Reset()
+
+	// Formatter output is scrubbed too
+	AddRedaction("joy")
+	SetFormatter(LevelNote, replaceMsg{})
+	Noteln("anything")
+	ClearFormatter(LevelAll)
+	formatted := screenBuf.String()
+	screenBuf.Reset()
+
+	// As are stack traces
+	AddRedaction("re:TestRedaction")
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	Issueln("disk full")
+	stack := screenBuf.String()
+	screenBuf.Reset()
+
+	ClearRedactions()
+	Noteln("s3cr3t")
+	cleared := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: token *** used, *** sent\n", message)
+	assert.Equal(t, message, logfile)
+	assert.Equal(t, "Replacement message, *** *** ***", formatted)
+	assert.Contains(t, stack, "Stack Trace")
+	assert.NotContains(t, stack, "TestRedaction")
+	assert.Contains(t, stack, "***")
+	assert.Equal(t, "Note: s3cr3t\n", cleared)
+}
@@ -0,0 +1,155 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultNilRendering is how fmt renders nil values (and our default)
+const defaultNilRendering = "<nil>"
+
+var (
+	// nilRendering holds the string nil args are rendered as in the Print
+	// family of output routines, see SetNilRendering()
+	nilRendering atomic.Value
+
+	// expandErrors, if non-zero, means a lone error arg to the Print family
+	// of output routines is expanded with its code and origin
+	expandErrors int32
+)
+
+// NilRendering returns how nil args are shown by the Print family of output
+// routines (eg: Print(), Noteln(), Issue()), see SetNilRendering()
+func NilRendering() string {
+	if s, ok := nilRendering.Load().(string); ok {
+		return s
+	}
+	return defaultNilRendering
+}
+
+// SetNilRendering sets how nil args (nil interfaces and nil pointers) are
+// shown by the Print family of output routines (eg: Print(), Infoln() and
+// Issue(), the "f" routines like Printf() aren't effected as the format
+// controls the rendering there).  The default is fmt's "<nil>", one could
+// use "" to have nothing shown or something like "(none)", eg:
+//   out.SetNilRendering("(none)")
+//   out.Println("Last error:", err)   // "Last error: (none)" if err is nil
+// Setting it back to "<nil>" restores the default behavior.
+func SetNilRendering(s string) {
+	nilRendering.Store(s)
+}
+
+// ExpandErrors returns true if a lone error arg to the Print family of output
+// routines is expanded with its code and origin, see SetExpandErrors()
+func ExpandErrors() bool {
+	return atomic.LoadInt32(&expandErrors) != 0
+}
+
+// SetExpandErrors controls what happens when an error is the only arg given
+// to one of the Print family of output routines (eg: out.Issueln(err)), by
+// default the errors Error() string is shown (as fmt does).  If set to true
+// then, for a DetailedError (see deterr.go), the error code and a short
+// summary of where the error came from are added inline, eg:
+//   Error #209: unable to read config file [code: 209, at main.loadCfg (cfg.go:42)]
+// For other errors the code is the default error code and no origin is
+// available.  The "f" routines like Errorf() aren't effected.
+func SetExpandErrors(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&expandErrors, val)
+}
+
+// renderArgs adjusts the args for the Print family of output routines based
+// upon the nil rendering and expand errors settings, the args are returned
+// as-is (no copy made) if nothing needs changing
+func renderArgs(v []interface{}) []interface{} {
+	if len(v) == 1 && ExpandErrors() {
+		if err, ok := v[0].(error); ok && !isNil(err) {
+			return []interface{}{expandError(err)}
+		}
+	}
+	nilStr := NilRendering()
+	if nilStr == defaultNilRendering {
+		return v
+	}
+	var rendered []interface{}
+	for i, arg := range v {
+		if isNil(arg) {
+			if rendered == nil {
+				rendered = make([]interface{}, len(v))
+				copy(rendered, v)
+			}
+			rendered[i] = nilStr
+		}
+	}
+	if rendered == nil {
+		return v
+	}
+	return rendered
+}
+
+// isNil returns true for a nil interface or a nil pointer in an interface
+func isNil(arg interface{}) bool {
+	if arg == nil {
+		return true
+	}
+	val := reflect.ValueOf(arg)
+	return val.Kind() == reflect.Ptr && val.IsNil()
+}
+
+// expandError returns the error string with the errors code and, if it is a
+// DetailedError with a stack, the func, file and line# where it was created
+func expandError(err error) string {
+	details := fmt.Sprintf("code: %d", Code(err))
+	if detErr, ok := err.(DetailedError); ok {
+		var errLines []string
+		var origStack string
+		fillErrorInfo(detErr, true, &errLines, &origStack)
+		if origin := stackOrigin(origStack); origin != "" {
+			details += ", at " + origin
+		}
+	}
+	return fmt.Sprintf("%s [%s]", err.Error(), details)
+}
+
+// stackOrigin returns "<func> (<file>:<line#>)" for the first frame in the
+// given stack trace (as produced by stackTrace()), "" if it can't be found
+func stackOrigin(stack string) string {
+	lines := strings.Split(stack, "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i+1], "\t") || strings.HasPrefix(lines[i], "\t") {
+			continue
+		}
+		funcName := lines[i]
+		if paren := strings.LastIndex(funcName, "("); paren > 0 {
+			funcName = funcName[:paren]
+		}
+		if slash := strings.LastIndex(funcName, "/"); slash != -1 {
+			funcName = funcName[slash+1:]
+		}
+		fileLine := strings.TrimSpace(lines[i+1])
+		if space := strings.Index(fileLine, " "); space != -1 {
+			fileLine = fileLine[:space]
+		}
+		return fmt.Sprintf("%s (%s)", funcName, filepath.Base(fileLine))
+	}
+	return ""
+}
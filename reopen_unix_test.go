@@ -0,0 +1,67 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+// Package test for: out/out.go
+//   Checks HandleReopenSignal() reopens the log file when the signal comes
+//   in (eg: after logrotate moved it), unix only as it sends itself SIGHUP.
+
+package out
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestHandleReopenSignal(t *testing.T) {
+	currFileName := LogFileName()
+	dir, err := ioutil.TempDir("", "reopensig")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetLogFile(logPath)
+	Println("before logrotate")
+	os.Rename(logPath, logPath+".1")
+	origHndl := Writer(LevelInfo, ForLogfile)
+	HandleReopenSignal(syscall.SIGHUP)
+	proc, _ := os.FindProcess(os.Getpid())
+	proc.Signal(syscall.SIGHUP)
+	for i := 0; i < 200 && Writer(LevelInfo, ForLogfile) == origHndl; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	Println("after logrotate")
+	rotatedContents, _ := ioutil.ReadFile(logPath + ".1")
+	contents, _ := ioutil.ReadFile(logPath)
+	CloseLogFile()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+	std.useLogFile(currFileName, ioutil.Discard)
+
+	assert.Equal(t, "before logrotate\n", string(rotatedContents))
+	assert.Equal(t, "after logrotate\n", string(contents))
+}
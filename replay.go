@@ -0,0 +1,177 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// replayEntry is a message kept in the replay ring buffer, seq numbers start
+// at 1 and go up by one for each message kept
+type replayEntry struct {
+	seq  uint64
+	held heldOutput
+}
+
+var (
+	// replayMu protects the replay ring buffer settings and entries below
+	replayMu sync.Mutex
+
+	// replayBufSize is the max number of entries in the ring buffer, 0 means
+	// the buffer is off (the default), see SetReplayBuffer()
+	replayBufSize int32
+
+	// replayEntries is the ring buffer, replayNext is where the next entry
+	// goes once it is full (ie: the oldest entry)
+	replayEntries []*replayEntry
+	replayNext    int
+
+	// replaySeq is the seq# of the last entry kept, replayedSeq the seq# of
+	// the last entry sent out by ReplayTo()
+	replaySeq   uint64
+	replayedSeq uint64
+
+	// activeReplays counts the ReplayTo() calls under way, if non-zero the
+	// strictReplays map is checked for the original metadata to use
+	activeReplays int32
+)
+
+// ReplayBufferSize returns how many messages the replay ring buffer keeps,
+// 0 if it is off, see SetReplayBuffer()
+func ReplayBufferSize() int {
+	return int(atomic.LoadInt32(&replayBufSize))
+}
+
+// SetReplayBuffer turns on a ring buffer that keeps the last n messages sent
+// to any output level (with their original time and file/line# info) so they
+// can be sent to a target configured later via ReplayTo().  This is for tools
+// where the logfile is set up late (eg: after parsing a config file which
+// itself may produce output), early in main():
+//
+//	out.SetReplayBuffer(500)
+//
+// Messages are kept regardless of the screen and logfile thresholds at the
+// time (the thresholds in effect when replaying are used).  Use 0 to turn the
+// buffer off and drop anything kept, changing the size also drops anything
+// kept.  See SetStrictMode() if output should be held back until the pkg is
+// configured instead of going to the screen and being replayed.
+func SetReplayBuffer(n int) {
+	if n < 0 {
+		n = 0
+	}
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	atomic.StoreInt32(&replayBufSize, int32(n))
+	replayEntries = nil
+	replayNext = 0
+	replayedSeq = replaySeq
+}
+
+// keepForReplay adds the message to the replay ring buffer (if it is on), the
+// depth is relative to the caller of this routine (for file/line#)
+func keepForReplay(level Level, msg string, tgt int, mmeta *msgMetadata, fields map[string]interface{}, stack string, depth int) {
+	if atomic.LoadInt32(&replayBufSize) == 0 || level == LevelDiscard {
+		return
+	}
+	meta := mmeta.flagMetadata(level, depth+1)
+	meta.Stack = stack
+	meta.GoID = mmeta.goroutineID() // so Lgoid shows the original goroutine
+	meta.Fields = mergeFields(fields, meta.Fields)
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	size := int(atomic.LoadInt32(&replayBufSize))
+	if size == 0 {
+		return
+	}
+	replaySeq++
+	entry := &replayEntry{seq: replaySeq, held: heldOutput{level: level, tgt: tgt, msg: msg, meta: meta}}
+	if len(replayEntries) < size {
+		replayEntries = append(replayEntries, entry)
+		return
+	}
+	replayEntries[replayNext] = entry
+	replayNext = (replayNext + 1) % size
+}
+
+// ReplayTo writes the messages kept in the replay ring buffer (see the
+// SetReplayBuffer() routine) to the given writer, formatted as logfile output
+// with their original time and file/line# info, eg: once the logfile is open
+// the startup output that already went to the screen can be added to it:
+//
+//	out.SetLogFile(logPath)
+//	err := out.ReplayTo(out.Writer(out.LevelInfo, out.ForLogfile), true)
+//
+// If sinceStart is true everything still in the buffer is written, otherwise
+// only the messages kept since the last ReplayTo() call.  The current logfile
+// threshold, flags, prefixes and stack trace settings are used, messages that
+// were only sent to the screen (eg: via PrintTo()) are skipped.  This is meant
+// for use at startup, output from other goroutines while replaying may end
+// up on a partial line in the logfile.  Returns the first write error, if any.
+func ReplayTo(w io.Writer, sinceStart bool) error {
+	replayMu.Lock()
+	var entries []*replayEntry
+	entries = append(entries, replayEntries[replayNext:]...)
+	entries = append(entries, replayEntries[:replayNext]...)
+	since := replayedSeq
+	replayedSeq = replaySeq
+	replayMu.Unlock()
+
+	mutex.Lock()
+	logThresh := std.logThresh()
+	logThreshFunc := std.logThresholdFunc
+	logNewline := std.logfileNewline
+	std.logfileNewline = true
+	mutex.Unlock()
+	atomic.AddInt32(&activeReplays, 1)
+	gid := goroutineID()
+	defer func() {
+		strictReplays.Delete(gid)
+		atomic.AddInt32(&activeReplays, -1)
+		ResetNewline(logNewline, ForLogfile)
+	}()
+
+	for _, entry := range entries {
+		held := entry.held
+		if (!sinceStart && entry.seq <= since) || held.tgt&ForLogfile == 0 || categoryFiltered(held.meta.Category) || !passesThreshold(held.level, logThresh, logThreshFunc) {
+			continue
+		}
+		o := LevelWriter(held.level)
+		o.mu.RLock()
+		ro := &LvlOutput{
+			level:       o.level,
+			prefix:      o.prefix,
+			prefixFunc:  o.prefixFunc,
+			logfileHndl: w,
+			logFlags:    o.logFlags,
+		}
+		o.mu.RUnlock()
+		strictReplays.Store(gid, &held)
+		mmeta := newMsgMetadata(held.meta.Category)
+		pfxStr, mdata, suppressOutput := ro.doPrefixing(held.msg, ForLogfile, SmartInsert, mmeta, nil, false)
+		if suppressOutput {
+			continue
+		}
+		mdata.Fields = mergeFields(held.meta.Fields, mdata.Fields)
+		pfxStackTrace := ""
+		if held.meta.Stack != "" {
+			pfxStackTrace, _, _ = ro.doPrefixing(SymbolizeStack(held.meta.Stack), ForLogfile, SmartInsert, mmeta, nil, false)
+		}
+		if _, err := ro.writeOutput(pfxStr, ForLogfile, false, 0, pfxStackTrace, mdata, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"sync/atomic"
+)
+
+// Reset puts the pkg back to the settings it starts up with, it is mostly
+// meant for tests that tweak settings and want a clean slate for the next
+// test and it's safe to call while other goroutines are writing output.
+// Every output level (TRACE, DEBUG, .. FATAL and any level added via the
+// RegisterLevel() routine) gets its default prefix, screen writer (stdout,
+// or stderr for errors and fatals), flags and a discarded logfile writer
+// back with any formatter or prefix func cleared.  The thresholds (and
+// threshold funcs, package thresholds and debug scope), newline tracking,
+// stack trace config, depth and trimming, call depth, file/func/user/host
+// name lengths, error exit value, default error code, exit timeout and exit
+// func are set to their defaults, the defer func, log file name, hooks,
+// redactions, once keys, group depth, progress lines and written counts are
+// cleared and the rate limits, dedup (any pending repeat count is written
+// first), sampling, replay buffer, category filter, message size limits,
+// wrapping, colors, exit code accumulation, exit summary, last fatal capture
+// and the other On/Off style settings go back to their defaults too.  Reset leaves alone:
+//
+//   - levels added via RegisterLevel() and names via RegisterErrorCode()
+//   - open files and connections, it closes nothing, so if a log file was
+//     set up (eg: via SetLogFile(), SetLevelFiles() or SetOTLPExporter())
+//     close it first (eg: via CloseLogFile()) or it will be left open
+//   - strict mode (see SetStrictMode(), turning it off writes held output)
+//   - the message counts and start time used for the exit summary
+//   - the PKG_OUT_* env settings, they still override as they normally do
+func Reset() {
+	// any pending "last message repeated" line goes where it was headed
+	SetDedup(0)
+	resetDefaults()
+	ClearPackageThresholds()
+	SetDebugScope(nil)
+	ResetOnce()
+	SetRateLimit(LevelAll, 0, 0)
+	SetDedupMode(DedupDeferred)
+	SetSampling(LevelAll, 1)
+	SetSampleIndicator(false)
+	SetReplayBuffer(0)
+	SetCategoryFilter(nil, nil)
+	SetMaxMessageBytes(0, ForBoth)
+	SetWrapWidth(-1)
+	SetTabWidth(defaultTabWidth)
+	SetRuleInLogfile(true)
+	SetNilRendering(defaultNilRendering)
+	SetExpandErrors(false)
+	ClearRedactions()
+	SetStackTraceDepth(0)
+	SetStackTraceTrimInternal(true)
+	SetLazyStackTrace(false)
+	SetShowErrorCodeNames(true)
+	SetClosedFilePolicy(ClosedFileError)
+	SetAccumulateExitCode(0)
+	SetAccumulateExitLevel(LevelIssue)
+	ClearPendingExitCode()
+	SetExitSummary(nil, ExitSummaryJSON)
+	SetExitFunc(nil)
+	SetCaptureLastFatal(false)
+	SetVerbosityLevelMap(nil)
+	SetBuildInfo(nil)
+	ResetCounts()
+	ClearHooks()
+	atomic.StoreInt32(&groupDepth, 0)
+	atomic.StoreInt32(&colorMode, ColorAuto)
+	levelColors.Store(defaultLevelColors())
+}
+
+// resetDefaults is the part of Reset() done under the pkg mutex, ie: the
+// output levels and thresholds of the default Outputter and the settings
+// kept in out.go
+func resetDefaults() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	fresh := NewOutputter()
+	for i, o := range std.outputters {
+		if i >= len(fresh.outputters) {
+			break
+		}
+		def := fresh.outputters[i]
+		o.mu.Lock()
+		o.prefix = def.prefix
+		o.screenHndl = def.screenHndl
+		o.screenFlags = def.screenFlags
+		o.logfileHndl = def.logfileHndl
+		o.logFlags = def.logFlags
+		o.formatter = nil
+		o.prefixFunc = nil
+		o.mu.Unlock()
+	}
+	atomic.StoreInt32(&std.screenThreshold, int32(defaultScreenThreshold))
+	atomic.StoreInt32(&std.logThreshold, int32(defaultLogThreshold))
+	std.screenThresholdFunc = nil
+	std.logThresholdFunc = nil
+	std.updateQuietBelow()
+	std.screenNewline = true
+	std.logfileNewline = true
+	std.logFileName = ""
+	std.dailyLog = nil
+	progressActive = false
+	progressReported = make(map[string]int64)
+
+	screenStackTrace = 0
+	logfileStackTrace = defaultLogfileStackTrace
+	deferFunc = nil
+	atomic.StoreInt64(&exitTimeout, 0)
+	atomic.StoreInt32(&callDepth, defaultCallDepth)
+	atomic.StoreInt32(&errorExitVal, defaultErrorExitVal)
+	atomic.StoreInt32(&defaultErrCode, defaultErrCodeValue)
+	atomic.StoreInt32(&shortFileNameLength, defaultShortFileNameLength)
+	atomic.StoreInt32(&longFileNameLength, defaultLongFileNameLength)
+	atomic.StoreInt32(&shortFuncNameLength, defaultShortFuncNameLength)
+	atomic.StoreInt32(&longFuncNameLength, defaultLongFuncNameLength)
+	atomic.StoreInt32(&userNameLength, defaultUserNameLength)
+	atomic.StoreInt32(&hostNameLength, defaultHostNameLength)
+	atomic.StoreInt32(&emptyPrefixSeparator, 0)
+	atomic.StoreInt32(&panicOnWriteFail, 0)
+	atomic.StoreInt32(&writerExitsOnFatal, 0)
+	metadataSeparator.Store("")
+	screenTimeFormat.Store("")
+	logfileTimeFormat.Store("")
+}
@@ -0,0 +1,154 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/reset.go
+//   Checks Reset() puts the prefixes, writers, flags, thresholds and the other
+//   pkg settings (the feature toggles too) back to their startup defaults
+//   and that it's safe to call while output is being written (go test -race).
+
+package out
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestReset(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetPrefix(LevelNote, "N> ")
+	SetPrefix(LevelError, "E> ")
+	SetWriter(LevelAll, buf, ForScreen)
+	SetFlags(LevelAll, Lshortfile, ForLogfile)
+	SetThreshold(LevelTrace, ForScreen)
+	SetStackTraceConfig(StackTraceAllIssues | ForBoth)
+	SetCallDepth(7)
+	SetShortFileNameLength(30)
+	SetDeferFunc(func(exitVal int) {})
+	Print("no newline")
+
+	Reset()
+	notePrefix := Prefix(LevelNote)
+	errorPrefix := Prefix(LevelError)
+	infoScreen := Writer(LevelInfo, ForScreen)
+	errorScreen := Writer(LevelError, ForScreen)
+	noteLog := Writer(LevelNote, ForLogfile)
+	debugFlags := Flags(LevelDebug, ForScreen)
+	infoFlags := Flags(LevelInfo, ForScreen)
+	logFlags := Flags(LevelWarn, ForLogfile)
+	screenThresh := Threshold(ForScreen)
+	logThresh := Threshold(ForLogfile)
+	depth := CallDepth()
+	fileLen := ShortFileNameLength()
+	dFunc := DeferFunc()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "Note: ", notePrefix)
+	assert.Equal(t, "Error: ", errorPrefix)
+	assert.Equal(t, os.Stdout, infoScreen)
+	assert.Equal(t, os.Stderr, errorScreen)
+	assert.Equal(t, ioutil.Discard, noteLog)
+	assert.Equal(t, LscreenFlags, debugFlags)
+	assert.Equal(t, 0, infoFlags)
+	assert.Equal(t, LlogfileFlags, logFlags)
+	assert.Equal(t, defaultScreenThreshold, screenThresh)
+	assert.Equal(t, defaultLogThreshold, logThresh)
+	assert.Equal(t, defaultCallDepth, depth)
+	assert.Equal(t, defaultShortFileNameLength, fileLen)
+	assert.Nil(t, dFunc)
+	assert.Equal(t, "no newline", buf.String())
+}
+
+func TestResetSettings(t *testing.T) {
+	SetWriter(LevelAll, new(bytes.Buffer), ForScreen)
+	SetErrorExitVal(3)
+	SetDefaultErrCode(7)
+	SetExitTimeout(time.Second)
+	SetEmptyPrefixSeparator(true)
+	SetMetadataSeparator(" | ")
+	SetPackageThreshold("github.com/dvln/out", LevelTrace, ForBoth)
+	SetRateLimit(LevelError, 10, time.Second)
+	SetDedup(time.Minute)
+	SetSampling(LevelInfo, 0.5)
+	SetReplayBuffer(10)
+	SetWrapWidth(40)
+	SetTabWidth(4)
+	SetStackTraceDepth(3)
+	SetAccumulateExitCode(2)
+	SetCaptureLastFatal(true)
+	SetColorMode(ColorNever)
+	SetLevelColor(LevelError, "35")
+	AddHook(LevelInfo, func(level Level, msg string, meta FlagMetadata) {})
+	Group("open group")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	_, ok := PackageThreshold("github.com/dvln/out", ForScreen)
+	limit, _ := RateLimit(LevelError)
+	assert.Equal(t, defaultErrorExitVal, ErrorExitVal())
+	assert.Equal(t, defaultErrCodeValue, DefaultErrCode())
+	assert.Equal(t, time.Duration(0), ExitTimeout())
+	assert.False(t, EmptyPrefixSeparator())
+	assert.Equal(t, "", MetadataSeparator())
+	assert.False(t, ok)
+	assert.Equal(t, 0, limit)
+	assert.Equal(t, time.Duration(0), Dedup())
+	assert.Equal(t, 1.0, Sampling(LevelInfo))
+	assert.Equal(t, 0, ReplayBufferSize())
+	assert.Equal(t, -1, WrapWidth())
+	assert.Equal(t, defaultTabWidth, TabWidth())
+	assert.Equal(t, 0, StackTraceDepth())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&accumulateExitCode))
+	assert.False(t, CaptureLastFatal())
+	assert.Equal(t, ColorAuto, ColorMode())
+	assert.Equal(t, "31", LevelColor(LevelError))
+	assert.Nil(t, hooksWanted(LevelFatal))
+	assert.Equal(t, 0, GroupDepth())
+}
+
+func TestResetWhileWriting(t *testing.T) {
+	// an Outputter of its own so the output goes nowhere even after Reset()
+	op := NewOutputter()
+	op.SetWriter(LevelAll, ioutil.Discard, ForBoth)
+	op.SetThreshold(LevelInfo, ForBoth)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				Reset()
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		op.Noteln("busy")
+		op.Issue(NewErr("failed", 42))
+	}
+	close(done)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
@@ -7,7 +7,11 @@
 package out
 
 import (
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -65,3 +69,314 @@ func (w *RotateWriter) Rotate() error {
 	w.fp, err = os.Create(w.filename)
 	return err
 }
+
+// sizeRotateWriter is the io.Writer used for a log file set up via the
+// SetLogFileWithRotation() routine, it rotates the file by size
+type sizeRotateWriter struct {
+	lock     sync.Mutex
+	path     string // the log file path
+	maxBytes int64  // rotate when a write would go over this, 0 means never
+	keep     int    // how many rotated files (path.1 .. path.<keep>) to keep
+	compress bool   // gzip rotated files (path.1.gz and so on)
+	size     int64  // current size of the open file
+	fp       *os.File
+
+	// compressing tracks the background gzip of path.1 (if compressing),
+	// the next rotation waits for it before shifting the rotated files
+	compressing sync.WaitGroup
+}
+
+// newSizeRotateWriter opens (creates, if needed) the log file at the given path
+// in append mode, see SetLogFileWithRotation()
+func newSizeRotateWriter(path string, maxBytes int64, keep int, compress bool) (*sizeRotateWriter, error) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	w := &sizeRotateWriter{path: path, maxBytes: maxBytes, keep: keep, compress: compress}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write satisfies the io.Writer interface, if the write would take the file
+// over the max size it is rotated first (a single write bigger than the max
+// size still goes to a fresh file whole)
+func (w *sizeRotateWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.fp == nil {
+		return 0, &os.PathError{Op: "write", Path: w.path, Err: os.ErrClosed}
+	}
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.fp.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// reopen reopens the log file (no rotation), used if the file was closed or
+// moved aside out from under the pkg (see ClosedFileReopen and ReopenLogFile),
+// if the open fails the current handle is kept
+func (w *sizeRotateWriter) reopen() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	old := w.fp
+	w.fp = nil
+	if err := w.open(); err != nil {
+		w.fp = old
+		return err
+	}
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// close waits for any background compression and closes the log file, later
+// writes fail until it is reopened (see CloseLogFile())
+func (w *sizeRotateWriter) close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.compressing.Wait()
+	if w.fp == nil {
+		return nil
+	}
+	err := w.fp.Close()
+	w.fp = nil
+	return err
+}
+
+// open opens the log file in append mode and picks up its current size, the
+// lock must be held by the caller
+func (w *sizeRotateWriter) open() error {
+	fp, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	w.fp = fp
+	w.size = info.Size()
+	return nil
+}
+
+// rotate closes the log file, shifts the rotated files up by one (path.1 to
+// path.2 and so on, dropping any beyond the number to keep), renames the log
+// file to path.1 (gzip'ing it in the background if compressing) and opens a
+// fresh one, the lock must be held by the caller
+func (w *sizeRotateWriter) rotate() error {
+	if w.fp != nil {
+		err := w.fp.Close()
+		w.fp = nil
+		if err != nil {
+			return err
+		}
+	}
+	// path.1 may still be getting compressed from the last rotation
+	w.compressing.Wait()
+	// drop the oldest file along with any left beyond the number to keep
+	// (eg: from an earlier run that kept more)
+	for i := w.keep + 1; ; i++ {
+		if !w.removeRotated(i) {
+			break
+		}
+	}
+	if w.keep > 0 {
+		w.removeRotated(w.keep)
+	}
+	for i := w.keep - 1; i >= 1; i-- {
+		for _, ext := range []string{"", ".gz"} {
+			if err := os.Rename(w.rotatedName(i)+ext, w.rotatedName(i+1)+ext); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if w.keep > 0 {
+		err := os.Rename(w.path, w.rotatedName(1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil && w.compress {
+			w.compressing.Add(1)
+			go w.gzipRotated(w.rotatedName(1))
+		}
+	}
+	if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.open()
+}
+
+// removeRotated removes the n'th rotated file, compressed or not, returns
+// true if there was one to remove (errors are ignored, rotation goes on)
+func (w *sizeRotateWriter) removeRotated(n int) bool {
+	found := false
+	for _, ext := range []string{"", ".gz"} {
+		if err := os.Remove(w.rotatedName(n) + ext); !os.IsNotExist(err) {
+			found = true
+		}
+	}
+	return found
+}
+
+// gzipRotated compresses the given rotated file to <name>.gz and removes the
+// uncompressed one, run in the background after a rotation.  Errors are shown
+// via Error() (once the next rotation is free to go on) and the uncompressed
+// file is kept if compressing it fails.
+func (w *sizeRotateWriter) gzipRotated(name string) {
+	err := gzipFile(name)
+	w.compressing.Done()
+	if err != nil {
+		Errorln("Failed to compress rotated log file:", name, "Err:", err)
+	}
+}
+
+// gzipFile compresses the given file to <name>.gz and removes the original
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	gz, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(gz)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	in.Close()
+	return os.Remove(name)
+}
+
+// rotatedName returns the name of the n'th rotated file, eg: path.1
+func (w *sizeRotateWriter) rotatedName(n int) string {
+	return fmt.Sprintf("%s.%d", w.path, n)
+}
+
+// dailyRotateWriter is the io.Writer used for a log file set up via the
+// SetLogFileDailyRotation() routine, it moves to a new dated file each day
+type dailyRotateWriter struct {
+	lock    sync.Mutex
+	pattern string           // the log file path pattern, eg: app-%Y-%m-%d.log
+	name    string           // the path of the open log file
+	now     func() time.Time // the clock, time.Now() except when testing
+	closed  bool             // closed via close(), writes fail until reopened
+	fp      *os.File
+}
+
+// newDailyRotateWriter opens (creates, if needed) today's log file for the
+// given path pattern in append mode, see SetLogFileDailyRotation()
+func newDailyRotateWriter(pattern string) (*dailyRotateWriter, error) {
+	w := &dailyRotateWriter{pattern: pattern, now: time.Now}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.open(w.datedName(w.now())); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write satisfies the io.Writer interface, if the date has changed since the
+// log file was opened (even by several days) the dated file for the current
+// date is opened first
+func (w *dailyRotateWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return 0, &os.PathError{Op: "write", Path: w.name, Err: os.ErrClosed}
+	}
+	if name := w.datedName(w.now()); name != w.name || w.fp == nil {
+		if err := w.open(name); err != nil {
+			return 0, err
+		}
+	}
+	return w.fp.Write(p)
+}
+
+// currentName returns the path of the log file currently in use
+func (w *dailyRotateWriter) currentName() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.name
+}
+
+// reopen reopens the current log file, used if the file was closed or moved
+// aside out from under the pkg (see ClosedFileReopen and ReopenLogFile), if
+// the open fails the current handle is kept
+func (w *dailyRotateWriter) reopen() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	old := w.fp
+	w.fp = nil
+	if err := w.open(w.name); err != nil {
+		w.fp = old
+		return err
+	}
+	w.closed = false
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// close closes the log file, later writes fail until it is reopened (see
+// CloseLogFile())
+func (w *dailyRotateWriter) close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.closed = true
+	if w.fp == nil {
+		return nil
+	}
+	err := w.fp.Close()
+	w.fp = nil
+	return err
+}
+
+// open closes any open log file and opens the given one in append mode, the
+// lock must be held by the caller
+func (w *dailyRotateWriter) open(name string) error {
+	if w.fp != nil {
+		w.fp.Close()
+		w.fp = nil
+	}
+	fp, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	w.fp = fp
+	w.name = name
+	return nil
+}
+
+// datedName returns the log file path for the given time, ie: the pattern
+// with %Y, %m and %d replaced by the year, month and day (%% is a '%')
+func (w *dailyRotateWriter) datedName(t time.Time) string {
+	return strings.NewReplacer(
+		"%Y", fmt.Sprintf("%04d", t.Year()),
+		"%m", fmt.Sprintf("%02d", int(t.Month())),
+		"%d", fmt.Sprintf("%02d", t.Day()),
+		"%%", "%",
+	).Replace(w.pattern)
+}
@@ -0,0 +1,157 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// Package test for: out/rotator.go
+//   Checks that size based log file rotation shifts, compresses and drops
+//   files right and that daily rotation moves to the right dated file.
+
+package out
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetLogFileWithRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotation")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+	// a leftover from a run that kept more files should be dropped
+	ioutil.WriteFile(logPath+".3", []byte("stale\n"), 0666)
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetLogFileWithRotation(logPath, 10, 2, false)
+	logFileName := LogFileName()
+	Println("line one") // 9 bytes each
+	Println("line two")
+	Println("line 333")
+	Println("line 444")
+	Noteln("too big for any file")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	contents := func(path string) string {
+		b, _ := ioutil.ReadFile(path)
+		return string(b)
+	}
+	assert.Equal(t, logPath, logFileName)
+	assert.Equal(t, "Note: too big for any file\n", contents(logPath))
+	assert.Equal(t, "line 444\n", contents(logPath+".1"))
+	assert.Equal(t, "line 333\n", contents(logPath+".2"))
+	_, err = os.Stat(logPath + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSetLogFileWithRotationCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotation")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "tool.log")
+	// leftovers beyond keep, compressed or not, should be dropped
+	ioutil.WriteFile(logPath+".3", []byte("stale\n"), 0666)
+	ioutil.WriteFile(logPath+".4.gz", []byte("stale\n"), 0666)
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	SetLogFileWithRotation(logPath, 10, 2, true)
+	Println("line one") // 9 bytes each
+	Println("line two")
+	Println("line 333")
+	Println("line 444")
+	Writer(LevelInfo, ForLogfile).(*sizeRotateWriter).compressing.Wait()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	gunzip := func(path string) string {
+		f, err := os.Open(path)
+		if err != nil {
+			return err.Error()
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err.Error()
+		}
+		b, _ := ioutil.ReadAll(zr)
+		return string(b)
+	}
+	assert.Equal(t, "line 333\n", gunzip(logPath+".1.gz"))
+	assert.Equal(t, "line two\n", gunzip(logPath+".2.gz"))
+	files, _ := ioutil.ReadDir(dir)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"tool.log", "tool.log.1.gz", "tool.log.2.gz"}, names)
+}
+
+func TestSetLogFileDailyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotation")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pattern := filepath.Join(dir, "audit-%Y-%m-%d.log")
+	today := filepath.Join(dir, time.Now().Format("audit-2006-01-02.log"))
+
+	Discard(ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForLogfile)
+	currFileName := LogFileName()
+	SetLogFileDailyRotation(pattern)
+	assert.Equal(t, today, LogFileName())
+	w := Writer(LevelInfo, ForLogfile).(*dailyRotateWriter)
+	day := time.Date(2016, time.January, 30, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return day }
+	Println("before midnight")
+	day = day.Add(2 * time.Minute)
+	Println("after midnight")
+	day = day.AddDate(0, 0, 3) // slept for a few days
+	Println("after a long sleep")
+	lastFileName := LogFileName()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+	std.useLogFile(currFileName, ioutil.Discard)
+
+	contents := func(path string) string {
+		b, _ := ioutil.ReadFile(path)
+		return string(b)
+	}
+	assert.Equal(t, "before midnight\n", contents(filepath.Join(dir, "audit-2016-01-30.log")))
+	assert.Equal(t, "after midnight\n", contents(filepath.Join(dir, "audit-2016-01-31.log")))
+	assert.Equal(t, "after a long sleep\n", contents(filepath.Join(dir, "audit-2016-02-03.log")))
+	assert.Equal(t, filepath.Join(dir, "audit-2016-02-03.log"), lastFileName)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 4) // today's file was opened at setup
+}
@@ -0,0 +1,88 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultRuleWidth is the width of a rule (separator line) in the logfile
+// and on the screen when the terminal width isn't known (eg: not a TTY)
+const DefaultRuleWidth = 80
+
+// ruleInLogfile, if non-zero (the default), means rules are also written to
+// the logfile, see SetRuleInLogfile()
+var ruleInLogfile int32 = 1
+
+// RuleInLogfile returns true if rules (see Rule()) go to the logfile too
+func RuleInLogfile() bool {
+	return atomic.LoadInt32(&ruleInLogfile) != 0
+}
+
+// SetRuleInLogfile controls if rules (see Rule() and Rulef()) are written to
+// the logfile, by default they are (at DefaultRuleWidth), if set to false
+// they only go to the screen as they're often just visual polish
+func SetRuleInLogfile(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&ruleInLogfile, val)
+}
+
+// Rule writes a separator line of dashes to the given target(s) at the Info
+// level (so thresholds, prefixes and flags apply as for Println()), eg:
+//   out.Println("Section 1")
+//   out.Rule(out.ForBoth)
+// On the screen the rule is the width of the terminal (DefaultRuleWidth if
+// that can't be found, eg: if not a TTY or on an unsupported platform, the
+// COLUMNS env var is also checked), in the logfile it is DefaultRuleWidth
+// wide.  Nothing goes to the logfile if SetRuleInLogfile(false) was used.
+func Rule(outputTgt int) {
+	if outputTgt&ForScreen != 0 {
+		INFO.outputRaw(false, 0, ForScreen, ruleLine('-', 0))
+	}
+	if outputTgt&ForLogfile != 0 && RuleInLogfile() {
+		INFO.outputRaw(false, 0, ForLogfile, ruleLine('-', DefaultRuleWidth))
+	}
+}
+
+// Rulef is like Rule(ForBoth) but the char used and the width can be given,
+// a width of 0 (or less) means the screen gets the terminal width and the
+// logfile gets DefaultRuleWidth (as with Rule()), eg: out.Rulef('=', 40)
+func Rulef(char byte, width int) {
+	INFO.outputRaw(false, 0, ForScreen, ruleLine(char, width))
+	if RuleInLogfile() {
+		if width <= 0 {
+			width = DefaultRuleWidth
+		}
+		INFO.outputRaw(false, 0, ForLogfile, ruleLine(char, width))
+	}
+}
+
+// ruleLine returns a rule of the given width with a trailing newline, if the
+// width isn't given the width of the Info level's screen writer is used
+func ruleLine(char byte, width int) string {
+	if width <= 0 {
+		INFO.mu.RLock()
+		hndl := INFO.screenHndl
+		INFO.mu.RUnlock()
+		width = terminalWidth(hndl)
+		if width <= 0 {
+			width = DefaultRuleWidth
+		}
+	}
+	return strings.Repeat(string(char), width) + "\n"
+}
@@ -0,0 +1,149 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sampleRand is the PRNG used for sampling, seeded at startup and guarded by
+// sampleRandMu as a *rand.Rand isn't safe for concurrent use
+var (
+	sampleRandMu sync.Mutex
+	sampleRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// sampler holds the SetSampling() rate and totals for one level
+type sampler struct {
+	rate      float64 // fraction of messages kept, 0.0 to 1.0
+	seen      int64   // messages at the level since sampling was set
+	kept      int64   // how many of those were written
+	sinceKept int64   // messages seen since the last one kept (inclusive)
+}
+
+// samplers holds a map[Level]*sampler of the levels being sampled, the map
+// is replaced (not modified) when SetSampling() is called
+var samplers atomic.Value
+
+// samplingMu serializes SetSampling() calls updating samplers
+var samplingMu sync.Mutex
+
+// sampleIndicator is 1 if kept sampled messages are annotated, see
+// SetSampleIndicator()
+var sampleIndicator int32
+
+// SetSampling keeps only a random fraction of the output at the given level,
+// rate is 0.0 (drop everything) to 1.0 (keep everything, the default), eg:
+// out.SetSampling(out.LevelTrace, 0.01) keeps about 1 in 100 trace lines for
+// production tracing.  Each message is kept or dropped independently, dying
+// (Fatal) output is never dropped.  LevelAll sets the rate for every level.
+// Dropped messages are still counted, see SamplingStats() and
+// SetSampleIndicator().  Setting a new rate resets the level's totals.
+func SetSampling(level Level, rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+	levels := []Level{level}
+	if level == LevelAll {
+		levels = nil
+		for _, o := range outputters {
+			if o.level != LevelDiscard {
+				levels = append(levels, o.level)
+			}
+		}
+	}
+	old, _ := samplers.Load().(map[Level]*sampler)
+	rates := make(map[Level]*sampler, len(old)+len(levels))
+	for l, s := range old {
+		rates[l] = s
+	}
+	for _, l := range levels {
+		if rate >= 1 {
+			delete(rates, l)
+			continue
+		}
+		rates[l] = &sampler{rate: rate}
+	}
+	samplers.Store(rates)
+}
+
+// Sampling returns the rate set for the given level via SetSampling(), 1.0
+// if the level isn't sampled
+func Sampling(level Level) float64 {
+	rates, _ := samplers.Load().(map[Level]*sampler)
+	if s := rates[level]; s != nil {
+		return s.rate
+	}
+	return 1
+}
+
+// SamplingStats returns how many messages were seen at the given level since
+// its SetSampling() rate was set and how many of those were kept (written),
+// both are 0 if the level isn't sampled
+func SamplingStats(level Level) (seen int64, kept int64) {
+	rates, _ := samplers.Load().(map[Level]*sampler)
+	s := rates[level]
+	if s == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&s.seen), atomic.LoadInt64(&s.kept)
+}
+
+// SetSampleIndicator turns on (or off) annotating messages kept by sampling
+// with how many messages they stand for, ie: the kept message plus those
+// dropped since the last kept one, eg: "Trace: (sampled 1 of 97) <msg>".  It
+// is off by default.
+func SetSampleIndicator(on bool) {
+	val := int32(0)
+	if on {
+		val = 1
+	}
+	atomic.StoreInt32(&sampleIndicator, val)
+}
+
+// sampleFloat returns the next number in [0.0,1.0) from the sampling PRNG
+func sampleFloat() float64 {
+	sampleRandMu.Lock()
+	f := sampleRand.Float64()
+	sampleRandMu.Unlock()
+	return f
+}
+
+// sampledOut returns true if the message at the given level lost the
+// SetSampling() draw and should be dropped, else the message to write (with
+// the sample indicator added if SetSampleIndicator() is on)
+func sampledOut(level Level, s string) (bool, string) {
+	rates, _ := samplers.Load().(map[Level]*sampler)
+	smp := rates[level]
+	if smp == nil {
+		return false, s
+	}
+	atomic.AddInt64(&smp.seen, 1)
+	since := atomic.AddInt64(&smp.sinceKept, 1)
+	if smp.rate <= 0 || sampleFloat() >= smp.rate {
+		return true, s
+	}
+	atomic.AddInt64(&smp.kept, 1)
+	atomic.AddInt64(&smp.sinceKept, -since)
+	if atomic.LoadInt32(&sampleIndicator) != 0 {
+		s = fmt.Sprintf("(sampled 1 of %d) ", since) + s
+	}
+	return false, s
+}
@@ -0,0 +1,113 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/sampling.go
+//   Checks SetSampling() keeps about the given fraction of messages, counts
+//   the dropped ones and annotates kept lines if asked to.
+
+package out
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSampling(t *testing.T) {
+	sampleRandMu.Lock()
+	origRand := sampleRand
+	sampleRand = rand.New(rand.NewSource(42))
+	sampleRandMu.Unlock()
+	defer func() {
+		sampleRandMu.Lock()
+		sampleRand = origRand
+		sampleRandMu.Unlock()
+	}()
+
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelTrace, ForScreen)
+	defaultRate := Sampling(LevelNote)
+
+	// About a quarter kept, from many goroutines, Info isn't sampled
+	SetSampling(LevelNote, 0.25)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Noteln("sampled")
+			}
+		}()
+	}
+	wg.Wait()
+	Infoln("not sampled")
+	seen, kept := SamplingStats(LevelNote)
+	quarter := screenBuf.String()
+	screenBuf.Reset()
+
+	// Kept lines note how many messages they stand for
+	SetSampling(LevelNote, 0.5)
+	SetSampleIndicator(true)
+	for i := 0; i < 100; i++ {
+		Noteln("x")
+	}
+	indicatorSeen, indicatorKept := SamplingStats(LevelNote)
+	indicated := strings.Split(strings.TrimSuffix(screenBuf.String(), "\n"), "\n")
+	screenBuf.Reset()
+
+	// A rate of 0 drops everything, 1 (or above) turns sampling off
+	SetSampling(LevelNote, 0)
+	Noteln("dropped")
+	dropped := screenBuf.String()
+	SetSampling(LevelNote, 1.5)
+	Noteln("kept")
+	offRate := Sampling(LevelNote)
+	offSeen, _ := SamplingStats(LevelNote)
+	off := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 1.0, defaultRate)
+	assert.Equal(t, int64(1000), seen)
+	assert.True(t, kept > 150 && kept < 350, "kept %d of 1000 at a 0.25 rate", kept)
+	assert.Equal(t, int(kept), strings.Count(quarter, "Note: sampled\n"))
+	assert.Contains(t, quarter, "not sampled\n")
+
+	assert.Equal(t, int64(100), indicatorSeen)
+	assert.Equal(t, int(indicatorKept), len(indicated))
+	lineRE := regexp.MustCompile(`^Note: \(sampled 1 of (\d+)\) x$`)
+	total := 0
+	for _, line := range indicated {
+		match := lineRE.FindStringSubmatch(line)
+		if assert.NotNil(t, match, "line %q", line) {
+			n, _ := strconv.Atoi(match[1])
+			total += n
+		}
+	}
+	assert.True(t, total <= 100 && total > 90, "kept lines stand for %d messages", total)
+
+	assert.Equal(t, "", dropped)
+	assert.Equal(t, 1.0, offRate)
+	assert.Equal(t, int64(0), offSeen)
+	assert.Equal(t, "Note: kept\n", off)
+}
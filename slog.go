@@ -0,0 +1,136 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package out
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a log/slog Handler that sends slog records through this
+// pkg's levels, thresholds, writers and such, eg:
+//
+//	logger := slog.New(out.NewSlogHandler())
+//	logger.Warn("disk almost full", "free", "2G")
+//
+// The slog levels map to this pkg's levels like so:
+//
+//	below Debug -> LevelTrace, Debug -> LevelDebug, Info -> LevelInfo,
+//	between Info and Warn -> LevelNote, Warn -> LevelWarn,
+//	Error and above -> LevelError
+//
+// The record's attrs are added to the structured fields in the output's
+// metadata and to the end of the message as key=value pairs, as is done for
+// an Entry (see WithFields()).  Groups prefix the keys of the attrs in them,
+// eg: logger.WithGroup("req").Info("done", "id", 7) gives a "req.id" field.
+// The callers file, line# and func come from the record.  Handlers are never
+// modified once created so they can be shared across goroutines.
+type SlogHandler struct {
+	fields Fields // attrs from WithAttrs(), keys include any group prefix
+	group  string // WithGroup() prefix for attr keys, eg: "req.", or empty
+}
+
+// NewSlogHandler returns a slog.Handler that outputs via this pkg, see
+// SlogHandler
+func NewSlogHandler() *SlogHandler {
+	return &SlogHandler{}
+}
+
+// slogLevel maps a slog level to this pkg's output level, see SlogHandler
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level == slog.LevelInfo:
+		return LevelInfo
+	case level < slog.LevelWarn:
+		return LevelNote
+	case level < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
+
+// addSlogAttr adds the attr to the fields with the given key prefix, groups
+// are flattened with their name added to the prefix, eg: "req.id"
+func addSlogAttr(fields Fields, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			addSlogAttr(fields, prefix, groupAttr)
+		}
+		return
+	}
+	fields[prefix+attr.Key] = attr.Value.Any()
+}
+
+// Enabled reports if output at the given slog level can be shown anywhere
+// (ie: it isn't below both the screen and logfile thresholds)
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return !LevelWriter(slogLevel(level)).quiet()
+}
+
+// Handle outputs the record at the matching level, see SlogHandler
+func (h *SlogHandler) Handle(_ context.Context, record slog.Record) error {
+	o := LevelWriter(slogLevel(record.Level))
+	if o.skipQuietOutput(false) {
+		return nil
+	}
+	fields := make(Fields, len(h.fields)+record.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(fields, h.group, attr)
+		return true
+	})
+	msg := (&Entry{Data: fields}).text(record.Message + "\n")
+	_, err := o.stringOutput(msg, false, 0, ForBoth, "", fields, record.PC)
+	return err
+}
+
+// WithAttrs returns a new SlogHandler with the given attrs added (under any
+// current group) to all of its output
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, attr := range attrs {
+		addSlogAttr(fields, h.group, attr)
+	}
+	return &SlogHandler{fields: fields, group: h.group}
+}
+
+// WithGroup returns a new SlogHandler that puts the attrs added later into
+// the given group, ie: their keys get a "<name>." prefix
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{fields: h.fields, group: h.group + name + "."}
+}
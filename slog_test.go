@@ -0,0 +1,68 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+// Package test for: out/slog.go
+//   Checks slog records logged via a SlogHandler are routed to the matching
+//   levels with their attrs (and groups) added as fields.
+
+package out
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+	capture := &fieldsCapture{}
+	SetFormatter(LevelNote, capture)
+
+	logger := slog.New(NewSlogHandler())
+	debugEnabled := logger.Enabled(context.Background(), slog.LevelDebug)
+	infoEnabled := logger.Enabled(context.Background(), slog.LevelInfo)
+	logger.Debug("hidden")
+	logger.Info("hello", "user", "bob")
+	logger.Warn("disk almost full", "free", "2G")
+	logger.Error("write failed", slog.Group("file", "name", "a.txt", "size", 3))
+	logger.With("req", 7).WithGroup("db").With("table", "users").Log(context.Background(), slog.LevelInfo+2, "slow query", "ms", 250)
+	routed := screenBuf.String()
+	screenBuf.Reset()
+
+	SetFlags(LevelInfo, Lshortfile, ForScreen)
+	logger.Info("where")
+	where := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.False(t, debugEnabled)
+	assert.True(t, infoEnabled)
+	assert.Equal(t, "hello user=bob\n"+
+		"Warning: disk almost full free=2G\n"+
+		"Error: write failed file.name=a.txt file.size=3\n"+
+		"Note: slow query db.ms=250 db.table=users req=7\n", routed)
+	if assert.Len(t, capture.fields, 1) {
+		assert.Equal(t, map[string]interface{}{"req": int64(7), "db.table": "users", "db.ms": int64(250)}, capture.fields[0])
+	}
+	assert.True(t, strings.HasPrefix(where, "slog_test.go:"), "caller info from the record: %q", where)
+}
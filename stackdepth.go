@@ -0,0 +1,61 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// stackTraceDepth is the max number of frames in a stack trace, 0 means
+// there is no limit (the default), see SetStackTraceDepth()
+var stackTraceDepth int32
+
+// StackTraceDepth returns the max number of frames kept in stack traces, 0
+// if there is no limit, see SetStackTraceDepth()
+func StackTraceDepth() int {
+	return int(atomic.LoadInt32(&stackTraceDepth))
+}
+
+// SetStackTraceDepth limits how many frames (func and file:line# pairs) are
+// captured and shown in stack traces, eg: out.SetStackTraceDepth(10) keeps
+// the 10 frames closest to where the output (or DetailedError) came from so
+// the stack traces for deep call chains don't swamp the logfile.  This is for
+// the regular, lazy (see SetLazyStackTrace()) and DetailedError stack traces
+// alike.  A max of 0 (the default), or less, means no limit.
+func SetStackTraceDepth(maxFrames int) {
+	if maxFrames < 0 {
+		maxFrames = 0
+	}
+	atomic.StoreInt32(&stackTraceDepth, int32(maxFrames))
+}
+
+// trimStackFrames cuts the given stack trace (in the form stackTrace() gives,
+// ie: a goroutine header line then 2 lines per frame) down to the max depth
+// set via SetStackTraceDepth(), any text after the frames is dropped too
+func trimStackFrames(stack string) string {
+	maxFrames := StackTraceDepth()
+	if maxFrames == 0 {
+		return stack
+	}
+	index := 0
+	for lines := 0; lines <= maxFrames*2; lines++ {
+		next := strings.IndexByte(stack[index:], '\n')
+		if next == -1 {
+			return stack
+		}
+		index += next + 1
+	}
+	return stack[:index-1]
+}
@@ -0,0 +1,79 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/stackdepth.go
+//   Checks SetStackTraceDepth() limits the frames in the regular, lazy and
+//   DetailedError stack traces for a deep (recursive) call chain.
+
+package out
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+// recurseStack calls itself depth times and then runs fn, giving a stack
+// trace with (at least) depth recurseStack frames in it
+func recurseStack(depth int, fn func()) {
+	if depth == 0 {
+		fn()
+		return
+	}
+	recurseStack(depth-1, fn)
+}
+
+func TestStackTraceDepth(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	var detErr DetailedError
+	recurseStack(20, func() {
+		Issueln("unlimited")
+		detErr = NewErr("deep error")
+	})
+	unlimited := screenBuf.String()
+	screenBuf.Reset()
+
+	SetStackTraceDepth(3)
+	recurseStack(20, func() { Issueln("limited") })
+	limited := screenBuf.String()
+	screenBuf.Reset()
+	Error(detErr)
+	limitedDetErr := screenBuf.String()
+	screenBuf.Reset()
+	SetLazyStackTrace(true)
+	recurseStack(20, func() { Issueln("lazy") })
+	SetLazyStackTrace(false)
+	limitedLazy := screenBuf.String()
+	depth := StackTraceDepth()
+	SetStackTraceDepth(-1)
+	noLimit := StackTraceDepth()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 3, depth)
+	assert.Equal(t, 0, noLimit)
+	assert.Contains(t, unlimited, "out.TestStackTraceDepth(")
+	assert.True(t, strings.Count(unlimited, "out.recurseStack(") > 20)
+	for _, output := range []string{limited, limitedDetErr, limitedLazy} {
+		assert.Contains(t, output, "Stack Trace: goroutine ")
+		assert.NotContains(t, output, "out.TestStackTraceDepth(")
+		assert.True(t, strings.Count(output, "out.recurseStack(") <= 3, output)
+		assert.Equal(t, 3, strings.Count(output, ".go:"), output)
+	}
+}
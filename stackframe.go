@@ -0,0 +1,77 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackFrame is one frame of a stack trace, see FlagMetadata.Frames
+type StackFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// callerFrames returns the stack frames for the given program counters,
+// runtime.goexit is always left out, the internal frames are left out if
+// SetStackTraceTrimInternal() is on and the number of frames is limited to
+// the SetStackTraceDepth() max (if set)
+func callerFrames(pcs []uintptr) []StackFrame {
+	trim := StackTraceTrimInternal()
+	maxFrames := StackTraceDepth()
+	var stackFrames []StackFrame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && frame.Function != "runtime.goexit" && !(trim && internalFrame(frame)) {
+			stackFrames = append(stackFrames, StackFrame{Func: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more || (maxFrames != 0 && len(stackFrames) == maxFrames) {
+			break
+		}
+	}
+	return stackFrames
+}
+
+// parseStackFrames breaks a stack trace in the text form (ie: the goroutine
+// header line and then a func line and a tab indented file:line# line for
+// each frame, as runtime.Stack() gives) into its frames
+func parseStackFrames(stack string) []StackFrame {
+	var frames []StackFrame
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	for i := 1; i+1 < len(lines); i += 2 {
+		funcName := strings.TrimPrefix(lines[i], "created by ")
+		if in := strings.Index(funcName, " in goroutine "); in != -1 {
+			funcName = funcName[:in]
+		} else if paren := strings.LastIndexByte(funcName, '('); paren > 0 {
+			funcName = funcName[:paren]
+		}
+		fileLine := strings.TrimSpace(lines[i+1])
+		if space := strings.IndexByte(fileLine, ' '); space != -1 {
+			fileLine = fileLine[:space] // drop the " +0x1d" pc offset
+		}
+		frame := StackFrame{Func: funcName, File: fileLine}
+		if colon := strings.LastIndexByte(fileLine, ':'); colon != -1 {
+			if line, err := strconv.Atoi(fileLine[colon+1:]); err == nil {
+				frame.File = fileLine[:colon]
+				frame.Line = line
+			}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
@@ -0,0 +1,96 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/stackframe.go
+//   Checks the stack trace frames are in FlagMetadata.Frames (and its JSON)
+//   for regular, untrimmed and DetailedError stack traces, with the depth
+//   limit applied, and that text stack traces are parsed into frames.
+
+package out
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+// frameCapture is a Formatter that records the frames in the metadata of
+// each message formatted and leaves the message itself alone
+type frameCapture struct {
+	frames [][]StackFrame
+	json   []string
+}
+
+func (f *frameCapture) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	f.frames = append(f.frames, mdata.Frames)
+	b, _ := json.Marshal(mdata)
+	f.json = append(f.json, string(b))
+	return msg, ForBoth, 0, false
+}
+
+func TestStackFrames(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	capture := &frameCapture{}
+	SetFormatter(LevelAll, capture)
+	Infoln("no stack")
+	Issueln("trimmed")
+	SetStackTraceTrimInternal(false)
+	Issueln("untrimmed")
+	SetStackTraceTrimInternal(true)
+	Error(NewErr("detailed"))
+	SetStackTraceDepth(1)
+	recurseStack(5, func() { Issueln("limited") })
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	if !assert.Equal(t, 5, len(capture.frames)) {
+		return
+	}
+	assert.Nil(t, capture.frames[0])
+	assert.NotContains(t, capture.json[0], `"frames"`)
+	for i := 1; i < 4; i++ {
+		frames := capture.frames[i]
+		if assert.True(t, len(frames) > 0) {
+			assert.Equal(t, "github.com/dvln/out.TestStackFrames", frames[0].Func)
+			assert.Contains(t, frames[0].File, "stackframe_test.go")
+			assert.True(t, frames[0].Line > 0)
+		}
+		assert.Contains(t, capture.json[i], `"frames":[{"func":"github.com/dvln/out.TestStackFrames","file":"`)
+	}
+	if assert.Equal(t, 1, len(capture.frames[4])) {
+		assert.Equal(t, "github.com/dvln/out.TestStackFrames.func1", capture.frames[4][0].Func)
+	}
+}
+
+func TestParseStackFrames(t *testing.T) {
+	stack := "goroutine 7 [running]:\n" +
+		"main.(*server).handle(0xc000010000, 0x2)\n" +
+		"\t/src/main/server.go:42 +0x1d\n" +
+		"main.main()\n" +
+		"\t/src/main/main.go:10 +0x25\n" +
+		"created by main.start in goroutine 1\n" +
+		"\t/src/main/start.go:5 +0x3f\n"
+	frames := parseStackFrames(stack)
+	assert.Equal(t, []StackFrame{
+		{Func: "main.(*server).handle", File: "/src/main/server.go", Line: 42},
+		{Func: "main.main", File: "/src/main/main.go", Line: 10},
+		{Func: "main.start", File: "/src/main/start.go", Line: 5},
+	}, frames)
+	assert.Nil(t, parseStackFrames(""))
+}
@@ -0,0 +1,106 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// stackTraceTrimInternal, if non-zero (the default), means the frames from
+// inside this pkg and the Go runtime and testing pkgs are left out of stack
+// traces, see SetStackTraceTrimInternal()
+var stackTraceTrimInternal int32 = 1
+
+// outPkgPrefix is the start of the func names in this pkg, eg:
+// "github.com/dvln/out.", worked out at runtime so it's right for forks
+var outPkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	dot := strings.IndexByte(name[slash+1:], '.')
+	return name[:slash+1+dot+1]
+}()
+
+// StackTraceTrimInternal returns true if the 'out' pkg, Go runtime and
+// testing pkg frames are left out of stack traces, see the routine
+// SetStackTraceTrimInternal()
+func StackTraceTrimInternal() bool {
+	return atomic.LoadInt32(&stackTraceTrimInternal) != 0
+}
+
+// SetStackTraceTrimInternal controls if the frames from inside the 'out' pkg
+// and the Go runtime and testing pkgs are left out of stack traces (for output
+// and for DetailedError's), which is the default.  With this on the frames
+// are picked out by their func names so the stack trace starts at the users
+// code no matter how many layers (eg: the users own wrappers around the 'out'
+// routines) are in between.  With it off the stack trace has all the frames
+// below the 'out' routine called, based on the call depth (see SetCallDepth()).
+// Note: the frames of the pkg's own tests (in _test.go files) are kept.
+func SetStackTraceTrimInternal(trim bool) {
+	val := int32(0)
+	if trim {
+		val = 1
+	}
+	atomic.StoreInt32(&stackTraceTrimInternal, val)
+}
+
+// internalFrame returns true if the stack frame is from the 'out' pkg (but
+// not its tests) or the Go runtime or testing pkgs
+func internalFrame(frame runtime.Frame) bool {
+	switch {
+	case strings.HasPrefix(frame.Function, "runtime."),
+		strings.HasPrefix(frame.Function, "testing."):
+		return true
+	case strings.HasPrefix(frame.Function, outPkgPrefix):
+		return !strings.HasSuffix(frame.File, "_test.go")
+	}
+	return false
+}
+
+// trimmedStackTrace returns the current goroutine's stack trace and frames
+// without the internal frames (see internalFrame()), the trace is in the same
+// form as the lazy stack traces are symbolized into (see SymbolizeStack())
+func trimmedStackTrace() (string, []StackFrame) {
+	pcs := make([]uintptr, lazyStackMaxDepth)
+	for {
+		n := runtime.Callers(1, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+	frames := callerFrames(pcs)
+	return formatStackFrames(strconv.FormatUint(goroutineID(), 10), frames), frames
+}
+
+// formatStackFrames returns a stack trace for the given goroutine id and
+// frames (see callerFrames()), eg:
+//
+//	goroutine 18 [running]:
+//	main.main(...)
+//		/path/to/main.go:12
+func formatStackFrames(gid string, frames []StackFrame) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "goroutine %s [running]:", gid)
+	for _, frame := range frames {
+		fmt.Fprintf(&buf, "\n%s(...)\n\t%s:%d", frame.Func, frame.File, frame.Line)
+	}
+	return buf.String()
+}
@@ -0,0 +1,70 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/stacktrim.go
+//   Checks the 'out' pkg, runtime and testing frames are left out of stack
+//   traces by default (even via an extra wrapper) and kept if turned off.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+// wrappedIssueln is like a users own wrapper around the 'out' routines
+func wrappedIssueln(msg string) {
+	Issueln(msg)
+}
+
+func TestStackTraceTrimInternal(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStackTraceConfig(ForScreen | StackTraceAllIssues)
+	wrappedIssueln("trimmed")
+	trimmed := screenBuf.String()
+	screenBuf.Reset()
+	detErr := NewErr("trimmed error")
+	SetLazyStackTrace(true)
+	wrappedIssueln("lazy")
+	SetLazyStackTrace(false)
+	lazy := screenBuf.String()
+	screenBuf.Reset()
+
+	SetStackTraceTrimInternal(false)
+	wrappedIssueln("untrimmed")
+	untrimmed := screenBuf.String()
+	trimOff := StackTraceTrimInternal()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.False(t, trimOff)
+	assert.True(t, StackTraceTrimInternal())
+	for _, output := range []string{trimmed, lazy} {
+		assert.Contains(t, output, "Issue: Stack Trace: goroutine ")
+		assert.Contains(t, output, "Issue: github.com/dvln/out.wrappedIssueln(...)\n")
+		assert.Contains(t, output, "Issue: github.com/dvln/out.TestStackTraceTrimInternal(...)\n")
+		assert.NotContains(t, output, "out.Issueln")
+		assert.NotContains(t, output, "out.stackTrace")
+		assert.NotContains(t, output, "testing.tRunner")
+		assert.NotContains(t, output, "runtime.")
+	}
+	assert.Contains(t, detErr.Stack(), "out.TestStackTraceTrimInternal(...)")
+	assert.NotContains(t, detErr.Stack(), "out.NewErr")
+	assert.Contains(t, untrimmed, "out.wrappedIssueln(")
+	assert.Contains(t, untrimmed, "testing.tRunner(")
+}
@@ -0,0 +1,36 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import "log"
+
+// StdLogger returns a standard library *log.Logger that writes at the given
+// level, for the many packages that take one, eg:
+//
+//	srv := &http.Server{Addr: ":8080", ErrorLog: out.StdLogger(out.LevelError)}
+//
+// The logger's writer is the level's io.Writer (see LevelWriter()) and its
+// flags are 0 with no prefix so this pkg's own prefixes, flags, thresholds
+// and such own the formatting.  The log pkg adds a newline to any message
+// missing one and makes one Write() call per message, so each message is
+// treated as a complete line by the newline tracking done when writing the
+// output (the next message gets its prefix and flags as usual).  Note that
+// calling Fatal() on the logger exits via os.Exit() directly (and Panic()
+// panics) as the log pkg always does, writes at LevelFatal only exit via
+// this pkg if SetWriterExitsOnFatal(true) is set.  The log pkg adds a call
+// frame so the Lshortfile and such flags show the log pkg's file and func,
+// not the callers (use the pkg's own output routines if that matters).
+func StdLogger(level Level) *log.Logger {
+	return log.New(LevelWriter(level), "", 0)
+}
@@ -0,0 +1,50 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/stdlog.go
+//   Checks a *log.Logger from StdLogger() writes at the given level with this
+//   pkg's prefixes and newline handling.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetThreshold(LevelInfo, ForScreen)
+
+	errLogger := StdLogger(LevelError)
+	errLogger.Printf("http: TLS handshake error from %s", "10.0.0.1")
+	errLogger.Println("second error")
+	StdLogger(LevelDebug).Print("below the threshold")
+	StdLogger(LevelWarn).Print("multi\nline")
+	flags := errLogger.Flags()
+	prefix := errLogger.Prefix()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 0, flags)
+	assert.Equal(t, "", prefix)
+	assert.Equal(t, "Error: http: TLS handshake error from 10.0.0.1\n"+
+		"Error: second error\n"+
+		"Warning: multi\n"+
+		"Warning: line\n", screenBuf.String())
+}
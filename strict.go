@@ -0,0 +1,180 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Strict mode states, see SetStrictMode()
+const (
+	strictOff       int32 = iota // output goes straight out (the default)
+	strictBuffering              // output is held until the pkg is configured
+	strictReplaying              // held output is being sent out
+)
+
+// StrictModeBufferMax is the most messages strict mode will hold on to while
+// waiting for the pkg to be configured, if more come in the oldest ones are
+// dropped (and a note about that is added when the messages are replayed)
+const StrictModeBufferMax = 1000
+
+// heldOutput is a message held back in strict mode, meta has the time, level
+// and caller info from when the message was originally emitted
+type heldOutput struct {
+	level Level
+	tgt   int // the output target(s) the message was sent to
+	msg   string
+	meta  FlagMetadata
+}
+
+var (
+	// strictState is one of strictOff, strictBuffering or strictReplaying
+	strictState int32
+
+	// strictMu protects heldOutputs and heldDropped and is held for the
+	// full replay so that new output waits for older held output to go out
+	strictMu sync.Mutex
+
+	// heldOutputs are the messages held back in strict mode, oldest first
+	heldOutputs []*heldOutput
+
+	// heldDropped counts the messages dropped when heldOutputs was full
+	heldDropped int
+
+	// strictReplays maps the goroutine id doing a replay to the message it
+	// is currently replaying so the original time and caller info is used
+	strictReplays sync.Map
+)
+
+// StrictMode returns true if strict mode is on and output is being held
+// back as the pkg hasn't been configured yet, see SetStrictMode()
+func StrictMode() bool {
+	return atomic.LoadInt32(&strictState) == strictBuffering
+}
+
+// SetStrictMode is for larger apps where code may emit output during init,
+// before the app has gotten around to configuring this pkg (eg: before the
+// log file has been set), normally such output just goes to the default
+// targets (the screen).  With strict mode on output is instead held back
+// (in memory, up to StrictModeBufferMax messages) until the next config call
+// of SetWriter(), SetOutput(), SetLogFile() or SetThreshold(), at that point
+// the held messages are replayed in order to the newly configured targets
+// (honoring the thresholds at that time) with their original levels, times
+// and file/line# info.  So, early in main() (or in an init() func):
+//   out.SetStrictMode(true)
+//   ...
+//   out.SetLogFile(logPath)     // held output is replayed from here
+// If the pkg is never configured the held output is sent to the current
+// targets (ie: the screen) if the tool exits via this pkg (eg: out.Exit()
+// or any Fatal), or when FlushStrictMode() is called (eg: via a defer in
+// main()).  Setting strict mode off also sends out any held output.
+func SetStrictMode(on bool) {
+	if on {
+		atomic.CompareAndSwapInt32(&strictState, strictOff, strictBuffering)
+		return
+	}
+	FlushStrictMode()
+}
+
+// FlushStrictMode sends out any output held back by strict mode to the
+// current screen and logfile targets and turns strict mode off, it does
+// nothing if strict mode isn't on
+func FlushStrictMode() {
+	if atomic.LoadInt32(&strictState) != strictBuffering {
+		return
+	}
+	strictMu.Lock()
+	defer strictMu.Unlock()
+	if !atomic.CompareAndSwapInt32(&strictState, strictBuffering, strictReplaying) {
+		return
+	}
+	gid := goroutineID()
+	if heldDropped != 0 && len(heldOutputs) != 0 {
+		note := &heldOutput{level: LevelNote, tgt: ForBoth, meta: heldOutputs[0].meta}
+		note.msg = fmt.Sprintf("Strict mode held too much early output, dropped the oldest %d message(s)\n", heldDropped)
+		note.meta.Level = LevelNote.String()
+		note.meta.Stack = ""
+		note.meta.Frames = nil
+		heldOutputs = append([]*heldOutput{note}, heldOutputs...)
+	}
+	for _, held := range heldOutputs {
+		strictReplays.Store(gid, held)
+		o := LevelWriter(held.level)
+		if _, err := o.stringOutput(held.msg, false, 0, held.tgt, held.meta.Category, held.meta.Fields, 0); err != nil {
+			strictReplays.Delete(gid)
+			heldOutputs = nil
+			heldDropped = 0
+			atomic.StoreInt32(&strictState, strictOff)
+			outputFailed(err)
+			return
+		}
+	}
+	strictReplays.Delete(gid)
+	heldOutputs = nil
+	heldDropped = 0
+	atomic.StoreInt32(&strictState, strictOff)
+}
+
+// strictConfigured is called by the pkg config routines to indicate that
+// the client has configured the pkg, any held output is replayed
+func strictConfigured() {
+	if atomic.LoadInt32(&strictState) == strictBuffering {
+		FlushStrictMode()
+	}
+}
+
+// holdStrictOutput holds back the given message if strict mode is buffering,
+// returning true if it did so.  If the message is a dying one then all held
+// output is sent out first and false is returned so the message goes out
+// too.  The depth is relative to the caller of this routine (for file/line#).
+func holdStrictOutput(level Level, msg string, tgt int, mmeta *msgMetadata, fields map[string]interface{}, stack string, dying bool, depth int) bool {
+	if mmeta.replay != nil {
+		return false // we're the replayer, let it through
+	}
+	if dying {
+		FlushStrictMode()
+		return false
+	}
+	meta := mmeta.flagMetadata(level, depth+1)
+	meta.Stack = stack
+	meta.GoID = mmeta.goroutineID() // so Lgoid shows the original goroutine
+	meta.Fields = mergeFields(fields, meta.Fields)
+	strictMu.Lock()
+	defer strictMu.Unlock()
+	// If a replay was under way we waited for it above, check if still on
+	if atomic.LoadInt32(&strictState) != strictBuffering {
+		return false
+	}
+	if len(heldOutputs) >= StrictModeBufferMax {
+		heldOutputs = heldOutputs[1:]
+		heldDropped++
+	}
+	heldOutputs = append(heldOutputs, &heldOutput{level: level, tgt: tgt, msg: msg, meta: meta})
+	return true
+}
+
+// strictReplay returns the held output being replayed by the calling
+// goroutine (by strict mode or ReplayTo()), nil if it isn't replaying
+// anything (the common case)
+func strictReplay() *heldOutput {
+	if atomic.LoadInt32(&strictState) != strictReplaying && atomic.LoadInt32(&activeReplays) == 0 {
+		return nil
+	}
+	if held, ok := strictReplays.Load(goroutineID()); ok {
+		return held.(*heldOutput)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/strict.go
+//   Checks that strict mode holds output until the pkg is configured and
+//   then replays it in order with the original levels and times.
+
+package out
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+// timeCapture is a Formatter that records the time in the metadata of each
+// message formatted and leaves the message itself alone
+type timeCapture struct {
+	times []time.Time
+	files []string
+}
+
+func (f *timeCapture) FormatMessage(msg string, outLevel Level, code int, dying bool, mdata FlagMetadata) (string, int, int, bool) {
+	f.times = append(f.times, *mdata.Time)
+	f.files = append(f.files, mdata.File)
+	return msg, ForBoth, 0, false
+}
+
+func TestStrictMode(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelDebug, ForLogfile)
+	capture := &timeCapture{}
+	SetFormatter(LevelAll, capture)
+
+	SetStrictMode(true)
+	assert.True(t, StrictMode())
+	before := time.Now()
+	Println("early info")
+	Debugln("early debug")
+	Issueln("early issue")
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, "", screenBuf.String())
+	assert.Equal(t, 0, len(capture.times))
+
+	// configuring the logfile replays the held output (as does the screen
+	// threshold set below, but by then there is nothing held anymore)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	assert.False(t, StrictMode())
+	SetThreshold(LevelDebug, ForScreen)
+	Println("late info")
+	times := capture.times
+	files := capture.files
+	SetStrictMode(false)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "early info\nIssue: early issue\nlate info\n", screenBuf.String())
+	assert.Equal(t, "early info\nDebug: early debug\nIssue: early issue\nlate info\n", logBuf.String())
+	if assert.Equal(t, 4, len(times)) {
+		for i := 0; i < 3; i++ {
+			assert.True(t, times[i].Sub(before) < 5*time.Millisecond, "replayed time should be the original time")
+			assert.Equal(t, "strict_test.go", files[i])
+		}
+		assert.True(t, times[3].Sub(before) >= 5*time.Millisecond)
+	}
+}
+
+func TestStrictModeBounded(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetStrictMode(true)
+	for i := 0; i < StrictModeBufferMax+2; i++ {
+		Printf("msg %d\n", i)
+	}
+	FlushStrictMode()
+	assert.False(t, StrictMode())
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	lines := strings.Split(strings.TrimSuffix(screenBuf.String(), "\n"), "\n")
+	if assert.Equal(t, StrictModeBufferMax+1, len(lines)) {
+		assert.Equal(t, "Note: Strict mode held too much early output, dropped the oldest 2 message(s)", lines[0])
+		assert.Equal(t, "msg 2", lines[1])
+		assert.Equal(t, "msg 1001", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9
+
+package out
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// syslogWriter is the io.Writer for one output level that sends each write
+// to syslog at the priority mapped from that level, each level gets its own
+// so the priorities are right (they all share the one syslog connection)
+type syslogWriter struct {
+	w     *syslog.Writer
+	level Level
+}
+
+// SetSyslog points the output stream(s) for every level, outputTgt being
+// ForLogfile, ForScreen or ForBoth, at syslog.  The network and addr are as
+// for syslog.Dial(), eg: "udp" and "loghost:514", or empty strings for the
+// local syslog daemon, and the tag is the tag for the messages (empty means
+// the tool name).  The level sets each message's priority, eg: LevelError
+// output is sent as LOG_ERR, see syslogPriority().  As syslog records its
+// own timestamp, host and such the flags are cleared for the target(s) for
+// all levels (use SetFlags() after this if you want some of them back).  An
+// error is returned, and the output left alone, if syslog can't be reached.
+// Note: as with SetLogFile() remember to set a logfile threshold so that
+// something is actually logged, eg: SetThreshold(LevelInfo, ForLogfile)
+func SetSyslog(network, addr, tag string, outputTgt int) error {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to syslog: %v", err)
+	}
+	for _, o := range outputters {
+		o.mu.Lock()
+		sw := &syslogWriter{w: w, level: o.level}
+		if outputTgt&ForScreen != 0 {
+			o.screenHndl = sw
+			o.screenFlags = 0
+		}
+		if outputTgt&ForLogfile != 0 {
+			o.logfileHndl = sw
+			o.logFlags = 0
+		}
+		o.mu.Unlock()
+	}
+	return nil
+}
+
+// syslogPriority maps an output level to the syslog severity to send it at
+func syslogPriority(level Level) syslog.Priority {
+	level = level.Severity()
+	switch {
+	case level <= LevelDebug:
+		return syslog.LOG_DEBUG
+	case level <= LevelInfo:
+		return syslog.LOG_INFO
+	case level == LevelNote:
+		return syslog.LOG_NOTICE
+	case level == LevelWarn || level == LevelIssue:
+		return syslog.LOG_WARNING
+	case level == LevelError:
+		return syslog.LOG_ERR
+	default:
+		return syslog.LOG_CRIT
+	}
+}
+
+// Write sends the output to syslog as one message at the level's priority,
+// whitespace only writes (eg: the newline added on dying) are dropped
+func (sw *syslogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if strings.TrimSpace(msg) == "" {
+		return len(p), nil
+	}
+	var err error
+	switch syslogPriority(sw.level) {
+	case syslog.LOG_DEBUG:
+		err = sw.w.Debug(msg)
+	case syslog.LOG_INFO:
+		err = sw.w.Info(msg)
+	case syslog.LOG_NOTICE:
+		err = sw.w.Notice(msg)
+	case syslog.LOG_WARNING:
+		err = sw.w.Warning(msg)
+	case syslog.LOG_ERR:
+		err = sw.w.Err(msg)
+	default:
+		err = sw.w.Crit(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
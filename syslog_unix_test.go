@@ -0,0 +1,76 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9
+
+// Package test for: out/syslog_unix.go
+//   Checks each level is sent to syslog at its own priority by pointing
+//   SetSyslog() at a fake syslog server of our own.
+
+package out
+
+import (
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetSyslog(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to create a udp socket: %v", err)
+	}
+	defer server.Close()
+	if err = SetSyslog("udp", server.LocalAddr().String(), "mytool", ForLogfile); err != nil {
+		t.Fatalf("Failed to set up syslog output: %v", err)
+	}
+	SetThreshold(LevelInfo, ForLogfile)
+	SetThreshold(LevelDiscard, ForScreen)
+
+	Errorln("disk failed")
+	Noteln("disk replaced")
+	Debugln("below the threshold")
+
+	var msgs []string
+	buf := make([]byte, 4096)
+	for i := 0; i < 2; i++ {
+		server.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		msgs = append(msgs, string(buf[:n]))
+	}
+	badErr := SetSyslog("udp", "no such host:", "mytool", ForLogfile)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	if assert.Len(t, msgs, 2) {
+		// the priority is the LOG_USER facility (1) * 8 + the severity
+		assert.True(t, strings.HasPrefix(msgs[0], "<11>"), "LOG_ERR priority: %q", msgs[0])
+		assert.Contains(t, msgs[0], "mytool[")
+		assert.True(t, strings.HasSuffix(msgs[0], ": Error: disk failed\n"), "message: %q", msgs[0])
+		assert.True(t, strings.HasPrefix(msgs[1], "<13>"), "LOG_NOTICE priority: %q", msgs[1])
+		assert.True(t, strings.HasSuffix(msgs[1], ": Note: disk replaced\n"), "message: %q", msgs[1])
+	}
+	assert.Error(t, badErr)
+	assert.Equal(t, syslog.LOG_DEBUG, syslogPriority(LevelTrace))
+	assert.Equal(t, syslog.LOG_WARNING, syslogPriority(LevelIssue))
+	assert.Equal(t, syslog.LOG_CRIT, syslogPriority(LevelFatal))
+}
@@ -0,0 +1,59 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// unwrapWriter returns the writer underneath any wrappers that have an
+// Unwrap() io.Writer method (eg: the shared writers of a child Outputter,
+// see Child()) so the terminal checks see the real *os.File
+func unwrapWriter(w io.Writer) io.Writer {
+	for {
+		u, ok := w.(interface{ Unwrap() io.Writer })
+		if !ok {
+			return w
+		}
+		w = u.Unwrap()
+	}
+}
+
+// isTerminal returns true if the given writer is (or wraps, see
+// unwrapWriter()) an *os.File that is open on a terminal (vs a pipe, regular
+// file, buffer or other device like /dev/null), see isTTY()
+func isTerminal(w io.Writer) bool {
+	f, ok := unwrapWriter(w).(*os.File)
+	if !ok {
+		return false
+	}
+	return isTTY(f)
+}
+
+// terminalWidth returns the width (in columns) of the terminal the given
+// writer is on, if it isn't a terminal or the width can't be found then the
+// COLUMNS env var is tried, 0 is returned if the width is still unknown
+func terminalWidth(w io.Writer) int {
+	if isTerminal(w) {
+		if width := ttyWidth(unwrapWriter(w).(*os.File)); width > 0 {
+			return width
+		}
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTTY returns true if the file is a console (the NUL device and
+// redirected output aren't)
+func isTTY(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
+
+// ttyWidth isn't supported here, 0 (unknown) is always returned so callers
+// fall back to the COLUMNS env var or a default width
+func ttyWidth(f *os.File) int {
+	return 0
+}
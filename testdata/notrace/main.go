@@ -0,0 +1,25 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is built by TestNotraceBinarySize (see tracedebug_test.go) with and
+// without the notrace build tag to check the debug strings are dropped
+package main
+
+import "github.com/dvln/out"
+
+func main() {
+	out.Debugf("notrace-marker: debug detail %d\n", 1)
+	out.Traceln("notrace-marker: trace detail")
+	out.DebuglnTo(out.ForLogfile, "notrace-marker: logfile only detail")
+	out.Println("regular output")
+}
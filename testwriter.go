@@ -0,0 +1,95 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"io"
+	"strings"
+)
+
+// TestLogger is the part of testing.TB (ie: *testing.T or *testing.B) that
+// is needed to send output to a test's log, using it here avoids pulling the
+// testing pkg into this pkg
+type TestLogger interface {
+	Log(args ...interface{})
+}
+
+// testWriter is the io.Writer returned by TestWriter()
+type testWriter struct {
+	tb TestLogger
+}
+
+// Write satisfies the io.Writer interface, each write becomes one tb.Log()
+// call with any trailing newline removed (tb.Log() adds its own)
+func (w testWriter) Write(p []byte) (int, error) {
+	w.tb.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// TestWriter returns an io.Writer that sends output to the given test's log
+// (ie: via t.Log()) so, when running "go test", the output is tied to the
+// test that produced it and only shown if the test fails (or with -v), eg:
+//   out.SetWriter(out.LevelAll, out.TestWriter(t), out.ForScreen)
+// Each write is logged separately so output done in pieces without a newline
+// (eg: out.Print("a"); out.Println("b")) shows up as multiple log lines.
+func TestWriter(tb TestLogger) io.Writer {
+	return testWriter{tb: tb}
+}
+
+// testCleaner is the Cleanup() part of testing.TB, used by SetTestWriter()
+// to put the writers back when the test ends if the TestLogger has it
+type testCleaner interface {
+	Cleanup(func())
+}
+
+// SetTestWriter sends the output for all levels to the given test's log (see
+// TestWriter()) for the screen and/or logfile targets (outputTgt of
+// ForScreen, ForLogfile or ForBoth) so, when running "go test", the output
+// is tied to the right (sub)test and only shown if it fails, eg:
+//
+//	func TestSomething(t *testing.T) {
+//		out.SetTestWriter(t, out.ForScreen)
+//		...
+//
+// If tb has a Cleanup() method (a *testing.T or *testing.B does) the
+// writers that were in place are put back when the test ends.  Note that
+// t.Log() adds its own file:line# (that of this pkg's writer), so turn the
+// Lshortfile and Llongfile flags off for the target(s) if they're on, eg:
+// out.SetFlags(out.LevelAll, 0, out.ForLogfile), to avoid a second location.
+func SetTestWriter(tb TestLogger, outputTgt int) {
+	if cleaner, ok := tb.(testCleaner); ok {
+		type handles struct {
+			screen, logfile io.Writer
+		}
+		orig := make(map[*LvlOutput]handles, len(std.outputters))
+		for _, o := range std.outputters {
+			o.mu.RLock()
+			orig[o] = handles{screen: o.screenHndl, logfile: o.logfileHndl}
+			o.mu.RUnlock()
+		}
+		cleaner.Cleanup(func() {
+			for o, h := range orig {
+				o.mu.Lock()
+				if outputTgt&ForScreen != 0 {
+					o.screenHndl = h.screen
+				}
+				if outputTgt&ForLogfile != 0 {
+					o.logfileHndl = h.logfile
+				}
+				o.mu.Unlock()
+			}
+		})
+	}
+	SetWriter(LevelAll, TestWriter(tb), outputTgt)
+}
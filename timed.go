@@ -0,0 +1,45 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"time"
+)
+
+// Timed starts timing something and returns a func that, when called, outputs
+// "<label> took <duration>" at the given level, the duration being the time
+// since Timed() was called (as time.Duration's String() gives it), eg: to time
+// a func:
+//
+//	func fetch() {
+//		defer out.Timed(out.LevelDebug, "fetch")()
+//		...
+//	}
+//
+// which outputs something like "Debug: fetch took 1.503s".  If the level is
+// below the screen and logfile thresholds when the func is called nothing is
+// output (and the message isn't built), LevelDiscard never outputs anything.
+func Timed(level Level, label string) func() {
+	start := time.Now()
+	if levelCheck(level) == LevelDiscard {
+		return func() {}
+	}
+	o := LevelWriter(level)
+	return func() {
+		if o.skipQuietOutput(false) {
+			return
+		}
+		o.outputRaw(false, 0, ForBoth, label+" took "+time.Since(start).String()+"\n")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/timed.go
+//   Checks Timed() outputs the elapsed time at the level given when its func
+//   is called, and nothing if the level is below the thresholds.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dvln/testify/assert"
+)
+
+// timedWork sleeps a bit, timing itself at the given level
+func timedWork(level Level) {
+	defer Timed(level, "work")()
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestTimed(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Lshortfile, ForScreen)
+	timedWork(LevelInfo)
+	infoOutput := screenBuf.String()
+	screenBuf.Reset()
+	SetFlags(LevelAll, 0, ForScreen)
+	timedWork(LevelDebug)
+	timedWork(LevelDiscard)
+	suppressedOutput := screenBuf.String()
+	SetThreshold(LevelDebug, ForScreen)
+	timedWork(LevelDebug)
+	debugOutput := screenBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Regexp(t, `^timed_test\.go:\d+ *: work took \d`, infoOutput)
+	assert.Regexp(t, `took .*s\n$`, infoOutput)
+	assert.Equal(t, "", suppressedOutput)
+	assert.Regexp(t, `^Debug: work took .*s\n$`, debugOutput)
+	if d, err := time.ParseDuration(debugOutput[len("Debug: work took ") : len(debugOutput)-1]); assert.Nil(t, err) {
+		assert.True(t, d >= 5*time.Millisecond)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !notrace
+
+package out
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// The Trace and Debug output routines are here so that building with the
+// notrace build tag can swap in empty versions, see tracedebug_notrace.go
+
+// traceEnabled is false when Trace and Debug output is compiled out (notrace)
+const traceEnabled = true
+
+// Trace is the most verbose debug level, space separate opts with no newline
+// added and is by default prefixed with "Trace: <date/time> <msg>" for each
+// line but you can use flags and remove the timestamp, can also drop the prefix
+func Trace(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	TRACE.output(terminate, exitVal, ForBoth, v...)
+}
+
+// Debug is meant for basic debugging, space separate opts with no newline added
+// and is, by default, prefixed with "Debug: <date/time> <your msg>" for each
+// line but you can use flags and remove the timestamp, can also drop the prefix
+func Debug(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	DEBUG.output(terminate, exitVal, ForBoth, v...)
+}
+
+// Traceln is the most verbose debug level, space separate opts with newline
+// added and is, by default, prefixed with "Trace: <your output>" for each line
+// but you can use flags and remove the timestamp, can also drop the prefix
+func Traceln(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	TRACE.outputln(terminate, exitVal, ForBoth, v...)
+}
+
+// Debugln is meant for basic debugging, space separate opts with newline added
+// and is, by default, prefixed with "Debug: <date/time> <yourmsg>" for each
+// line but you can use flags and remove the timestamp, can also drop the prefix
+func Debugln(v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	DEBUG.outputln(terminate, exitVal, ForBoth, v...)
+}
+
+// Tracef is the most verbose debug level, format string followed by args and
+// output is, by default, prefixed with "Trace: <date/time> <your msg>" for each
+// line but you can use flags and remove the timestamp, can also drop the prefix
+func Tracef(format string, v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	TRACE.outputf(terminate, exitVal, ForBoth, format, v...)
+}
+
+// Debugf is meant for basic debugging, format string followed by args and
+// output is by default prefixed with "Debug: <date/time> <your msg>" for each
+// line but you can use flags and remove the timestamp, can also drop the prefix
+func Debugf(format string, v ...interface{}) {
+	mutex.Lock()
+	terminate := false
+	exitVal := 0
+	mutex.Unlock()
+	DEBUG.outputf(terminate, exitVal, ForBoth, format, v...)
+}
+
+// TraceTo is the same as Trace() but only sends the trace output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func TraceTo(outputTgt int, v ...interface{}) {
+	TRACE.output(false, 0, outputTgt, v...)
+}
+
+// TracelnTo is the same as Traceln() but only sends the trace output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func TracelnTo(outputTgt int, v ...interface{}) {
+	TRACE.outputln(false, 0, outputTgt, v...)
+}
+
+// TracefTo is the same as Tracef() but only sends the trace output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func TracefTo(outputTgt int, format string, v ...interface{}) {
+	TRACE.outputf(false, 0, outputTgt, format, v...)
+}
+
+// DebugTo is the same as Debug() but only sends the debug output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func DebugTo(outputTgt int, v ...interface{}) {
+	DEBUG.output(false, 0, outputTgt, v...)
+}
+
+// DebuglnTo is the same as Debugln() but only sends the debug output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func DebuglnTo(outputTgt int, v ...interface{}) {
+	DEBUG.outputln(false, 0, outputTgt, v...)
+}
+
+// DebugfTo is the same as Debugf() but only sends the debug output to the
+// given output target(s), ie: ForScreen or ForLogfile
+func DebugfTo(outputTgt int, format string, v ...interface{}) {
+	DEBUG.outputf(false, 0, outputTgt, format, v...)
+}
+
+// TraceCat is like Trace() with the output tagged with the given category,
+// see PrintCat()
+func TraceCat(category string, v ...interface{}) {
+	TRACE.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// TraceCatf is like Tracef() with the output tagged with the given category,
+// see PrintCat()
+func TraceCatf(category string, format string, v ...interface{}) {
+	TRACE.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// DebugCat is like Debug() with the output tagged with the given category,
+// see PrintCat()
+func DebugCat(category string, v ...interface{}) {
+	DEBUG.catOutput(false, 0, category, fmt.Sprint(renderArgs(v)...), v)
+}
+
+// DebugCatf is like Debugf() with the output tagged with the given category,
+// see PrintCat()
+func DebugCatf(category string, format string, v ...interface{}) {
+	DEBUG.catOutput(false, 0, category, fmt.Sprintf(format, v...), v)
+}
+
+// Tracek is like Trace() but the message comes from calling fn, which is
+// only called if trace output isn't below the screen and logfile output
+// thresholds, eg: out.Tracek(func() string { return spew.Sdump(bigStruct) })
+func Tracek(fn func() string) {
+	TRACE.output(false, 0, ForBoth, lazyString(fn))
+}
+
+// Debugk is like Debug() but the message comes from calling fn, which is
+// only called if debug output isn't below the screen and logfile output
+// thresholds, see Tracek()
+func Debugk(fn func() string) {
+	DEBUG.output(false, 0, ForBoth, lazyString(fn))
+}
+
+// TraceHex outputs a hex dump of the data at the trace level, like hexdump -C
+// does it (the offset, 16 bytes in hex and then as ASCII) after a line with
+// the label and the length, eg: out.TraceHex("packet", buf) gives:
+//
+//	Trace: packet (20 bytes):
+//	Trace: 00000000  47 45 54 20 2f 20 48 54  54 50 2f 31 2e 31 0d 0a  |GET / HTTP/1.1..|
+//	Trace: 00000010  48 6f 73 74                                       |Host|
+//
+// The dump is only built if trace output isn't below the screen and logfile
+// output thresholds, see Tracek()
+func TraceHex(label string, data []byte) {
+	TRACE.output(false, 0, ForBoth, lazyString(func() string { return hexDump(label, data) }))
+}
+
+// DebugHex is like TraceHex() but outputs the hex dump at the debug level
+func DebugHex(label string, data []byte) {
+	DEBUG.output(false, 0, ForBoth, lazyString(func() string { return hexDump(label, data) }))
+}
+
+// hexDump returns the label and length line followed by the hex dump of the
+// data, see TraceHex()
+func hexDump(label string, data []byte) string {
+	return fmt.Sprintf("%s (%d bytes):\n%s", label, len(data), hex.Dump(data))
+}
+
+// Trace is like the pkg Trace() but for this Outputter
+func (op *Outputter) Trace(v ...interface{}) {
+	op.outputters[LevelTrace].output(false, 0, ForBoth, v...)
+}
+
+// Debug is like the pkg Debug() but for this Outputter
+func (op *Outputter) Debug(v ...interface{}) {
+	op.outputters[LevelDebug].output(false, 0, ForBoth, v...)
+}
+
+// Traceln is like the pkg Traceln() but for this Outputter
+func (op *Outputter) Traceln(v ...interface{}) {
+	op.outputters[LevelTrace].outputln(false, 0, ForBoth, v...)
+}
+
+// Debugln is like the pkg Debugln() but for this Outputter
+func (op *Outputter) Debugln(v ...interface{}) {
+	op.outputters[LevelDebug].outputln(false, 0, ForBoth, v...)
+}
+
+// Tracef is like the pkg Tracef() but for this Outputter
+func (op *Outputter) Tracef(format string, v ...interface{}) {
+	op.outputters[LevelTrace].outputf(false, 0, ForBoth, format, v...)
+}
+
+// Debugf is like the pkg Debugf() but for this Outputter
+func (op *Outputter) Debugf(format string, v ...interface{}) {
+	op.outputters[LevelDebug].outputf(false, 0, ForBoth, format, v...)
+}
@@ -0,0 +1,106 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build notrace
+
+package out
+
+// Building with the notrace build tag (eg: go build -tags notrace) replaces
+// the Trace and Debug output routines with these empty versions, they are
+// inlined away to nothing so the linker can drop the format strings and such
+// given to them at the call sites (the args are still evaluated if they're
+// not constants, eg: a func call in the args is still made).  This is for
+// embedded or size sensitive targets, see tracedebug.go for the real ones.
+// Note that output via the TRACE and DEBUG io.Writers, LevelWriter(), an
+// Entry or at those levels via EmitRaw() and such still works.
+
+// traceEnabled is false when Trace and Debug output is compiled out (notrace)
+const traceEnabled = false
+
+// Trace does nothing, trace and debug output is compiled out (notrace)
+func Trace(v ...interface{}) {}
+
+// Debug does nothing, trace and debug output is compiled out (notrace)
+func Debug(v ...interface{}) {}
+
+// Traceln does nothing, trace and debug output is compiled out (notrace)
+func Traceln(v ...interface{}) {}
+
+// Debugln does nothing, trace and debug output is compiled out (notrace)
+func Debugln(v ...interface{}) {}
+
+// Tracef does nothing, trace and debug output is compiled out (notrace)
+func Tracef(format string, v ...interface{}) {}
+
+// Debugf does nothing, trace and debug output is compiled out (notrace)
+func Debugf(format string, v ...interface{}) {}
+
+// TraceTo does nothing, trace and debug output is compiled out (notrace)
+func TraceTo(outputTgt int, v ...interface{}) {}
+
+// TracelnTo does nothing, trace and debug output is compiled out (notrace)
+func TracelnTo(outputTgt int, v ...interface{}) {}
+
+// TracefTo does nothing, trace and debug output is compiled out (notrace)
+func TracefTo(outputTgt int, format string, v ...interface{}) {}
+
+// DebugTo does nothing, trace and debug output is compiled out (notrace)
+func DebugTo(outputTgt int, v ...interface{}) {}
+
+// DebuglnTo does nothing, trace and debug output is compiled out (notrace)
+func DebuglnTo(outputTgt int, v ...interface{}) {}
+
+// DebugfTo does nothing, trace and debug output is compiled out (notrace)
+func DebugfTo(outputTgt int, format string, v ...interface{}) {}
+
+// TraceCat does nothing, trace and debug output is compiled out (notrace)
+func TraceCat(category string, v ...interface{}) {}
+
+// TraceCatf does nothing, trace and debug output is compiled out (notrace)
+func TraceCatf(category string, format string, v ...interface{}) {}
+
+// DebugCat does nothing, trace and debug output is compiled out (notrace)
+func DebugCat(category string, v ...interface{}) {}
+
+// DebugCatf does nothing, trace and debug output is compiled out (notrace)
+func DebugCatf(category string, format string, v ...interface{}) {}
+
+// Tracek does nothing, trace and debug output is compiled out (notrace)
+func Tracek(fn func() string) {}
+
+// Debugk does nothing, trace and debug output is compiled out (notrace)
+func Debugk(fn func() string) {}
+
+// TraceHex does nothing, trace and debug output is compiled out (notrace)
+func TraceHex(label string, data []byte) {}
+
+// DebugHex does nothing, trace and debug output is compiled out (notrace)
+func DebugHex(label string, data []byte) {}
+
+// Trace does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Trace(v ...interface{}) {}
+
+// Debug does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Debug(v ...interface{}) {}
+
+// Traceln does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Traceln(v ...interface{}) {}
+
+// Debugln does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Debugln(v ...interface{}) {}
+
+// Tracef does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Tracef(format string, v ...interface{}) {}
+
+// Debugf does nothing, trace and debug output is compiled out (notrace)
+func (op *Outputter) Debugf(format string, v ...interface{}) {}
@@ -0,0 +1,203 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/tracedebug.go
+//   Builds a small program (testdata/notrace) with and without the notrace
+//   build tag and checks the trace/debug strings are only in the normal one
+//   and that the notrace binary is smaller.  Also checks the lazy Tracek()
+//   style routines only build the message when it will be output and the
+//   TraceHex()/DebugHex() output against a golden file.  The package tests
+//   are also run under the notrace build tag.
+
+package out
+
+import (
+	"bytes"
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestNotraceBinarySize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the notrace build test in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("skipping the notrace build test, no go tool found")
+	}
+	dir, err := ioutil.TempDir("", "notrace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	build := func(name string, tags ...string) []byte {
+		bin := filepath.Join(dir, name)
+		args := append([]string{"build", "-o", bin}, tags...)
+		cmd := exec.Command(goTool, append(args, "./testdata/notrace")...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("skipping the notrace build test, unable to build: %v\n%s", err, output)
+		}
+		contents, err := ioutil.ReadFile(bin)
+		assert.Nil(t, err)
+		return contents
+	}
+	normal := build("normal")
+	notrace := build("notrace", "-tags", "notrace")
+
+	marker := []byte("notrace-marker")
+	assert.True(t, bytes.Contains(normal, marker))
+	assert.False(t, bytes.Contains(notrace, marker))
+	assert.True(t, codeSize(notrace) < codeSize(normal), "notrace: %d bytes, normal: %d bytes", codeSize(notrace), codeSize(normal))
+}
+
+// TestNotraceSuite runs the package tests again built with the notrace tag so
+// a test that depends on Trace() or Debug() output without checking
+// traceEnabled is caught by a plain "go test" run
+func TestNotraceSuite(t *testing.T) {
+	if testing.Short() || !traceEnabled {
+		t.Skip("skipping the notrace test suite run in short mode or a notrace build")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("skipping the notrace test suite run, no go tool found")
+	}
+	cmd := exec.Command(goTool, "test", "-short", "-tags", "notrace", ".")
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, "go test -tags notrace failed:\n%s", output)
+}
+
+// codeSize returns the size of the code and read-only data in the binary for
+// comparing builds, for ELF binaries just those sections are counted as the
+// (compressed) debug info and section alignment can swamp the few hundred
+// bytes the notrace build saves, for other formats it's the file size
+func codeSize(bin []byte) int {
+	f, err := elf.NewFile(bytes.NewReader(bin))
+	if err != nil {
+		return len(bin)
+	}
+	size := 0
+	for _, name := range []string{".text", ".rodata", ".gopclntab"} {
+		if section := f.Section(name); section != nil {
+			size += int(section.Size)
+		}
+	}
+	return size
+}
+
+func TestLazyOutput(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, ioutil.Discard, ForLogfile)
+	SetFlags(LevelAll, 0, ForScreen)
+	calls := 0
+	msg := func(s string) func() string {
+		return func() string { calls++; return s }
+	}
+	Tracek(msg("trace\n"))
+	Debugk(msg("debug\n"))
+	Verbosek(msg("verbose\n"))
+	suppressedCalls := calls
+	SetThreshold(LevelTrace, ForScreen)
+	Tracek(msg("trace\n"))
+	Debugk(msg("debug\n"))
+	Verbosek(msg("verbose\n"))
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, 0, suppressedCalls, "fn is not called when the level is suppressed")
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, "Trace: trace\nDebug: debug\nverbose\n", screenBuf.String())
+}
+
+func TestHexDump(t *testing.T) {
+	skipWithoutTrace(t)
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, ioutil.Discard, ForLogfile)
+	SetFlags(LevelAll, 0, ForScreen)
+	data := []byte("GET /index.html HTTP/1.1\r\nHost: example.com\r\n\x00\x01\xff")
+	TraceHex("suppressed", data)
+	DebugHex("suppressed", data)
+	suppressed := screenBuf.String()
+	SetThreshold(LevelTrace, ForScreen)
+	TraceHex("request", data)
+	DebugHex("empty", nil)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, "", suppressed)
+	golden := filepath.Join("testdata", "hexdump.golden")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(golden, screenBuf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), screenBuf.String())
+}
+
+// BenchmarkTracef times trace output with the default logfile flags (pid,
+// level, date/time and file/line#:func) going to both targets
+func BenchmarkTracef(b *testing.B) {
+	SetWriter(LevelAll, ioutil.Discard, ForBoth)
+	SetThreshold(LevelTrace, ForBoth)
+	SetFlags(LevelAll, LlogfileFlags, ForBoth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Tracef("iteration %d of the loop\n", i)
+	}
+	b.StopTimer()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+// BenchmarkTracefParallel is BenchmarkTracef with trace output coming from
+// several goroutines at once
+func BenchmarkTracefParallel(b *testing.B) {
+	SetWriter(LevelAll, ioutil.Discard, ForBoth)
+	SetThreshold(LevelTrace, ForBoth)
+	SetFlags(LevelAll, LlogfileFlags, ForBoth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			Tracef("iteration %d of the loop\n", i)
+		}
+	})
+	b.StopTimer()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+}
+
+// BenchmarkTracefDisabled times trace output that is below both thresholds
+// (the default), it should cost next to nothing
+func BenchmarkTracefDisabled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Tracef("iteration %d of the loop\n", i)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+var (
+	// maxScreenMsgBytes and maxLogfileMsgBytes are the max size of a message
+	// (before prefixes and flag metadata are added) for each target, 0 means
+	// there is no limit, see SetMaxMessageBytes()
+	maxScreenMsgBytes  int32
+	maxLogfileMsgBytes int32
+)
+
+// MaxMessageBytes returns the max message size for the screen or logfile
+// target (outputTgt is out.ForScreen or out.ForLogfile), 0 means no limit
+func MaxMessageBytes(outputTgt int) int {
+	if outputTgt&ForScreen != 0 {
+		return int(atomic.LoadInt32(&maxScreenMsgBytes))
+	}
+	return int(atomic.LoadInt32(&maxLogfileMsgBytes))
+}
+
+// SetMaxMessageBytes limits the size of a message body (ie: what the client
+// gave us, before any prefix or flag metadata is added) to n bytes for the
+// screen and/or logfile targets (outputTgt of ForScreen, ForLogfile or
+// ForBoth).  Longer messages are cut and a marker noting how much was cut is
+// added, eg: with out.SetMaxMessageBytes(10, out.ForScreen):
+//   out.Noteln("0123456789abcdefghij")
+// would show on the screen as:
+//   Note: 0123456789…(truncated, 10 bytes omitted)
+// This guards against accidentally dumping a giant buffer into the logfile
+// or onto the terminal, the screen can be limited aggressively while the
+// logfile keeps more.  The cut is never made in the middle of a multi-byte
+// UTF-8 character (so slightly less than n bytes may be kept) and a trailing
+// newline is kept (and not counted).  Stack traces aren't limited and nor
+// is the output of a formatter (see SetFormatter()) for the targets it
+// formats, cutting a JSON or logfmt record would make it invalid.  Use 0
+// (the default) to remove the limit.
+func SetMaxMessageBytes(n int, outputTgt int) {
+	if n < 0 {
+		n = 0
+	}
+	if outputTgt&ForScreen != 0 {
+		atomic.StoreInt32(&maxScreenMsgBytes, int32(n))
+	}
+	if outputTgt&ForLogfile != 0 {
+		atomic.StoreInt32(&maxLogfileMsgBytes, int32(n))
+	}
+}
+
+// truncateMessage cuts the message body down to max bytes (if max is non-zero)
+// on a UTF-8 boundary and adds the truncation marker, any trailing newline is
+// kept
+func truncateMessage(msg string, max int) string {
+	if max <= 0 || len(msg) <= max {
+		return msg
+	}
+	body := strings.TrimSuffix(msg, "\n")
+	if len(body) <= max {
+		return msg
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	truncated := fmt.Sprintf("%s…(truncated, %d bytes omitted)", body[:cut], len(body)-cut)
+	if len(body) != len(msg) {
+		truncated += "\n"
+	}
+	return truncated
+}
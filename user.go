@@ -0,0 +1,55 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// userName is the name shown for the Luser flag, it is looked up once
+	// on first use (see currentUserName())
+	userName     string
+	userNameOnce sync.Once
+)
+
+// currentUserName returns the name of the user running the tool for the
+// Luser flag, if the user can't be looked up the numeric uid is used and,
+// failing that (eg: on windows), "???"
+func currentUserName() string {
+	userNameOnce.Do(func() {
+		userName = lookupUserName()
+	})
+	return userName
+}
+
+// lookupUserName does the actual user lookup for currentUserName(), any
+// windows domain prefix (DOMAIN\user) is dropped to keep it short
+func lookupUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name := u.Username
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		return strconv.Itoa(uid)
+	}
+	return "???"
+}
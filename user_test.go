@@ -0,0 +1,52 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/user.go
+//   Checks the Luser flag puts the (padded) user name in the output metadata
+//   and that it can be turned on via the "user" flag string.
+
+package out
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestUserFlag(t *testing.T) {
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, Luser|Llevel, ForScreen)
+	SetUserNameLength(int32(len(currentUserName()) + 3))
+	Println("hello")
+	paddedOutput := screenBuf.String()
+	screenBuf.Reset()
+	SetUserNameLength(0)
+	Println("again")
+	unpaddedOutput := screenBuf.String()
+	userFlags := determineFlags("user,level")
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	name := currentUserName()
+	assert.NotEqual(t, "", name)
+	assert.False(t, strings.Contains(name, `\`))
+	assert.Equal(t, "   "+name+" INFO    hello\n", paddedOutput)
+	assert.Equal(t, name+" INFO    again\n", unpaddedOutput)
+	assert.Equal(t, Luser|Llevel, userFlags)
+	assert.Equal(t, int32(8), UserNameLength())
+}
@@ -0,0 +1,51 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+// SetVerbosity sets the screen output threshold from the "-D" (debug) and
+// "-v" (verbose) style CLI options described in the pkg docs, ie: both gives
+// the trace level ("-Dv" is the "output everything" mode), just debug gives
+// the debug level, just verbose the verbose level and neither the info level
+// (the default).  The logfile threshold isn't changed, it's set separately via
+// SetThreshold(<level>, out.ForLogfile), eg: to keep full trace output in a
+// logfile no matter what verbosity the user asked for on the screen.
+func SetVerbosity(debug bool, verbose bool) {
+	switch {
+	case debug && verbose:
+		SetVerbosityLevel(3)
+	case debug:
+		SetVerbosityLevel(2)
+	case verbose:
+		SetVerbosityLevel(1)
+	default:
+		SetVerbosityLevel(0)
+	}
+}
+
+// SetVerbosityLevel sets the screen output threshold from a verbosity count,
+// eg: the number of times "-v" was given (spf13/pflag's CountP() counts them):
+// 0 (or less) is the info level, 1 is verbose, 2 is debug and 3 (or more) is
+// trace.  As with SetVerbosity() the logfile threshold isn't changed.
+func SetVerbosityLevel(n int) {
+	level := LevelInfo
+	switch {
+	case n >= 3:
+		level = LevelTrace
+	case n == 2:
+		level = LevelDebug
+	case n == 1:
+		level = LevelVerbose
+	}
+	SetThreshold(level, ForScreen)
+}
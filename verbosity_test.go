@@ -0,0 +1,60 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/verbosity.go
+//   Checks SetVerbosity() and SetVerbosityLevel() set the screen threshold
+//   for each -D/-v combination and count, leaving the logfile one alone.
+
+package out
+
+import (
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestSetVerbosity(t *testing.T) {
+	SetThreshold(LevelError, ForLogfile)
+	levels := make(map[int]Level)
+	for _, n := range []int{-1, 0, 1, 2, 3, 4} {
+		SetVerbosityLevel(n)
+		levels[n] = Threshold(ForScreen)
+	}
+	SetVerbosity(true, true)
+	debugVerbose := Threshold(ForScreen)
+	SetVerbosity(true, false)
+	debug := Threshold(ForScreen)
+	SetVerbosity(false, true)
+	verbose := Threshold(ForScreen)
+	SetVerbosity(false, false)
+	neither := Threshold(ForScreen)
+	logThreshold := Threshold(ForLogfile)
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, map[int]Level{
+		-1: LevelInfo,
+		0:  LevelInfo,
+		1:  LevelVerbose,
+		2:  LevelDebug,
+		3:  LevelTrace,
+		4:  LevelTrace,
+	}, levels)
+	assert.Equal(t, LevelTrace, debugVerbose)
+	assert.Equal(t, LevelDebug, debug)
+	assert.Equal(t, LevelVerbose, verbose)
+	assert.Equal(t, LevelInfo, neither)
+	assert.Equal(t, LevelError, logThreshold)
+}
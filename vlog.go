@@ -0,0 +1,78 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+// verbosityLevels maps glog/klog style integer verbosity to output levels,
+// see SetVerbosityLevelMap() to change it (protected by the pkg mutex)
+var verbosityLevels = defaultVerbosityLevels()
+
+// defaultVerbosityLevels is the starting verbosity map: 0 is regular info
+// output and each step up is a more verbose level (3 and up is trace)
+func defaultVerbosityLevels() map[int]Level {
+	return map[int]Level{
+		0: LevelInfo,
+		1: LevelVerbose,
+		2: LevelDebug,
+		3: LevelTrace,
+	}
+}
+
+// SetVerbosityLevelMap replaces the integer verbosity to Level mapping used
+// by VLogf(), the default is 0=LevelInfo, 1=LevelVerbose, 2=LevelDebug and
+// 3=LevelTrace.  A verbosity not in the map uses the closest lower entry (so
+// with the default map 7 is also trace), pass nil to restore the default.
+func SetVerbosityLevelMap(levels map[int]Level) {
+	newLevels := defaultVerbosityLevels()
+	if levels != nil {
+		newLevels = make(map[int]Level, len(levels))
+		for v, l := range levels {
+			newLevels[v] = levelCheck(l)
+		}
+	}
+	mutex.Lock()
+	verbosityLevels = newLevels
+	mutex.Unlock()
+}
+
+// VerbosityLevel returns the output level that the given integer verbosity
+// maps to, see SetVerbosityLevelMap().  If nothing at or below the given
+// verbosity is in the map then LevelInfo is used.
+func VerbosityLevel(verbosity int) Level {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if level, ok := verbosityLevels[verbosity]; ok {
+		return level
+	}
+	found := false
+	closest := 0
+	for v := range verbosityLevels {
+		if v < verbosity && (!found || v > closest) {
+			closest = v
+			found = true
+		}
+	}
+	if !found {
+		return LevelInfo
+	}
+	return verbosityLevels[closest]
+}
+
+// VLogf is for code ported from glog/klog style logging where verbosity is
+// an integer (like glog's V(n).Infof()), the verbosity is mapped to a level
+// (see SetVerbosityLevelMap()) and the format string and args are output at
+// that level.  As with the other output routines, if the mapped level can't
+// be output anywhere then this returns without formatting anything.
+func VLogf(verbosity int, format string, v ...interface{}) {
+	LevelWriter(VerbosityLevel(verbosity)).outputf(false, 0, ForBoth, format, v...)
+}
@@ -0,0 +1,71 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/vlog.go
+//   Checks the integer verbosity to level mapping and VLogf() dispatch.
+
+package out
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+// expensive counts how often it is formatted so we can tell if VLogf()
+// formatted output for a disabled level
+type expensive struct{ calls *int }
+
+func (e expensive) String() string {
+	*e.calls++
+	return "expensive"
+}
+
+func TestVLogf(t *testing.T) {
+	assert.Equal(t, LevelInfo, VerbosityLevel(0))
+	assert.Equal(t, LevelVerbose, VerbosityLevel(1))
+	assert.Equal(t, LevelTrace, VerbosityLevel(9))
+	assert.Equal(t, LevelInfo, VerbosityLevel(-2))
+
+	screenBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetFlags(LevelAll, 0, ForScreen)
+	SetThreshold(LevelVerbose, ForScreen)
+	calls := 0
+	VLogf(0, "v0 %s\n", "shown")
+	VLogf(1, "v1 %s\n", "shown")
+	VLogf(2, "v2 %s\n", expensive{&calls})
+
+	SetVerbosityLevelMap(map[int]Level{0: LevelNote, 5: LevelVerbose})
+	VLogf(0, "v0 %s\n", "as note")
+	VLogf(4, "v4 %s\n", "as note too")
+	VLogf(6, "v6 %s\n", "verbose")
+	SetVerbosityLevelMap(nil)
+
+	// below the thresholds but the replay buffer still gets it
+	SetReplayBuffer(4)
+	SetThreshold(LevelInfo, ForScreen)
+	VLogf(1, "v1 %s\n", "replayed")
+	replayBuf := new(bytes.Buffer)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelVerbose, ForLogfile)
+	ReplayTo(replayBuf, false)
+	SetReplayBuffer(0)
+
+	ResetOutPkg()
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, "v1 replayed\n", replayBuf.String())
+	assert.Equal(t, "v0 shown\nv1 shown\nNote: v0 as note\nNote: v4 as note too\nv6 verbose\n", screenBuf.String())
+}
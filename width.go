@@ -0,0 +1,132 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// defaultTabWidth is the starting tab stop width, see SetTabWidth()
+const defaultTabWidth int32 = 8
+
+// tabWidth is the tab stop width used when figuring out how wide a string
+// displays (eg: for blank prefix alignment), see SetTabWidth()
+var tabWidth = defaultTabWidth
+
+// TabWidth returns the tab stop width used for alignment calculations
+func TabWidth() int32 {
+	return atomic.LoadInt32(&tabWidth)
+}
+
+// SetTabWidth sets the tab stop width (default 8) used when calculating
+// how wide prefixes and such display, eg: so blank (space only) prefixes
+// line up under a prefix or flag metadata block containing tabs.  Values
+// less than 1 are treated as 1.
+func SetTabWidth(width int32) {
+	if width < 1 {
+		width = 1
+	}
+	atomic.StoreInt32(&tabWidth, width)
+}
+
+// displayWidth returns the number of columns the given (single line) string
+// takes up when displayed starting at column 0, tabs advance to the next tab
+// stop, ANSI escape sequences (eg: colors) take up none and other runes (not
+// bytes) take up the columns given by runeWidth()
+func displayWidth(s string) int {
+	tw := int(atomic.LoadInt32(&tabWidth))
+	col := 0
+	inEscape := false
+	for i, r := range s {
+		if inEscape {
+			// a CSI sequence ends with a byte in the range '@' to '~'
+			inEscape = r < '@' || r > '~' || s[i-1] == '\x1b'
+			continue
+		}
+		if r == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			inEscape = true
+		} else if r == '\t' {
+			col += tw - col%tw
+		} else {
+			col += runeWidth(r)
+		}
+	}
+	return col
+}
+
+// wideRanges are the (inclusive) rune ranges that display two columns wide
+// on a terminal, ie: the East Asian wide and full width chars and emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi, CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // full width forms
+	{0xFFE0, 0xFFE6},   // full width signs
+	{0x1F300, 0x1F64F}, // misc symbols and pictographs, emoticons
+	{0x1F900, 0x1F9FF}, // supplemental symbols and pictographs
+	{0x20000, 0x2FFFD}, // CJK unified ideographs extensions B and up
+	{0x30000, 0x3FFFD}, // CJK unified ideographs extension G and up
+}
+
+// runeWidth returns the number of columns a (non-tab) rune takes up when
+// displayed, a simple wcwidth(): 0 for combining marks and format chars (eg:
+// a zero width joiner), 2 for wide chars (eg: CJK and emoji) and 1 otherwise
+func runeWidth(r rune) int {
+	if r == 0 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	if r < wideRanges[0][0] {
+		return 1
+	}
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// RenderedWidth returns how many columns wide the first line of the given
+// message would be when output at the given level to the screen or logfile
+// target (outputTgt is out.ForScreen or out.ForLogfile), ie: with the level's
+// prefix and flag metadata (time, file/line#, etc) added, without writing it
+// anywhere, eg: to reserve space in a TUI or to truncate a message to fit:
+//   avail := termWidth - out.RenderedWidth(out.LevelNote, out.ForScreen, "")
+// Wide chars (eg: CJK or emoji) count as two columns, combining marks as none
+// and tabs go to the next tab stop (see SetTabWidth()).  The message is
+// treated as starting on a fresh line and any formatter isn't run.  Note that
+// the time (and file/line# info, which is for this call) is that of now so
+// flags that don't pad those fields (eg: Llongfile or a custom separator) can
+// give a width that differs a bit from the real output.
+func RenderedWidth(level Level, outputTgt int, msg string) int {
+	if outputTgt&ForScreen != 0 {
+		outputTgt = ForScreen
+	} else {
+		outputTgt = ForLogfile
+	}
+	o := LevelWriter(level)
+	rendered, _, _ := o.doPrefixing(msg, outputTgt, AlwaysInsert, nil, nil, false)
+	if nl := strings.Index(rendered, "\n"); nl != -1 {
+		rendered = rendered[:nl]
+	}
+	return displayWidth(rendered)
+}
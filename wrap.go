@@ -0,0 +1,158 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package out
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// defaultWrapWidth is the width wrapped to when auto-detecting and the
+// screen writer isn't a terminal (or its width is unknown)
+const defaultWrapWidth = 80
+
+// minWrapWidth is the fewest columns left for the message after the prefix
+// and flag metadata for wrapping to be done, any less and it isn't worth it
+const minWrapWidth = 10
+
+// wrapWidth is the SetWrapWidth() setting: -1 is off, 0 auto, else columns
+var wrapWidth int32 = -1
+
+// WrapWidth returns the width screen output is word-wrapped to, -1 means no
+// wrapping (the default) and 0 auto-detection, see SetWrapWidth()
+func WrapWidth() int {
+	return int(atomic.LoadInt32(&wrapWidth))
+}
+
+// SetWrapWidth word-wraps screen output to the given number of columns so
+// help text, notes and such don't break mid-word in a narrow terminal, eg:
+// out.SetWrapWidth(0) wraps to the terminal width.  Use 0 to auto-detect the
+// width from the screen writer (if it's a terminal, else the COLUMNS env var
+// is tried and then 80 is used) and -1 (the default) to turn wrapping off.
+// The width includes the prefix and flag metadata, each wrapped line gets
+// them as well so the text of continuation lines lines up under the first.
+// Words longer than the room left are broken where they hit the width, the
+// spaces and tabs between words are kept except where a line is broken.  If
+// fewer than 10 columns are left for the text after the prefix and flag
+// metadata then the output isn't wrapped at all (eg: SetWrapWidth(3) has no
+// effect).  Logfile output is never wrapped and neither is output from a formatter
+// that owns newlines (see FormatterOwnsNewlines).
+func SetWrapWidth(cols int) {
+	if cols < -1 {
+		cols = -1
+	}
+	atomic.StoreInt32(&wrapWidth, int32(cols))
+}
+
+// screenWrapWidth returns the columns to wrap this level's screen output to,
+// 0 if wrapping is off
+func (o *LvlOutput) screenWrapWidth() int {
+	cols := int(atomic.LoadInt32(&wrapWidth))
+	if cols != 0 {
+		if cols < 0 {
+			return 0
+		}
+		return cols
+	}
+	o.mu.RLock()
+	screenHndl := o.screenHndl
+	o.mu.RUnlock()
+	if width := terminalWidth(screenHndl); width > 0 {
+		return width
+	}
+	return defaultWrapWidth
+}
+
+// wrapText word-wraps each line of the message to the given width, breaking
+// at spaces where possible and within a word only when the word alone is
+// wider than the width, any trailing newline is kept
+func wrapText(msg string, width int) string {
+	if width < minWrapWidth {
+		return msg
+	}
+	body := strings.TrimSuffix(msg, "\n")
+	lines := strings.Split(body, "\n")
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapLine(line, width)...)
+	}
+	out := strings.Join(wrapped, "\n")
+	if len(body) != len(msg) {
+		out += "\n"
+	}
+	return out
+}
+
+// wrapLine breaks a single line up into lines no wider than width, any
+// leading indentation is kept on the first line only and the spaces and tabs
+// between words are kept as is (eg: for aligned columns) except where a line
+// is broken, there they are dropped
+func wrapLine(line string, width int) []string {
+	if displayWidth(line) <= width {
+		return []string{line}
+	}
+	var lines []string
+	rest := strings.TrimLeft(line, " \t")
+	curr := line[:len(line)-len(rest)]
+	empty := true // no words on the current line yet
+	for rest != "" {
+		// the next word and the whitespace before it
+		gap := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+		rest = rest[len(gap):]
+		if rest == "" {
+			break
+		}
+		wordEnd := strings.IndexAny(rest, " \t")
+		if wordEnd < 0 {
+			wordEnd = len(rest)
+		}
+		word := rest[:wordEnd]
+		rest = rest[wordEnd:]
+		if !empty && displayWidth(curr+gap+word) > width {
+			lines = append(lines, curr)
+			curr, empty = "", true
+		}
+		if !empty {
+			curr += gap
+		}
+		for word != "" && displayWidth(curr+word) > width {
+			// a word too long for a line of its own, break it at the width
+			head, tail := splitAtWidth(word, width-displayWidth(curr))
+			lines = append(lines, curr+head)
+			curr, word = "", tail
+		}
+		if word != "" {
+			curr += word
+			empty = false
+		}
+	}
+	if !empty || len(lines) == 0 {
+		lines = append(lines, curr)
+	}
+	return lines
+}
+
+// splitAtWidth splits the word after as many runes as fit in the width (at
+// least one, so progress is always made)
+func splitAtWidth(word string, width int) (string, string) {
+	col := 0
+	for i, r := range word {
+		w := runeWidth(r)
+		if col+w > width && i > 0 {
+			return word[:i], word[i:]
+		}
+		col += w
+	}
+	return word, ""
+}
@@ -0,0 +1,83 @@
+// Copyright © 2015-2016 Erik Brady <brady@dvln.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test for: out/wrap.go
+//   Checks screen output is word-wrapped to the SetWrapWidth() width (with
+//   the prefix accounted for) and that logfile output never is.
+
+package out
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dvln/testify/assert"
+)
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, "short\n", wrapText("short\n", 20))
+	assert.Equal(t, "the quick brown\nfox jumps over the\nlazy dog\n", wrapText("the quick brown fox jumps over the lazy dog\n", 18))
+	assert.Equal(t, "  indented text\nwraps here", wrapText("  indented text wraps here", 16))
+	assert.Equal(t, "see\nhttp://example.c\nom/a/very/long/p\nath ok", wrapText("see http://example.com/a/very/long/path ok", 16))
+	assert.Equal(t, "first line\n\nsecond", wrapText("first line\n\nsecond", 12))
+	assert.Equal(t, "too narrow to wrap", wrapText("too narrow to wrap", minWrapWidth-1))
+	assert.Equal(t, "a  b\tc  dd\nee", wrapText("a  b\tc  dd ee", 14))
+	assert.Equal(t, "\tname\nvalue   next", wrapText("\tname   value   next", 16))
+}
+
+func TestSetWrapWidth(t *testing.T) {
+	origColumns := os.Getenv("COLUMNS")
+	os.Unsetenv("COLUMNS")
+	defer os.Setenv("COLUMNS", origColumns)
+
+	screenBuf := new(bytes.Buffer)
+	logBuf := new(bytes.Buffer)
+	SetWriter(LevelAll, screenBuf, ForScreen)
+	SetWriter(LevelAll, logBuf, ForLogfile)
+	SetFlags(LevelAll, 0, ForLogfile)
+	SetThreshold(LevelInfo, ForBoth)
+	defaultWidth := WrapWidth()
+	msg := "this note is long enough that it has to be wrapped onto more lines"
+
+	Noteln(msg)
+	unwrapped := screenBuf.String()
+	screenBuf.Reset()
+
+	SetWrapWidth(30)
+	Noteln(msg)
+	wrapped := screenBuf.String()
+	screenBuf.Reset()
+
+	// auto-detection falls back to 80 columns for a buffer
+	SetWrapWidth(0)
+	autoWidth := WrapWidth()
+	Noteln(strings.Repeat("word ", 20))
+	auto := screenBuf.String()
+	logfile := logBuf.String()
+
+	// Now reset the most common things for the 'out' pkg so the next test
+	// func will operate sanely as if we're coming in fresh
+	ResetOutPkg()
+
+	assert.Equal(t, -1, defaultWidth)
+	assert.Equal(t, 0, autoWidth)
+	assert.Equal(t, "Note: "+msg+"\n", unwrapped)
+	assert.Equal(t, "Note: this note is long enough\n"+
+		"Note: that it has to be\n"+
+		"Note: wrapped onto more lines\n", wrapped)
+	assert.Equal(t, "Note: "+strings.Repeat("word ", 14)+"word\n"+
+		"Note: "+strings.Repeat("word ", 4)+"word\n", auto)
+	assert.Equal(t, unwrapped+unwrapped+"Note: "+strings.Repeat("word ", 20)+"\n", logfile)
+}